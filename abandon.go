@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// AbandonmentSummary counts the tasks of one class that impatient clients abandoned
+// before they started. Abandoned tasks count as failed requests, as do tasks whose work
+// failed for good.
+type AbandonmentSummary struct {
+	Class     string
+	Tasks     int
+	Abandoned int
+	Failed    int // Abandoned tasks and tasks that failed for good
+}
+
+// Rate returns the share of the class's tasks that were abandoned
+func (s AbandonmentSummary) Rate() float64 {
+	if s.Tasks == 0 {
+		return 0
+	}
+	return float64(s.Abandoned) / float64(s.Tasks)
+}
+
+// summarizeAbandonment counts abandoned and failed tasks for all tasks, then for short
+// and long ones
+func summarizeAbandonment(tasks []Task) []AbandonmentSummary {
+	var summaries []AbandonmentSummary
+	for _, class := range []string{"all", "short", "long"} {
+		summary := AbandonmentSummary{Class: class}
+		for _, task := range tasks {
+			if class != "all" && taskClass(task) != class {
+				continue
+			}
+			summary.Tasks++
+			if task.Abandoned {
+				summary.Abandoned++
+			}
+			if task.Abandoned || task.Failed {
+				summary.Failed++
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// taskPatience returns the patience of the clients of a run, 0 if they wait as long as
+// it takes
+func taskPatience(tasks []Task) time.Duration {
+	for _, task := range tasks {
+		if task.Patience > 0 {
+			return task.Patience
+		}
+	}
+	return 0
+}
+
+// printAbandonmentReport prints how many tasks of each class impatient clients abandoned
+// and how many requests failed in all. It prints nothing when clients are patient.
+func printAbandonmentReport(tasks []Task) {
+	patience := taskPatience(tasks)
+	if patience == 0 {
+		return
+	}
+	fmt.Printf("\nAbandonment (clients give up on tasks not started within %v):\n", patience)
+	for _, s := range summarizeAbandonment(tasks) {
+		if s.Tasks == 0 {
+			continue
+		}
+		fmt.Printf("  %s tasks: %d/%d abandoned (%.1f%%); with failed work, %d failed requests (%.1f%%)\n",
+			s.Class, s.Abandoned, s.Tasks, 100*s.Rate(), s.Failed, 100*float64(s.Failed)/float64(s.Tasks))
+	}
+}
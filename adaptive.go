@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// defaultAdaptive is the adaptive policy's tuning for the fields left unset: FCFS at low
+// load, SJF once more than 2 tasks per worker slot queue up, back to FCFS at 0.5
+var defaultAdaptive = AdaptiveConfig{
+	Low:         "fcfs",
+	High:        "sjf",
+	HighBacklog: 2,
+	LowBacklog:  0.5,
+	IntervalMs:  200,
+}
+
+// The adaptive policy builds its policies from the registry, so it registers itself once
+// the registry exists
+func init() {
+	algorithms["adaptive"] = Algorithm{
+		Policy: adaptivePolicy,
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.adaptive.low", Description: "Algorithm prioritizing the tasks that arrive while the queue is shallow"},
+			{Key: "algorithms.adaptive.high", Description: "Algorithm prioritizing the tasks that arrive while the backlog is deep"},
+			{Key: "algorithms.adaptive.high_backlog", Description: "Queued tasks per worker slot above which the high-load algorithm takes over"},
+			{Key: "algorithms.adaptive.low_backlog", Description: "Queued tasks per worker slot at which the low-load algorithm takes back over"},
+			{Key: "algorithms.adaptive.interval_ms", Description: "How often real runs check the queue backlog"},
+		},
+	}
+}
+
+// withDefaults returns the tuning with the unset fields taken from defaultAdaptive
+func (c AdaptiveConfig) withDefaults() AdaptiveConfig {
+	if c.Low == "" {
+		c.Low = defaultAdaptive.Low
+	}
+	if c.High == "" {
+		c.High = defaultAdaptive.High
+	}
+	if c.HighBacklog == 0 {
+		c.HighBacklog = defaultAdaptive.HighBacklog
+	}
+	if c.LowBacklog == 0 {
+		c.LowBacklog = defaultAdaptive.LowBacklog
+	}
+	if c.IntervalMs == 0 {
+		c.IntervalMs = defaultAdaptive.IntervalMs
+	}
+	return c
+}
+
+// EvaluationInterval returns how often real runs check the queue backlog
+func (c AdaptiveConfig) EvaluationInterval() time.Duration {
+	return time.Duration(c.withDefaults().IntervalMs) * time.Millisecond
+}
+
+// adaptivePolicy returns the policy switching between the configured low-load and
+// high-load policies with the queue backlog. Validation makes sure both exist and can be
+// switched between.
+func adaptivePolicy(cfg AlgorithmsConfig) SchedulingPolicy {
+	adaptive := cfg.Adaptive.withDefaults()
+	a := &sched.Adaptive{
+		Low:         algorithms[adaptive.Low].Policy(cfg),
+		High:        algorithms[adaptive.High].Policy(cfg),
+		Slots:       AppConfig.Queue.Capacity(),
+		HighBacklog: adaptive.HighBacklog,
+		LowBacklog:  adaptive.LowBacklog,
+	}
+	return sched.AdaptivePolicy(a)
+}
+
+// checkAdaptivePolicy reports why a policy can't be switched to by the adaptive policy,
+// or "" if it can
+func checkAdaptivePolicy(name string, cfg AlgorithmsConfig) string {
+	if name == "adaptive" {
+		return "can't be the adaptive policy itself"
+	}
+	algorithm, ok := algorithms[name]
+	if !ok {
+		return fmt.Sprintf("must be one of %s", algorithmNames())
+	}
+	policy := algorithm.Policy(cfg)
+	switch {
+	case policy.Lanes != nil:
+		return "can't be a policy with lanes"
+	case policy.Estimator != nil:
+		return "can't be a policy that predicts sizes"
+	case policy.FairShare != nil:
+		return "can't be a fair-share policy"
+	case policy.Dispatcher != nil:
+		return "can't be a policy that picks tasks itself"
+	}
+	return ""
+}
+
+// adaptiveMonitor feeds the queue backlog of a real run to the adaptive policy at a fixed
+// interval, and logs its switches
+type adaptiveMonitor struct {
+	adaptive *sched.Adaptive
+	queue    taskQueue
+	interval time.Duration
+	err      error
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startAdaptiveMonitor starts watching the backlog of the queue for an adaptive policy,
+// or returns nil for other policies
+func startAdaptiveMonitor(policy SchedulingPolicy, queue taskQueue, capacity int) *adaptiveMonitor {
+	if policy.Adaptive == nil {
+		return nil
+	}
+	m := &adaptiveMonitor{
+		adaptive: policy.Adaptive,
+		queue:    queue,
+		interval: AppConfig.Algorithms.Adaptive.EvaluationInterval(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	m.adaptive.Slots = capacity
+	start := time.Now()
+	m.adaptive.OnSwitch = func(event sched.SwitchEvent) {
+		fmt.Printf("  Switched to %s at %.1fs (%d tasks queued)\n", event.To, event.At.Sub(start).Seconds(), event.Backlog)
+	}
+	go m.run()
+	return m
+}
+
+func (m *adaptiveMonitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			depth, err := m.queue.Depth()
+			if err != nil {
+				// Keep the current mode; the report shows the first error
+				if m.err == nil {
+					m.err = err
+				}
+				continue
+			}
+			m.adaptive.Observe(now, depth)
+		}
+	}
+}
+
+// register makes the monitor follow the run until every task was collected, and
+// reports on the switches of the policy
+func (m *adaptiveMonitor) register(r *experimentRun) {
+	r.OnCollected(func([]Task) error {
+		m.Stop()
+		return nil
+	})
+	r.OnReport(func(outcome *runOutcome) error {
+		printAdaptiveReport(outcome.completed, r.policy, m)
+		return nil
+	})
+	r.OnClose(m.Stop)
+}
+
+// Stop stops watching the backlog
+func (m *adaptiveMonitor) Stop() {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+		<-m.done
+	}
+}
+
+// adaptiveReplay is the outcome of an idealized replay of a run's tasks under one policy
+type adaptiveReplay struct {
+	Policy   string
+	Response ResponseSummary
+	Short    ResponseSummary
+}
+
+// replayAdaptive replays the tasks through an idealized queue with the low-load policy
+// alone, the high-load policy alone, and the priorities the adaptive policy gave them, so
+// the benefit of switching shows on the same arrivals and durations
+func replayAdaptive(tasks []Task, policy SchedulingPolicy, capacity int) []adaptiveReplay {
+	a := policy.Adaptive
+	service := func(task Task) time.Duration { return task.Duration }
+	var replays []adaptiveReplay
+	for _, p := range []struct {
+		name     string
+		priority func(Task) uint
+	}{
+		{a.Low.Name, a.Low.Priority},
+		{a.High.Name, a.High.Priority},
+		{policy.Name, policy.Priority},
+	} {
+		waits := sched.Simulate(tasks, capacity, p.priority, service)
+		replayed := make([]Task, len(tasks))
+		for i, task := range tasks {
+			task.DequeueTime = task.ArrivalTime.Add(waits[i])
+			task.CompletionTime = task.DequeueTime.Add(task.Duration)
+			replayed[i] = task
+		}
+		replays = append(replays, adaptiveReplay{
+			Policy:   p.name,
+			Response: summarizeResponseTimes(replayed, nil),
+			Short:    summarizeResponseTimes(replayed, func(task Task) bool { return taskClass(task) == "short" }),
+		})
+	}
+	return replays
+}
+
+// printAdaptiveReport prints the switches of an adaptive policy, the latency of the tasks
+// each policy prioritized, and the benefit of switching over either policy alone. It
+// prints nothing for other policies.
+func printAdaptiveReport(tasks []Task, policy SchedulingPolicy, monitor *adaptiveMonitor) {
+	a := policy.Adaptive
+	if a == nil || len(tasks) == 0 {
+		return
+	}
+	fmt.Printf("\nAdaptive policy (%s at low load, %s at high load):\n", a.Low.Name, a.High.Name)
+	if monitor != nil && monitor.err != nil {
+		fmt.Printf("  Warning: failed to read the queue backlog: %v\n", monitor.err)
+	}
+	events := a.Events()
+	high, total := a.TimeInHigh()
+	fmt.Printf("  Switches: %d", len(events))
+	if total > 0 {
+		fmt.Printf(", %s in charge %.0f%% of the time", a.High.Name, 100*high.Seconds()/total.Seconds())
+	}
+	fmt.Println()
+	first := tasks[0].ArrivalTime
+	for _, task := range tasks {
+		if task.ArrivalTime.Before(first) {
+			first = task.ArrivalTime
+		}
+	}
+	for i, event := range events {
+		if i == 20 {
+			fmt.Printf("  ... %d more\n", len(events)-i)
+			break
+		}
+		fmt.Printf("  %10.1fs  to %-10s (%d tasks queued)\n", event.At.Sub(first).Seconds(), event.To, event.Backlog)
+	}
+
+	for _, mode := range []struct {
+		name string
+		high bool
+	}{{a.Low.Name, false}, {a.High.Name, true}} {
+		s := summarizeResponseTimes(tasks, func(task Task) bool { return a.PrioritizedInHigh(task) == mode.high })
+		if s.Count > 0 {
+			fmt.Printf("  Tasks prioritized by %s: %d, response mean %s ms, p99 %s ms\n", mode.name, s.Count, formatMs(s.Mean), formatMs(s.P99))
+		}
+	}
+
+	fmt.Printf("  Idealized replay of the same tasks:\n")
+	fmt.Printf("  %-10s %12s %12s %12s\n", "Policy", "Resp mean", "Resp p99", "Short p99")
+	for _, r := range replayAdaptive(tasks, policy, a.Slots) {
+		fmt.Printf("  %-10s %12s %12s %12s\n", r.Policy, formatMs(r.Response.Mean), formatMs(r.Response.P99), formatMs(r.Short.P99))
+	}
+	fmt.Println("  (times in ms)")
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// defaultAgent is the agent policy's setup for the fields left unset: no agent, so tasks
+// run in arrival order, decisions that take over a second fall back to the oldest task,
+// and each executor dequeues 16 tasks beyond its worker slots for the agent to pick from
+var defaultAgent = AgentConfig{
+	TimeoutMs: 1000,
+	Lookahead: 16,
+}
+
+// withDefaults returns the setup with the unset fields taken from defaultAgent
+func (c AgentConfig) withDefaults() AgentConfig {
+	if c.TimeoutMs == 0 {
+		c.TimeoutMs = defaultAgent.TimeoutMs
+	}
+	if c.Lookahead == 0 {
+		c.Lookahead = defaultAgent.Lookahead
+	}
+	return c
+}
+
+// Timeout returns how long a decision may take before the oldest task runs instead
+func (c AgentConfig) Timeout() time.Duration {
+	return time.Duration(c.withDefaults().TimeoutMs) * time.Millisecond
+}
+
+// QueueConfig returns the queue configuration executors dequeue with under the agent
+// policy: the lookahead on top of the worker slots of each executor, and of the global
+// limit if there is one. The gate keeps the tasks running at once to the capacity.
+func (c AgentConfig) QueueConfig(queueCfg QueueConfig) QueueConfig {
+	return queueCfg.withLookahead(c.withDefaults().Lookahead)
+}
+
+// agentPolicy returns the policy handing every dispatch decision to the configured
+// agent. Each run starts its own agent process, on its first decision.
+func agentPolicy(cfg AlgorithmsConfig) SchedulingPolicy {
+	agent := cfg.Agent.withDefaults()
+	return sched.Delegated(&stdioAgent{command: agent.Command, timeout: agent.Timeout()})
+}
+
+// The agent protocol is line-delimited JSON. Whenever a worker slot frees up and tasks
+// wait, the agent gets a decide message and answers with the task to run, echoing the
+// message's seq; answers to earlier messages are ignored. Whenever a task completes, the
+// agent gets a completed message, which needs no answer. Times are in milliseconds, from
+// the first message. The agent should exit when its stdin closes.
+type agentDecide struct {
+	Type    string         `json:"type"` // "decide"
+	Seq     int            `json:"seq"`
+	TimeMs  float64        `json:"time_ms"`
+	Waiting []agentWaiting `json:"waiting"` // Oldest first
+}
+
+type agentWaiting struct {
+	TaskID       int     `json:"task_id"`
+	WaitMs       float64 `json:"wait_ms"`
+	Class        string  `json:"class"`
+	TenantID     string  `json:"tenant,omitempty"`
+	PayloadBytes int     `json:"payload_bytes"`
+}
+
+type agentCompleted struct {
+	Type       string  `json:"type"` // "completed"
+	TimeMs     float64 `json:"time_ms"`
+	TaskID     int     `json:"task_id"`
+	Class      string  `json:"class"`
+	TenantID   string  `json:"tenant,omitempty"`
+	WaitMs     float64 `json:"wait_ms"`
+	DurationMs float64 `json:"duration_ms"`
+	ResponseMs float64 `json:"response_ms"`
+	Failed     bool    `json:"failed"`
+}
+
+type agentAnswer struct {
+	Seq    int `json:"seq"`
+	TaskID int `json:"task_id"`
+}
+
+// stdioAgent is a dispatcher running as an external process, such as a reinforcement
+// learning agent, spoken to over its stdin and stdout. Without a command, or once the
+// agent fails, the oldest task runs. Decisions that time out or name a task that isn't
+// waiting also run the oldest task, and count as fallbacks.
+type stdioAgent struct {
+	command string
+	timeout time.Duration
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	encoder   *json.Encoder
+	answers   chan agentAnswer
+	origin    time.Time
+	seq       int
+	err       error // First failure of the agent, after which it isn't asked anymore
+	exitErr   error // How the agent exited, if not cleanly
+	closed    bool
+	decisions int
+	fallbacks int
+}
+
+func (a *stdioAgent) String() string {
+	if a.command == "" {
+		return "no agent (algorithms.agent.command unset), arrival order"
+	}
+	return fmt.Sprintf("agent %q", a.command)
+}
+
+// start starts the agent process unless it runs already or can't. Callers hold the lock.
+func (a *stdioAgent) start() bool {
+	if a.cmd != nil {
+		return a.err == nil
+	}
+	if a.command == "" || a.err != nil || a.closed {
+		return false
+	}
+	cmd := exec.Command("sh", "-c", a.command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		a.err = err
+		return false
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		a.err = err
+		return false
+	}
+	if err := cmd.Start(); err != nil {
+		a.err = fmt.Errorf("failed to start the agent: %w", err)
+		return false
+	}
+	a.cmd, a.stdin, a.encoder = cmd, stdin, json.NewEncoder(stdin)
+	a.answers = make(chan agentAnswer, 16)
+	go a.read(stdout)
+	return true
+}
+
+// read forwards the agent's answers until its stdout closes
+func (a *stdioAgent) read(stdout io.Reader) {
+	defer close(a.answers)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var answer agentAnswer
+		if err := json.Unmarshal(scanner.Bytes(), &answer); err != nil {
+			// A malformed answer counts as no answer
+			continue
+		}
+		// Answers nobody waits for anymore are dropped
+		select {
+		case a.answers <- answer:
+		default:
+		}
+	}
+}
+
+// elapsedMs returns the time since the first message, in milliseconds. Callers hold the
+// lock.
+func (a *stdioAgent) elapsedMs(at time.Time) float64 {
+	if a.origin.IsZero() {
+		a.origin = at
+	}
+	return float64(at.Sub(a.origin)) / float64(time.Millisecond)
+}
+
+// send writes a message to the agent, recording the failure if it can't. Callers hold the
+// lock.
+func (a *stdioAgent) send(message any) bool {
+	if err := a.encoder.Encode(message); err != nil {
+		a.err = fmt.Errorf("failed to write to the agent: %w", err)
+		return false
+	}
+	return true
+}
+
+func (a *stdioAgent) Pick(now time.Time, waiting []Task) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.decisions++
+	if !a.start() {
+		return 0
+	}
+	a.seq++
+	message := agentDecide{Type: "decide", Seq: a.seq, TimeMs: a.elapsedMs(now)}
+	index := make(map[int]int, len(waiting))
+	for i, task := range waiting {
+		index[task.TaskID] = i
+		message.Waiting = append(message.Waiting, agentWaiting{
+			TaskID:       task.TaskID,
+			WaitMs:       float64(now.Sub(task.ArrivalTime)) / float64(time.Millisecond),
+			Class:        taskClass(task),
+			TenantID:     task.TenantID,
+			PayloadBytes: len(task.Payload),
+		})
+	}
+	if !a.send(message) {
+		return 0
+	}
+
+	timeout := time.NewTimer(a.timeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case answer, ok := <-a.answers:
+			if !ok {
+				a.err = fmt.Errorf("the agent exited")
+				return 0
+			}
+			if answer.Seq != a.seq {
+				continue
+			}
+			if i, ok := index[answer.TaskID]; ok {
+				return i
+			}
+			a.fallbacks++
+			return 0
+		case <-timeout.C:
+			a.fallbacks++
+			return 0
+		}
+	}
+}
+
+func (a *stdioAgent) Completed(task Task) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.start() {
+		return
+	}
+	a.send(agentCompleted{
+		Type:       "completed",
+		TimeMs:     a.elapsedMs(task.CompletionTime),
+		TaskID:     task.TaskID,
+		Class:      taskClass(task),
+		TenantID:   task.TenantID,
+		WaitMs:     float64(task.WaitTime()) / float64(time.Millisecond),
+		DurationMs: float64(task.Duration) / float64(time.Millisecond),
+		ResponseMs: float64(task.ResponseTime()) / float64(time.Millisecond),
+		Failed:     task.Failed,
+	})
+}
+
+// Close closes the agent's stdin and waits for it to exit, killing it if it takes longer
+// than a decision may
+func (a *stdioAgent) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+	if a.cmd == nil {
+		return
+	}
+	a.stdin.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- a.cmd.Wait() }()
+	select {
+	case err := <-exited:
+		a.exitErr = err
+	case <-time.After(a.timeout):
+		a.cmd.Process.Kill()
+		a.exitErr = fmt.Errorf("killed, as it didn't exit once its stdin closed")
+		<-exited
+	}
+}
+
+// closeAgent stops the agent process of the policy, if it has one
+func closeAgent(policy SchedulingPolicy) {
+	if agent, ok := policy.Dispatcher.(*stdioAgent); ok {
+		agent.Close()
+	}
+}
+
+// printAgentReport stops the agent process of the policy, and prints how many decisions
+// it made and how many fell back to the oldest task. It prints nothing for other
+// policies.
+func printAgentReport(policy SchedulingPolicy) {
+	agent, ok := policy.Dispatcher.(*stdioAgent)
+	if !ok {
+		return
+	}
+	agent.Close()
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	fmt.Printf("\nAgent (%s):\n", agent)
+	if agent.command == "" {
+		fmt.Println("  No agent command set (algorithms.agent.command): every task ran in arrival order")
+		return
+	}
+	fmt.Printf("  Decisions: %d, fallbacks to the oldest task: %d\n", agent.decisions, agent.fallbacks)
+	if agent.err != nil {
+		fmt.Printf("  Warning: %v; the tasks after it ran in arrival order\n", agent.err)
+	}
+	if agent.exitErr != nil {
+		fmt.Printf("  Warning: the agent exited badly: %v\n", agent.exitErr)
+	}
+}
@@ -0,0 +1,32 @@
+#!/usr/bin/env python3
+"""Example agent for the agent algorithm: runs the short task that waited longest, or the
+oldest task when none is short, and keeps the mean response time of what completed.
+
+Run it with -algo agent -algorithms-agent-command "python3 agents/short_first.py".
+Replace decide() with a learned policy to train or evaluate it against the backend.
+"""
+import json
+import sys
+
+completed, total_response_ms = 0, 0.0
+
+
+def decide(message):
+    for task in message["waiting"]:
+        if task["class"] == "short":
+            return task["task_id"]
+    return message["waiting"][0]["task_id"]
+
+
+for line in sys.stdin:
+    message = json.loads(line)
+    if message["type"] == "decide":
+        answer = {"seq": message["seq"], "task_id": decide(message)}
+        sys.stdout.write(json.dumps(answer) + "\n")
+        sys.stdout.flush()
+    elif message["type"] == "completed":
+        completed += 1
+        total_response_ms += message["response_ms"]
+
+if completed:
+    print(f"short_first: {completed} tasks, mean response {total_response_ms / completed:.1f} ms", file=sys.stderr)
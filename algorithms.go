@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"fifo-queue-demo/sched"
+)
+
+// Algorithm is a scheduling algorithm that can be selected with -algo
+type Algorithm struct {
+	Policy     func(cfg AlgorithmsConfig) SchedulingPolicy // Builds the policy from its config section
+	Parameters []AlgorithmParameter                        // Settings that tune the algorithm
+}
+
+// AlgorithmParameter documents a configuration setting an algorithm depends on
+type AlgorithmParameter struct {
+	Key         string // Key in config.yaml, e.g. "workload.deadline_factor"
+	Description string
+}
+
+// algorithms lists the available scheduling algorithms by name
+var algorithms = map[string]Algorithm{
+	"fcfs": {
+		Policy: func(AlgorithmsConfig) SchedulingPolicy { return sched.FCFS() },
+	},
+	"sjf": {
+		Policy: func(cfg AlgorithmsConfig) SchedulingPolicy { return sjfPolicy(cfg.SJF) },
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.sjf.cutoff_ms", Description: "Tasks up to this duration get priority 1, longer tasks priority 2 (0 = workload.short_task_duration_ms)"},
+		},
+	},
+	"sjf-lanes": {
+		Policy: sjfLanesPolicy,
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.sjf.cutoff_ms", Description: "Tasks up to this duration go to the short lane, longer tasks to the long lane (0 = workload.short_task_duration_ms)"},
+			{Key: "algorithms.sjf_lanes.mode", Description: "strict serves the short lane first; weighted shares freed worker slots by the lane weights"},
+			{Key: "algorithms.sjf_lanes.short_weight", Description: "Weight of the short lane in weighted mode"},
+			{Key: "algorithms.sjf_lanes.long_weight", Description: "Weight of the long lane in weighted mode"},
+		},
+	},
+	"sjf-buckets": {
+		Policy: sjfBucketsPolicy,
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.sjf_buckets.boundaries_ms", Description: "Increasing upper bounds of the size buckets, comma-separated; a task is only known by the first bucket it fits in, and longer tasks fall into a last bucket"},
+		},
+	},
+	"sjf-predicted": {
+		Policy: sjfPredictedPolicy,
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.sjf_predicted.estimator", Description: "moving-average learns the mean duration of each class from completed tasks; history a quantile of the last completed tasks of each class; model uses a fixed linear model from model_file"},
+			{Key: "algorithms.sjf_predicted.alpha", Description: "Weight of each completed task in the moving average, in (0, 1]"},
+			{Key: "algorithms.sjf_predicted.window", Description: "Completed tasks per class the history estimator remembers"},
+			{Key: "algorithms.sjf_predicted.quantile", Description: "Quantile of the remembered durations the history estimator predicts, in (0, 1]"},
+			{Key: "algorithms.sjf_predicted.model_file", Description: "YAML file of the linear model (intercept_ms, class_ms, tenant_ms, payload_byte_ms)"},
+		},
+	},
+	"fair-share": {
+		Policy: fairSharePolicy,
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.fair_share.half_life_ms", Description: "Time for a tenant's recent usage to decay by half; shorter forgets past usage faster"},
+		},
+	},
+	"agent": {
+		Policy: agentPolicy,
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.agent.command", Description: "Shell command starting the agent, which gets decide and completed messages as JSON lines on stdin and answers decisions on stdout; tasks run in arrival order without one"},
+			{Key: "algorithms.agent.timeout_ms", Description: "How long a decision may take before the oldest task runs instead"},
+			{Key: "algorithms.agent.lookahead", Description: "Tasks per executor dequeued beyond its worker slots in real runs, for the agent to pick from"},
+		},
+	},
+	"batch": {
+		Policy: batchPolicy,
+		Parameters: []AlgorithmParameter{
+			{Key: "algorithms.batch.size", Description: "Most tasks of a class run back to back before the oldest waiting task starts the next batch"},
+			{Key: "algorithms.batch.lookahead", Description: "Tasks per executor dequeued beyond its worker slots in real runs, to batch from"},
+			{Key: "cold_start.switch_ms", Description: "Setup workers pay when they switch classes, which batching amortizes"},
+		},
+	},
+	"edf": {
+		Policy: func(AlgorithmsConfig) SchedulingPolicy { return sched.EDF() },
+		Parameters: []AlgorithmParameter{
+			{Key: "workload.deadline_factor", Description: "Deadline of each task, in multiples of its duration after arrival; without it tasks run in arrival order"},
+		},
+	},
+}
+
+// algorithmNames returns the sorted list of algorithm names, for help and error messages
+func algorithmNames() string {
+	return strings.Join(sortedKeys(algorithms), ", ")
+}
+
+// lookupPolicy returns the scheduling policy registered under the given algorithm name
+func lookupPolicy(name string) (SchedulingPolicy, error) {
+	algorithm, ok := algorithms[name]
+	if !ok {
+		return SchedulingPolicy{}, fmt.Errorf("unknown algorithm: %s (available algorithms: %s)", name, algorithmNames())
+	}
+	return algorithm.Policy(AppConfig.Algorithms), nil
+}
+
+// listAlgosCommand lists the registered scheduling algorithms with the settings that tune
+// them and their current values
+func listAlgosCommand(args []string) error {
+	fs := flag.NewFlagSet("list-algos", flag.ExitOnError)
+	registerConfigFlags(fs)
+	fs.Parse(args)
+
+	values := make(map[string]any)
+	for _, field := range configFields(&AppConfig) {
+		values[field.Key] = field.Value.Interface()
+	}
+	for _, name := range sortedKeys(algorithms) {
+		algorithm := algorithms[name]
+		policy := algorithm.Policy(AppConfig.Algorithms)
+		fmt.Printf("%s: %s\n", name, policy.Description)
+		if len(algorithm.Parameters) == 0 {
+			fmt.Println("  Parameters: none")
+		} else {
+			fmt.Println("  Parameters:")
+		}
+		for _, param := range algorithm.Parameters {
+			fmt.Printf("    %s (current: %v)\n      %s\n", param.Key, values[param.Key], param.Description)
+		}
+		fmt.Println()
+	}
+	return nil
+}
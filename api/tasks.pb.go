@@ -0,0 +1,364 @@
+// The gRPC API of the serve command: external clients submit tasks to the queue it
+// serves and stream their completions.
+//
+// Regenerate tasks.pb.go and tasks_grpc.pb.go after changing this file:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative api/tasks.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: api/tasks.proto
+
+package api
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubmitRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Service time of the task; 0 to use the configured duration of its class
+	DurationMs int64 `protobuf:"varint,1,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	// "short" or "long"
+	Class string `protobuf:"bytes,2,opt,name=class,proto3" json:"class,omitempty"`
+	// Queue priority, only used by policies with priorities; 0 to let the policy decide
+	Priority uint32 `protobuf:"varint,3,opt,name=priority,proto3" json:"priority,omitempty"`
+	// Optional deadline, for deadline-aware policies
+	Deadline      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitRequest) Reset() {
+	*x = SubmitRequest{}
+	mi := &file_api_tasks_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitRequest) ProtoMessage() {}
+
+func (x *SubmitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_tasks_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitRequest.ProtoReflect.Descriptor instead.
+func (*SubmitRequest) Descriptor() ([]byte, []int) {
+	return file_api_tasks_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitRequest) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *SubmitRequest) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+func (x *SubmitRequest) GetPriority() uint32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *SubmitRequest) GetDeadline() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Deadline
+	}
+	return nil
+}
+
+type SubmitResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TaskId        int64                  `protobuf:"varint,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitResponse) Reset() {
+	*x = SubmitResponse{}
+	mi := &file_api_tasks_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitResponse) ProtoMessage() {}
+
+func (x *SubmitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_tasks_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitResponse.ProtoReflect.Descriptor instead.
+func (*SubmitResponse) Descriptor() ([]byte, []int) {
+	return file_api_tasks_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitResponse) GetTaskId() int64 {
+	if x != nil {
+		return x.TaskId
+	}
+	return 0
+}
+
+type CompletionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only stream the completions of these tasks; empty to stream them all
+	TaskIds       []int64 `protobuf:"varint,1,rep,packed,name=task_ids,json=taskIds,proto3" json:"task_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompletionsRequest) Reset() {
+	*x = CompletionsRequest{}
+	mi := &file_api_tasks_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompletionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompletionsRequest) ProtoMessage() {}
+
+func (x *CompletionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_tasks_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompletionsRequest.ProtoReflect.Descriptor instead.
+func (*CompletionsRequest) Descriptor() ([]byte, []int) {
+	return file_api_tasks_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CompletionsRequest) GetTaskIds() []int64 {
+	if x != nil {
+		return x.TaskIds
+	}
+	return nil
+}
+
+type Completion struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	TaskId int64                  `protobuf:"varint,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	// "completed" or "cancelled"
+	Status         string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	ArrivalTime    *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=arrival_time,json=arrivalTime,proto3" json:"arrival_time,omitempty"`
+	CompletionTime *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=completion_time,json=completionTime,proto3" json:"completion_time,omitempty"`
+	// Time spent waiting in the queue, and from arrival to completion, as the server saw it
+	WaitMs        float64 `protobuf:"fixed64,5,opt,name=wait_ms,json=waitMs,proto3" json:"wait_ms,omitempty"`
+	ResponseMs    float64 `protobuf:"fixed64,6,opt,name=response_ms,json=responseMs,proto3" json:"response_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Completion) Reset() {
+	*x = Completion{}
+	mi := &file_api_tasks_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Completion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Completion) ProtoMessage() {}
+
+func (x *Completion) ProtoReflect() protoreflect.Message {
+	mi := &file_api_tasks_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Completion.ProtoReflect.Descriptor instead.
+func (*Completion) Descriptor() ([]byte, []int) {
+	return file_api_tasks_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Completion) GetTaskId() int64 {
+	if x != nil {
+		return x.TaskId
+	}
+	return 0
+}
+
+func (x *Completion) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Completion) GetArrivalTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ArrivalTime
+	}
+	return nil
+}
+
+func (x *Completion) GetCompletionTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CompletionTime
+	}
+	return nil
+}
+
+func (x *Completion) GetWaitMs() float64 {
+	if x != nil {
+		return x.WaitMs
+	}
+	return 0
+}
+
+func (x *Completion) GetResponseMs() float64 {
+	if x != nil {
+		return x.ResponseMs
+	}
+	return 0
+}
+
+var File_api_tasks_proto protoreflect.FileDescriptor
+
+const file_api_tasks_proto_rawDesc = "" +
+	"\n" +
+	"\x0fapi/tasks.proto\x12\ffifoqueue.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9a\x01\n" +
+	"\rSubmitRequest\x12\x1f\n" +
+	"\vduration_ms\x18\x01 \x01(\x03R\n" +
+	"durationMs\x12\x14\n" +
+	"\x05class\x18\x02 \x01(\tR\x05class\x12\x1a\n" +
+	"\bpriority\x18\x03 \x01(\rR\bpriority\x126\n" +
+	"\bdeadline\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\bdeadline\")\n" +
+	"\x0eSubmitResponse\x12\x17\n" +
+	"\atask_id\x18\x01 \x01(\x03R\x06taskId\"/\n" +
+	"\x12CompletionsRequest\x12\x19\n" +
+	"\btask_ids\x18\x01 \x03(\x03R\ataskIds\"\xfb\x01\n" +
+	"\n" +
+	"Completion\x12\x17\n" +
+	"\atask_id\x18\x01 \x01(\x03R\x06taskId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12=\n" +
+	"\farrival_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\varrivalTime\x12C\n" +
+	"\x0fcompletion_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x0ecompletionTime\x12\x17\n" +
+	"\await_ms\x18\x05 \x01(\x01R\x06waitMs\x12\x1f\n" +
+	"\vresponse_ms\x18\x06 \x01(\x01R\n" +
+	"responseMs2\x9d\x01\n" +
+	"\tTaskQueue\x12C\n" +
+	"\x06Submit\x12\x1b.fifoqueue.v1.SubmitRequest\x1a\x1c.fifoqueue.v1.SubmitResponse\x12K\n" +
+	"\vCompletions\x12 .fifoqueue.v1.CompletionsRequest\x1a\x18.fifoqueue.v1.Completion0\x01B\x15Z\x13fifo-queue-demo/apib\x06proto3"
+
+var (
+	file_api_tasks_proto_rawDescOnce sync.Once
+	file_api_tasks_proto_rawDescData []byte
+)
+
+func file_api_tasks_proto_rawDescGZIP() []byte {
+	file_api_tasks_proto_rawDescOnce.Do(func() {
+		file_api_tasks_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_tasks_proto_rawDesc), len(file_api_tasks_proto_rawDesc)))
+	})
+	return file_api_tasks_proto_rawDescData
+}
+
+var file_api_tasks_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_api_tasks_proto_goTypes = []any{
+	(*SubmitRequest)(nil),         // 0: fifoqueue.v1.SubmitRequest
+	(*SubmitResponse)(nil),        // 1: fifoqueue.v1.SubmitResponse
+	(*CompletionsRequest)(nil),    // 2: fifoqueue.v1.CompletionsRequest
+	(*Completion)(nil),            // 3: fifoqueue.v1.Completion
+	(*timestamppb.Timestamp)(nil), // 4: google.protobuf.Timestamp
+}
+var file_api_tasks_proto_depIdxs = []int32{
+	4, // 0: fifoqueue.v1.SubmitRequest.deadline:type_name -> google.protobuf.Timestamp
+	4, // 1: fifoqueue.v1.Completion.arrival_time:type_name -> google.protobuf.Timestamp
+	4, // 2: fifoqueue.v1.Completion.completion_time:type_name -> google.protobuf.Timestamp
+	0, // 3: fifoqueue.v1.TaskQueue.Submit:input_type -> fifoqueue.v1.SubmitRequest
+	2, // 4: fifoqueue.v1.TaskQueue.Completions:input_type -> fifoqueue.v1.CompletionsRequest
+	1, // 5: fifoqueue.v1.TaskQueue.Submit:output_type -> fifoqueue.v1.SubmitResponse
+	3, // 6: fifoqueue.v1.TaskQueue.Completions:output_type -> fifoqueue.v1.Completion
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_api_tasks_proto_init() }
+func file_api_tasks_proto_init() {
+	if File_api_tasks_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_tasks_proto_rawDesc), len(file_api_tasks_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_tasks_proto_goTypes,
+		DependencyIndexes: file_api_tasks_proto_depIdxs,
+		MessageInfos:      file_api_tasks_proto_msgTypes,
+	}.Build()
+	File_api_tasks_proto = out.File
+	file_api_tasks_proto_goTypes = nil
+	file_api_tasks_proto_depIdxs = nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// arrivalSource delivers task descriptors published by external event producers. A
+// descriptor is the JSON body of POST /tasks.
+type arrivalSource interface {
+	// Run hands each descriptor to submit, in the order they are consumed, until ctx is
+	// done or the source fails
+	Run(ctx context.Context, submit func(descriptor []byte)) error
+	// String describes where descriptors come from
+	String() string
+}
+
+// newArrivalSource returns the configured arrival source, or nil when there is none
+func newArrivalSource(cfg ArrivalsConfig) arrivalSource {
+	switch cfg.Source {
+	case "nats":
+		return &natsSource{cfg: cfg}
+	case "kafka":
+		return &kafkaRESTSource{cfg: cfg, client: &http.Client{Timeout: kafkaPollTimeout + 10*time.Second}}
+	}
+	return nil
+}
+
+// natsSource consumes a NATS subject. Servers in the same queue group share the
+// descriptors, each getting a part of them; with no group, each gets them all.
+type natsSource struct {
+	cfg ArrivalsConfig
+}
+
+func (s *natsSource) String() string {
+	return fmt.Sprintf("NATS subject %s at %s", s.cfg.Subject, s.cfg.URL)
+}
+
+func (s *natsSource) Run(ctx context.Context, submit func([]byte)) error {
+	conn, err := nats.Connect(s.cfg.URL, nats.Name("fifo-queue-demo serve"))
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS at %s: %w", s.cfg.URL, err)
+	}
+	defer conn.Close()
+	// The handler runs on a single goroutine per subscription, so descriptors are
+	// submitted in the order they were published
+	handler := func(msg *nats.Msg) { submit(msg.Data) }
+	var sub *nats.Subscription
+	if s.cfg.Group != "" {
+		sub, err = conn.QueueSubscribe(s.cfg.Subject, s.cfg.Group, handler)
+	} else {
+		sub, err = conn.Subscribe(s.cfg.Subject, handler)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", s.cfg.Subject, err)
+	}
+	<-ctx.Done()
+	// Let the descriptors already received be submitted
+	return sub.Drain()
+}
+
+// kafkaPollTimeout is how long a poll of the Kafka REST Proxy waits for records
+const kafkaPollTimeout = time.Second
+
+// kafkaRESTSource consumes a Kafka topic through a Kafka REST Proxy (API v2), as a member
+// of a consumer group, so the testbed needs no Kafka client library. Consumers of the
+// same group share the topic's partitions. Records hold the descriptors as JSON values,
+// and consumption starts from the latest offset when the group has none.
+type kafkaRESTSource struct {
+	cfg    ArrivalsConfig
+	client *http.Client
+}
+
+func (s *kafkaRESTSource) String() string {
+	return fmt.Sprintf("Kafka topic %s through the REST Proxy at %s", s.cfg.Subject, s.cfg.URL)
+}
+
+func (s *kafkaRESTSource) Run(ctx context.Context, submit func([]byte)) error {
+	hostname, _ := os.Hostname()
+	instance := fmt.Sprintf("serve-%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+	var consumer struct {
+		BaseURI string `json:"base_uri"`
+	}
+	err := s.request(context.Background(), http.MethodPost, strings.TrimSuffix(s.cfg.URL, "/")+"/consumers/"+s.cfg.Group,
+		map[string]string{"name": instance, "format": "json", "auto.offset.reset": "latest"}, &consumer)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+	// The consumer instance lives on in the proxy until deleted, or until the proxy's
+	// consumer.instance.timeout.ms expires it, holding its share of the topic's partitions
+	defer func() {
+		if err := s.request(context.Background(), http.MethodDelete, consumer.BaseURI, nil, nil); err != nil {
+			fmt.Printf("Warning: failed to delete Kafka consumer %s; the REST Proxy keeps it until it times out: %v\n", instance, err)
+		}
+	}()
+	err = s.request(ctx, http.MethodPost, consumer.BaseURI+"/subscription", map[string][]string{"topics": {s.cfg.Subject}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", s.cfg.Subject, err)
+	}
+
+	url := fmt.Sprintf("%s/records?timeout=%d", consumer.BaseURI, kafkaPollTimeout.Milliseconds())
+	for ctx.Err() == nil {
+		var records []struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := s.request(ctx, http.MethodGet, url, nil, &records); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return fmt.Errorf("failed to poll %s: %w", s.cfg.Subject, err)
+		}
+		for _, record := range records {
+			submit(record.Value)
+		}
+	}
+	return nil
+}
+
+// request sends a REST Proxy request with a JSON body, if any, and decodes the JSON
+// answer into out, if set
+func (s *kafkaRESTSource) request(ctx context.Context, method, url string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.json.v2+json, application/vnd.kafka.v2+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/sched"
+)
+
+// activeGate is the capacity gate of the autoscaled run in progress, or the gate sharing
+// worker slots between the lanes of a policy, nil otherwise. processTask waits on it
+// before starting a task.
+var activeGate atomic.Pointer[capacityGate]
+
+// capacityGate caps how many tasks run at once in this process. The queue is launched
+// with the autoscaler's maximum capacity and the gate lowers the effective concurrency to
+// the current capacity. Waiting tasks are admitted by priority (lower first), then arrival,
+// or from the lane the lane picker chooses, then by arrival, for policies with lanes, or as
+// the dispatcher picks for policies that pick tasks themselves. Tasks the watchdog boosted
+// go first, by arrival. With reservations, a task only starts when a reserved slot of its
+// group or a shared slot is free, and the first waiting task that may start goes first.
+type capacityGate struct {
+	mu         sync.Mutex
+	limit      int
+	inUse      int
+	waiters    []*gateWaiter
+	priority   func(Task) uint
+	lanes      *sched.Lanes
+	picker     *sched.LanePicker
+	dispatcher sched.Dispatcher // Picks among the waiting tasks instead of the priority or the lanes
+	slots      *sched.SlotUsage // Reserved and shared slots in use, nil without reservations
+	waits      []time.Duration  // Wait of the tasks admitted since the last evaluation
+}
+
+type gateWaiter struct {
+	task    Task
+	ready   chan struct{}
+	boosted bool
+}
+
+// Acquire blocks until the task can start under the current capacity
+func (g *capacityGate) Acquire(task Task) {
+	g.mu.Lock()
+	if g.inUse < g.limit && len(g.waiters) == 0 && (g.slots == nil || g.slots.CanStart(task)) {
+		g.admit(task)
+		g.mu.Unlock()
+		return
+	}
+	waiter := &gateWaiter{task: task, ready: make(chan struct{})}
+	g.waiters = append(g.waiters, waiter)
+	g.mu.Unlock()
+	<-waiter.ready
+}
+
+// Release frees the slot of a finished task
+func (g *capacityGate) Release(task Task) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inUse--
+	if g.slots != nil {
+		g.slots.Finish(task)
+	}
+	g.grant()
+}
+
+// SetLimit changes the capacity. Lowering it doesn't preempt running tasks: the capacity
+// shrinks as they finish.
+func (g *capacityGate) SetLimit(limit int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.limit = limit
+	g.grant()
+}
+
+// grant admits waiting tasks while there are free slots. Callers hold the lock.
+func (g *capacityGate) grant() {
+	if g.slots != nil {
+		g.grantReserved()
+		return
+	}
+	for g.inUse < g.limit && len(g.waiters) > 0 {
+		if best := g.firstBoosted(); best >= 0 {
+			g.release(best)
+			continue
+		}
+		if g.dispatcher != nil {
+			g.release(g.dispatch())
+			continue
+		}
+		lane := -1
+		if g.lanes != nil {
+			lane = g.picker.Pick(func(k int) bool {
+				for _, waiter := range g.waiters {
+					if g.lanes.Lane(waiter.task) == k {
+						return true
+					}
+				}
+				return false
+			})
+		}
+		best := -1
+		for i, waiter := range g.waiters {
+			if lane >= 0 && g.lanes.Lane(waiter.task) != lane {
+				continue
+			}
+			if best < 0 || g.before(waiter.task, g.waiters[best].task) {
+				best = i
+			}
+		}
+		g.release(best)
+	}
+}
+
+// grantReserved admits the first waiting tasks that may start under the reservations:
+// boosted tasks by arrival, then the others by priority and arrival. Callers hold the
+// lock.
+func (g *capacityGate) grantReserved() {
+	for g.inUse < g.limit {
+		best := -1
+		for i, waiter := range g.waiters {
+			if !g.slots.CanStart(waiter.task) {
+				continue
+			}
+			if best < 0 {
+				best = i
+				continue
+			}
+			current := g.waiters[best]
+			if waiter.boosted != current.boosted {
+				if waiter.boosted {
+					best = i
+				}
+				continue
+			}
+			if waiter.boosted && waiter.task.ArrivalTime.Before(current.task.ArrivalTime) ||
+				!waiter.boosted && g.before(waiter.task, current.task) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return
+		}
+		g.release(best)
+	}
+}
+
+// dispatch returns the index of the waiter the dispatcher picks. Callers hold the lock.
+func (g *capacityGate) dispatch() int {
+	order := make([]int, len(g.waiters))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return g.waiters[order[a]].task.ArrivalTime.Before(g.waiters[order[b]].task.ArrivalTime)
+	})
+	waiting := make([]Task, len(order))
+	for i, w := range order {
+		waiting[i] = g.waiters[w].task
+	}
+	i := g.dispatcher.Pick(time.Now(), waiting)
+	if i < 0 || i >= len(order) {
+		i = 0
+	}
+	return order[i]
+}
+
+// firstBoosted returns the index of the earliest-arrived boosted waiter, or -1 if none
+// was boosted. Callers hold the lock.
+func (g *capacityGate) firstBoosted() int {
+	best := -1
+	for i, waiter := range g.waiters {
+		if waiter.boosted && (best < 0 || waiter.task.ArrivalTime.Before(g.waiters[best].task.ArrivalTime)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// release admits waiter i. Callers hold the lock.
+func (g *capacityGate) release(i int) {
+	waiter := g.waiters[i]
+	g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+	g.admit(waiter.task)
+	close(waiter.ready)
+}
+
+// Boost moves the waiting tasks that arrived before cutoff ahead of the others, and
+// returns the ones it hadn't boosted yet
+func (g *capacityGate) Boost(cutoff time.Time) []Task {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var boosted []Task
+	for _, waiter := range g.waiters {
+		if !waiter.boosted && waiter.task.ArrivalTime.Before(cutoff) {
+			waiter.boosted = true
+			boosted = append(boosted, waiter.task)
+		}
+	}
+	return boosted
+}
+
+func (g *capacityGate) before(a, b Task) bool {
+	if g.priority != nil && g.priority(a) != g.priority(b) {
+		return g.priority(a) < g.priority(b)
+	}
+	return a.ArrivalTime.Before(b.ArrivalTime)
+}
+
+// admit takes a slot for the task. Callers hold the lock.
+func (g *capacityGate) admit(task Task) {
+	g.inUse++
+	if g.slots != nil {
+		g.slots.Start(task)
+	}
+	g.waits = append(g.waits, time.Since(task.ArrivalTime))
+}
+
+// snapshot returns the current usage and the waits observed since the last snapshot
+func (g *capacityGate) snapshot() (limit, inUse, waiting int, waits []time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	waits, g.waits = g.waits, nil
+	return g.limit, g.inUse, len(g.waiters), waits
+}
+
+// capacitySample is one point of the capacity-over-time series
+type capacitySample struct {
+	Elapsed  time.Duration
+	Capacity int
+	InUse    int
+	Backlog  int
+	Wait     ResponseSummary // Wait of the tasks started since the previous sample
+}
+
+// Autoscaler adjusts the effective worker concurrency during a run, based on the queue
+// backlog or on the p99 wait of recently started tasks
+type Autoscaler struct {
+	cfg   AutoscalerConfig
+	gate  *capacityGate
+	queue taskQueue
+
+	start      time.Time
+	lastScale  time.Time
+	samples    []capacitySample
+	scaleUps   int
+	scaleDowns int
+	err        error
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// newAutoscaler installs a capacity gate starting at the minimum capacity
+func newAutoscaler(cfg AutoscalerConfig, priority func(Task) uint) *Autoscaler {
+	a := &Autoscaler{
+		cfg:  cfg,
+		gate: &capacityGate{limit: cfg.MinCapacity, priority: priority},
+	}
+	activeGate.Store(a.gate)
+	return a
+}
+
+// Start begins evaluating the scaling rules against the queue
+func (a *Autoscaler) Start(queue taskQueue) {
+	a.queue = queue
+	a.start = time.Now()
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.run()
+}
+
+// register makes the autoscaler scale the run until every task was collected, then
+// report and export the capacity it provisioned, which the run's cost is based on
+func (a *Autoscaler) register(r *experimentRun) {
+	r.OnCollected(func([]Task) error {
+		a.Stop()
+		return nil
+	})
+	r.OnReport(func(outcome *runOutcome) error {
+		a.Print()
+		outcome.workerSeconds = a.WorkerSeconds()
+		return a.Export(strings.TrimSuffix(outcome.filename, ".csv") + "_capacity.csv")
+	})
+	r.OnClose(a.Stop)
+}
+
+// Stop stops scaling and removes the capacity gate
+func (a *Autoscaler) Stop() {
+	if a.stop != nil {
+		select {
+		case <-a.stop:
+		default:
+			close(a.stop)
+			<-a.done
+		}
+	}
+	activeGate.CompareAndSwap(a.gate, nil)
+}
+
+func (a *Autoscaler) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.cfg.EvaluationInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.evaluate()
+		}
+	}
+}
+
+// evaluate records a sample and applies the scaling rules, honoring the cooldowns
+func (a *Autoscaler) evaluate() {
+	limit, inUse, waiting, waits := a.gate.snapshot()
+	queued, err := a.queue.Depth()
+	if err != nil {
+		// Keep the current capacity; the run reports the first error
+		if a.err == nil {
+			a.err = err
+		}
+		return
+	}
+	backlog := queued + waiting
+	wait := metrics.Summarize(waits)
+	a.samples = append(a.samples, capacitySample{
+		Elapsed:  time.Since(a.start),
+		Capacity: limit,
+		InUse:    inUse,
+		Backlog:  backlog,
+		Wait:     wait,
+	})
+
+	var up, down bool
+	switch a.cfg.Metric {
+	case "p99":
+		target := a.cfg.TargetP99Wait()
+		up = wait.Count > 0 && wait.P99 > target
+		down = backlog == 0 && wait.P99 < target/2
+	default:
+		perSlot := float64(backlog) / float64(limit)
+		up = perSlot > a.cfg.ScaleUpBacklog
+		down = perSlot < a.cfg.ScaleDownBacklog
+	}
+
+	sinceScale := time.Since(a.lastScale)
+	switch {
+	case up && limit < a.cfg.MaxCapacity && sinceScale >= a.cfg.ScaleUpCooldown():
+		a.gate.SetLimit(min(limit+a.cfg.Step, a.cfg.MaxCapacity))
+		a.lastScale = time.Now()
+		a.scaleUps++
+	case down && limit > a.cfg.MinCapacity && sinceScale >= a.cfg.ScaleDownCooldown():
+		a.gate.SetLimit(max(limit-a.cfg.Step, a.cfg.MinCapacity))
+		a.lastScale = time.Now()
+		a.scaleDowns++
+	}
+}
+
+// Print reports scaling activity, provisioned capacity and a capacity-over-time timeline
+func (a *Autoscaler) Print() {
+	fmt.Printf("\nAutoscaler (%s metric, capacity %d-%d):\n", a.cfg.Metric, a.cfg.MinCapacity, a.cfg.MaxCapacity)
+	if a.err != nil {
+		fmt.Printf("  Warning: failed to read the queue backlog: %v\n", a.err)
+	}
+	if len(a.samples) == 0 {
+		fmt.Printf("  No samples recorded\n")
+		return
+	}
+
+	elapsed := time.Duration(len(a.samples)) * a.cfg.EvaluationInterval()
+	workerSeconds := a.WorkerSeconds()
+	fmt.Printf("  Scale ups: %d, scale downs: %d\n", a.scaleUps, a.scaleDowns)
+	fmt.Printf("  Mean capacity: %.2f slots (%.1f worker-seconds, vs %.1f at fixed max capacity)\n",
+		workerSeconds/elapsed.Seconds(), workerSeconds, float64(a.cfg.MaxCapacity)*elapsed.Seconds())
+
+	// Print about 20 evenly spaced rows of the series
+	fmt.Printf("  %10s %9s %7s %8s %14s\n", "time (s)", "capacity", "in use", "backlog", "p99 wait (ms)")
+	every := max(1, len(a.samples)/20)
+	for i := 0; i < len(a.samples); i += every {
+		sample := a.samples[i]
+		fmt.Printf("  %10.1f %9d %7d %8d %14s\n", sample.Elapsed.Seconds(), sample.Capacity,
+			sample.InUse, sample.Backlog, formatMs(sample.Wait.P99))
+	}
+}
+
+// WorkerSeconds returns the slot-seconds provisioned during the run. Capacity is held
+// between samples, so each sample accounts for one evaluation interval.
+func (a *Autoscaler) WorkerSeconds() float64 {
+	var slotTime time.Duration
+	for _, sample := range a.samples {
+		slotTime += time.Duration(sample.Capacity) * a.cfg.EvaluationInterval()
+	}
+	return slotTime.Seconds()
+}
+
+// Export writes the capacity and latency series to a CSV file
+func (a *Autoscaler) Export(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create capacity CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"elapsed_ms", "capacity", "in_use", "backlog", "started", "mean_wait_ms", "p99_wait_ms"})
+	for _, sample := range a.samples {
+		writer.Write([]string{
+			fmt.Sprintf("%d", sample.Elapsed.Milliseconds()),
+			fmt.Sprintf("%d", sample.Capacity),
+			fmt.Sprintf("%d", sample.InUse),
+			fmt.Sprintf("%d", sample.Backlog),
+			fmt.Sprintf("%d", sample.Wait.Count),
+			formatMs(sample.Wait.Mean),
+			formatMs(sample.Wait.P99),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write capacity CSV file: %w", err)
+	}
+	fmt.Printf("Capacity series exported to %s\n", filename)
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Backpressure models a client that watches the queue backlog before submitting work
+type Backpressure struct {
+	cfg   ProducerConfig
+	queue taskQueue
+
+	Shed        int           // Requests dropped because the backlog was over the threshold
+	ShedWork    time.Duration // Service time of the dropped requests
+	Delayed     int           // Requests held until the backlog drained
+	TotalDelay  time.Duration // Sum of the time requests were held
+	MaxDelay    time.Duration // Longest time a request was held
+	PeakBacklog int           // Highest backlog observed by the producer
+}
+
+func newBackpressure(cfg ProducerConfig, queue taskQueue) *Backpressure {
+	return &Backpressure{cfg: cfg, queue: queue}
+}
+
+// Enabled reports whether the producer applies backpressure at all
+func (b *Backpressure) Enabled() bool {
+	return b.cfg.BackpressureThreshold > 0
+}
+
+// queueDepth returns the number of tasks enqueued but not yet started
+func (b *Backpressure) queueDepth() (int, error) {
+	depth, err := b.queue.Depth()
+	if err != nil {
+		return 0, err
+	}
+	if depth > b.PeakBacklog {
+		b.PeakBacklog = depth
+	}
+	return depth, nil
+}
+
+// Admit checks the backlog before a request of the given duration is submitted. In pause
+// mode it blocks until the backlog is under the threshold and returns how long it waited.
+// In shed mode it returns admitted=false when the request must be dropped.
+func (b *Backpressure) Admit(duration time.Duration) (admitted bool, delay time.Duration, err error) {
+	if !b.Enabled() {
+		return true, 0, nil
+	}
+
+	start := time.Now()
+	for {
+		depth, err := b.queueDepth()
+		if err != nil {
+			return false, 0, err
+		}
+		if depth < b.cfg.BackpressureThreshold {
+			break
+		}
+		if b.cfg.BackpressureMode == "shed" {
+			b.Shed++
+			b.ShedWork += duration
+			return false, 0, nil
+		}
+		time.Sleep(b.cfg.BackpressurePollInterval())
+	}
+
+	delay = time.Since(start)
+	if delay >= b.cfg.BackpressurePollInterval() {
+		b.Delayed++
+		b.TotalDelay += delay
+		if delay > b.MaxDelay {
+			b.MaxDelay = delay
+		}
+	} else {
+		// The backlog was under the threshold on the first check
+		delay = 0
+	}
+	return true, delay, nil
+}
+
+// Print reports what backpressure did to the arrivals and the latency clients observed
+func (b *Backpressure) Print(tasks []Task) {
+	fmt.Printf("\nBackpressure (%s at backlog >= %d):\n", b.cfg.BackpressureMode, b.cfg.BackpressureThreshold)
+	fmt.Printf("  Peak backlog observed: %d\n", b.PeakBacklog)
+	fmt.Printf("  Requests shed: %d (%v of work)\n", b.Shed, b.ShedWork)
+	fmt.Printf("  Requests delayed: %d\n", b.Delayed)
+	if b.Delayed > 0 {
+		fmt.Printf("  Mean delay of delayed requests: %.3f ms\n", float64(b.TotalDelay.Microseconds())/1000/float64(b.Delayed))
+		fmt.Printf("  Max delay: %.3f ms\n", float64(b.MaxDelay.Microseconds())/1000)
+	}
+
+	// Clients see the time their request was held on top of the queue's response time
+	if len(tasks) > 0 {
+		var queueResponse, clientResponse time.Duration
+		for _, task := range tasks {
+			respTime := task.CompletionTime.Sub(task.ArrivalTime)
+			queueResponse += respTime
+			clientResponse += respTime + task.BackpressureDelay
+		}
+		n := time.Duration(len(tasks))
+		fmt.Printf("  Mean response time (queue): %.3f ms\n", float64((queueResponse/n).Microseconds())/1000)
+		fmt.Printf("  Mean response time (client, incl. delay): %.3f ms\n", float64((clientResponse/n).Microseconds())/1000)
+	}
+}
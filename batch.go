@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/sched"
+)
+
+// defaultBatch is the batch policy's setup for the fields left unset: batches of up to 4
+// tasks, and each executor dequeues 16 tasks beyond its worker slots to batch from
+var defaultBatch = BatchConfig{
+	Size:      4,
+	Lookahead: 16,
+}
+
+// withDefaults returns the setup with the unset fields taken from defaultBatch
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.Size == 0 {
+		c.Size = defaultBatch.Size
+	}
+	if c.Lookahead == 0 {
+		c.Lookahead = defaultBatch.Lookahead
+	}
+	return c
+}
+
+// QueueConfig returns the queue configuration executors dequeue with under the batch
+// policy: the lookahead on top of the worker slots of each executor
+func (c BatchConfig) QueueConfig(queueCfg QueueConfig) QueueConfig {
+	return queueCfg.withLookahead(c.withDefaults().Lookahead)
+}
+
+// batchPolicy returns the policy running tasks of the same class back to back. Each run
+// starts with no batch.
+func batchPolicy(cfg AlgorithmsConfig) SchedulingPolicy {
+	return sched.Batched(&sched.Batcher{Class: taskClass, Size: cfg.Batch.withDefaults().Size})
+}
+
+// dispatcherQueueConfig returns the queue configuration executors dequeue with under a
+// policy that picks tasks itself, with the lookahead of its config section
+func dispatcherQueueConfig(policy SchedulingPolicy, queueCfg QueueConfig) QueueConfig {
+	if _, ok := policy.Dispatcher.(*sched.Batcher); ok {
+		return AppConfig.Algorithms.Batch.QueueConfig(queueCfg)
+	}
+	return AppConfig.Algorithms.Agent.QueueConfig(queueCfg)
+}
+
+// printBatchReport prints, for each class, how many batches the batch policy started and
+// how many tasks they held on average. It prints nothing for other policies.
+func printBatchReport(policy SchedulingPolicy) {
+	batcher, ok := policy.Dispatcher.(*sched.Batcher)
+	if !ok {
+		return
+	}
+	stats := batcher.Stats()
+	fmt.Printf("\nBatches (%s):\n", batcher)
+	for _, class := range sortedKeys(stats) {
+		s := stats[class]
+		fmt.Printf("  %-8s %6d batches, %7d tasks, %.2f tasks per batch\n", class, s.Batches, s.Tasks,
+			float64(s.Tasks)/float64(s.Batches))
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/workload"
+)
+
+// benchCommands maps bench subcommand names to their implementations
+var benchCommands = map[string]func(args []string) error{
+	"enqueue": benchEnqueueCommand,
+	"dequeue": benchDequeueCommand,
+}
+
+// benchCommand runs a microbenchmark of the queue machinery. Benchmarks measure the fixed
+// per-task overhead of the configured Postgres, so it can be told apart from the wait
+// caused by the scheduling policy in experiment results.
+func benchCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: bench <%s> [flags]", joinKeys(benchCommands))
+	}
+	bench, ok := benchCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown benchmark: %s (available benchmarks: %s)", args[0], joinKeys(benchCommands))
+	}
+	return bench(args[1:])
+}
+
+// benchEnqueueCommand measures raw enqueue latency and throughput, for each of the given
+// payload sizes. Tasks go to a queue no executor serves, so nothing runs, and they are
+// removed once the benchmark is done.
+func benchEnqueueCommand(args []string) error {
+	fs := flag.NewFlagSet("bench enqueue", flag.ExitOnError)
+	algo := fs.String("algo", "fcfs", "Scheduling algorithm whose enqueue options to use ("+algorithmNames()+")")
+	numTasks := fs.Int("n", 1000, "Number of tasks to enqueue per payload size")
+	workers := fs.Int("workers", AppConfig.Producer.EnqueueWorkers, "Number of concurrent enqueue workers")
+	payloadSizes := fs.String("payload-sizes", "", "Comma-separated task payload sizes to measure, in bytes (default workload.payload_bytes)")
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if err := validateForRun(); err != nil {
+		return err
+	}
+
+	policy, err := lookupPolicy(*algo)
+	if err != nil {
+		return err
+	}
+	if *numTasks <= 0 || *workers <= 0 {
+		return fmt.Errorf("-n and -workers must be positive")
+	}
+	sizes := []int{AppConfig.Workload.PayloadBytes}
+	if *payloadSizes != "" {
+		if sizes, err = parseIntList(*payloadSizes); err != nil {
+			return fmt.Errorf("invalid -payload-sizes: %w", err)
+		}
+	}
+
+	// The benchmark queue has its own name so no executor of a real run picks its tasks up
+	runID := fmt.Sprintf("bench-enqueue-%s", time.Now().Format("20060102T150405.000"))
+	queueName := "bench_" + policy.QueueName
+	queue, cleanup, err := newBenchQueue(policy, queueName)
+	if err != nil {
+		return err
+	}
+	defer cleanup(runID, *numTasks*len(sizes))
+
+	fmt.Println("============================================================")
+	fmt.Println("Enqueue Path Benchmark")
+	fmt.Println("============================================================")
+	fmt.Printf("  Tasks: %d per payload size, enqueue workers: %d, dispatch: %s, algorithm: %s, payload format: %s\n",
+		*numTasks, *workers, AppConfig.Queue.Dispatch, policy.Name, AppConfig.Workload.PayloadFormat)
+
+	fmt.Printf("\nResults:\n")
+	fmt.Printf("  %12s %14s %10s %10s %10s %10s\n", "Payload (B)", "Throughput/s", "Mean ms", "p50 ms", "p99 ms", "Max ms")
+	var baseline time.Duration
+	for n, size := range sizes {
+		// Every size gets its own range of task IDs so workflow IDs don't collide
+		latencies, elapsed, err := benchEnqueue(queue, runID, n**numTasks, *numTasks, *workers, size)
+		if err != nil {
+			return err
+		}
+
+		summary := metrics.Summarize(latencies)
+		fmt.Printf("  %12d %14.1f %10s %10s %10s %10s\n", size, float64(*numTasks)/elapsed.Seconds(),
+			formatMs(summary.Mean), formatMs(summary.Median), formatMs(summary.P99), formatMs(summary.Max))
+		if n == 0 {
+			baseline = summary.Mean
+		}
+	}
+	if len(sizes) > 1 {
+		fmt.Printf("\nCompare the mean latencies with the first size (%s ms) for the serialization cost of payloads.\n", formatMs(baseline))
+	}
+	fmt.Printf("\nSubtract the mean enqueue latency from experiment response times to remove the fixed enqueue overhead.\n")
+	return nil
+}
+
+// benchEnqueue enqueues numTasks tasks with payloads of payloadBytes, with task IDs from
+// firstID, as fast as workers can. It returns the latency of every enqueue and the time
+// they took altogether.
+func benchEnqueue(queue taskQueue, runID string, firstID, numTasks, workers, payloadBytes int) ([]time.Duration, time.Duration, error) {
+	// Payloads are generated up front so the benchmark only measures the enqueue
+	payloadCfg := AppConfig.Workload
+	payloadCfg.PayloadBytes = payloadBytes
+	generator := workload.NewBimodal(payloadCfg, 0, time.Now().UnixNano())
+
+	tasks := make([]Task, numTasks)
+	for i := range tasks {
+		generated, _ := generator.Next()
+		tasks[i] = Task{
+			TaskID:   firstID + i,
+			Duration: AppConfig.Workload.ShortTaskDuration(),
+			Payload:  generated.Payload,
+		}
+	}
+
+	// Workers enqueue back to back, as fast as Postgres accepts the inserts
+	latencies := make([]time.Duration, numTasks)
+	indexes := make(chan int, workers)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				task := tasks[i]
+				task.ArrivalTime = time.Now()
+				enqueueStart := time.Now()
+				if err := queue.Enqueue(task, taskWorkflowID(runID, task.TaskID)); err != nil {
+					errs <- fmt.Errorf("failed to enqueue task %d: %w", task.TaskID, err)
+					for range indexes {
+						// Keep draining so the producer loop doesn't block
+					}
+					return
+				}
+				latencies[i] = time.Since(enqueueStart)
+			}
+		}()
+	}
+	for i := range numTasks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(errs)
+	if err := <-errs; err != nil {
+		return nil, 0, err
+	}
+	return latencies, elapsed, nil
+}
+
+// newBenchQueue returns a task queue using the configured dispatch that no executor serves,
+// along with a function that removes the benchmark's tasks from it
+func newBenchQueue(policy SchedulingPolicy, queueName string) (taskQueue, func(runID string, numTasks int), error) {
+	if AppConfig.Queue.Dispatch == "notify" {
+		pool, err := newNotifyPool(context.Background(), 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		benchPolicy := policy
+		benchPolicy.QueueName = queueName
+		cleanup := func(runID string, numTasks int) {
+			if _, err := pool.Exec(context.Background(), `DELETE FROM schedq_tasks WHERE queue_name = $1`, queueName); err != nil {
+				fmt.Printf("Warning: failed to remove benchmark tasks: %v\n", err)
+			}
+			pool.Close()
+		}
+		return &notifyTaskQueue{pool: pool, policy: benchPolicy}, cleanup, nil
+	}
+
+	pool, err := newPool(context.Background(), AppConfig.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := dbos.NewClient(context.Background(), dbos.ClientConfig{SystemDBPool: pool})
+	if err != nil {
+		pool.Close()
+		return nil, nil, fmt.Errorf("initializing DBOS client failed: %w", err)
+	}
+	cleanup := func(runID string, numTasks int) {
+		// Cancelled workflows are never dequeued, even if the queue is served later
+		for i := range numTasks {
+			if err := client.CancelWorkflow(taskWorkflowID(runID, i)); err != nil {
+				fmt.Printf("Warning: failed to cancel benchmark task %d: %v\n", i, err)
+				break
+			}
+		}
+		client.Shutdown(5 * time.Second)
+	}
+	return &clientTaskQueue{client: client, pool: pool, queueName: queueName, policy: policy}, cleanup, nil
+}
+
+// benchDequeueCommand measures the time from enqueue to worker claim for every combination
+// of worker concurrency and polling interval. Tasks take no time and arrive in bursts no
+// larger than the concurrency, so a slot is always free and the measured wait is pure
+// dispatch overhead.
+func benchDequeueCommand(args []string) error {
+	fs := flag.NewFlagSet("bench dequeue", flag.ExitOnError)
+	algo := fs.String("algo", "fcfs", "Scheduling algorithm whose queue options to use ("+algorithmNames()+")")
+	numTasks := fs.Int("n", 200, "Number of tasks per combination")
+	concurrencies := fs.String("concurrency", "1,4,16", "Comma-separated worker concurrencies to measure")
+	intervals := fs.String("intervals", "10,100,1000", "Comma-separated base polling intervals to measure, in ms")
+	gapMs := fs.Int("gap", 50, "Pause between bursts of enqueues, in ms")
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if err := validateForRun(); err != nil {
+		return err
+	}
+
+	policy, err := lookupPolicy(*algo)
+	if err != nil {
+		return err
+	}
+	concurrencyList, err := parseIntList(*concurrencies)
+	if err != nil {
+		return fmt.Errorf("invalid -concurrency: %w", err)
+	}
+	intervalList, err := parseIntList(*intervals)
+	if err != nil {
+		return fmt.Errorf("invalid -intervals: %w", err)
+	}
+	if *numTasks <= 0 {
+		return fmt.Errorf("-n must be positive")
+	}
+	gap := time.Duration(*gapMs) * time.Millisecond
+
+	// Runs use their own queue so no executor of a real run picks the tasks up
+	policy.QueueName = "bench_" + policy.QueueName
+	policy.Title = "Dequeue Path Benchmark"
+
+	type row struct {
+		concurrency int
+		intervalMs  int
+		wait        ResponseSummary
+	}
+	var rows []row
+	for _, concurrency := range concurrencyList {
+		for _, intervalMs := range intervalList {
+			queueCfg := AppConfig.Queue
+			queueCfg.WorkerConcurrency = concurrency
+			queueCfg.GlobalConcurrency = 0
+			queueCfg.BasePollingIntervalMs = intervalMs
+			fmt.Printf("\nMeasuring concurrency %d, polling interval %d ms...\n", concurrency, intervalMs)
+
+			tasks, err := benchDequeue(policy, queueCfg, *numTasks, gap)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, row{concurrency, intervalMs, summarizeWaitTimes(tasks, nil)})
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Println("Dequeue Overhead Baseline (enqueue to claim)")
+	fmt.Println("============================================================")
+	fmt.Printf("Dispatch: %s, executors: %d, tasks per combination: %d\n",
+		AppConfig.Queue.Dispatch, AppConfig.Queue.NumExecutors, *numTasks)
+	if AppConfig.Queue.Dispatch == "notify" {
+		fmt.Printf("Notify dispatch only polls as a fallback, so the interval should barely matter.\n")
+	}
+	fmt.Printf("%-12s %-14s %10s %10s %10s\n", "concurrency", "interval (ms)", "mean (ms)", "p50 (ms)", "p99 (ms)")
+	for _, r := range rows {
+		fmt.Printf("%-12d %-14d %10s %10s %10s\n", r.concurrency, r.intervalMs,
+			formatMs(r.wait.Mean), formatMs(r.wait.Median), formatMs(r.wait.P99))
+	}
+	return nil
+}
+
+// benchDequeue runs zero-duration tasks through a fresh cluster and returns them once
+// they completed. Tasks are enqueued in bursts of queueCfg.WorkerConcurrency.
+func benchDequeue(policy SchedulingPolicy, queueCfg QueueConfig, numTasks int, gap time.Duration) ([]Task, error) {
+	cluster, err := launchExecutors(policy, queueCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer cluster.Shutdown()
+
+	runID := fmt.Sprintf("bench-dequeue-c%d-i%d-%s", queueCfg.WorkerConcurrency, queueCfg.BasePollingIntervalMs,
+		time.Now().Format("20060102T150405.000"))
+	enqueuer := newEnqueuer(cluster.queue, runID, queueCfg.WorkerConcurrency, &DedupStats{})
+	for i := range numTasks {
+		if i > 0 && i%queueCfg.WorkerConcurrency == 0 {
+			time.Sleep(gap)
+		}
+		enqueuer.Submit(Task{TaskID: i, ArrivalTime: time.Now()})
+		if err := enqueuer.Err(); err != nil {
+			return nil, err
+		}
+	}
+	taskIDs, err := enqueuer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return newResultCollector(cluster.executors[0]).Collect(runID, taskIDs, nil, nil)
+}
+
+// parseIntList parses a comma-separated list of positive integers
+func parseIntList(list string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if value <= 0 {
+			return nil, fmt.Errorf("%d is not positive", value)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
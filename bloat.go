@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Every how many memory samples the resource monitor samples the queue tables, and
+// checks for vacuums running on them
+const (
+	tableSampleTicks  = 10 // Every 5 s
+	vacuumSampleTicks = 2  // Every second
+)
+
+// queueTablesFilter selects the tables of the queue: the DBOS system tables, where
+// workflows wait and record their steps, and the harness's own tables
+const queueTablesFilter = `(n.nspname = 'dbos' OR c.relname LIKE 'schedq\_%')`
+
+// tableStats are the counters of pg_stat_user_tables for one table, and its size
+type tableStats struct {
+	Live        int64
+	Dead        int64
+	Inserted    int64
+	Updated     int64
+	Deleted     int64
+	Vacuums     int64 // Manual vacuums so far
+	Autovacuums int64 // Autovacuums so far
+	Bytes       int64 // Size with indexes and TOAST
+}
+
+// tablePeak is the largest a table got during a run
+type tablePeak struct {
+	Bytes int64
+	Dead  int64
+}
+
+// vacuumSample is whether a vacuum ran on a queue table at one check
+type vacuumSample struct {
+	at      time.Time
+	running bool
+}
+
+// readQueueTables reads the statistics of the queue tables, by schema-qualified name
+func (m *resourceMonitor) readQueueTables(ctx context.Context) (map[string]tableStats, error) {
+	rows, err := m.pool.Query(ctx, `
+		SELECT n.nspname || '.' || c.relname, s.n_live_tup, s.n_dead_tup, s.n_tup_ins, s.n_tup_upd, s.n_tup_del,
+			s.vacuum_count, s.autovacuum_count, pg_total_relation_size(c.oid)
+		FROM pg_stat_user_tables s
+		JOIN pg_class c ON c.oid = s.relid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE `+queueTablesFilter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make(map[string]tableStats)
+	for rows.Next() {
+		var name string
+		var t tableStats
+		if err := rows.Scan(&name, &t.Live, &t.Dead, &t.Inserted, &t.Updated, &t.Deleted, &t.Vacuums, &t.Autovacuums, &t.Bytes); err != nil {
+			return nil, err
+		}
+		tables[name] = t
+	}
+	return tables, rows.Err()
+}
+
+// sampleTables records the peak size and dead tuples of the queue tables. Failures are
+// left to the snapshots to report.
+func (m *resourceMonitor) sampleTables(ctx context.Context) {
+	tables, err := m.readQueueTables(ctx)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.raisePeaks(tables)
+	m.tables = tables
+}
+
+// QueueTables returns the total size and dead tuples of the queue tables at the last
+// sample, if there was one
+func (m *resourceMonitor) QueueTables() (bytes, dead int64, ok bool) {
+	if m == nil {
+		return 0, 0, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tables {
+		bytes += t.Bytes
+		dead += t.Dead
+	}
+	return bytes, dead, m.tables != nil
+}
+
+// raisePeaks raises the peaks of the tables to their current statistics. Callers hold
+// the lock.
+func (m *resourceMonitor) raisePeaks(tables map[string]tableStats) {
+	if m.tablePeaks == nil {
+		m.tablePeaks = make(map[string]tablePeak)
+	}
+	for name, t := range tables {
+		peak := m.tablePeaks[name]
+		m.tablePeaks[name] = tablePeak{Bytes: max(peak.Bytes, t.Bytes), Dead: max(peak.Dead, t.Dead)}
+	}
+}
+
+// sampleVacuum records whether a vacuum, manual or automatic, runs on a queue table
+func (m *resourceMonitor) sampleVacuum(ctx context.Context) {
+	var running bool
+	err := m.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_stat_progress_vacuum p
+			JOIN pg_class c ON c.oid = p.relid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE p.datname = current_database() AND `+queueTablesFilter+`)`).Scan(&running)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vacuums = append(m.vacuums, vacuumSample{at: time.Now(), running: running})
+}
+
+// vacuumingAt reports whether a vacuum ran on a queue table at the check before the
+// given time. Callers hold the lock.
+func (m *resourceMonitor) vacuumingAt(at time.Time) bool {
+	i := sort.Search(len(m.vacuums), func(i int) bool { return m.vacuums[i].at.After(at) })
+	return i > 0 && m.vacuums[i-1].running
+}
+
+// printTableReport prints, for each queue table the run changed, its size at the start
+// and end of the run and at its peak, its dead tuples, the tuples the run wrote, and the
+// vacuums that ran on it. With the run's tasks, it also compares the wait of the tasks
+// that started while a vacuum ran on a queue table with that of the others.
+func (m *resourceMonitor) printTableReport(completed int, tasks []Task) {
+	if m.tableErr != nil {
+		fmt.Printf("  Queue tables: unknown (%s)\n", firstLine(m.tableErr.Error()))
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.raisePeaks(m.start.tables)
+	m.raisePeaks(m.end.tables)
+
+	fmt.Printf("  Queue tables (size in MB at start -> end, peak; dead tuples at end, peak; vacuums auto+manual):\n")
+	fmt.Printf("    %-28s %22s %12s %10s %10s %10s %8s\n", "Table", "Size", "Dead", "Inserted", "Updated", "Deleted", "Vacuums")
+	var growth int64
+	var autovacuums int64
+	for _, name := range sortedKeys(m.end.tables) {
+		start, end, peak := m.start.tables[name], m.end.tables[name], m.tablePeaks[name]
+		written := (end.Inserted - start.Inserted) + (end.Updated - start.Updated) + (end.Deleted - start.Deleted)
+		if written == 0 && end.Bytes == start.Bytes {
+			continue
+		}
+		growth += end.Bytes - start.Bytes
+		autovacuums += end.Autovacuums - start.Autovacuums
+		fmt.Printf("    %-28s %6.2f -> %6.2f, %6.2f %5d, %5d %10d %10d %10d %4d+%-3d\n", name,
+			float64(start.Bytes)/1e6, float64(end.Bytes)/1e6, float64(peak.Bytes)/1e6, end.Dead, peak.Dead,
+			end.Inserted-start.Inserted, end.Updated-start.Updated, end.Deleted-start.Deleted,
+			end.Autovacuums-start.Autovacuums, end.Vacuums-start.Vacuums)
+	}
+	perTask := 0.0
+	if completed > 0 {
+		perTask = float64(growth) / float64(completed)
+	}
+	fmt.Printf("    Growth: %.2f MB (%.0f bytes per completed task), %d autovacuums\n",
+		float64(growth)/1e6, perTask, autovacuums)
+
+	// What vacuums did to the tasks that started meanwhile
+	vacuuming := 0
+	for _, sample := range m.vacuums {
+		if sample.running {
+			vacuuming++
+		}
+	}
+	if len(m.vacuums) == 0 {
+		return
+	}
+	fmt.Printf("    Vacuum running on a queue table in %d of %d checks (%.1f%%)\n", vacuuming, len(m.vacuums),
+		100*float64(vacuuming)/float64(len(m.vacuums)))
+	if vacuuming == 0 || len(tasks) == 0 {
+		return
+	}
+	during := summarizeWaitTimes(tasks, func(task Task) bool { return m.vacuumingAt(task.DequeueTime) })
+	outside := summarizeWaitTimes(tasks, func(task Task) bool { return !m.vacuumingAt(task.DequeueTime) })
+	fmt.Printf("    Tasks started during a vacuum: %d, wait mean %s / p99 %s ms; otherwise: %d, wait mean %s / p99 %s ms\n",
+		during.Count, formatMs(during.Mean), formatMs(during.P99), outside.Count, formatMs(outside.Mean), formatMs(outside.P99))
+}
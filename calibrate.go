@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Calibration is the per-task overhead of the queue, measured before a run on tasks that
+// do no work
+type Calibration struct {
+	Tasks    int
+	Enqueue  time.Duration // From arrival until the task is in the queue
+	Dispatch time.Duration // From then until an executor claims it, with a worker slot free
+	Hold     time.Duration // From the claim until the task completes, with no work to do
+}
+
+// Overhead returns the time a task costs a worker slot on top of its work: the time the
+// slot waits to be handed a task, and the time it holds it without working. The enqueue
+// time doesn't hold a slot, so it doesn't add to the load.
+func (c Calibration) Overhead() time.Duration {
+	return c.Dispatch + c.Hold
+}
+
+// InterArrival stretches an inter-arrival time computed from the work of the tasks alone,
+// so that the load the queue sees, overhead included, is the target utilization
+func (c Calibration) InterArrival(avgTaskDuration, interArrival time.Duration) time.Duration {
+	if avgTaskDuration <= 0 {
+		return interArrival
+	}
+	return time.Duration(float64(interArrival) * float64(avgTaskDuration+c.Overhead()) / float64(avgTaskDuration))
+}
+
+// calibrate runs numTasks tasks that do no work through the run's queue and measures
+// their overhead. Tasks are enqueued in bursts no larger than the queue's capacity, and a
+// burst waits for the previous one to complete, so a worker slot is always free and the
+// wait measured is dispatch alone. Calibration tasks belong to the run, under their own
+// run ID prefix, so cleaning up the run removes them too.
+func calibrate(c *cluster, runID string, queueCfg QueueConfig, numTasks int) (Calibration, error) {
+	calibrationID := runID + "-calibration"
+	burst := max(1, queueCfg.Capacity())
+	var enqueue, dispatch, hold time.Duration
+	for first := 0; first < numTasks; first += burst {
+		var taskIDs []int
+		for id := first; id < min(first+burst, numTasks); id++ {
+			task := Task{TaskID: id, ArrivalTime: time.Now()}
+			if err := c.queue.Enqueue(task, taskWorkflowID(calibrationID, id)); err != nil {
+				return Calibration{}, fmt.Errorf("failed to enqueue calibration task %d: %w", id, err)
+			}
+			taskIDs = append(taskIDs, id)
+		}
+		tasks, err := newResultCollector(c.executors[0]).Collect(calibrationID, taskIDs, nil, nil)
+		if err != nil {
+			return Calibration{}, fmt.Errorf("calibration failed: %w", err)
+		}
+		for _, task := range tasks {
+			enqueue += task.EnqueueDelay()
+			dispatch += task.QueueingDelay()
+			hold += task.CompletionTime.Sub(task.StartedAt)
+		}
+	}
+	n := time.Duration(numTasks)
+	return Calibration{Tasks: numTasks, Enqueue: enqueue / n, Dispatch: dispatch / n, Hold: hold / n}, nil
+}
+
+// Print prints the measured overhead and how it changes the inter-arrival time
+func (c Calibration) Print(avgTaskDuration, interArrival time.Duration) {
+	fmt.Printf("\nCalibration (%d tasks with no work):\n", c.Tasks)
+	fmt.Printf("  Per task: enqueue %s ms, dispatch %s ms, claim to completion %s ms\n",
+		formatMs(c.Enqueue), formatMs(c.Dispatch), formatMs(c.Hold))
+	fmt.Printf("  A worker slot spends %v per task for %v of work on average\n",
+		(avgTaskDuration + c.Overhead()).Round(time.Microsecond), avgTaskDuration)
+	fmt.Printf("  Average inter-arrival time: %v -> %v\n", interArrival, c.InterArrival(avgTaskDuration, interArrival))
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// cancelRetries bounds how often a client retries giving up on a task whose workflow
+// doesn't exist yet, or is enqueued but not yet visible to the cancelling UPDATE, because
+// its enqueue is still in flight
+const (
+	cancelRetries       = 20
+	cancelRetryInterval = 50 * time.Millisecond
+)
+
+// canceller plays the clients that give up on their tasks. It cancels the workflow of
+// each task with a CancelAfter delay once the delay has passed since the task arrived:
+// DBOS removes the task from the queue if it is still waiting, and stops it at its next
+// step if it is running, so work in progress isn't interrupted. It abandons each task
+// with a Patience that hasn't started once its patience has run out, in a single update
+// that only cancels the workflow if it is still enqueued.
+type canceller struct {
+	ctx   dbos.DBOSContext
+	pool  *pgxpool.Pool // Connections abandoning tasks, nil when clients are patient
+	runID string
+
+	mu        sync.Mutex
+	timers    []*time.Timer
+	started   map[int]time.Time // Start of the tasks cancelled while running
+	abandoned map[int]bool
+	err       error
+	wg        sync.WaitGroup
+}
+
+// newCanceller returns a canceller for the run's tasks. It connects to Postgres if
+// clients abandon tasks.
+func newCanceller(ctx dbos.DBOSContext, runID string, abandon bool, monitor *poolMonitor) (*canceller, error) {
+	c := &canceller{ctx: ctx, runID: runID, started: make(map[int]time.Time), abandoned: make(map[int]bool)}
+	if !abandon {
+		return c, nil
+	}
+	pool, err := newPool(context.Background(), AppConfig.Database)
+	if err != nil {
+		return nil, err
+	}
+	monitor.Add("abandon", pool)
+	c.pool = pool
+	return c, nil
+}
+
+// Schedule arranges the cancellation of an enqueued task if its client gives up on it,
+// and its abandonment if its client runs out of patience
+func (c *canceller) Schedule(task Task) {
+	if task.CancelAfter > 0 {
+		c.after(task.ArrivalTime.Add(task.CancelAfter), func() { c.cancel(task.TaskID) })
+	}
+	if task.Patience > 0 && c.pool != nil {
+		c.after(task.ArrivalTime.Add(task.Patience), func() { c.abandon(task.TaskID) })
+	}
+}
+
+func (c *canceller) after(at time.Time, f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wg.Add(1)
+	c.timers = append(c.timers, time.AfterFunc(time.Until(at), func() {
+		defer c.wg.Done()
+		f()
+	}))
+}
+
+// cancel cancels a task's workflow. DBOS clears the start time of cancelled workflows,
+// so the start of a running task is looked up first.
+func (c *canceller) cancel(taskID int) {
+	workflowID := taskWorkflowID(c.runID, taskID)
+	wf, err := c.lookup(workflowID)
+	if err == nil {
+		err = dbos.CancelWorkflow(c.ctx, workflowID)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.fail(fmt.Errorf("failed to cancel task workflow %s: %w", workflowID, err))
+		return
+	}
+	if wf.Status == dbos.WorkflowStatusPending {
+		c.started[taskID] = wf.StartedAt
+	}
+}
+
+// abandon cancels a task's workflow if it is still waiting in the queue
+func (c *canceller) abandon(taskID int) {
+	workflowID := taskWorkflowID(c.runID, taskID)
+	var cancelled bool
+	var err error
+	for attempt := 1; ; attempt++ {
+		cancelled, err = cancelEnqueuedWorkflow(context.Background(), c.pool, workflowID)
+		if err != nil || cancelled {
+			break
+		}
+		// Either the task started in time, or its enqueue is still in flight
+		var wf dbos.WorkflowStatus
+		if wf, err = c.lookup(workflowID); err != nil || wf.Status != dbos.WorkflowStatusEnqueued {
+			break
+		}
+		if attempt == cancelRetries {
+			err = fmt.Errorf("still enqueued after %d attempts to cancel it", cancelRetries)
+			break
+		}
+		time.Sleep(cancelRetryInterval)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.fail(fmt.Errorf("failed to abandon task workflow %s: %w", workflowID, err))
+		return
+	}
+	if cancelled {
+		c.abandoned[taskID] = true
+	}
+}
+
+// lookup returns the status of a workflow, waiting briefly for it to be enqueued
+func (c *canceller) lookup(workflowID string) (dbos.WorkflowStatus, error) {
+	for range cancelRetries {
+		workflows, err := dbos.ListWorkflows(c.ctx, dbos.WithWorkflowIDs([]string{workflowID}),
+			dbos.WithLoadInput(false), dbos.WithLoadOutput(false))
+		if err != nil {
+			return dbos.WorkflowStatus{}, err
+		}
+		if len(workflows) > 0 {
+			return workflows[0], nil
+		}
+		time.Sleep(cancelRetryInterval)
+	}
+	return dbos.WorkflowStatus{}, fmt.Errorf("task workflow %s wasn't enqueued", workflowID)
+}
+
+// fail records the first error; c.mu must be held
+func (c *canceller) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Stop drops the cancellations that haven't fired, whose tasks completed first, waits
+// for the ones in progress and closes the canceller's connections. It returns the first
+// error of a cancellation.
+func (c *canceller) Stop() error {
+	c.mu.Lock()
+	for _, timer := range c.timers {
+		if timer.Stop() {
+			c.wg.Done()
+		}
+	}
+	c.timers = nil
+	c.mu.Unlock()
+	c.wg.Wait()
+	if c.pool != nil {
+		c.pool.Close()
+		c.pool = nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Mark completes what the workflows of cancelled tasks don't tell: which ones were
+// abandoned rather than cancelled, and when the tasks cancelled while running started.
+// Those still finish the work they started, since DBOS doesn't interrupt a step.
+func (c *canceller) Mark(tasks []Task) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range tasks {
+		task := &tasks[i]
+		if !task.Cancelled {
+			continue
+		}
+		if c.abandoned[task.TaskID] {
+			task.Cancelled, task.Abandoned = false, true
+			continue
+		}
+		if started, ok := c.started[task.TaskID]; ok {
+			task.StartedAt, task.DequeueTime = started, started
+			task.CompletionTime = started.Add(task.Duration)
+		}
+	}
+}
+
+// decodeCancelledTask turns a cancelled processTask workflow, loaded with its input, back
+// into its Task. The task completes when it was cancelled; the canceller tells which
+// tasks were running by then, see Mark.
+func decodeCancelledTask(wf dbos.WorkflowStatus) (Task, error) {
+	var task Task
+	input, ok := wf.Input.(string)
+	if !ok {
+		return task, fmt.Errorf("cancelled task workflow %s has no input", wf.ID)
+	}
+	if err := json.Unmarshal([]byte(input), &task); err != nil {
+		return task, fmt.Errorf("failed to decode input of task workflow %s: %w", wf.ID, err)
+	}
+	task.Cancelled = true
+	task.Payload = nil
+	task.EnqueuedAt = wf.CreatedAt
+	task.CompletionTime = wf.UpdatedAt
+	task.ExecutorID = wf.ExecutorID
+	return task, nil
+}
+
+// splitCompleted separates the tasks that completed from those that returned no result,
+// as their client cancelled or abandoned them
+func splitCompleted(tasks []Task) (completed, dropped []Task) {
+	for _, task := range tasks {
+		if task.Completed() {
+			completed = append(completed, task)
+		} else {
+			dropped = append(dropped, task)
+		}
+	}
+	return completed, dropped
+}
+
+// CancellationSummary describes the cancellations of a run and their effect on the load
+type CancellationSummary struct {
+	Tasks            int
+	Requested        int           // Tasks whose client gave up
+	WhileWaiting     int           // Cancelled before they were dequeued
+	WhileRunning     int           // Cancelled while running
+	AfterCompletion  int           // Completed before their client gave up
+	OfferedWork      time.Duration // Work of every task
+	WastedWork       time.Duration // Work done for tasks cancelled while running
+	DoneWork         time.Duration // Work actually run, wasted or not
+	AvoidedWork      time.Duration // Work of the tasks cancelled while waiting
+	WastedShareOfRun float64       // Wasted work over the work actually run
+}
+
+// summarizeCancellations counts cancellations by when they hit their task, and the work
+// they saved or wasted
+func summarizeCancellations(tasks []Task) CancellationSummary {
+	s := CancellationSummary{Tasks: len(tasks)}
+	for _, task := range tasks {
+		s.OfferedWork += task.Duration
+		// Abandoned tasks never ran, whether their client would have cancelled them or not
+		if task.Abandoned {
+			continue
+		}
+		if task.CancelAfter > 0 {
+			s.Requested++
+		}
+		switch {
+		case !task.Ran():
+			s.WhileWaiting++
+			s.AvoidedWork += task.Duration
+			continue
+		case task.Cancelled:
+			s.WhileRunning++
+			s.WastedWork += task.Duration
+		case task.CancelAfter > 0:
+			s.AfterCompletion++
+		}
+		s.DoneWork += task.Duration
+	}
+	if s.DoneWork > 0 {
+		s.WastedShareOfRun = float64(s.WastedWork) / float64(s.DoneWork)
+	}
+	return s
+}
+
+// printCancellationReport prints how many tasks their clients cancelled, how much work
+// that avoided or wasted, and the load the queue actually ran compared to the offered
+// load. It prints nothing when no client gave up.
+func printCancellationReport(tasks []Task, targetUtilization float64) {
+	s := summarizeCancellations(tasks)
+	if s.Requested == 0 {
+		return
+	}
+	fmt.Printf("\nCancellations:\n")
+	fmt.Printf("  %d/%d clients gave up on their task (%.1f%%): %d cancelled while waiting, %d while running, %d too late\n",
+		s.Requested, s.Tasks, 100*float64(s.Requested)/float64(s.Tasks), s.WhileWaiting, s.WhileRunning, s.AfterCompletion)
+	fmt.Printf("  Work avoided: %.3f s, work wasted on cancelled tasks: %.3f s (%.1f%% of the work run)\n",
+		s.AvoidedWork.Seconds(), s.WastedWork.Seconds(), 100*s.WastedShareOfRun)
+	if s.OfferedWork > 0 {
+		effective := targetUtilization * float64(s.DoneWork) / float64(s.OfferedWork)
+		useful := targetUtilization * float64(s.DoneWork-s.WastedWork) / float64(s.OfferedWork)
+		fmt.Printf("  Effective load: %.1f%% utilization run (%.1f%% useful) of the %.0f%% offered\n",
+			effective*100, useful*100, targetUtilization*100)
+	}
+}
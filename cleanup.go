@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+)
+
+// unfinishedStatuses are the workflow states a leftover task can be stuck in: still
+// queued, or claimed by an executor that went away
+var unfinishedStatuses = []dbos.WorkflowStatusType{
+	dbos.WorkflowStatusEnqueued,
+	dbos.WorkflowStatusPending,
+}
+
+// cleanupCommand cancels the tasks that aborted runs left behind, so their backlog isn't
+// picked up by the executors of the next experiment. It covers the queues of every
+// algorithm, including the queues of their lanes, and their benchmark queues, the stage
+// queues of the configured pipeline, the dead-letter queue, the notify task table, the
+// io work scratch table, and the saved state of the runs it cleans up.
+func cleanupCommand(args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	algo := fs.String("algo", "", "Only clean up the queues of this algorithm ("+algorithmNames()+"; default all)")
+	run := fs.String("run", "", "Only clean up tasks whose workflow ID starts with this run ID or prefix (default the run tag)")
+	olderThan := fs.Duration("older-than", 0, "Only clean up tasks enqueued at least this long ago, to spare a run in progress")
+	dryRun := fs.Bool("dry-run", false, "List what would be cleaned up without changing anything")
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if err := validateForRun(); err != nil {
+		return err
+	}
+
+	names := sortedKeys(algorithms)
+	if *algo != "" {
+		if _, err := lookupPolicy(*algo); err != nil {
+			return err
+		}
+		names = []string{*algo}
+	}
+	var queues []string
+	for _, name := range names {
+		policy, _ := lookupPolicy(name)
+		queues = append(queues, policyQueueNames(policy, policy.QueueName)...)
+		queues = append(queues, policyQueueNames(policy, "bench_"+policy.QueueName)...)
+		for k := 1; k < len(AppConfig.Pipeline.Stages); k++ {
+			queues = append(queues, stageQueueName(policy, k))
+		}
+	}
+	queues = append(queues, deadLetterQueueName)
+	cutoff := time.Now().Add(-*olderThan)
+	if *run == "" {
+		*run = AppConfig.Queue.RunTagPrefix()
+	}
+
+	pool, err := newNotifyPool(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	client, err := dbos.NewClient(context.Background(), dbos.ClientConfig{SystemDBPool: pool})
+	if err != nil {
+		return fmt.Errorf("initializing DBOS client failed: %w", err)
+	}
+	defer client.Shutdown(5 * time.Second)
+
+	// Cancelled workflows are never dequeued, and running ones stop at their next step
+	found, cancelled := 0, 0
+	runs := make(map[string]bool)
+	for _, queue := range queues {
+		opts := []dbos.ListWorkflowsOption{
+			dbos.WithQueueName(queue),
+			dbos.WithStatus(unfinishedStatuses),
+			dbos.WithEndTime(cutoff),
+			dbos.WithLoadInput(false),
+			dbos.WithLoadOutput(false),
+		}
+		if *run != "" {
+			opts = append(opts, dbos.WithWorkflowIDPrefix(*run))
+		}
+		workflows, err := client.ListWorkflows(opts...)
+		if err != nil {
+			return fmt.Errorf("failed to list workflows of %s: %w", queue, err)
+		}
+		found += len(workflows)
+		if len(workflows) > 0 {
+			fmt.Printf("%s: %d unfinished tasks\n", queue, len(workflows))
+		}
+		for _, wf := range workflows {
+			runs[runIDOf(wf.ID)] = true
+			if *dryRun {
+				continue
+			}
+			if err := client.CancelWorkflow(wf.ID); err != nil {
+				return fmt.Errorf("failed to cancel %s: %w", wf.ID, err)
+			}
+			cancelled++
+		}
+	}
+
+	// Tasks of notify runs wait in the task table until a dispatcher claims them
+	var notifyTasks int
+	err = pool.QueryRow(context.Background(),
+		`SELECT count(*) FROM schedq_tasks WHERE queue_name = ANY($1) AND workflow_id LIKE $2 || '%' AND enqueued_at < $3`,
+		queues, *run, cutoff).Scan(&notifyTasks)
+	if err != nil {
+		return fmt.Errorf("failed to count notify tasks: %w", err)
+	}
+	if notifyTasks > 0 && !*dryRun {
+		_, err = pool.Exec(context.Background(),
+			`DELETE FROM schedq_tasks WHERE queue_name = ANY($1) AND workflow_id LIKE $2 || '%' AND enqueued_at < $3`,
+			queues, *run, cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to remove notify tasks: %w", err)
+		}
+	}
+
+	// Interrupted io-bound tasks leave their rows in the scratch table, if there is one
+	var scratchRows int
+	var hasScratch bool
+	if err := pool.QueryRow(context.Background(), `SELECT to_regclass('schedq_scratch') IS NOT NULL`).Scan(&hasScratch); err != nil {
+		return fmt.Errorf("failed to look up the scratch table: %w", err)
+	}
+	if hasScratch {
+		err = pool.QueryRow(context.Background(),
+			`SELECT count(*) FROM schedq_scratch WHERE owner LIKE $1 || '%' AND created_at < $2`, *run, cutoff).Scan(&scratchRows)
+		if err != nil {
+			return fmt.Errorf("failed to count scratch rows: %w", err)
+		}
+		if scratchRows > 0 && !*dryRun {
+			_, err = pool.Exec(context.Background(),
+				`DELETE FROM schedq_scratch WHERE owner LIKE $1 || '%' AND created_at < $2`, *run, cutoff)
+			if err != nil {
+				return fmt.Errorf("failed to remove scratch rows: %w", err)
+			}
+		}
+	}
+
+	// Runs whose tasks were cancelled can't be resumed meaningfully anymore
+	var states []string
+	for runID := range runs {
+		if _, err := os.Stat(runStatePath(runID)); err == nil {
+			states = append(states, runID)
+		}
+	}
+	if !*dryRun {
+		for _, runID := range states {
+			removeRunState(runID)
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("\nDry run: would cancel %d workflows, remove %d notify tasks, %d scratch rows and the state of %d runs\n",
+			found, notifyTasks, scratchRows, len(states))
+		return nil
+	}
+	fmt.Printf("\nCancelled %d workflows, removed %d notify tasks, %d scratch rows and the state of %d runs\n",
+		cancelled, notifyTasks, scratchRows, len(states))
+	return nil
+}
+
+// runIDOf returns the run ID of a task workflow ID, see taskWorkflowID
+func runIDOf(workflowID string) string {
+	if i := strings.LastIndex(workflowID, "-"); i >= 0 {
+		return workflowID[:i]
+	}
+	return workflowID
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"fifo-queue-demo/sched"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+)
+
+// clientRetrier plays clients that time out on their tasks: when an attempt hasn't
+// finished by the client's timeout, it enqueues a new attempt of the same request after
+// the backoff, as a task with a new ID. Timed-out attempts keep running.
+type clientRetrier struct {
+	ctx      dbos.DBOSContext
+	runID    string
+	policy   *sched.ClientRetry
+	enqueuer *enqueuer
+
+	mu     sync.Mutex
+	nextID int // ID of the next retry, above the IDs of every first attempt
+	err    error
+	wg     sync.WaitGroup
+}
+
+func newClientRetrier(ctx dbos.DBOSContext, runID string, policy *sched.ClientRetry, enqueuer *enqueuer, firstID int) *clientRetrier {
+	return &clientRetrier{ctx: ctx, runID: runID, policy: policy, enqueuer: enqueuer, nextID: firstID}
+}
+
+// Track watches an attempt the client sent, and resends it if it times out
+func (r *clientRetrier) Track(attempt Task) {
+	if attempt.ClientAttempt >= r.policy.Backoff.MaxRetries {
+		return
+	}
+	r.wg.Add(1)
+	time.AfterFunc(time.Until(attempt.ArrivalTime.Add(r.policy.Timeout)), func() {
+		defer r.wg.Done()
+		workflows, err := dbos.ListWorkflows(r.ctx,
+			dbos.WithWorkflowIDs([]string{taskWorkflowID(r.runID, attempt.TaskID)}),
+			dbos.WithStatus(finishedStatuses),
+			dbos.WithLoadInput(false), dbos.WithLoadOutput(false))
+		if err != nil {
+			r.mu.Lock()
+			if r.err == nil {
+				r.err = fmt.Errorf("failed to check task %d for a client timeout: %w", attempt.TaskID, err)
+			}
+			r.mu.Unlock()
+			return
+		}
+		if len(workflows) > 0 {
+			return
+		}
+		r.wg.Add(1)
+		time.AfterFunc(time.Until(r.policy.ResendAt(attempt)), func() {
+			defer r.wg.Done()
+			r.mu.Lock()
+			retry, _ := r.policy.Resend(attempt, r.nextID)
+			r.nextID++
+			r.mu.Unlock()
+			retry.ArrivalTime = monotonicNow()
+			r.enqueuer.Submit(retry)
+			r.Track(retry)
+		})
+	})
+}
+
+// Wait waits until every request has completed in time or was sent for the last time,
+// and returns the first error of a timeout check
+func (r *clientRetrier) Wait() error {
+	r.wg.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ClientRetrySummary describes how client retries amplified the load of a run
+type ClientRetrySummary struct {
+	Requests    int
+	Attempts    int
+	Retried     int             // Requests sent more than once
+	TimedOut    int             // Attempts the client gave up on
+	OfferedWork time.Duration   // Work of the requests, sent once each
+	SentWork    time.Duration   // Work of every attempt
+	WastedWork  time.Duration   // Work of the attempts the client gave up on
+	Response    ResponseSummary // From the first attempt of a request to the result the client took
+}
+
+// Amplification returns how many times the requests' work the clients sent
+func (s ClientRetrySummary) Amplification() float64 {
+	if s.OfferedWork == 0 {
+		return 1
+	}
+	return float64(s.SentWork) / float64(s.OfferedWork)
+}
+
+// summarizeClientRetries groups the attempts of each request and sums up the load they
+// added. A request's response time runs from its first attempt to the completion of the
+// attempt the client didn't time out on.
+func summarizeClientRetries(tasks []Task) ClientRetrySummary {
+	var s ClientRetrySummary
+	type request struct {
+		first, taken Task
+		attempts     int
+	}
+	requests := make(map[int]*request)
+	for _, task := range tasks {
+		s.Attempts++
+		s.SentWork += task.Duration
+		if task.TimedOut {
+			s.TimedOut++
+			s.WastedWork += task.Duration
+		}
+		req := requests[task.Request()]
+		if req == nil {
+			req = &request{}
+			requests[task.Request()] = req
+		}
+		req.attempts++
+		if task.ClientAttempt == 0 {
+			req.first = task
+			s.OfferedWork += task.Duration
+		}
+		if !task.TimedOut {
+			req.taken = task
+		}
+	}
+
+	var responses []Task
+	for _, req := range requests {
+		s.Requests++
+		if req.attempts > 1 {
+			s.Retried++
+		}
+		response := req.taken
+		response.ArrivalTime = req.first.ArrivalTime
+		responses = append(responses, response)
+	}
+	s.Response = summarizeResponseTimes(responses, nil)
+	return s
+}
+
+// printClientRetryReport prints how much load the clients' retries added, how much of it
+// was wasted on attempts they had given up on, and the response time they saw. It prints
+// nothing when clients don't time out.
+func printClientRetryReport(tasks []Task, cfg ClientRetryConfig, targetUtilization float64) {
+	if cfg.TimeoutMs == 0 {
+		return
+	}
+	s := summarizeClientRetries(tasks)
+	fmt.Printf("\nClient retries (timeout %v, up to %d retries):\n", cfg.Timeout(), cfg.MaxRetries)
+	fmt.Printf("  %d requests sent as %d attempts: %d requests retried, %d attempts timed out\n",
+		s.Requests, s.Attempts, s.Retried, s.TimedOut)
+	fmt.Printf("  Load amplified x%.2f: %.0f%% utilization offered by the requests, %.0f%% sent\n",
+		s.Amplification(), targetUtilization*100, targetUtilization*s.Amplification()*100)
+	if s.SentWork > 0 {
+		fmt.Printf("  Work wasted on timed-out attempts: %.3f s (%.1f%% of the work sent)\n",
+			s.WastedWork.Seconds(), 100*float64(s.WastedWork)/float64(s.SentWork))
+	}
+	fmt.Printf("  Request response time: mean %s ms, p99 %s ms, max %s ms\n",
+		formatMs(s.Response.Mean), formatMs(s.Response.P99), formatMs(s.Response.Max))
+}
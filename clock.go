@@ -0,0 +1,36 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// clockAnchor ties the harness's clock to the wall clock, once, at startup
+var clockAnchor = time.Now()
+
+// spinMargin is how long before a precise deadline waits stop sleeping and spin instead:
+// enough to absorb how late the runtime's timers fire
+const spinMargin = 200 * time.Microsecond
+
+// monotonicNow returns the current time as read from the monotonic clock: the wall clock
+// time at startup plus the monotonic time elapsed since. Timing is captured with it, so
+// timestamps never jump when the system clock is adjusted and stay consistent with each
+// other at microsecond scale, even once serialized with their task, which drops the
+// monotonic reading of time.Now.
+func monotonicNow() time.Time {
+	return clockAnchor.Add(time.Since(clockAnchor))
+}
+
+// sleepPrecisely waits for d, sleeping for most of it and spinning on the monotonic
+// clock for the rest, for the microsecond accuracy timers alone don't provide. It keeps
+// a CPU core busy for the last spinMargin.
+func sleepPrecisely(d time.Duration) {
+	deadline := monotonicNow().Add(d)
+	if d > spinMargin {
+		time.Sleep(d - spinMargin)
+	}
+	for monotonicNow().Before(deadline) {
+		// Let other goroutines run meanwhile, such as the producer on few cores
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// activeColdStart is the pool of workers of the run in progress when workers pay a
+// cold-start setup, nil otherwise
+var activeColdStart atomic.Pointer[coldStartPool]
+
+// coldStartPool tracks what the free workers last ran, so that each task can take the
+// free worker it has the least setup on. Workers are created as tasks need them, cold,
+// and the workers of every executor form one pool.
+type coldStartPool struct {
+	model *sched.ColdStart
+
+	mu   sync.Mutex
+	free []sched.Worker
+}
+
+func newColdStartPool(model *sched.ColdStart) *coldStartPool {
+	return &coldStartPool{model: model}
+}
+
+// Acquire takes the free worker the task has the least setup on, or a new one, and
+// returns the setup the task pays first. release gives the worker back once the task
+// completes.
+func (p *coldStartPool) Acquire(task Task) (setup time.Duration, release func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var worker sched.Worker
+	if best := p.model.Pick(p.free, task, now); best >= 0 {
+		worker = p.free[best]
+		p.free = slices.Delete(p.free, best, best+1)
+	}
+	setup = p.model.Setup(worker, task, now)
+	return setup, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.free = append(p.free, sched.Worker{Class: p.model.Class(task), IdleAt: time.Now()})
+	}
+}
+
+// printColdStartReport prints, for each class of tasks, how many of its tasks paid a
+// setup, how long it took, and how much of the worker time went to setups rather than
+// work. It prints nothing when workers pay no setup.
+func printColdStartReport(model *sched.ColdStart, tasks []Task) {
+	if model == nil || len(tasks) == 0 {
+		return
+	}
+	type classStats struct {
+		tasks, setups int
+		setup, busy   time.Duration
+	}
+	stats := make(map[string]*classStats)
+	var total classStats
+	for _, task := range tasks {
+		class := model.Class(task)
+		s := stats[class]
+		if s == nil {
+			s = &classStats{}
+			stats[class] = s
+		}
+		busy := task.CompletionTime.Sub(task.DequeueTime)
+		for _, s := range []*classStats{s, &total} {
+			s.tasks++
+			s.busy += busy
+			s.setup += task.Setup
+			if task.Setup > 0 {
+				s.setups++
+			}
+		}
+	}
+
+	cfg := AppConfig.ColdStart
+	idle := "never"
+	if cfg.IdleAfterMs > 0 {
+		idle = fmt.Sprintf("after %d ms idle", cfg.IdleAfterMs)
+	}
+	fmt.Printf("\nCold starts (switch %d ms, warm-up %d ms, workers go cold %s):\n", cfg.SwitchMs, cfg.WarmUpMs, idle)
+	fmt.Printf("  %-8s %7s %16s %12s %12s %14s\n", "Class", "Tasks", "Setups", "Setup mean", "Setup total", "Worker time")
+	row := func(name string, s *classStats) {
+		mean := 0.0
+		if s.setups > 0 {
+			mean = float64(s.setup.Microseconds()) / 1000 / float64(s.setups)
+		}
+		share := 0.0
+		if s.busy > 0 {
+			share = 100 * s.setup.Seconds() / s.busy.Seconds()
+		}
+		fmt.Printf("  %-8s %7d %7d (%5.1f%%) %12.1f %11.1fs %13.1f%%\n", name, s.tasks, s.setups,
+			100*float64(s.setups)/float64(s.tasks), mean, s.setup.Seconds(), share)
+	}
+	for _, class := range sortedKeys(stats) {
+		row(class, stats[class])
+	}
+	row("all", &total)
+	fmt.Println("  (setup mean in ms, over the tasks that paid one; worker time: share of the time tasks")
+	fmt.Println("   held a worker slot spent on setup)")
+}
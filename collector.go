@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	resultPollInterval = 200 * time.Millisecond
+	resultBatchSize    = 500   // Workflow IDs looked up per ListWorkflows call
+	resultScanWindow   = 10000 // Pending workflows checked per poll
+)
+
+// taskWorkflowID returns the workflow ID of a task within a run. Deriving IDs from the
+// run and task IDs means the producer only needs to remember which tasks it enqueued.
+func taskWorkflowID(runID string, taskID int) string {
+	return fmt.Sprintf("%s-%d", runID, taskID)
+}
+
+// resultCollector gathers task results by polling DBOS for finished workflows, instead
+// of blocking on one handle at a time. Results are picked up in completion order, so a
+// slow early task doesn't hold back everything that finished after it.
+type resultCollector struct {
+	ctx dbos.DBOSContext
+
+	// discard drops each task once onResult has seen it, so streamed runs don't keep
+	// every task in memory. Collect then returns no tasks.
+	discard bool
+
+	// abort, if set, stops the collection once closed: Collect then returns the tasks
+	// that finished so far and leaves the others out
+	abort <-chan struct{}
+
+	// steps, if set, reads the step timings of each batch of finished workflows
+	steps *pgxpool.Pool
+}
+
+func newResultCollector(ctx dbos.DBOSContext) *resultCollector {
+	return &resultCollector{ctx: ctx}
+}
+
+// Collect waits until the workflows of every task in taskIDs have finished and returns
+// the tasks they produced, ordered by task ID. onResult, if set, sees each task as soon
+// as it is picked up, and the progress callback is invoked after each poll.
+//
+// Each poll checks a bounded window of pending tasks so database load stays flat for very
+// large runs: half of the window always covers the oldest pending tasks, the other half
+// rotates through the rest so tasks that overtook them are found too.
+func (c *resultCollector) Collect(runID string, taskIDs []int, onResult func(Task) error, progress func(done, total int)) ([]Task, error) {
+	total := len(taskIDs)
+	var tasks []Task
+	if !c.discard {
+		tasks = make([]Task, 0, total)
+	}
+	pending := taskIDs
+	cursor := 0
+
+	for len(pending) > 0 {
+		// Pick the task IDs to check this round
+		var window []int
+		if len(pending) <= resultScanWindow {
+			window = pending
+		} else {
+			half := resultScanWindow / 2
+			if cursor < half || cursor >= len(pending) {
+				cursor = half
+			}
+			end := min(cursor+half, len(pending))
+			window = append(append(window, pending[:half]...), pending[cursor:end]...)
+			cursor = end
+		}
+
+		finished := make(map[int]bool)
+		for start := 0; start < len(window); start += resultBatchSize {
+			end := min(start+resultBatchSize, len(window))
+			ids := make([]string, 0, end-start)
+			for _, taskID := range window[start:end] {
+				ids = append(ids, resultWorkflowID(runID, taskID))
+			}
+			workflows, err := dbos.ListWorkflows(c.ctx,
+				dbos.WithWorkflowIDs(ids),
+				dbos.WithStatus(finishedStatuses),
+				dbos.WithLoadInput(false))
+			if err != nil {
+				return nil, fmt.Errorf("failed to poll task results: %w", err)
+			}
+			workflows, err = c.withCancelled(workflows)
+			if err != nil {
+				return nil, err
+			}
+			batch, err := c.decodeBatch(workflows)
+			if err != nil {
+				return nil, err
+			}
+			for _, task := range batch {
+				if onResult != nil {
+					if err := onResult(task); err != nil {
+						return nil, err
+					}
+				}
+				if !c.discard {
+					tasks = append(tasks, task)
+				}
+				finished[task.TaskID] = true
+			}
+		}
+
+		// Keep only the tasks that are still running
+		if len(finished) > 0 {
+			remaining := pending[:0]
+			for _, taskID := range pending {
+				if !finished[taskID] {
+					remaining = append(remaining, taskID)
+				}
+			}
+			pending = remaining
+		}
+		if progress != nil {
+			progress(total-len(pending), total)
+		}
+		if len(pending) > 0 {
+			select {
+			case <-c.abort:
+				pending = nil
+			case <-time.After(resultPollInterval):
+			}
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].TaskID < tasks[j].TaskID })
+	return tasks, nil
+}
+
+// finishedStatuses are the terminal workflow states
+var finishedStatuses = []dbos.WorkflowStatusType{
+	dbos.WorkflowStatusSuccess,
+	dbos.WorkflowStatusError,
+	dbos.WorkflowStatusCancelled,
+	dbos.WorkflowStatusMaxRecoveryAttemptsExceeded,
+}
+
+// CollectRange reads the results of every task enqueued on queueName, or any queue if
+// empty, between since and until straight from the DBOS system database behind pool,
+// without needing the workflow IDs. The prefix restricts it to the tasks of one run or
+// run tag. Zero times don't restrict anything. Unfinished tasks are skipped, see
+// countUnfinished.
+func (c *resultCollector) CollectRange(pool *pgxpool.Pool, queueName, prefix string, since, until time.Time) ([]Task, error) {
+	var tasks []Task
+	var after workflowKey
+	for {
+		ids, last, err := pageFinished(context.Background(), pool, queueName, prefix, since, until, after, resultBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task results: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		after = last
+		workflows, err := dbos.ListWorkflows(c.ctx, dbos.WithWorkflowIDs(ids), dbos.WithLoadInput(false))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task results: %w", err)
+		}
+		workflows, err = c.withCancelled(workflows)
+		if err != nil {
+			return nil, err
+		}
+		batch, err := c.decodeBatch(workflows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, batch...)
+		if len(ids) < resultBatchSize {
+			break
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].TaskID < tasks[j].TaskID })
+	return tasks, nil
+}
+
+// withCancelled reloads the cancelled workflows among workflows with their input, as
+// they have no output to decode the task from
+func (c *resultCollector) withCancelled(workflows []dbos.WorkflowStatus) ([]dbos.WorkflowStatus, error) {
+	var ids []string
+	for _, wf := range workflows {
+		if wf.Status == dbos.WorkflowStatusCancelled {
+			ids = append(ids, wf.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return workflows, nil
+	}
+	cancelled, err := dbos.ListWorkflows(c.ctx, dbos.WithWorkflowIDs(ids), dbos.WithLoadInput(true), dbos.WithLoadOutput(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cancelled tasks: %w", err)
+	}
+	reloaded := workflows[:0]
+	for _, wf := range workflows {
+		if wf.Status != dbos.WorkflowStatusCancelled {
+			reloaded = append(reloaded, wf)
+		}
+	}
+	return append(reloaded, cancelled...), nil
+}
+
+// decodeBatch turns finished processTask workflows back into their tasks, with the
+// timings of their steps if the collector reads them
+func (c *resultCollector) decodeBatch(workflows []dbos.WorkflowStatus) ([]Task, error) {
+	tasks := make([]Task, len(workflows))
+	byID := make(map[string]*Task, len(workflows))
+	for i, wf := range workflows {
+		task, err := decodeTask(wf)
+		if err != nil {
+			return nil, err
+		}
+		tasks[i] = task
+		byID[wf.ID] = &tasks[i]
+	}
+	if c.steps != nil {
+		if err := readStepTimings(context.Background(), c.steps, byID); err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+// decodeTask turns a finished processTask workflow back into its Task, from its input if
+// it was cancelled and from its output otherwise
+func decodeTask(wf dbos.WorkflowStatus) (Task, error) {
+	if wf.Status == dbos.WorkflowStatusCancelled {
+		return decodeCancelledTask(wf)
+	}
+	return decodeTaskOutput(wf)
+}
+
+// decodeTaskOutput turns a finished processTask workflow back into its Task
+func decodeTaskOutput(wf dbos.WorkflowStatus) (Task, error) {
+	var task Task
+	if wf.Status != dbos.WorkflowStatusSuccess {
+		return task, fmt.Errorf("task workflow %s finished with status %s: %v", wf.ID, wf.Status, wf.Error)
+	}
+	output, ok := wf.Output.(string)
+	if !ok {
+		return task, fmt.Errorf("task workflow %s has no output", wf.ID)
+	}
+	if err := json.Unmarshal([]byte(output), &task); err != nil {
+		return task, fmt.Errorf("failed to decode output of task workflow %s: %w", wf.ID, err)
+	}
+	task.ExecutorID = wf.ExecutorID
+	// The workflow was enqueued when DBOS recorded it, and claimed when an executor
+	// dequeued it, unless the notify dispatcher already stamped its own task table's times.
+	// The workflow of the last stage of a pipeline doesn't tell when the task entered it.
+	if len(task.Stages) > 0 {
+		return task, nil
+	}
+	if task.EnqueuedAt.IsZero() {
+		task.EnqueuedAt = wf.CreatedAt
+	}
+	if task.StartedAt.IsZero() {
+		task.StartedAt = wf.StartedAt
+	}
+	return task, nil
+}
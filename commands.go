@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+)
+
+// Command is a subcommand of the tool
+type Command struct {
+	Description string
+	Run         func(args []string) error
+}
+
+// commands maps subcommand names to their implementations
+var commands = map[string]Command{
+	"bench": {
+		Description: "Measure the enqueue and dequeue overhead of Postgres (bench enqueue|dequeue)",
+		Run:         benchCommand,
+	},
+	"cleanup": {
+		Description: "Cancel the leftover tasks of aborted runs so they don't pollute the next experiment",
+		Run:         cleanupCommand,
+	},
+	"collect": {
+		Description: "Build the results CSV of an earlier run from the DBOS system database",
+		Run:         collectCommand,
+	},
+	"compare": {
+		Description: "Compare the latency of results CSV files side by side",
+		Run:         compareCommand,
+	},
+	"list-algos": {
+		Description: "List the available scheduling algorithms",
+		Run:         listAlgosCommand,
+	},
+	"plot": {
+		Description: "Plot results CSV files with plot_results.py",
+		Run:         plotCommand,
+	},
+	"run": {
+		Description: "Run an experiment with one algorithm or a canned scenario (default command)",
+		Run:         runCommand,
+	},
+	"serve": {
+		Description: "Serve a queue behind an HTTP API that takes tasks from external clients",
+		Run:         serveCommand,
+	},
+	"validate-config": {
+		Description: "Check the configuration and print the effective values",
+		Run:         validateConfigCommand,
+	},
+	"work": {
+		Description: "Serve a queue with executors only, until interrupted",
+		Run:         workCommand,
+	},
+}
+
+// runCommand runs an experiment: one algorithm, a canned scenario, or a dry run that
+// only generates the workload
+func runCommand(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	algo := fs.String("algo", "fcfs", "Scheduling algorithm to use ("+algorithmNames()+")")
+	scenario := fs.String("scenario", "", "Run a canned scenario instead of a single algorithm ("+strings.Join(scenarioNames(), ", ")+"), or a scenario file (.yaml)")
+	noWait := fs.Bool("no-wait", false, "Exit once all tasks are enqueued; gather results later with the collect command")
+	dryRunFlag := fs.Bool("dry-run", false, "Print the generated workload schedule and its offered load without enqueuing anything")
+	dryRunOut := fs.String("dry-run-out", "", "With -dry-run, write the schedule to this CSV file instead of printing it")
+	resume := fs.String("resume", "", "Continue the interrupted run with this run ID, with the configuration it started with")
+	golden := fs.Bool("golden", false, "Run every algorithm on the fixed-seed golden workload with the simulation backend and compare with the golden metrics")
+	goldenUpdate := fs.Bool("golden-update", false, "Like -golden, but write the current metrics as the new golden metrics")
+	goldenFile := fs.String("golden-file", defaultGoldenPath, "File holding the golden metrics")
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if *noWait {
+		AppConfig.Producer.NoWait = true
+	}
+
+	// Golden runs bring their own workload and never touch the database
+	if *golden || *goldenUpdate {
+		return runGolden(*goldenFile, *goldenUpdate)
+	}
+
+	// A dry run never touches the database, so only the configuration is checked
+	if *dryRunFlag {
+		if err := AppConfig.Validate(); err != nil {
+			return err
+		}
+		policy, err := lookupPolicy(*algo)
+		if err != nil {
+			return err
+		}
+		return dryRun(policy, AppConfig.Queue, *dryRunOut)
+	}
+
+	// A resumed run brings its own configuration and algorithm
+	if *resume != "" {
+		return resumeRun(*resume)
+	}
+
+	// Without a database, runs fall back to the in-process simulation so first-time users
+	// get results right away
+	if AppConfig.Database.Mode == "external" && databaseURL() == "" {
+		AppConfig.Database.Mode = "simulated"
+		printSimulationBanner()
+	}
+	if AppConfig.Database.Mode == "simulated" {
+		if err := AppConfig.Validate(); err != nil {
+			return err
+		}
+		if AppConfig.Producer.NoWait {
+			return fmt.Errorf("-no-wait needs Postgres to hold the enqueued tasks; it can't be used in simulated mode")
+		}
+	} else if err := validateForRun(); err != nil {
+		return err
+	}
+
+	// Scenarios drive their own runs
+	if *scenario != "" {
+		return runScenario(*scenario)
+	}
+
+	// Run the selected algorithm
+	policy, err := lookupPolicy(*algo)
+	if err != nil {
+		return err
+	}
+	_, err = runExperiment(policy, AppConfig.Queue, "")
+	return err
+}
+
+// validateConfigCommand validates the configuration without running anything, and
+// prints the effective value of every field after profiles, environment and flags
+func validateConfigCommand(args []string) error {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if err := AppConfig.Validate(); err != nil {
+		return err
+	}
+
+	fmt.Println("Effective configuration:")
+	for _, field := range configFields(&AppConfig) {
+		fmt.Printf("  %-40s %v\n", field.Key, field.Value.Interface())
+	}
+	for i, slo := range AppConfig.SLOs {
+		fmt.Printf("  slos[%d]: %s p%g %s < %dms\n", i, slo.Class, slo.Percentile, slo.Metric, slo.ThresholdMs)
+	}
+	if err := checkDatabaseURL(); err != nil {
+		fmt.Printf("\nWarning: %v\n", err)
+	}
+	fmt.Println("\nConfiguration is valid")
+	return nil
+}
+
+// collectCommand builds the results CSV of an earlier run from the DBOS system database.
+// It lets producers started with -no-wait exit as soon as their tasks are enqueued.
+func collectCommand(args []string) error {
+	fs := flag.NewFlagSet("collect", flag.ExitOnError)
+	algo := fs.String("algo", "fcfs", "Scheduling algorithm whose queue to collect ("+algorithmNames()+")")
+	run := fs.String("run", "", "Collect the tasks of this run ID, or of every run whose ID starts with it (default the run tag)")
+	since := fs.String("since", "", "Collect tasks enqueued at or after this time (RFC3339; required without -run)")
+	until := fs.String("until", "", "Collect tasks enqueued before this time (RFC3339, optional)")
+	wait := fs.Bool("wait", false, "Wait until every task in the range has finished before collecting")
+	label := fs.String("label", "", "Label appended to the algorithm name in the results file")
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if err := validateForRun(); err != nil {
+		return err
+	}
+
+	policy, err := lookupPolicy(*algo)
+	if err != nil {
+		return err
+	}
+	prefix := *run
+	if prefix == "" {
+		prefix = AppConfig.Queue.RunTagPrefix()
+	}
+	if *since == "" && prefix == "" {
+		return fmt.Errorf("-since or -run is required")
+	}
+
+	// Notify dispatch starts workflows outside DBOS queues, and lanes spread tasks over
+	// several, so only the run prefix finds them. Pipeline tasks finish in the queue of
+	// the last stage.
+	queueName := stageQueueName(policy, max(0, len(AppConfig.Pipeline.Stages)-1))
+	if AppConfig.Queue.Dispatch == "notify" || policy.Lanes != nil {
+		if prefix == "" {
+			return fmt.Errorf("-run is required to collect tasks dispatched with notify or spread over lanes")
+		}
+		queueName = ""
+	}
+	var sinceTime time.Time
+	if *since != "" {
+		if sinceTime, err = time.Parse(time.RFC3339Nano, *since); err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	var untilTime time.Time
+	if *until != "" {
+		if untilTime, err = time.Parse(time.RFC3339Nano, *until); err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+
+	// This context only reads the system database: it registers no queue, so it runs no tasks
+	pool, err := newPool(context.Background(), AppConfig.Database)
+	if err != nil {
+		return err
+	}
+	dbosContext, err := dbos.NewDBOSContext(context.Background(), dbos.Config{
+		AppName:      policy.Name + "-collector",
+		SystemDBPool: pool,
+	})
+	if err != nil {
+		pool.Close()
+		return fmt.Errorf("initializing DBOS failed: %w", err)
+	}
+	if err := dbos.Launch(dbosContext); err != nil {
+		return fmt.Errorf("launching DBOS failed: %w", err)
+	}
+	defer dbos.Shutdown(dbosContext, 5*time.Second)
+
+	collector := newResultCollector(dbosContext)
+	collector.steps = pool
+	for {
+		unfinished, err := countUnfinished(context.Background(), pool, queueName, prefix, sinceTime, untilTime)
+		if err != nil {
+			return fmt.Errorf("failed to count unfinished tasks: %w", err)
+		}
+		if unfinished == 0 {
+			break
+		}
+		if !*wait {
+			fmt.Printf("Warning: %d tasks are still queued or running and will be missing from the results\n", unfinished)
+			break
+		}
+		fmt.Printf("  Waiting for %d unfinished tasks...\n", unfinished)
+		time.Sleep(time.Second)
+	}
+
+	tasks, err := collector.CollectRange(pool, queueName, prefix, sinceTime, untilTime)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Collected %d finished tasks from %s\n", len(tasks), policy.QueueName)
+	allTasks := tasks
+	tasks, dropped := splitCompleted(tasks)
+	if len(dropped) > 0 {
+		fmt.Printf("  %d of them were cancelled and are left out of the results\n", len(dropped))
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	filename, err := exportResults(tasks, policy, *label)
+	if err != nil {
+		return err
+	}
+	return exportTaskLogs(allTasks, policy, filename)
+}
+
+// workCommand runs executors serving an algorithm's queue until interrupted, so tasks
+// enqueued by a producer started with -no-wait get processed by separate processes.
+func workCommand(args []string) error {
+	fs := flag.NewFlagSet("work", flag.ExitOnError)
+	algo := fs.String("algo", "fcfs", "Scheduling algorithm whose queue to serve ("+algorithmNames()+")")
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if err := validateForRun(); err != nil {
+		return err
+	}
+
+	policy, err := lookupPolicy(*algo)
+	if err != nil {
+		return err
+	}
+	cluster, err := launchExecutors(policy, AppConfig.Queue)
+	if err != nil {
+		return err
+	}
+	defer cluster.Shutdown()
+
+	fmt.Printf("Serving %s with %d executors. Press Ctrl+C to stop.\n", policy.QueueName, AppConfig.Queue.NumExecutors)
+	if AppConfig.Queue.RunTag != "" {
+		fmt.Printf("Only running tasks tagged %s\n", AppConfig.Queue.RunTag)
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	return nil
+}
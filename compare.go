@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// compareCommand prints the latency of several results CSV files side by side, for
+// instance the results of FCFS and SJF on the same workload
+func compareCommand(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: compare [flags] <results.csv> [results.csv...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no results files given")
+	}
+
+	fmt.Printf("%-40s %7s %12s %12s %12s %12s %12s %12s %13s %8s %14s %14s\n", "Results", "Tasks",
+		"Mean", "p50", "p99", "Short p99", "Long p99", "Wait p99", "Dead letters", "Convoys", "Convoy delay", "HOL blocking")
+	var runs [][]Task
+	for _, filename := range fs.Args() {
+		tasks, err := metrics.ReadResults(filename)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, tasks)
+
+		// Files don't record the workload, so the shortest duration found is the short class
+		var shortDuration time.Duration
+		for i, task := range tasks {
+			if i == 0 || task.Duration < shortDuration {
+				shortDuration = task.Duration
+			}
+		}
+		isShort := func(task Task) bool { return task.Duration == shortDuration }
+		isLong := func(task Task) bool { return task.Duration != shortDuration }
+
+		all := summarizeResponseTimes(tasks, nil)
+		short := summarizeResponseTimes(tasks, isShort)
+		long := summarizeResponseTimes(tasks, isLong)
+		wait := summarizeWaitTimes(tasks, nil)
+		deadLetters := summarizeDeadLetters(tasks).DeadLetters
+		convoys := summarizeConvoys(tasks, isShort, AppConfig.Convoys.MinSize)
+		hol := summarizeHOL(tasks, isShort)[0]
+		fmt.Printf("%-40s %7d %12s %12s %12s %12s %12s %12s %13d %8d %14s %14s\n",
+			strings.TrimSuffix(filepath.Base(filename), ".csv"), len(tasks), formatMs(all.Mean),
+			formatMs(all.Median), formatMs(all.P99), formatMs(short.P99), formatMs(long.P99), formatMs(wait.P99), deadLetters,
+			convoys.Convoys, formatMs(convoys.Delay), formatMs(hol.Cost))
+	}
+	fmt.Println("(response times unless noted, in ms; convoy delay is the short tasks' wait behind convoys,")
+	fmt.Println(" HOL blocking the wait of all tasks while every worker slot ran a longer task)")
+	compareSlowdownBySize(fs.Args(), runs)
+	if len(runs) == 2 {
+		compareFairness(fs.Args(), runs[0], runs[1])
+	}
+	return nil
+}
+
+// slowdownStats holds the response time and slowdown of a class of tasks
+type slowdownStats struct {
+	Tasks        int
+	Response     ResponseSummary
+	MeanSlowdown float64
+	P99Slowdown  float64
+}
+
+func summarizeSlowdowns(tasks []Task, filter func(Task) bool) slowdownStats {
+	stats := slowdownStats{Response: summarizeResponseTimes(tasks, filter)}
+	var slowdowns []float64
+	for _, task := range tasks {
+		if filter == nil || filter(task) {
+			slowdowns = append(slowdowns, slowdown(task))
+			stats.MeanSlowdown += slowdown(task)
+		}
+	}
+	if len(slowdowns) == 0 {
+		return stats
+	}
+	stats.Tasks = len(slowdowns)
+	stats.MeanSlowdown /= float64(len(slowdowns))
+	slices.Sort(slowdowns)
+	stats.P99Slowdown = slowdowns[min(len(slowdowns)-1, int(math.Ceil(0.99*float64(len(slowdowns))))-1)]
+	return stats
+}
+
+// compareFairness weighs two runs of the same workload against each other, typically FCFS
+// and SJF on the same seed: how much better short tasks fare under the second run, how
+// much worse long tasks fare, and what the efficiency gain over all tasks costs in
+// fairness to long tasks. Tasks of the two runs are matched by ID to check that they ran
+// the same workload.
+func compareFairness(filenames []string, baseline, other []Task) {
+	names := make([]string, 2)
+	for i, filename := range filenames[:2] {
+		names[i] = strings.TrimSuffix(filepath.Base(filename), ".csv")
+	}
+
+	// The same seed draws the same tasks, with the same IDs and durations
+	durations := make(map[int]time.Duration, len(baseline))
+	for _, task := range baseline {
+		durations[task.TaskID] = task.Duration
+	}
+	matched := 0
+	distinct := make(map[time.Duration]bool)
+	for _, task := range other {
+		if d, ok := durations[task.TaskID]; ok && d == task.Duration {
+			matched++
+		}
+		distinct[task.Duration] = true
+	}
+	for _, d := range durations {
+		distinct[d] = true
+	}
+
+	// A short/long mix has two sizes, the smaller one short; other workloads are split at
+	// the configured short task duration
+	isShort := func(task Task) bool { return AppConfig.Workload.IsShort(task.Duration) }
+	if len(distinct) == 2 {
+		shortest := time.Duration(math.MaxInt64)
+		for d := range distinct {
+			shortest = min(shortest, d)
+		}
+		isShort = func(task Task) bool { return task.Duration == shortest }
+	}
+	isLong := func(task Task) bool { return !isShort(task) }
+
+	fmt.Printf("\nFairness vs efficiency: %s against %s\n", names[1], names[0])
+	if matched < len(baseline) || matched < len(other) {
+		fmt.Printf("  Warning: only %d of the %d and %d tasks have the same ID and duration in both runs, which didn't run the same workload (same seed)\n",
+			matched, len(baseline), len(other))
+	}
+	fmt.Printf("  %-22s %14s %14s %14s %10s\n", "", "Baseline", "Other", "Change", "Change %")
+	change := func(label string, a, b float64, unit string, format string) {
+		relative := "-"
+		if a != 0 {
+			relative = fmt.Sprintf("%+.1f%%", 100*(b-a)/a)
+		}
+		fmt.Printf("  %-22s %13s%s %13s%s %13s%s %10s\n", label,
+			fmt.Sprintf(format, a), unit, fmt.Sprintf(format, b), unit, fmt.Sprintf("%+"+format[1:], b-a), unit, relative)
+	}
+	type classStats struct{ baseline, other slowdownStats }
+	classes := []struct {
+		name   string
+		filter func(Task) bool
+	}{{"Short tasks", isShort}, {"Long tasks", isLong}, {"All tasks", nil}}
+	stats := make([]classStats, len(classes))
+	for i, class := range classes {
+		stats[i] = classStats{summarizeSlowdowns(baseline, class.filter), summarizeSlowdowns(other, class.filter)}
+		a, b := stats[i].baseline, stats[i].other
+		if a.Tasks == 0 || b.Tasks == 0 {
+			continue
+		}
+		fmt.Printf("  %s (%d, %d):\n", class.name, a.Tasks, b.Tasks)
+		change("  Mean response", durationMs(a.Response.Mean), durationMs(b.Response.Mean), " ", "%.1f")
+		change("  p99 response", durationMs(a.Response.P99), durationMs(b.Response.P99), " ", "%.1f")
+		change("  Mean slowdown", a.MeanSlowdown, b.MeanSlowdown, "x", "%.2f")
+		change("  p99 slowdown", a.P99Slowdown, b.P99Slowdown, "x", "%.2f")
+	}
+	fmt.Println("  (response times in ms; slowdown is response time / service time)")
+
+	short, long, all := stats[0], stats[1], stats[2]
+	if short.baseline.Tasks == 0 || long.baseline.Tasks == 0 || short.other.Tasks == 0 || long.other.Tasks == 0 {
+		return
+	}
+	shortGain := short.baseline.P99Slowdown - short.other.P99Slowdown
+	longLoss := long.other.P99Slowdown - long.baseline.P99Slowdown
+	fmt.Printf("  Short tasks' p99 slowdown changes by %+.2fx, long tasks' by %+.2fx, and the mean response time of all tasks by %+.1f%%\n",
+		-shortGain, longLoss, 100*(durationMs(all.other.Response.Mean)-durationMs(all.baseline.Response.Mean))/durationMs(all.baseline.Response.Mean))
+	if shortGain > 0 && longLoss > 0 {
+		fmt.Printf("  Every 1x of p99 slowdown long tasks lose buys short tasks %.2fx\n", shortGain/longLoss)
+	}
+}
+
+// compareSlowdownBySize prints the mean slowdown of each run by task size, on size bins
+// shared by every run, so the slowdown curves of policies run on the same workload line
+// up bin by bin
+func compareSlowdownBySize(filenames []string, runs [][]Task) {
+	edges := sizeBinEdges(slices.Concat(runs...))
+	if len(edges) < 3 {
+		return
+	}
+	curves := make([][]sizeBin, len(runs))
+	for i, tasks := range runs {
+		curves[i] = slowdownBySize(tasks, edges)
+	}
+	fmt.Printf("\nMean slowdown by task size (response time / service time, by size quantile):\n")
+	fmt.Printf("%-21s", "Size (ms)")
+	for _, filename := range filenames {
+		fmt.Printf(" %40s", strings.TrimSuffix(filepath.Base(filename), ".csv"))
+	}
+	fmt.Println()
+	for b := range len(edges) - 1 {
+		// The bounds of the bin over every run, as each run holds its own tasks
+		var smallest, largest time.Duration
+		for _, curve := range curves {
+			if bin := curve[b]; bin.Tasks > 0 {
+				if smallest == 0 || bin.Smallest < smallest {
+					smallest = bin.Smallest
+				}
+				largest = max(largest, bin.Largest)
+			}
+		}
+		if largest == 0 {
+			continue
+		}
+		fmt.Printf("%-21s", formatMs(smallest)+" - "+formatMs(largest))
+		for _, curve := range curves {
+			if curve[b].Tasks == 0 {
+				fmt.Printf(" %40s", "-")
+			} else {
+				fmt.Printf(" %39.2fx", curve[b].MeanSlowdown)
+			}
+		}
+		fmt.Println()
+	}
+}
@@ -3,85 +3,1517 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
 	"gopkg.in/yaml.v3"
+
+	"fifo-queue-demo/sched"
+	"fifo-queue-demo/workload"
 )
 
-// WorkloadConfig holds the workload configuration parameters
-type WorkloadConfig struct {
-	NumTasks             int     `yaml:"num_tasks"`
-	ShortTaskDurationMs  int     `yaml:"short_task_duration_ms"`
-	LongTaskDurationMs   int     `yaml:"long_task_duration_ms"`
-	ShortTaskProbability float64 `yaml:"short_task_probability"`
-	TargetUtilization    float64 `yaml:"target_utilization"`
+// WorkloadConfig holds the workload configuration parameters, as defined by the workload
+// package
+type WorkloadConfig = workload.Config
+
+// QueueConfig holds the queue and executor configuration parameters
+type QueueConfig struct {
+	WorkerConcurrency int `yaml:"worker_concurrency"`
+	GlobalConcurrency int `yaml:"global_concurrency"` // 0 means no global limit
+	NumExecutors      int `yaml:"num_executors"`
+
+	// How workers learn about new tasks: "polling" uses DBOS queues, which workers poll;
+	// "notify" uses a task table with LISTEN/NOTIFY so workers wake up on enqueue
+	Dispatch string `yaml:"dispatch"`
+
+	// DBOS polls the queue every base interval, backing off up to the max interval on
+	// database errors. It never polls faster than the base interval.
+	BasePollingIntervalMs int `yaml:"base_polling_interval_ms"`
+	MaxPollingIntervalMs  int `yaml:"max_polling_interval_ms"`
+
+	// Executors only run tasks enqueued with the same run tag, and run IDs start with it.
+	// Runs without a tag are tagged with their run ID, unless they don't wait for results.
+	RunTag string `yaml:"run_tag"`
+}
+
+// ProducerConfig holds the producer (client) configuration parameters
+type ProducerConfig struct {
+	BackpressureThreshold      int    `yaml:"backpressure_threshold"` // 0 disables backpressure
+	BackpressureMode           string `yaml:"backpressure_mode"`      // "pause" or "shed"
+	BackpressurePollIntervalMs int    `yaml:"backpressure_poll_interval_ms"`
+	EnqueueWorkers             int    `yaml:"enqueue_workers"` // Concurrent enqueue calls in flight
+	NoWait                     bool   `yaml:"no_wait"`         // Exit after enqueueing instead of collecting results
+}
+
+// DatabaseConfig holds the Postgres connection pool configuration parameters
+type DatabaseConfig struct {
+	// "external" connects to DBOS_SYSTEM_DATABASE_URL; "embedded" starts an ephemeral
+	// Postgres server for the duration of the command; "simulated" runs experiments
+	// through an in-process queue simulation, which runs fall back to when no database
+	// URL is set
+	Mode string `yaml:"mode"`
+
+	PoolMaxConns       int `yaml:"pool_max_conns"`
+	PoolMinConns       int `yaml:"pool_min_conns"`
+	MaxConnLifetimeS   int `yaml:"max_conn_lifetime_s"`
+	MaxConnIdleTimeS   int `yaml:"max_conn_idle_time_s"`
+	StatementTimeoutMs int `yaml:"statement_timeout_ms"` // 0 keeps the server default
+}
+
+// AutoscalerConfig holds the autoscaler configuration parameters. Capacities count task
+// slots across all executors.
+type AutoscalerConfig struct {
+	Enabled              bool    `yaml:"enabled"`
+	Metric               string  `yaml:"metric"` // "backlog" or "p99"
+	MinCapacity          int     `yaml:"min_capacity"`
+	MaxCapacity          int     `yaml:"max_capacity"`
+	Step                 int     `yaml:"step"` // Slots added or removed per scaling action
+	EvaluationIntervalMs int     `yaml:"evaluation_interval_ms"`
+	ScaleUpBacklog       float64 `yaml:"scale_up_backlog"`   // Backlog per slot above which to scale up
+	ScaleDownBacklog     float64 `yaml:"scale_down_backlog"` // Backlog per slot below which to scale down
+	TargetP99WaitMs      int     `yaml:"target_p99_wait_ms"`
+	ScaleUpCooldownMs    int     `yaml:"scale_up_cooldown_ms"`
+	ScaleDownCooldownMs  int     `yaml:"scale_down_cooldown_ms"`
+}
+
+// SLOConfig is a latency objective for one class of tasks, e.g. short tasks p99 < 1s
+type SLOConfig struct {
+	Class       string  `yaml:"class"`  // "short", "long" or "all"
+	Metric      string  `yaml:"metric"` // "response" or "wait"
+	Percentile  float64 `yaml:"percentile"`
+	ThresholdMs int     `yaml:"threshold_ms"`
+}
+
+// CostConfig holds the cost model parameters. Rates are in an arbitrary currency unit.
+type CostConfig struct {
+	WorkerSecondCost  float64 `yaml:"worker_second_cost"`  // Per task slot provisioned for one second
+	SLOViolationCost  float64 `yaml:"slo_violation_cost"`  // Per task violating an SLO
+	LatencySecondCost float64 `yaml:"latency_second_cost"` // Per second of task response time
+}
+
+// Enabled reports whether any cost rate is set
+func (c *CostConfig) Enabled() bool {
+	return c.WorkerSecondCost > 0 || c.SLOViolationCost > 0 || c.LatencySecondCost > 0
+}
+
+// RetryConfig holds the retry policy of failed task attempts. Retries are DBOS step
+// retries, so a retried task keeps its worker slot through its backoff.
+type RetryConfig struct {
+	MaxRetries     int     `yaml:"max_retries"` // Retries after the first attempt, 0 for none
+	BaseIntervalMs int     `yaml:"base_interval_ms"`
+	MaxIntervalMs  int     `yaml:"max_interval_ms"`
+	BackoffFactor  float64 `yaml:"backoff_factor"`
+	DeadLetter     bool    `yaml:"dead_letter"` // Route tasks that fail for good to the dead-letter queue
+}
+
+// ClientRetryConfig sets up clients that time out on their tasks and send them again:
+// the producer re-enqueues a task that hasn't completed TimeoutMs after it was sent, as a
+// new attempt of the same request, after a backoff like that of step retries. The attempt
+// it timed out on isn't cancelled.
+type ClientRetryConfig struct {
+	TimeoutMs      int     `yaml:"timeout_ms"`  // 0 for clients that wait as long as it takes
+	MaxRetries     int     `yaml:"max_retries"` // Attempts after the first one
+	BaseIntervalMs int     `yaml:"base_interval_ms"`
+	MaxIntervalMs  int     `yaml:"max_interval_ms"`
+	BackoffFactor  float64 `yaml:"backoff_factor"`
+}
+
+// RateLimitConfig sets up per-tenant rate limiting in front of the queue: each tenant
+// gets a token bucket refilling at Rate tasks per second, holding up to Burst tokens.
+// Tasks that arrive to an empty bucket are rejected, or delayed until a token is due.
+type RateLimitConfig struct {
+	Rate    float64           `yaml:"rate"`  // Tasks per second each tenant may submit, 0 for no limit
+	Burst   int               `yaml:"burst"` // Tasks a tenant may submit at once after a quiet spell
+	Mode    string            `yaml:"mode"`  // "reject" or "delay"
+	Tenants []TenantRateLimit `yaml:"tenants"`
+}
+
+// ReservationsConfig splits the worker slots into slots reserved for tenants or classes
+// and a shared best-effort pool: a task takes a reserved slot of its group when one is
+// free, and a shared slot otherwise. Reserved slots their group doesn't use stay idle.
+type ReservationsConfig struct {
+	By string `yaml:"by"` // What groups tasks: "tenant" or "class"
+
+	// Reserved slots of each group across all executors, comma-separated, e.g.
+	// "tenant-0=2,tenant-1=1". The rest of the capacity is shared; empty disables
+	// reservations.
+	Slots string `yaml:"slots"`
+
+	Lookahead   int `yaml:"lookahead"`    // Tasks per executor dequeued beyond its worker slots, to wait for their slot at the gate
+	ToleranceMs int `yaml:"tolerance_ms"` // Wait within which a task with a free reserved slot counts as served by its reservation
+}
+
+// ColdStartConfig is the setup a worker pays before running a task when it last ran
+// another class of tasks (short or long), and on top when it is cold: it never ran a
+// task, or idled longer than idle_after_ms
+type ColdStartConfig struct {
+	SwitchMs      int    `yaml:"switch_ms"`       // Setup of a class, for the classes without their own (0 = none)
+	ClassSwitchMs string `yaml:"class_switch_ms"` // Setup of specific classes, comma-separated, e.g. "long=200"
+	WarmUpMs      int    `yaml:"warm_up_ms"`      // Extra setup of a cold worker (0 = none)
+	IdleAfterMs   int    `yaml:"idle_after_ms"`   // Idle time after which a worker is cold (0 = never)
+}
+
+// TenantRateLimit overrides the rate limit of one tenant
+type TenantRateLimit struct {
+	Tenant string  `yaml:"tenant"`
+	Rate   float64 `yaml:"rate"`  // 0 leaves the tenant unlimited
+	Burst  int     `yaml:"burst"` // 0 keeps rate_limit.burst
+}
+
+// StarvationConfig holds the thresholds of the starvation detector
+type StarvationConfig struct {
+	WaitMultiple float64 `yaml:"wait_multiple"` // Starved when waiting this many times the mean wait
+	MaxWaitMs    int     `yaml:"max_wait_ms"`   // Absolute bound on the wait, 0 to disable
+}
+
+// CalibrationConfig sets up the calibration phase of real runs, which measures the
+// queue's per-task overhead and spaces arrivals so the load includes it
+type CalibrationConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	NumTasks int  `yaml:"num_tasks"` // Tasks with no work run to measure the overhead
+}
+
+// SoakConfig holds the settings of soak runs, which workload.duration bounds by time.
+// Soak runs report rolling statistics over windows of the run as it goes, and checkpoint
+// them periodically.
+type SoakConfig struct {
+	WindowS             int `yaml:"window_s"`              // Length of the statistics windows
+	CheckpointIntervalS int `yaml:"checkpoint_interval_s"` // Time between two checkpoints
+}
+
+// Window returns the length of the statistics windows
+func (c *SoakConfig) Window() time.Duration {
+	return time.Duration(c.WindowS) * time.Second
+}
+
+// CheckpointInterval returns the time between two checkpoints
+func (c *SoakConfig) CheckpointInterval() time.Duration {
+	return time.Duration(c.CheckpointIntervalS) * time.Second
+}
+
+// ConvoyConfig holds the threshold of the convoy detector
+type ConvoyConfig struct {
+	MinSize int `yaml:"min_size"` // Short tasks waiting behind a long one that make a convoy
+}
+
+// WatchdogConfig sets up the anti-starvation watchdog, which boosts the tasks of a run
+// that wait longer than a bound
+type WatchdogConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	MaxWaitMs      int  `yaml:"max_wait_ms"`      // Tasks waiting longer are boosted
+	ScanIntervalMs int  `yaml:"scan_interval_ms"` // Time between two scans of the waiting tasks
+}
+
+// NetworkConfig adds network latency between the run and Postgres, as if they ran in
+// different regions. Latencies are round trips, split evenly between the two directions.
+type NetworkConfig struct {
+	ProducerLatencyMs int `yaml:"producer_latency_ms"` // Added to the connections the producer enqueues over
+	WorkerLatencyMs   int `yaml:"worker_latency_ms"`   // Added to the connections of the executors and their tasks
+}
+
+// Enabled returns whether either side is away from Postgres
+func (c *NetworkConfig) Enabled() bool {
+	return c.ProducerLatencyMs > 0 || c.WorkerLatencyMs > 0
+}
+
+func (c *NetworkConfig) ProducerLatency() time.Duration {
+	return time.Duration(c.ProducerLatencyMs) * time.Millisecond
+}
+
+func (c *NetworkConfig) WorkerLatency() time.Duration {
+	return time.Duration(c.WorkerLatencyMs) * time.Millisecond
+}
+
+// KillSwitchConfig sets up the kill switch, which aborts a run whose backlog or p99
+// response time exceeds a bound. It is off while both bounds are 0.
+type KillSwitchConfig struct {
+	MaxBacklog      int `yaml:"max_backlog"`       // Most tasks waiting in the queue
+	MaxP99Ms        int `yaml:"max_p99_ms"`        // Highest p99 response time of a window
+	WindowS         int `yaml:"window_s"`          // Length of the windows the p99 is taken over
+	CheckIntervalMs int `yaml:"check_interval_ms"` // Time between two checks of the backlog
+	DrainTimeoutS   int `yaml:"drain_timeout_s"`   // Time the tasks in flight get to finish once it trips
+}
+
+// Enabled returns whether the kill switch has a bound
+func (c *KillSwitchConfig) Enabled() bool {
+	return c.MaxBacklog > 0 || c.MaxP99Ms > 0
+}
+
+// Bounds describes the bounds of the kill switch
+func (c *KillSwitchConfig) Bounds() string {
+	var bounds []string
+	if c.MaxBacklog > 0 {
+		bounds = append(bounds, fmt.Sprintf("a backlog of %d tasks", c.MaxBacklog))
+	}
+	if c.MaxP99Ms > 0 {
+		bounds = append(bounds, fmt.Sprintf("a p99 response time of %d ms over %v", c.MaxP99Ms, c.Window()))
+	}
+	return strings.Join(bounds, " or ")
+}
+
+func (c *KillSwitchConfig) MaxP99() time.Duration {
+	return time.Duration(c.MaxP99Ms) * time.Millisecond
+}
+
+func (c *KillSwitchConfig) Window() time.Duration {
+	return time.Duration(c.WindowS) * time.Second
+}
+
+func (c *KillSwitchConfig) CheckInterval() time.Duration {
+	return time.Duration(c.CheckIntervalMs) * time.Millisecond
+}
+
+func (c *KillSwitchConfig) DrainTimeout() time.Duration {
+	return time.Duration(c.DrainTimeoutS) * time.Second
+}
+
+// WebhookConfig sets up the delivery of task completion events to an HTTP endpoint, as
+// they are collected
+type WebhookConfig struct {
+	URL       string `yaml:"url"`        // Endpoint events are POSTed to, none when empty
+	BatchSize int    `yaml:"batch_size"` // Most events per POST
+	TimeoutMs int    `yaml:"timeout_ms"` // Timeout of each POST
+}
+
+// ArrivalsConfig sets up an external arrival source for the serve command: task
+// descriptors consumed from a NATS subject or a Kafka topic are enqueued as they arrive
+type ArrivalsConfig struct {
+	Source  string `yaml:"source"`  // "nats", "kafka", or empty for none
+	URL     string `yaml:"url"`     // NATS server, or Kafka REST Proxy
+	Subject string `yaml:"subject"` // NATS subject or Kafka topic
+	Group   string `yaml:"group"`   // NATS queue group or Kafka consumer group shared by servers
+}
+
+// MetricsConfig holds the parameters of streamed result statistics
+type MetricsConfig struct {
+	// Runs with at least this many tasks are streamed: completed tasks aren't kept in
+	// memory and percentiles come from histograms (0 never streams)
+	StreamingThreshold          int `yaml:"streaming_threshold"`
+	HistogramSignificantFigures int `yaml:"histogram_significant_figures"` // 1 to 5
+	HistogramMaxMs              int `yaml:"histogram_max_ms"`              // Larger latencies are clamped
+
+	// Write wait and response time distributions to an HdrHistogram log next to the CSV
+	HdrLog           bool `yaml:"hdr_log"`
+	HdrLogIntervalMs int  `yaml:"hdr_log_interval_ms"`
+
+	// Write the dispatch decisions of a run to a decision log next to the CSV
+	DecisionLog bool `yaml:"decision_log"`
+	// Write the busy periods of the worker slots next to the CSV: "off", "csv", or "svg"
+	// for a Gantt chart as well
+	Timeline string `yaml:"timeline"`
+	// Report response and wait time percentiles per window of this many seconds of the
+	// run, and write them next to the CSV (0 = off)
+	WindowS int `yaml:"window_s"`
+
+	// Task attribute the summary statistics are broken down by: class, priority, tenant
+	// or queue
+	GroupBy string `yaml:"group_by"`
+
+	// Where the results of a run go, comma-separated: "csv", "json", "parquet" files next
+	// to each other, or "postgres" for the schedq_results table
+	Exporters string `yaml:"exporters"`
+}
+
+// PipelineConfig turns runs into a pipeline (tandem queue): every task flows through the
+// stages in order, each with its own queue and worker slots. Without stages, tasks go
+// through the single queue of the queue section.
+type PipelineConfig struct {
+	Stages []PipelineStage `yaml:"stages"`
+}
+
+// PipelineStage is one stage of a pipeline
+type PipelineStage struct {
+	DurationFactor    float64 `yaml:"duration_factor"`    // Stage duration as a multiple of the task duration (0 = 1)
+	WorkerConcurrency int     `yaml:"worker_concurrency"` // Per executor (0 = queue.worker_concurrency)
+}
+
+// AlgorithmsConfig holds the tuning of each scheduling algorithm, one section per
+// algorithm named like its -algo value. The registry hands each algorithm its section.
+type AlgorithmsConfig struct {
+	SJF          SJFConfig          `yaml:"sjf"`
+	SJFLanes     SJFLanesConfig     `yaml:"sjf_lanes"`
+	SJFBuckets   SJFBucketsConfig   `yaml:"sjf_buckets"`
+	SJFPredicted SJFPredictedConfig `yaml:"sjf_predicted"`
+	Adaptive     AdaptiveConfig     `yaml:"adaptive"`
+	Agent        AgentConfig        `yaml:"agent"`
+	Batch        BatchConfig        `yaml:"batch"`
+	FairShare    FairShareConfig    `yaml:"fair_share"`
+}
+
+// SJFConfig tunes Shortest Job First
+type SJFConfig struct {
+	// Tasks up to this duration get the high priority, longer tasks the low one
+	// (0 = short_task_duration_ms)
+	CutoffMs int `yaml:"cutoff_ms"`
+}
+
+// SJFBucketsConfig tunes Shortest Job First on size buckets, which only knows the size
+// class of each task rather than its duration
+type SJFBucketsConfig struct {
+	// Upper bounds of the buckets in milliseconds, increasing and comma-separated, e.g.
+	// "100,1000,10000" for 4 buckets
+	BoundariesMs string `yaml:"boundaries_ms"`
+}
+
+// SJFPredictedConfig tunes Shortest Job First on predicted sizes
+type SJFPredictedConfig struct {
+	// How sizes are predicted: "moving-average" learns the mean duration of each class
+	// from completed tasks, "history" a quantile of the durations of the last completed
+	// tasks of each class, "model" applies the linear model in ModelFile
+	Estimator string  `yaml:"estimator"`
+	Alpha     float64 `yaml:"alpha"` // Weight of each completed task in the moving average
+	ModelFile string  `yaml:"model_file"`
+
+	// Completed tasks per class the history estimator remembers, and the quantile of
+	// their durations it predicts
+	Window   int     `yaml:"window"`
+	Quantile float64 `yaml:"quantile"`
+}
+
+// AdaptiveConfig tunes the adaptive policy, which prioritizes arriving tasks with a
+// low-load policy while the queue is shallow and with a high-load policy while the
+// backlog is deep
+type AdaptiveConfig struct {
+	Low         string  `yaml:"low"`          // Algorithm at low load
+	High        string  `yaml:"high"`         // Algorithm at high load
+	HighBacklog float64 `yaml:"high_backlog"` // Queued tasks per worker slot above which High takes over
+	LowBacklog  float64 `yaml:"low_backlog"`  // Queued tasks per worker slot at which Low takes back over
+	IntervalMs  int     `yaml:"interval_ms"`  // How often real runs check the backlog
+}
+
+// FairShareConfig tunes decay-usage fair-share scheduling
+type FairShareConfig struct {
+	// Time for a tenant's recent usage to decay by half (0 = 10000)
+	HalfLifeMs int `yaml:"half_life_ms"`
+}
+
+// AgentConfig sets up the agent policy, which hands every dispatch decision to an
+// external process speaking line-delimited JSON on its stdin and stdout
+type AgentConfig struct {
+	Command   string `yaml:"command"`    // Shell command starting the agent, tasks run in arrival order without one
+	TimeoutMs int    `yaml:"timeout_ms"` // How long a decision may take before the oldest task runs instead
+	Lookahead int    `yaml:"lookahead"`  // Tasks per executor dequeued beyond its worker slots, for the agent to pick from
+}
+
+// BatchConfig tunes class-affinity batching, which runs tasks of the same class back to
+// back to amortize the setup of switching classes
+type BatchConfig struct {
+	Size      int `yaml:"size"`      // Most tasks of a class run in a row
+	Lookahead int `yaml:"lookahead"` // Tasks per executor dequeued beyond its worker slots, to batch from
+}
+
+// SJFLanesConfig tunes Shortest Job First with a queue per priority. Tasks are split
+// into the lanes at the cutoff of algorithms.sjf.
+type SJFLanesConfig struct {
+	// How lanes share worker slots: "strict" always serves the short lane first,
+	// "weighted" gives each lane with waiting tasks its weight's share of freed slots
+	Mode        string `yaml:"mode"`
+	ShortWeight int    `yaml:"short_weight"`
+	LongWeight  int    `yaml:"long_weight"`
 }
 
 // Config holds all application configuration
 type Config struct {
-	Workload WorkloadConfig `yaml:"workload"`
+	Workload     WorkloadConfig     `yaml:"workload"`
+	Queue        QueueConfig        `yaml:"queue"`
+	Producer     ProducerConfig     `yaml:"producer"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Autoscaler   AutoscalerConfig   `yaml:"autoscaler"`
+	SLOs         []SLOConfig        `yaml:"slos"`
+	Cost         CostConfig         `yaml:"cost"`
+	Starvation   StarvationConfig   `yaml:"starvation"`
+	Convoys      ConvoyConfig       `yaml:"convoys"`
+	Calibration  CalibrationConfig  `yaml:"calibration"`
+	Soak         SoakConfig         `yaml:"soak"`
+	Watchdog     WatchdogConfig     `yaml:"watchdog"`
+	KillSwitch   KillSwitchConfig   `yaml:"kill_switch"`
+	Network      NetworkConfig      `yaml:"network"`
+	Webhook      WebhookConfig      `yaml:"webhook"`
+	Arrivals     ArrivalsConfig     `yaml:"arrivals"`
+	Retry        RetryConfig        `yaml:"retry"`
+	ClientRetry  ClientRetryConfig  `yaml:"client_retry"`
+	RateLimit    RateLimitConfig    `yaml:"rate_limit"`
+	Reservations ReservationsConfig `yaml:"reservations"`
+	ColdStart    ColdStartConfig    `yaml:"cold_start"`
+	Metrics      MetricsConfig      `yaml:"metrics"`
+	Algorithms   AlgorithmsConfig   `yaml:"algorithms"`
+	Pipeline     PipelineConfig     `yaml:"pipeline"`
+
+	// Named experiment setups, each overriding part of the configuration above
+	Profiles map[string]Config `yaml:"profiles"`
 }
 
 // Global configuration instance
 var AppConfig Config
 
-// LoadConfig loads configuration from config.yaml file
-// If the file doesn't exist or has missing values, it uses defaults
-func LoadConfig() error {
-	// Set defaults
-	AppConfig = Config{
+// databaseURL returns the connection string of the Postgres database backing the queues
+func databaseURL() string {
+	return os.Getenv("DBOS_SYSTEM_DATABASE_URL")
+}
+
+// defaultConfigPath is the configuration file used when no -config flag is given
+const defaultConfigPath = "config.yaml"
+
+// defaultConfig returns the configuration used for the settings config.yaml leaves out
+func defaultConfig() Config {
+	return Config{
 		Workload: WorkloadConfig{
 			NumTasks:             100,
 			ShortTaskDurationMs:  300,
 			LongTaskDurationMs:   2000,
 			ShortTaskProbability: 0.8,
 			TargetUtilization:    0.7,
+			CancelDelayMs:        1000,
+			WorkMode:             "sleep",
+			PayloadFormat:        "bytes",
+		},
+		Queue: QueueConfig{
+			WorkerConcurrency: 1,
+			GlobalConcurrency: 0,
+			NumExecutors:      1,
+			Dispatch:          "polling",
+
+			BasePollingIntervalMs: 100,
+			MaxPollingIntervalMs:  120000,
+		},
+		Producer: ProducerConfig{
+			BackpressureThreshold:      0,
+			BackpressureMode:           "pause",
+			BackpressurePollIntervalMs: 50,
+			EnqueueWorkers:             1,
+		},
+		Database: DatabaseConfig{
+			Mode:               "external",
+			PoolMaxConns:       20,
+			PoolMinConns:       0,
+			MaxConnLifetimeS:   3600,
+			MaxConnIdleTimeS:   300,
+			StatementTimeoutMs: 0,
+		},
+		Metrics: MetricsConfig{
+			StreamingThreshold:          100000,
+			HistogramSignificantFigures: 3,
+			HistogramMaxMs:              3600000,
+			HdrLogIntervalMs:            1000,
+			GroupBy:                     "class",
+			Timeline:                    "off",
+			Exporters:                   "csv",
+		},
+		Algorithms: AlgorithmsConfig{
+			SJFLanes: SJFLanesConfig{
+				Mode:        "weighted",
+				ShortWeight: 3,
+				LongWeight:  1,
+			},
+			SJFBuckets: SJFBucketsConfig{
+				BoundariesMs: defaultSJFBucketBoundariesMs,
+			},
+			SJFPredicted: SJFPredictedConfig{
+				Estimator: "moving-average",
+				Alpha:     defaultSJFPredictedAlpha,
+				Window:    100,
+				Quantile:  0.5,
+			},
+			Adaptive: defaultAdaptive,
+			Agent:    defaultAgent,
+			Batch:    defaultBatch,
+			FairShare: FairShareConfig{
+				HalfLifeMs: defaultFairShareHalfLifeMs,
+			},
+		},
+		Starvation: StarvationConfig{
+			WaitMultiple: 10,
+		},
+		Convoys: ConvoyConfig{
+			MinSize: 3,
+		},
+		Calibration: CalibrationConfig{
+			NumTasks: 30,
+		},
+		Soak: SoakConfig{
+			WindowS:             60,
+			CheckpointIntervalS: 600,
+		},
+		Watchdog: WatchdogConfig{
+			MaxWaitMs:      2000,
+			ScanIntervalMs: 100,
+		},
+		KillSwitch: KillSwitchConfig{
+			WindowS:         10,
+			CheckIntervalMs: 1000,
+			DrainTimeoutS:   30,
+		},
+		Webhook: WebhookConfig{
+			BatchSize: 100,
+			TimeoutMs: 5000,
+		},
+		Arrivals: ArrivalsConfig{
+			Group: "fifo-queue-demo",
+		},
+		Retry: RetryConfig{
+			BaseIntervalMs: 100,
+			MaxIntervalMs:  5000,
+			BackoffFactor:  2,
+		},
+		ClientRetry: ClientRetryConfig{
+			MaxRetries:     3,
+			BaseIntervalMs: 100,
+			MaxIntervalMs:  5000,
+			BackoffFactor:  2,
+		},
+		RateLimit: RateLimitConfig{
+			Burst: 10,
+			Mode:  "reject",
+		},
+		Reservations: ReservationsConfig{
+			By:          "tenant",
+			Lookahead:   16,
+			ToleranceMs: 100,
+		},
+		Autoscaler: AutoscalerConfig{
+			Metric:               "backlog",
+			MinCapacity:          1,
+			MaxCapacity:          4,
+			Step:                 1,
+			EvaluationIntervalMs: 500,
+			ScaleUpBacklog:       2,
+			ScaleDownBacklog:     0.5,
+			TargetP99WaitMs:      1000,
+			ScaleUpCooldownMs:    1000,
+			ScaleDownCooldownMs:  5000,
 		},
 	}
+}
+
+// LoadConfig loads configuration from the given file, applying the named profile if
+// profile isn't empty, then the SCHEDQ_* environment variables
+func LoadConfig(path, profile string) error {
+	if err := loadConfigFile(path, profile); err != nil {
+		return err
+	}
+	return applyEnvConfig(&AppConfig)
+}
+
+// loadConfigFile loads configuration from the given file. If the default file doesn't
+// exist or has missing values, it uses defaults.
+func loadConfigFile(path, profile string) error {
+	AppConfig = defaultConfig()
 
 	// Try to read config file
-	data, err := os.ReadFile("config.yaml")
+	data, err := os.ReadFile(path)
 	if err != nil {
-		// If file doesn't exist, use defaults
-		if os.IsNotExist(err) {
+		// If the default file doesn't exist, use defaults
+		if os.IsNotExist(err) && path == defaultConfigPath && profile == "" {
 			fmt.Println("No config.yaml found, using default configuration")
 			return nil
 		}
-		return fmt.Errorf("failed to read config.yaml: %w", err)
+		return fmt.Errorf("failed to read %s: %w", path, err)
 	}
 
 	// Parse YAML
 	var fileConfig Config
 	if err := yaml.Unmarshal(data, &fileConfig); err != nil {
-		return fmt.Errorf("failed to parse config.yaml: %w", err)
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// Merge file config with defaults, then the selected profile over the result
+	mergeConfig(&AppConfig, fileConfig)
+	if profile != "" {
+		profileConfig, ok := fileConfig.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("unknown profile %q in %s (available profiles: %s)", profile, path, joinKeys(fileConfig.Profiles))
+		}
+		mergeConfig(&AppConfig, profileConfig)
+		fmt.Printf("Configuration loaded from %s (profile %s)\n", path, profile)
+		return nil
 	}
 
-	// Merge file config with defaults (only override non-zero values)
-	if fileConfig.Workload.NumTasks > 0 {
-		AppConfig.Workload.NumTasks = fileConfig.Workload.NumTasks
+	fmt.Printf("Configuration loaded from %s\n", path)
+	return nil
+}
+
+// mergeConfig overrides dst with the values set in src (only non-zero values)
+func mergeConfig(dst *Config, src Config) {
+	if src.Workload.NumTasks > 0 {
+		dst.Workload.NumTasks = src.Workload.NumTasks
+	}
+	if src.Workload.ShortTaskDurationMs > 0 {
+		dst.Workload.ShortTaskDurationMs = src.Workload.ShortTaskDurationMs
+	}
+	if src.Workload.LongTaskDurationMs > 0 {
+		dst.Workload.LongTaskDurationMs = src.Workload.LongTaskDurationMs
+	}
+	if src.Workload.ShortTaskProbability > 0 {
+		dst.Workload.ShortTaskProbability = src.Workload.ShortTaskProbability
+	}
+	if src.Workload.TargetUtilization > 0 {
+		dst.Workload.TargetUtilization = src.Workload.TargetUtilization
+	}
+	if src.Workload.DuplicateProbability > 0 {
+		dst.Workload.DuplicateProbability = src.Workload.DuplicateProbability
+	}
+	if src.Workload.NumTenants > 0 {
+		dst.Workload.NumTenants = src.Workload.NumTenants
+	}
+	if src.Workload.TenantSkew > 0 {
+		dst.Workload.TenantSkew = src.Workload.TenantSkew
+	}
+	if src.Workload.TasksPerJob > 0 {
+		dst.Workload.TasksPerJob = src.Workload.TasksPerJob
+	}
+	if src.Workload.LockProbability > 0 {
+		dst.Workload.LockProbability = src.Workload.LockProbability
+	}
+	if src.Workload.FanOut > 0 {
+		dst.Workload.FanOut = src.Workload.FanOut
+	}
+	if src.Workload.DeadlineFactor > 0 {
+		dst.Workload.DeadlineFactor = src.Workload.DeadlineFactor
+	}
+	if src.Workload.OverloadDurationMs > 0 {
+		dst.Workload.OverloadDurationMs = src.Workload.OverloadDurationMs
+	}
+	if len(src.Workload.UtilizationSteps) > 0 {
+		dst.Workload.UtilizationSteps = src.Workload.UtilizationSteps
+	}
+	if len(src.Workload.Phases) > 0 {
+		dst.Workload.Phases = src.Workload.Phases
+	}
+	if src.Workload.ServiceTimeMeanMs > 0 {
+		dst.Workload.ServiceTimeMeanMs = src.Workload.ServiceTimeMeanMs
+	}
+	if src.Workload.ServiceTimeSCV > 0 {
+		dst.Workload.ServiceTimeSCV = src.Workload.ServiceTimeSCV
+	}
+	if src.Workload.WorkMode != "" {
+		dst.Workload.WorkMode = src.Workload.WorkMode
+	}
+	if src.Workload.Duration != "" {
+		dst.Workload.Duration = src.Workload.Duration
+	}
+	if src.Workload.ShortTaskDurationUs > 0 {
+		dst.Workload.ShortTaskDurationUs = src.Workload.ShortTaskDurationUs
+	}
+	if src.Workload.LongTaskDurationUs > 0 {
+		dst.Workload.LongTaskDurationUs = src.Workload.LongTaskDurationUs
+	}
+	if src.Workload.ServiceTimeMeanUs > 0 {
+		dst.Workload.ServiceTimeMeanUs = src.Workload.ServiceTimeMeanUs
+	}
+	mergeWorkProfile(&dst.Workload.WorkProfiles.Short, src.Workload.WorkProfiles.Short)
+	mergeWorkProfile(&dst.Workload.WorkProfiles.Long, src.Workload.WorkProfiles.Long)
+	if src.Workload.PayloadBytes > 0 {
+		dst.Workload.PayloadBytes = src.Workload.PayloadBytes
+	}
+	if src.Workload.PayloadFormat != "" {
+		dst.Workload.PayloadFormat = src.Workload.PayloadFormat
+	}
+	if src.Queue.WorkerConcurrency > 0 {
+		dst.Queue.WorkerConcurrency = src.Queue.WorkerConcurrency
+	}
+	if src.Queue.GlobalConcurrency > 0 {
+		dst.Queue.GlobalConcurrency = src.Queue.GlobalConcurrency
+	}
+	if src.Queue.NumExecutors > 0 {
+		dst.Queue.NumExecutors = src.Queue.NumExecutors
+	}
+	if src.Queue.Dispatch != "" {
+		dst.Queue.Dispatch = src.Queue.Dispatch
+	}
+	if src.Queue.RunTag != "" {
+		dst.Queue.RunTag = src.Queue.RunTag
+	}
+	if src.Queue.BasePollingIntervalMs > 0 {
+		dst.Queue.BasePollingIntervalMs = src.Queue.BasePollingIntervalMs
+	}
+	if src.Queue.MaxPollingIntervalMs > 0 {
+		dst.Queue.MaxPollingIntervalMs = src.Queue.MaxPollingIntervalMs
+	}
+	if src.Producer.BackpressureThreshold > 0 {
+		dst.Producer.BackpressureThreshold = src.Producer.BackpressureThreshold
+	}
+	if src.Producer.BackpressureMode != "" {
+		dst.Producer.BackpressureMode = src.Producer.BackpressureMode
+	}
+	if src.Producer.BackpressurePollIntervalMs > 0 {
+		dst.Producer.BackpressurePollIntervalMs = src.Producer.BackpressurePollIntervalMs
+	}
+	if src.Producer.EnqueueWorkers > 0 {
+		dst.Producer.EnqueueWorkers = src.Producer.EnqueueWorkers
+	}
+	if src.Producer.NoWait {
+		dst.Producer.NoWait = true
+	}
+	if src.Database.PoolMaxConns > 0 {
+		dst.Database.PoolMaxConns = src.Database.PoolMaxConns
+	}
+	if src.Database.PoolMinConns > 0 {
+		dst.Database.PoolMinConns = src.Database.PoolMinConns
+	}
+	if src.Database.MaxConnLifetimeS > 0 {
+		dst.Database.MaxConnLifetimeS = src.Database.MaxConnLifetimeS
+	}
+	if src.Database.MaxConnIdleTimeS > 0 {
+		dst.Database.MaxConnIdleTimeS = src.Database.MaxConnIdleTimeS
+	}
+	if src.Database.Mode != "" {
+		dst.Database.Mode = src.Database.Mode
+	}
+	if src.Database.StatementTimeoutMs > 0 {
+		dst.Database.StatementTimeoutMs = src.Database.StatementTimeoutMs
+	}
+	if src.Autoscaler.Enabled {
+		dst.Autoscaler.Enabled = true
+	}
+	if src.Autoscaler.Metric != "" {
+		dst.Autoscaler.Metric = src.Autoscaler.Metric
+	}
+	if src.Autoscaler.MinCapacity > 0 {
+		dst.Autoscaler.MinCapacity = src.Autoscaler.MinCapacity
+	}
+	if src.Autoscaler.MaxCapacity > 0 {
+		dst.Autoscaler.MaxCapacity = src.Autoscaler.MaxCapacity
+	}
+	if src.Autoscaler.Step > 0 {
+		dst.Autoscaler.Step = src.Autoscaler.Step
+	}
+	if src.Autoscaler.EvaluationIntervalMs > 0 {
+		dst.Autoscaler.EvaluationIntervalMs = src.Autoscaler.EvaluationIntervalMs
+	}
+	if src.Autoscaler.ScaleUpBacklog > 0 {
+		dst.Autoscaler.ScaleUpBacklog = src.Autoscaler.ScaleUpBacklog
+	}
+	if src.Autoscaler.ScaleDownBacklog > 0 {
+		dst.Autoscaler.ScaleDownBacklog = src.Autoscaler.ScaleDownBacklog
+	}
+	if src.Autoscaler.TargetP99WaitMs > 0 {
+		dst.Autoscaler.TargetP99WaitMs = src.Autoscaler.TargetP99WaitMs
+	}
+	if src.Autoscaler.ScaleUpCooldownMs > 0 {
+		dst.Autoscaler.ScaleUpCooldownMs = src.Autoscaler.ScaleUpCooldownMs
+	}
+	if src.Autoscaler.ScaleDownCooldownMs > 0 {
+		dst.Autoscaler.ScaleDownCooldownMs = src.Autoscaler.ScaleDownCooldownMs
+	}
+	if len(src.SLOs) > 0 {
+		dst.SLOs = src.SLOs
+		for i := range dst.SLOs {
+			slo := &dst.SLOs[i]
+			if slo.Class == "" {
+				slo.Class = "all"
+			}
+			if slo.Metric == "" {
+				slo.Metric = "response"
+			}
+			if slo.Percentile == 0 {
+				slo.Percentile = 99
+			}
+		}
+	}
+	if src.Cost.WorkerSecondCost > 0 {
+		dst.Cost.WorkerSecondCost = src.Cost.WorkerSecondCost
+	}
+	if src.Cost.SLOViolationCost > 0 {
+		dst.Cost.SLOViolationCost = src.Cost.SLOViolationCost
+	}
+	if src.Cost.LatencySecondCost > 0 {
+		dst.Cost.LatencySecondCost = src.Cost.LatencySecondCost
+	}
+	if src.Starvation.WaitMultiple > 0 {
+		dst.Starvation.WaitMultiple = src.Starvation.WaitMultiple
+	}
+	if src.Starvation.MaxWaitMs > 0 {
+		dst.Starvation.MaxWaitMs = src.Starvation.MaxWaitMs
+	}
+	if src.Convoys.MinSize > 0 {
+		dst.Convoys.MinSize = src.Convoys.MinSize
+	}
+	if src.Calibration.Enabled {
+		dst.Calibration.Enabled = true
+	}
+	if src.Calibration.NumTasks > 0 {
+		dst.Calibration.NumTasks = src.Calibration.NumTasks
+	}
+	if src.Soak.WindowS > 0 {
+		dst.Soak.WindowS = src.Soak.WindowS
+	}
+	if src.Soak.CheckpointIntervalS > 0 {
+		dst.Soak.CheckpointIntervalS = src.Soak.CheckpointIntervalS
+	}
+	if src.Watchdog.Enabled {
+		dst.Watchdog.Enabled = true
+	}
+	if src.Watchdog.MaxWaitMs > 0 {
+		dst.Watchdog.MaxWaitMs = src.Watchdog.MaxWaitMs
+	}
+	if src.Watchdog.ScanIntervalMs > 0 {
+		dst.Watchdog.ScanIntervalMs = src.Watchdog.ScanIntervalMs
+	}
+	if src.Network.ProducerLatencyMs > 0 {
+		dst.Network.ProducerLatencyMs = src.Network.ProducerLatencyMs
+	}
+	if src.Network.WorkerLatencyMs > 0 {
+		dst.Network.WorkerLatencyMs = src.Network.WorkerLatencyMs
+	}
+	if src.KillSwitch.MaxBacklog > 0 {
+		dst.KillSwitch.MaxBacklog = src.KillSwitch.MaxBacklog
+	}
+	if src.KillSwitch.MaxP99Ms > 0 {
+		dst.KillSwitch.MaxP99Ms = src.KillSwitch.MaxP99Ms
+	}
+	if src.KillSwitch.WindowS > 0 {
+		dst.KillSwitch.WindowS = src.KillSwitch.WindowS
+	}
+	if src.KillSwitch.CheckIntervalMs > 0 {
+		dst.KillSwitch.CheckIntervalMs = src.KillSwitch.CheckIntervalMs
+	}
+	if src.KillSwitch.DrainTimeoutS > 0 {
+		dst.KillSwitch.DrainTimeoutS = src.KillSwitch.DrainTimeoutS
+	}
+	if src.Webhook.URL != "" {
+		dst.Webhook.URL = src.Webhook.URL
+	}
+	if src.Webhook.BatchSize > 0 {
+		dst.Webhook.BatchSize = src.Webhook.BatchSize
 	}
-	if fileConfig.Workload.ShortTaskDurationMs > 0 {
-		AppConfig.Workload.ShortTaskDurationMs = fileConfig.Workload.ShortTaskDurationMs
+	if src.Webhook.TimeoutMs > 0 {
+		dst.Webhook.TimeoutMs = src.Webhook.TimeoutMs
 	}
-	if fileConfig.Workload.LongTaskDurationMs > 0 {
-		AppConfig.Workload.LongTaskDurationMs = fileConfig.Workload.LongTaskDurationMs
+	if src.Arrivals.Source != "" {
+		dst.Arrivals.Source = src.Arrivals.Source
 	}
-	if fileConfig.Workload.ShortTaskProbability > 0 {
-		AppConfig.Workload.ShortTaskProbability = fileConfig.Workload.ShortTaskProbability
+	if src.Arrivals.URL != "" {
+		dst.Arrivals.URL = src.Arrivals.URL
 	}
-	if fileConfig.Workload.TargetUtilization > 0 {
-		AppConfig.Workload.TargetUtilization = fileConfig.Workload.TargetUtilization
+	if src.Arrivals.Subject != "" {
+		dst.Arrivals.Subject = src.Arrivals.Subject
 	}
+	if src.Arrivals.Group != "" {
+		dst.Arrivals.Group = src.Arrivals.Group
+	}
+	if src.Retry.DeadLetter {
+		dst.Retry.DeadLetter = true
+	}
+	if src.Retry.MaxRetries > 0 {
+		dst.Retry.MaxRetries = src.Retry.MaxRetries
+	}
+	if src.Retry.BaseIntervalMs > 0 {
+		dst.Retry.BaseIntervalMs = src.Retry.BaseIntervalMs
+	}
+	if src.Retry.MaxIntervalMs > 0 {
+		dst.Retry.MaxIntervalMs = src.Retry.MaxIntervalMs
+	}
+	if src.Retry.BackoffFactor > 0 {
+		dst.Retry.BackoffFactor = src.Retry.BackoffFactor
+	}
+	if src.ClientRetry.TimeoutMs > 0 {
+		dst.ClientRetry.TimeoutMs = src.ClientRetry.TimeoutMs
+	}
+	if src.ClientRetry.MaxRetries > 0 {
+		dst.ClientRetry.MaxRetries = src.ClientRetry.MaxRetries
+	}
+	if src.ClientRetry.BaseIntervalMs > 0 {
+		dst.ClientRetry.BaseIntervalMs = src.ClientRetry.BaseIntervalMs
+	}
+	if src.ClientRetry.MaxIntervalMs > 0 {
+		dst.ClientRetry.MaxIntervalMs = src.ClientRetry.MaxIntervalMs
+	}
+	if src.ClientRetry.BackoffFactor > 0 {
+		dst.ClientRetry.BackoffFactor = src.ClientRetry.BackoffFactor
+	}
+	if src.RateLimit.Rate > 0 {
+		dst.RateLimit.Rate = src.RateLimit.Rate
+	}
+	if src.RateLimit.Burst > 0 {
+		dst.RateLimit.Burst = src.RateLimit.Burst
+	}
+	if src.RateLimit.Mode != "" {
+		dst.RateLimit.Mode = src.RateLimit.Mode
+	}
+	if len(src.RateLimit.Tenants) > 0 {
+		dst.RateLimit.Tenants = src.RateLimit.Tenants
+	}
+	if src.Reservations.By != "" {
+		dst.Reservations.By = src.Reservations.By
+	}
+	if src.Reservations.Slots != "" {
+		dst.Reservations.Slots = src.Reservations.Slots
+	}
+	if src.Reservations.Lookahead > 0 {
+		dst.Reservations.Lookahead = src.Reservations.Lookahead
+	}
+	if src.Reservations.ToleranceMs > 0 {
+		dst.Reservations.ToleranceMs = src.Reservations.ToleranceMs
+	}
+	if src.ColdStart.SwitchMs > 0 {
+		dst.ColdStart.SwitchMs = src.ColdStart.SwitchMs
+	}
+	if src.ColdStart.ClassSwitchMs != "" {
+		dst.ColdStart.ClassSwitchMs = src.ColdStart.ClassSwitchMs
+	}
+	if src.ColdStart.WarmUpMs > 0 {
+		dst.ColdStart.WarmUpMs = src.ColdStart.WarmUpMs
+	}
+	if src.ColdStart.IdleAfterMs > 0 {
+		dst.ColdStart.IdleAfterMs = src.ColdStart.IdleAfterMs
+	}
+	if src.Workload.FailureProbability > 0 {
+		dst.Workload.FailureProbability = src.Workload.FailureProbability
+	}
+	if src.Workload.PermanentFailureProbability > 0 {
+		dst.Workload.PermanentFailureProbability = src.Workload.PermanentFailureProbability
+	}
+	if src.Workload.CancelProbability > 0 {
+		dst.Workload.CancelProbability = src.Workload.CancelProbability
+	}
+	if src.Workload.CancelDelayMs > 0 {
+		dst.Workload.CancelDelayMs = src.Workload.CancelDelayMs
+	}
+	if src.Workload.PatienceMs > 0 {
+		dst.Workload.PatienceMs = src.Workload.PatienceMs
+	}
+	if src.Workload.Seed != 0 {
+		dst.Workload.Seed = src.Workload.Seed
+	}
+	if src.Workload.BurstSize > 0 {
+		dst.Workload.BurstSize = src.Workload.BurstSize
+	}
+	if src.Workload.TraceFile != "" {
+		dst.Workload.TraceFile = src.Workload.TraceFile
+	}
+	if src.Metrics.StreamingThreshold > 0 {
+		dst.Metrics.StreamingThreshold = src.Metrics.StreamingThreshold
+	}
+	if src.Metrics.HistogramSignificantFigures > 0 {
+		dst.Metrics.HistogramSignificantFigures = src.Metrics.HistogramSignificantFigures
+	}
+	if src.Metrics.HistogramMaxMs > 0 {
+		dst.Metrics.HistogramMaxMs = src.Metrics.HistogramMaxMs
+	}
+	if src.Metrics.HdrLog {
+		dst.Metrics.HdrLog = true
+	}
+	if src.Metrics.GroupBy != "" {
+		dst.Metrics.GroupBy = src.Metrics.GroupBy
+	}
+	if src.Metrics.DecisionLog {
+		dst.Metrics.DecisionLog = true
+	}
+	if src.Metrics.Timeline != "" {
+		dst.Metrics.Timeline = src.Metrics.Timeline
+	}
+	if src.Metrics.WindowS > 0 {
+		dst.Metrics.WindowS = src.Metrics.WindowS
+	}
+	if src.Metrics.HdrLogIntervalMs > 0 {
+		dst.Metrics.HdrLogIntervalMs = src.Metrics.HdrLogIntervalMs
+	}
+	if src.Metrics.Exporters != "" {
+		dst.Metrics.Exporters = src.Metrics.Exporters
+	}
+	if src.Algorithms.SJF.CutoffMs > 0 {
+		dst.Algorithms.SJF.CutoffMs = src.Algorithms.SJF.CutoffMs
+	}
+	if src.Algorithms.SJFLanes.Mode != "" {
+		dst.Algorithms.SJFLanes.Mode = src.Algorithms.SJFLanes.Mode
+	}
+	if src.Algorithms.SJFLanes.ShortWeight > 0 {
+		dst.Algorithms.SJFLanes.ShortWeight = src.Algorithms.SJFLanes.ShortWeight
+	}
+	if src.Algorithms.SJFLanes.LongWeight > 0 {
+		dst.Algorithms.SJFLanes.LongWeight = src.Algorithms.SJFLanes.LongWeight
+	}
+	if src.Algorithms.SJFBuckets.BoundariesMs != "" {
+		dst.Algorithms.SJFBuckets.BoundariesMs = src.Algorithms.SJFBuckets.BoundariesMs
+	}
+	if src.Algorithms.SJFPredicted.Estimator != "" {
+		dst.Algorithms.SJFPredicted.Estimator = src.Algorithms.SJFPredicted.Estimator
+	}
+	if src.Algorithms.SJFPredicted.Alpha > 0 {
+		dst.Algorithms.SJFPredicted.Alpha = src.Algorithms.SJFPredicted.Alpha
+	}
+	if src.Algorithms.SJFPredicted.ModelFile != "" {
+		dst.Algorithms.SJFPredicted.ModelFile = src.Algorithms.SJFPredicted.ModelFile
+	}
+	if src.Algorithms.SJFPredicted.Window > 0 {
+		dst.Algorithms.SJFPredicted.Window = src.Algorithms.SJFPredicted.Window
+	}
+	if src.Algorithms.SJFPredicted.Quantile > 0 {
+		dst.Algorithms.SJFPredicted.Quantile = src.Algorithms.SJFPredicted.Quantile
+	}
+	if src.Algorithms.Adaptive.Low != "" {
+		dst.Algorithms.Adaptive.Low = src.Algorithms.Adaptive.Low
+	}
+	if src.Algorithms.Adaptive.High != "" {
+		dst.Algorithms.Adaptive.High = src.Algorithms.Adaptive.High
+	}
+	if src.Algorithms.Adaptive.HighBacklog > 0 {
+		dst.Algorithms.Adaptive.HighBacklog = src.Algorithms.Adaptive.HighBacklog
+	}
+	if src.Algorithms.Adaptive.LowBacklog > 0 {
+		dst.Algorithms.Adaptive.LowBacklog = src.Algorithms.Adaptive.LowBacklog
+	}
+	if src.Algorithms.Adaptive.IntervalMs > 0 {
+		dst.Algorithms.Adaptive.IntervalMs = src.Algorithms.Adaptive.IntervalMs
+	}
+	if src.Algorithms.FairShare.HalfLifeMs > 0 {
+		dst.Algorithms.FairShare.HalfLifeMs = src.Algorithms.FairShare.HalfLifeMs
+	}
+	if src.Algorithms.Agent.Command != "" {
+		dst.Algorithms.Agent.Command = src.Algorithms.Agent.Command
+	}
+	if src.Algorithms.Agent.TimeoutMs > 0 {
+		dst.Algorithms.Agent.TimeoutMs = src.Algorithms.Agent.TimeoutMs
+	}
+	if src.Algorithms.Agent.Lookahead > 0 {
+		dst.Algorithms.Agent.Lookahead = src.Algorithms.Agent.Lookahead
+	}
+	if src.Algorithms.Batch.Size > 0 {
+		dst.Algorithms.Batch.Size = src.Algorithms.Batch.Size
+	}
+	if src.Algorithms.Batch.Lookahead > 0 {
+		dst.Algorithms.Batch.Lookahead = src.Algorithms.Batch.Lookahead
+	}
+	if len(src.Pipeline.Stages) > 0 {
+		dst.Pipeline.Stages = src.Pipeline.Stages
+	}
+}
 
-	fmt.Println("Configuration loaded from config.yaml")
-	return nil
+// mergeWorkProfile overrides the work profile fields that are set in src
+func mergeWorkProfile(dst *WorkProfile, src WorkProfile) {
+	if src.CPUMs > 0 {
+		dst.CPUMs = src.CPUMs
+	}
+	if src.Queries > 0 {
+		dst.Queries = src.Queries
+	}
+	if src.SleepMs > 0 {
+		dst.SleepMs = src.SleepMs
+	}
 }
 
 // Helper methods to get durations as time.Duration
-func (c *WorkloadConfig) ShortTaskDuration() time.Duration {
-	return time.Duration(c.ShortTaskDurationMs) * time.Millisecond
+// Capacity returns the number of tasks the queue can run at once across all executors
+func (c *QueueConfig) Capacity() int {
+	capacity := c.WorkerConcurrency * c.NumExecutors
+	if c.GlobalConcurrency > 0 && c.GlobalConcurrency < capacity {
+		capacity = c.GlobalConcurrency
+	}
+	return capacity
+}
+
+// withLookahead returns the configuration with lookahead more worker slots on each
+// executor, and on the global limit if there is one, for executors that dequeue tasks
+// ahead into a gate that keeps the tasks running at once to the capacity
+func (c QueueConfig) withLookahead(lookahead int) QueueConfig {
+	c.WorkerConcurrency += lookahead
+	if c.GlobalConcurrency > 0 {
+		c.GlobalConcurrency += lookahead * c.NumExecutors
+	}
+	return c
+}
+
+// BasePollingInterval returns the interval at which DBOS polls the queue
+func (c *QueueConfig) BasePollingInterval() time.Duration {
+	return time.Duration(c.BasePollingIntervalMs) * time.Millisecond
+}
+
+func (c *QueueConfig) MaxPollingInterval() time.Duration {
+	return time.Duration(c.MaxPollingIntervalMs) * time.Millisecond
+}
+
+// QueueOptions returns the DBOS queue options implementing the concurrency limits and
+// polling intervals
+func (c *QueueConfig) QueueOptions() []dbos.QueueOption {
+	opts := []dbos.QueueOption{
+		dbos.WithWorkerConcurrency(c.WorkerConcurrency),
+		dbos.WithQueueBasePollingInterval(c.BasePollingInterval()),
+		dbos.WithQueueMaxPollingInterval(c.MaxPollingInterval()),
+	}
+	if c.GlobalConcurrency > 0 {
+		opts = append(opts, dbos.WithGlobalConcurrency(c.GlobalConcurrency))
+	}
+	return opts
+}
+
+func (c *ProducerConfig) BackpressurePollInterval() time.Duration {
+	return time.Duration(c.BackpressurePollIntervalMs) * time.Millisecond
+}
+
+func (c *DatabaseConfig) MaxConnLifetime() time.Duration {
+	return time.Duration(c.MaxConnLifetimeS) * time.Second
+}
+
+func (c *DatabaseConfig) MaxConnIdleTime() time.Duration {
+	return time.Duration(c.MaxConnIdleTimeS) * time.Second
+}
+
+func (c *AutoscalerConfig) EvaluationInterval() time.Duration {
+	return time.Duration(c.EvaluationIntervalMs) * time.Millisecond
+}
+
+func (c *AutoscalerConfig) TargetP99Wait() time.Duration {
+	return time.Duration(c.TargetP99WaitMs) * time.Millisecond
+}
+
+func (c *AutoscalerConfig) ScaleUpCooldown() time.Duration {
+	return time.Duration(c.ScaleUpCooldownMs) * time.Millisecond
+}
+
+func (c *AutoscalerConfig) ScaleDownCooldown() time.Duration {
+	return time.Duration(c.ScaleDownCooldownMs) * time.Millisecond
+}
+
+// QueueConfig returns the queue configuration an autoscaled run is launched with: the
+// queue allows the maximum capacity and the autoscaler lowers it from there
+func (c *AutoscalerConfig) QueueConfig(queueCfg QueueConfig) QueueConfig {
+	queueCfg.WorkerConcurrency = (c.MaxCapacity + queueCfg.NumExecutors - 1) / queueCfg.NumExecutors
+	queueCfg.GlobalConcurrency = c.MaxCapacity
+	return queueCfg
+}
+
+func (c *SLOConfig) Threshold() time.Duration {
+	return time.Duration(c.ThresholdMs) * time.Millisecond
+}
+
+// Latency returns the task latency the SLO is about
+func (c *SLOConfig) Latency(task Task) time.Duration {
+	if c.Metric == "wait" {
+		return task.DequeueTime.Sub(task.ArrivalTime)
+	}
+	return task.CompletionTime.Sub(task.ArrivalTime)
+}
+
+func (c *StarvationConfig) MaxWait() time.Duration {
+	return time.Duration(c.MaxWaitMs) * time.Millisecond
+}
+
+func (c *WatchdogConfig) MaxWait() time.Duration {
+	return time.Duration(c.MaxWaitMs) * time.Millisecond
+}
+
+func (c *WatchdogConfig) ScanInterval() time.Duration {
+	return time.Duration(c.ScanIntervalMs) * time.Millisecond
 }
 
-func (c *WorkloadConfig) LongTaskDuration() time.Duration {
-	return time.Duration(c.LongTaskDurationMs) * time.Millisecond
+func (c *WebhookConfig) Timeout() time.Duration {
+	return time.Duration(c.TimeoutMs) * time.Millisecond
+}
+
+// Policy returns the watchdog in the form the sched package uses, nil when it is disabled
+func (c *WatchdogConfig) Policy() *sched.Watchdog {
+	if !c.Enabled {
+		return nil
+	}
+	return &sched.Watchdog{MaxWait: c.MaxWait(), ScanInterval: c.ScanInterval()}
+}
+
+// Policy returns the retry policy in the form the sched package uses
+func (c *RetryConfig) Policy() sched.RetryPolicy {
+	return sched.RetryPolicy{
+		MaxRetries:    c.MaxRetries,
+		BaseInterval:  time.Duration(c.BaseIntervalMs) * time.Millisecond,
+		MaxInterval:   time.Duration(c.MaxIntervalMs) * time.Millisecond,
+		BackoffFactor: c.BackoffFactor,
+	}
+}
+
+func (c *ClientRetryConfig) Timeout() time.Duration {
+	return time.Duration(c.TimeoutMs) * time.Millisecond
+}
+
+// Policy returns the client retries in the form the sched package uses, or nil when
+// clients don't time out
+func (c *ClientRetryConfig) Policy() *sched.ClientRetry {
+	if c.TimeoutMs == 0 {
+		return nil
+	}
+	return &sched.ClientRetry{
+		Timeout: c.Timeout(),
+		Backoff: sched.RetryPolicy{
+			MaxRetries:    c.MaxRetries,
+			BaseInterval:  time.Duration(c.BaseIntervalMs) * time.Millisecond,
+			MaxInterval:   time.Duration(c.MaxIntervalMs) * time.Millisecond,
+			BackoffFactor: c.BackoffFactor,
+		},
+	}
+}
+
+// Enabled reports whether any tenant is rate limited
+func (c *RateLimitConfig) Enabled() bool {
+	if c.Rate > 0 {
+		return true
+	}
+	for _, tenant := range c.Tenants {
+		if tenant.Rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy returns a fresh rate limiter in the form the sched package uses, or nil when no
+// tenant is rate limited. Each run needs its own, as it keeps the run's statistics.
+func (c *RateLimitConfig) Policy() *sched.RateLimiter {
+	if !c.Enabled() {
+		return nil
+	}
+	limiter := &sched.RateLimiter{
+		Default: sched.TokenBucket{Rate: c.Rate, Burst: c.Burst},
+		Tenants: make(map[string]sched.TokenBucket),
+		Delay:   c.Mode == "delay",
+	}
+	for _, tenant := range c.Tenants {
+		burst := tenant.Burst
+		if burst == 0 {
+			burst = c.Burst
+		}
+		limiter.Tenants[tenant.Tenant] = sched.TokenBucket{Rate: tenant.Rate, Burst: burst}
+	}
+	return limiter
+}
+
+// parseNamedInts parses a comma-separated list of name=value pairs with non-negative
+// integer values, e.g. "tenant-0=2,short=1". An empty list has no pairs.
+func parseNamedInts(list string) (map[string]int, error) {
+	values := make(map[string]int)
+	if list == "" {
+		return values, nil
+	}
+	for _, part := range strings.Split(list, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not name=value", part)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q must have a non-negative integer value", part)
+		}
+		if _, ok := values[name]; ok {
+			return nil, fmt.Errorf("%s is listed twice", name)
+		}
+		values[name] = n
+	}
+	return values, nil
+}
+
+// ParseSlots parses the reserved slots of each group, e.g. "tenant-0=2,short=1". An empty
+// list reserves nothing.
+func (c *ReservationsConfig) ParseSlots() (map[string]int, error) {
+	slots, err := parseNamedInts(c.Slots)
+	if err != nil {
+		return nil, err
+	}
+	for group, n := range slots {
+		if n == 0 {
+			return nil, fmt.Errorf("%s must reserve at least one slot", group)
+		}
+	}
+	return slots, nil
+}
+
+// Enabled reports whether any group has reserved slots
+func (c *ReservationsConfig) Enabled() bool {
+	return c.Slots != ""
+}
+
+// Policy returns the reservations in the form the sched package uses, with the rest of
+// the capacity shared, or nil without reservations
+func (c *ReservationsConfig) Policy(capacity int) *sched.Reservations {
+	if !c.Enabled() {
+		return nil
+	}
+	slots, err := c.ParseSlots()
+	if err != nil {
+		return nil
+	}
+	reservations := &sched.Reservations{Group: func(task Task) string { return task.TenantID }, Slots: slots}
+	if c.By == "class" {
+		reservations.Group = taskClass
+	}
+	reservations.Shared = capacity - reservations.Reserved()
+	return reservations
+}
+
+// QueueConfig returns the queue configuration executors dequeue with under reservations:
+// the lookahead on top of the worker slots of each executor, so that tasks with a free
+// slot can run while others wait at the gate for theirs
+func (c *ReservationsConfig) QueueConfig(queueCfg QueueConfig) QueueConfig {
+	return queueCfg.withLookahead(c.Lookahead)
+}
+
+// ParseClassSwitches parses the setup of specific classes, in milliseconds, e.g.
+// "long=200"
+func (c *ColdStartConfig) ParseClassSwitches() (map[string]int, error) {
+	return parseNamedInts(c.ClassSwitchMs)
+}
+
+// Enabled reports whether workers pay any setup
+func (c *ColdStartConfig) Enabled() bool {
+	if c.SwitchMs > 0 || c.WarmUpMs > 0 {
+		return true
+	}
+	classes, _ := c.ParseClassSwitches()
+	for _, ms := range classes {
+		if ms > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy returns the cold-start model in the form the sched package uses, or nil when
+// workers pay no setup
+func (c *ColdStartConfig) Policy() *sched.ColdStart {
+	if !c.Enabled() {
+		return nil
+	}
+	classes, _ := c.ParseClassSwitches()
+	coldStart := &sched.ColdStart{
+		Class:     taskClass,
+		Switch:    time.Duration(c.SwitchMs) * time.Millisecond,
+		Classes:   make(map[string]time.Duration, len(classes)),
+		WarmUp:    time.Duration(c.WarmUpMs) * time.Millisecond,
+		IdleAfter: time.Duration(c.IdleAfterMs) * time.Millisecond,
+	}
+	for class, ms := range classes {
+		coldStart.Classes[class] = time.Duration(ms) * time.Millisecond
+	}
+	return coldStart
+}
+
+func (c *MetricsConfig) HistogramMax() time.Duration {
+	return time.Duration(c.HistogramMaxMs) * time.Millisecond
+}
+
+func (c *MetricsConfig) HdrLogInterval() time.Duration {
+	return time.Duration(c.HdrLogIntervalMs) * time.Millisecond
+}
+
+// Window returns the length of the latency windows, 0 if off
+func (c *MetricsConfig) Window() time.Duration {
+	return time.Duration(c.WindowS) * time.Second
+}
+
+// ExporterNames returns the names of the exporters of each run, in order
+func (c *MetricsConfig) ExporterNames() []string {
+	var names []string
+	for _, name := range strings.Split(c.Exporters, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Cutoff returns the longest duration of a high-priority task
+func (c *SJFConfig) Cutoff(workload WorkloadConfig) time.Duration {
+	if c.CutoffMs > 0 {
+		return time.Duration(c.CutoffMs) * time.Millisecond
+	}
+	return workload.ShortTaskDuration()
+}
+
+// defaultSJFBucketBoundariesMs are the size buckets of SJF on size buckets when none are
+// configured: up to 100 ms, 1 s, 10 s, and longer
+const defaultSJFBucketBoundariesMs = "100,1000,10000"
+
+// ParseBoundaries parses the upper bounds of the size buckets, in milliseconds. An empty
+// list stands for the default buckets.
+func (c *SJFBucketsConfig) ParseBoundaries() ([]int, error) {
+	if c.BoundariesMs == "" {
+		return parseIntList(defaultSJFBucketBoundariesMs)
+	}
+	return parseIntList(c.BoundariesMs)
+}
+
+// HalfLife returns the time for a tenant's recent usage to decay by half
+func (c *FairShareConfig) HalfLife() time.Duration {
+	ms := c.HalfLifeMs
+	if ms == 0 {
+		ms = defaultFairShareHalfLifeMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Boundaries returns the upper bounds of the size buckets, or none if they don't parse
+// (which validation reports)
+func (c *SJFBucketsConfig) Boundaries() []time.Duration {
+	values, err := c.ParseBoundaries()
+	if err != nil {
+		return nil
+	}
+	boundaries := make([]time.Duration, len(values))
+	for i, ms := range values {
+		boundaries[i] = time.Duration(ms) * time.Millisecond
+	}
+	return boundaries
+}
+
+// Streaming reports whether a run of numTasks tasks is streamed
+func (c *MetricsConfig) Streaming(numTasks int) bool {
+	return c.StreamingThreshold > 0 && numTasks >= c.StreamingThreshold
+}
+
+// RunTagPrefix returns the prefix of the workflow IDs of tagged tasks, or "" without a tag
+func (c *QueueConfig) RunTagPrefix() string {
+	return tagPrefix(c.RunTag)
+}
+
+// tagPrefix returns the workflow ID prefix of the tasks of a run tag or run ID. Task
+// workflow IDs are the run ID followed by "-" and the task ID, see taskWorkflowID.
+func tagPrefix(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	return tag + "-"
+}
+
+// StageQueue returns the queue configuration of stage k of the pipeline. The global
+// concurrency limit only applies to the first stage, which tasks enter through.
+func (c *PipelineConfig) StageQueue(queueCfg QueueConfig, k int) QueueConfig {
+	if concurrency := c.Stages[k].WorkerConcurrency; concurrency > 0 {
+		queueCfg.WorkerConcurrency = concurrency
+	}
+	if k > 0 {
+		queueCfg.GlobalConcurrency = 0
+	}
+	return queueCfg
+}
+
+// StageQueues returns the queue configuration of every stage of the pipeline, or just
+// queueCfg without stages
+func (c *PipelineConfig) StageQueues(queueCfg QueueConfig) []QueueConfig {
+	if len(c.Stages) == 0 {
+		return []QueueConfig{queueCfg}
+	}
+	queues := make([]QueueConfig, len(c.Stages))
+	for k := range c.Stages {
+		queues[k] = c.StageQueue(queueCfg, k)
+	}
+	return queues
+}
+
+// WorkloadStages returns the stages of the pipeline as the workload package describes
+// them, with the worker slots each stage has across all executors
+func (c *PipelineConfig) WorkloadStages(queueCfg QueueConfig) []workload.Stage {
+	var stages []workload.Stage
+	for k, stage := range c.Stages {
+		stageQueue := c.StageQueue(queueCfg, k)
+		factor := stage.DurationFactor
+		if factor == 0 {
+			factor = 1
+		}
+		stages = append(stages, workload.Stage{DurationFactor: factor, Capacity: stageQueue.Capacity()})
+	}
+	return stages
+}
+
+func (c *QueueConfig) globalConcurrencyString() string {
+	if c.GlobalConcurrency > 0 {
+		return fmt.Sprintf("%d", c.GlobalConcurrency)
+	}
+	return "unlimited"
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// occupancySegment is a stretch of a run during which the worker slots and the queue
+// didn't change
+type occupancySegment struct {
+	Start, End   time.Time
+	Running      int           // Tasks occupying a worker slot
+	MinRunning   time.Duration // Duration of the shortest running task, 0 if none runs
+	WaitingShort int           // Short tasks waiting in the queue
+}
+
+// taskLeave returns when a task left the queue: when it was dispatched, or when its
+// client gave up on it while it was waiting
+func taskLeave(task Task) time.Time {
+	if dispatch, ran := taskDispatch(task); ran {
+		return dispatch
+	}
+	return task.CompletionTime
+}
+
+// occupancy splits a run into segments between the moments a task entered the queue,
+// left it or finished, and counts the running tasks and the waiting short tasks of each.
+// It also returns the number of worker slots the run used at its busiest.
+func occupancy(tasks []Task, isShort func(Task) bool) ([]occupancySegment, int) {
+	type event struct {
+		at                    time.Time
+		running, waitingShort int
+		duration              time.Duration // Of the task starting or finishing
+	}
+	var events []event
+	for _, task := range tasks {
+		// Every enqueue and leave is a segment boundary, even for long tasks
+		short := 0
+		if isShort(task) {
+			short = 1
+		}
+		events = append(events, event{at: taskEnqueue(task), waitingShort: short}, event{at: taskLeave(task), waitingShort: -short})
+		if dispatch, ran := taskDispatch(task); ran {
+			events = append(events,
+				event{at: dispatch, running: 1, duration: task.Duration},
+				event{at: task.CompletionTime, running: -1, duration: task.Duration})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+
+	// Counts are taken once every event of an instant is in, as a slot freed and taken
+	// again at the same instant stays busy
+	var segments []occupancySegment
+	var durations durationHeap
+	finished := make(map[time.Duration]int) // Durations of finished tasks still in the heap
+	running, waitingShort, slots := 0, 0, 0
+	for i, e := range events {
+		running += e.running
+		waitingShort += e.waitingShort
+		switch e.running {
+		case 1:
+			heap.Push(&durations, e.duration)
+		case -1:
+			finished[e.duration]++
+		}
+		if i+1 < len(events) && events[i+1].at.After(e.at) {
+			for durations.Len() > 0 && finished[durations[0]] > 0 {
+				finished[durations[0]]--
+				heap.Pop(&durations)
+			}
+			segment := occupancySegment{Start: e.at, End: events[i+1].at, Running: running, WaitingShort: waitingShort}
+			if durations.Len() > 0 {
+				segment.MinRunning = durations[0]
+			}
+			slots = max(slots, running)
+			segments = append(segments, segment)
+		}
+	}
+	return segments, slots
+}
+
+// durationHeap is a min-heap of task durations
+type durationHeap []time.Duration
+
+func (h durationHeap) Len() int           { return len(h) }
+func (h durationHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h durationHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *durationHeap) Push(x any)        { *h = append(*h, x.(time.Duration)) }
+func (h *durationHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// segmentIntegral sums a rate over the segments of a run, so that the sum over any
+// stretch of the run is two lookups
+type segmentIntegral struct {
+	segments []occupancySegment
+	rates    []float64
+	sums     []float64 // Integral of the rate up to the start of each segment, in rate·seconds
+}
+
+func newSegmentIntegral(segments []occupancySegment, rate func(occupancySegment) float64) *segmentIntegral {
+	f := &segmentIntegral{segments: segments, rates: make([]float64, len(segments)), sums: make([]float64, len(segments)+1)}
+	for i, s := range segments {
+		f.rates[i] = rate(s)
+		f.sums[i+1] = f.sums[i] + f.rates[i]*s.End.Sub(s.Start).Seconds()
+	}
+	return f
+}
+
+// upTo returns the integral of the rate from the start of the run to t
+func (f *segmentIntegral) upTo(t time.Time) float64 {
+	i := sort.Search(len(f.segments), func(i int) bool { return f.segments[i].End.After(t) })
+	if i == len(f.segments) {
+		return f.sums[i]
+	}
+	s := f.segments[i]
+	if t.Before(s.Start) {
+		return f.sums[i]
+	}
+	return f.sums[i] + f.rates[i]*t.Sub(s.Start).Seconds()
+}
+
+// Between returns the integral of the rate from one time to another
+func (f *segmentIntegral) Between(from, to time.Time) float64 {
+	return f.upTo(to) - f.upTo(from)
+}
+
+// ConvoySummary describes the convoys of a run: short tasks held up in the queue behind a
+// long task occupying a worker slot
+type ConvoySummary struct {
+	LongTasks int
+	Convoys   int // Long tasks with at least the minimum number of short tasks behind them
+	MeanSize  float64
+	MaxSize   int
+	// Wait of the short tasks behind the convoys' long tasks while every worker slot was
+	// busy, each long task accounting for its share of the slots
+	Delay     time.Duration
+	ShortWait time.Duration // Wait of every short task, for scale
+}
+
+// summarizeConvoys finds the convoys of a run. The convoy of a long task is the short
+// tasks that waited at some point while it ran; it counts once it has minSize of them.
+// While every slot is busy, a waiting short task is held up by each running long task
+// for 1/slots of the time, so with a single worker the convoy delay is all the wait of
+// the short tasks during the long task's run.
+func summarizeConvoys(tasks []Task, isShort func(Task) bool, minSize int) ConvoySummary {
+	var s ConvoySummary
+	segments, slots := occupancy(tasks, isShort)
+	if slots == 0 {
+		return s
+	}
+	held := newSegmentIntegral(segments, func(seg occupancySegment) float64 {
+		if seg.Running < slots {
+			return 0
+		}
+		return float64(seg.WaitingShort) / float64(slots)
+	})
+
+	// The short tasks waiting during [start, end) are those enqueued before the end, minus
+	// those that left the queue by the start
+	var enqueued, left []time.Time
+	for _, task := range tasks {
+		if !isShort(task) {
+			continue
+		}
+		leave := taskLeave(task)
+		s.ShortWait += leave.Sub(taskEnqueue(task))
+		// Tasks that found a free slot weren't held up by anything
+		if !leave.After(taskEnqueue(task)) {
+			continue
+		}
+		enqueued = append(enqueued, taskEnqueue(task))
+		left = append(left, leave)
+	}
+	sort.Slice(enqueued, func(i, j int) bool { return enqueued[i].Before(enqueued[j]) })
+	sort.Slice(left, func(i, j int) bool { return left[i].Before(left[j]) })
+	count := func(times []time.Time, before time.Time, inclusive bool) int {
+		return sort.Search(len(times), func(i int) bool {
+			if inclusive {
+				return times[i].After(before)
+			}
+			return !times[i].Before(before)
+		})
+	}
+
+	totalSize := 0
+	for _, task := range tasks {
+		start, ran := taskDispatch(task)
+		if isShort(task) || !ran {
+			continue
+		}
+		s.LongTasks++
+		size := count(enqueued, task.CompletionTime, false) - count(left, start, true)
+		if size < minSize {
+			continue
+		}
+		s.Convoys++
+		totalSize += size
+		s.MaxSize = max(s.MaxSize, size)
+		s.Delay += time.Duration(held.Between(start, task.CompletionTime) * float64(time.Second))
+	}
+	if s.Convoys > 0 {
+		s.MeanSize = float64(totalSize) / float64(s.Convoys)
+	}
+	return s
+}
+
+// printConvoyReport prints the convoys of a run, short tasks stuck behind a long one.
+// Pipeline tasks run in several queues, so it prints nothing for pipelines.
+func printConvoyReport(tasks []Task, policy SchedulingPolicy) {
+	if len(AppConfig.Pipeline.Stages) > 0 {
+		return
+	}
+	isShort := func(task Task) bool { return taskClass(task) == "short" }
+	s := summarizeConvoys(tasks, isShort, AppConfig.Convoys.MinSize)
+	if s.LongTasks == 0 {
+		return
+	}
+	fmt.Printf("\nConvoys (%s, %d or more short tasks waiting behind a long one):\n", policy.Name, AppConfig.Convoys.MinSize)
+	fmt.Printf("  %d convoys behind %d long tasks", s.Convoys, s.LongTasks)
+	if s.Convoys > 0 {
+		fmt.Printf(": %.1f short tasks on average, up to %d", s.MeanSize, s.MaxSize)
+	}
+	fmt.Println()
+	if s.ShortWait > 0 {
+		fmt.Printf("  Short task wait attributable to convoys: %.3f s (%.1f%% of the short tasks' wait)\n",
+			s.Delay.Seconds(), 100*float64(s.Delay)/float64(s.ShortWait))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// CostBreakdown is the cost of a run under the cost model: provisioned capacity plus
+// penalties for SLO violations and for the time tasks spent in the system
+type CostBreakdown struct {
+	WorkerSeconds  float64 // Task slots provisioned × seconds
+	Violations     int     // Task SLO violations, summed over every SLO
+	LatencySeconds float64 // Sum of task response times
+	CapacityCost   float64
+	ViolationCost  float64
+	LatencyCost    float64
+	Total          float64
+}
+
+// fixedWorkerSeconds returns the slot-seconds provisioned by a fixed capacity from the
+// first arrival to the last completion
+func fixedWorkerSeconds(tasks []Task, capacity int) float64 {
+	if len(tasks) == 0 {
+		return 0
+	}
+	first, last := tasks[0].ArrivalTime, tasks[0].CompletionTime
+	for _, task := range tasks {
+		if task.ArrivalTime.Before(first) {
+			first = task.ArrivalTime
+		}
+		if task.CompletionTime.After(last) {
+			last = task.CompletionTime
+		}
+	}
+	return float64(capacity) * last.Sub(first).Seconds()
+}
+
+// computeCost applies the cost model to a run
+func computeCost(cfg CostConfig, tasks []Task, workerSeconds float64, slos []SLOResult) CostBreakdown {
+	cost := CostBreakdown{WorkerSeconds: workerSeconds}
+	for _, result := range slos {
+		cost.Violations += result.Violations
+	}
+	var latency time.Duration
+	for _, task := range tasks {
+		latency += task.CompletionTime.Sub(task.ArrivalTime)
+	}
+	cost.LatencySeconds = latency.Seconds()
+
+	cost.CapacityCost = cfg.WorkerSecondCost * cost.WorkerSeconds
+	cost.ViolationCost = cfg.SLOViolationCost * float64(cost.Violations)
+	cost.LatencyCost = cfg.LatencySecondCost * cost.LatencySeconds
+	cost.Total = cost.CapacityCost + cost.ViolationCost + cost.LatencyCost
+	return cost
+}
+
+// Print reports the total cost and what it is made of
+func (c CostBreakdown) Print() {
+	fmt.Printf("\nCost:\n")
+	fmt.Printf("  Capacity: %.1f worker-seconds = %.4f\n", c.WorkerSeconds, c.CapacityCost)
+	fmt.Printf("  SLO violations: %d = %.4f\n", c.Violations, c.ViolationCost)
+	fmt.Printf("  Latency: %.1f task-seconds = %.4f\n", c.LatencySeconds, c.LatencyCost)
+	fmt.Printf("  Total: %.4f\n", c.Total)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// cpuWorkChunk is the number of spin iterations between cancellation checks
+const cpuWorkChunk = 10000
+
+// cpuWorkCalibration is the number of spin iterations one core runs per millisecond,
+// measured once per process
+var cpuWorkCalibration struct {
+	once  sync.Once
+	perMs float64
+}
+
+// cpuWorkSink keeps the compiler from optimizing the spin loop away
+var cpuWorkSink uint64
+
+// spin runs n iterations of a xorshift generator, a cheap integer workload that keeps a
+// core busy without touching memory
+func spin(n int, state uint64) uint64 {
+	for range n {
+		state ^= state << 13
+		state ^= state >> 7
+		state ^= state << 17
+	}
+	return state
+}
+
+// calibrateCPUWork measures how many spin iterations run per millisecond on an idle core.
+// Executors calibrate when they start, so tasks don't pay for it.
+func calibrateCPUWork() float64 {
+	cpuWorkCalibration.once.Do(func() {
+		// Warm up, then take the median of a few rounds to filter out interruptions
+		state := spin(cpuWorkChunk, 1)
+		const iterations = 1000 * cpuWorkChunk
+		rounds := make([]time.Duration, 5)
+		for i := range rounds {
+			start := time.Now()
+			state = spin(iterations, state)
+			rounds[i] = time.Since(start)
+		}
+		slices.Sort(rounds)
+		cpuWorkSink = state
+		cpuWorkCalibration.perMs = float64(iterations) / (float64(rounds[len(rounds)/2]) / float64(time.Millisecond))
+		fmt.Printf("CPU work calibrated: %.0f iterations per ms\n", cpuWorkCalibration.perMs)
+	})
+	return cpuWorkCalibration.perMs
+}
+
+// cpuWork spins through the amount of CPU work that takes duration on an idle core. When
+// workers compete for cores, it takes longer, as real CPU-bound tasks do.
+func cpuWork(ctx context.Context, duration time.Duration) error {
+	remaining := int(calibrateCPUWork() * float64(duration) / float64(time.Millisecond))
+	state := uint64(duration) | 1
+	for remaining > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := min(remaining, cpuWorkChunk)
+		state = spin(n, state)
+		remaining -= n
+	}
+	cpuWorkSink = state
+	return nil
+}
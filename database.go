@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolSampleInterval is how often the pool monitor samples connection usage
+const poolSampleInterval = 50 * time.Millisecond
+
+// newPool opens a connection pool to the queue database using the database configuration
+func newPool(ctx context.Context, cfg DatabaseConfig) (*pgxpool.Pool, error) {
+	return newPoolWithLatency(ctx, cfg, 0)
+}
+
+// newPoolWithLatency opens a connection pool to the queue database whose connections add
+// the given round-trip latency to their traffic, as if Postgres were that far away
+func newPoolWithLatency(ctx context.Context, cfg DatabaseConfig, latency time.Duration) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	poolConfig.MaxConns = int32(cfg.PoolMaxConns)
+	poolConfig.MinConns = int32(cfg.PoolMinConns)
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime()
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime()
+	poolConfig.ConnConfig.ConnectTimeout = 10 * time.Second
+	if cfg.StatementTimeoutMs > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = fmt.Sprintf("%d", cfg.StatementTimeoutMs)
+	}
+	if latency > 0 {
+		dial := poolConfig.ConnConfig.DialFunc
+		poolConfig.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return newLatencyConn(conn, latency), nil
+		}
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	return pool, nil
+}
+
+// poolMonitor tracks how close connection pools get to saturation during a run
+type poolMonitor struct {
+	mu    sync.Mutex
+	pools map[string]*pgxpool.Pool
+	peak  map[string]int32 // Highest number of connections in use at once
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newPoolMonitor() *poolMonitor {
+	m := &poolMonitor{
+		pools: make(map[string]*pgxpool.Pool),
+		peak:  make(map[string]int32),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go m.sample()
+	return m
+}
+
+// Add starts monitoring a pool under the given name
+func (m *poolMonitor) Add(name string, pool *pgxpool.Pool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools[name] = pool
+}
+
+func (m *poolMonitor) sample() {
+	defer close(m.done)
+	ticker := time.NewTicker(poolSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			for name, pool := range m.pools {
+				if acquired := pool.Stat().AcquiredConns(); acquired > m.peak[name] {
+					m.peak[name] = acquired
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// Stop stops sampling. Pool statistics stay readable until the pools are closed.
+func (m *poolMonitor) Stop() {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+		<-m.done
+	}
+}
+
+// Usage returns the connections in use across the pools, and how many connection
+// acquires so far had to wait for one, out of how many
+func (m *poolMonitor) Usage() (inUse int32, waited, acquires int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, pool := range m.pools {
+		stat := pool.Stat()
+		inUse += stat.AcquiredConns()
+		waited += stat.EmptyAcquireCount()
+		acquires += stat.AcquireCount()
+	}
+	return inUse, waited, acquires
+}
+
+// Print reports, for each pool, how often acquiring a connection had to wait
+func (m *poolMonitor) Print() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.pools) == 0 {
+		return
+	}
+
+	fmt.Printf("\nConnection pools:\n")
+	for _, name := range sortedKeys(m.pools) {
+		stat := m.pools[name].Stat()
+		acquires := stat.AcquireCount()
+		var waited float64
+		var meanAcquire time.Duration
+		if acquires > 0 {
+			waited = 100 * float64(stat.EmptyAcquireCount()) / float64(acquires)
+			meanAcquire = stat.AcquireDuration() / time.Duration(acquires)
+		}
+		fmt.Printf("  %s: peak %d/%d connections in use, %d acquires, %.1f%% waited for a connection, mean acquire %v, %d canceled\n",
+			name, m.peak[name], stat.MaxConns(), acquires, waited, meanAcquire, stat.CanceledAcquireCount())
+		if m.peak[name] >= stat.MaxConns() {
+			fmt.Printf("    Warning: pool saturated; consider raising database.pool_max_conns\n")
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+)
+
+// deadLetterQueueName is the DBOS queue tasks that fail for good are routed to when
+// retry.dead_letter is set. It is shared by every algorithm; workflow IDs tell runs apart.
+const deadLetterQueueName = "dead_letter_queue"
+
+// deadLetterWorkflowID returns the workflow ID of a task in the dead-letter queue, given
+// the workflow ID it failed in
+func deadLetterWorkflowID(workflowID string) string {
+	return workflowID + ".dead_letter"
+}
+
+// deadLetterTask is the workflow a failed task is parked as in the dead-letter queue. It
+// does no work: its output keeps the failed task, with its attempts, for inspection or a
+// later replay.
+func deadLetterTask(ctx dbos.DBOSContext, task Task) (Task, error) {
+	return task, nil
+}
+
+// routeToDeadLetter enqueues a task that failed for good to the dead-letter queue
+func routeToDeadLetter(ctx dbos.DBOSContext, task Task, workflowID string) error {
+	_, err := dbos.RunWorkflow(ctx, deadLetterTask, task,
+		dbos.WithQueue(deadLetterQueueName), dbos.WithWorkflowID(deadLetterWorkflowID(workflowID)))
+	return err
+}
+
+// workerTime returns how long a task held worker slots: from the start of its first
+// attempt to the end of its last one, in every pipeline stage it went through
+func workerTime(task Task) time.Duration {
+	if len(task.Stages) == 0 {
+		return task.CompletionTime.Sub(task.DequeueTime)
+	}
+	var total time.Duration
+	for _, stage := range task.Stages {
+		total += stage.CompletionTime.Sub(stage.DequeueTime)
+	}
+	return total
+}
+
+// DeadLetterSummary describes the tasks routed to the dead-letter queue
+type DeadLetterSummary struct {
+	Tasks       int            // Every task of the run
+	DeadLetters int            // Tasks in the dead-letter queue
+	Reasons     map[string]int // Dead letters by failure reason
+	Wasted      time.Duration  // Worker time spent on the dead letters
+	WorkerTime  time.Duration  // Worker time spent on every task
+}
+
+// summarizeDeadLetters counts the dead letters by reason and the worker time they wasted
+func summarizeDeadLetters(tasks []Task) DeadLetterSummary {
+	summary := DeadLetterSummary{Tasks: len(tasks), Reasons: make(map[string]int)}
+	for _, task := range tasks {
+		summary.WorkerTime += workerTime(task)
+		if !task.DeadLettered {
+			continue
+		}
+		summary.DeadLetters++
+		summary.Reasons[task.FailureReason()]++
+		summary.Wasted += workerTime(task)
+	}
+	return summary
+}
+
+// printDeadLetterReport prints how many tasks ended up in the dead-letter queue, why, and
+// how much worker time they wasted. It prints nothing when no task was dead-lettered.
+func printDeadLetterReport(tasks []Task) {
+	s := summarizeDeadLetters(tasks)
+	if s.DeadLetters == 0 {
+		return
+	}
+	fmt.Printf("\nDead-letter queue:\n")
+	fmt.Printf("  %d/%d tasks dead-lettered (%.1f%%)\n", s.DeadLetters, s.Tasks, 100*float64(s.DeadLetters)/float64(s.Tasks))
+	for _, reason := range sortedKeys(s.Reasons) {
+		fmt.Printf("    %s: %d\n", reason, s.Reasons[reason])
+	}
+	fmt.Printf("  Wasted worker time: %.3f s (%.1f%% of the worker time of all tasks)\n",
+		s.Wasted.Seconds(), 100*s.Wasted.Seconds()/s.WorkerTime.Seconds())
+}
+
+// deadLetterFilename returns the dead-letter export that goes with a results file
+func deadLetterFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_dead_letter.csv"
+}
+
+// exportDeadLetters writes the tasks in the dead-letter queue with their failure reason
+// and wasted worker time. It writes nothing when no task was dead-lettered.
+func exportDeadLetters(tasks []Task, filename string) error {
+	if summarizeDeadLetters(tasks).DeadLetters == 0 {
+		return nil
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create dead-letter CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"task_id", "class", "reason", "attempts", "wasted_ms"})
+	for _, task := range tasks {
+		if !task.DeadLettered {
+			continue
+		}
+		writer.Write([]string{
+			fmt.Sprintf("%d", task.TaskID),
+			taskClass(task),
+			task.FailureReason(),
+			fmt.Sprintf("%d", task.Attempts),
+			fmt.Sprintf("%.3f", workerTime(task).Seconds()*1000),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write dead-letter CSV file: %w", err)
+	}
+	fmt.Printf("Dead letters exported to %s\n", filename)
+	return nil
+}
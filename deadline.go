@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeadlineSummary holds deadline statistics of the tasks that have a deadline
+type DeadlineSummary struct {
+	Tasks        int
+	Missed       int
+	MeanLateness time.Duration // Completion - deadline, negative when early
+	MeanTardy    time.Duration // Mean of max(0, lateness)
+	MaxTardy     time.Duration
+}
+
+// MissRatio returns the fraction of tasks that finished after their deadline
+func (s DeadlineSummary) MissRatio() float64 {
+	if s.Tasks == 0 {
+		return 0
+	}
+	return float64(s.Missed) / float64(s.Tasks)
+}
+
+// summarizeDeadlines computes deadline statistics for the tasks matching filter. Tasks
+// without a deadline are skipped.
+func summarizeDeadlines(tasks []Task, filter func(Task) bool) DeadlineSummary {
+	var summary DeadlineSummary
+	var totalLateness, totalTardiness time.Duration
+	for _, task := range tasks {
+		if task.Deadline.IsZero() || (filter != nil && !filter(task)) {
+			continue
+		}
+		late := task.Lateness()
+		summary.Tasks++
+		totalLateness += late
+		if late > 0 {
+			summary.Missed++
+			totalTardiness += late
+			summary.MaxTardy = max(summary.MaxTardy, late)
+		}
+	}
+	if summary.Tasks > 0 {
+		summary.MeanLateness = totalLateness / time.Duration(summary.Tasks)
+		summary.MeanTardy = totalTardiness / time.Duration(summary.Tasks)
+	}
+	return summary
+}
+
+// printDeadlineReport prints the miss ratio and tardiness overall and per class
+func printDeadlineReport(tasks []Task) {
+	all := summarizeDeadlines(tasks, nil)
+	if all.Tasks == 0 {
+		return
+	}
+	fmt.Printf("\nDeadlines:\n")
+	for _, group := range []struct {
+		name    string
+		summary DeadlineSummary
+	}{
+		{"All", all},
+		{"Short", summarizeDeadlines(tasks, func(task Task) bool { return taskClass(task) == "short" })},
+		{"Long", summarizeDeadlines(tasks, func(task Task) bool { return taskClass(task) == "long" })},
+	} {
+		s := group.summary
+		if s.Tasks == 0 {
+			continue
+		}
+		fmt.Printf("  %s tasks: miss ratio %.2f%% (%d/%d), mean lateness %s ms, mean tardiness %s ms, max tardiness %s ms\n",
+			group.name, s.MissRatio()*100, s.Missed, s.Tasks, formatMs(s.MeanLateness), formatMs(s.MeanTardy), formatMs(s.MaxTardy))
+	}
+}
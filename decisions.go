@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// decision is one dispatch: a free worker slot took a task while others were waiting
+type decision struct {
+	Time       time.Time
+	TaskID     int
+	Priority   uint
+	PassedOver []int // Tasks still waiting after the decision, in task ID order
+	OutOfOrder []int // The passed-over tasks the policy ranks ahead of the chosen one
+}
+
+// QueueSize returns how many tasks were waiting when the decision was made, the chosen
+// one included
+func (d decision) QueueSize() int {
+	return len(d.PassedOver) + 1
+}
+
+// taskDispatch returns when the queue gave a task to a worker slot, and false if the task
+// never left the queue for a slot, as its client gave up on it while it was waiting
+func taskDispatch(task Task) (time.Time, bool) {
+	if !task.Ran() {
+		return time.Time{}, false
+	}
+	if !task.StartedAt.IsZero() {
+		return task.StartedAt, true
+	}
+	return task.DequeueTime, true
+}
+
+// taskEnqueue returns when a task entered the queue
+func taskEnqueue(task Task) time.Time {
+	if !task.EnqueuedAt.IsZero() {
+		return task.EnqueuedAt
+	}
+	return task.ArrivalTime
+}
+
+// decisions rebuilds the dispatch decisions of a run from the times its tasks entered
+// and left the queue. The queue makes its decisions inside Postgres, so a decision is
+// known by the task it dispatched; the tasks waiting at the time are those enqueued but
+// not dispatched yet. Decisions made at the same instant are taken in policy order. The
+// tasks their client gave up on while waiting count as waiting until then.
+func decisions(tasks []Task, priority func(Task) uint) []decision {
+	rank := func(task Task) uint {
+		if priority == nil {
+			return 0
+		}
+		return priority(task)
+	}
+	// ahead tells whether the policy runs a before b: by priority, then enqueue time
+	ahead := func(a, b Task) bool {
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if !taskEnqueue(a).Equal(taskEnqueue(b)) {
+			return taskEnqueue(a).Before(taskEnqueue(b))
+		}
+		return a.TaskID < b.TaskID
+	}
+
+	var dispatched, dropped []Task
+	for _, task := range tasks {
+		if _, ok := taskDispatch(task); ok {
+			dispatched = append(dispatched, task)
+		} else {
+			dropped = append(dropped, task)
+		}
+	}
+	sort.SliceStable(dispatched, func(i, j int) bool {
+		ti, _ := taskDispatch(dispatched[i])
+		tj, _ := taskDispatch(dispatched[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return ahead(dispatched[i], dispatched[j])
+	})
+	sort.SliceStable(dropped, func(i, j int) bool { return dropped[i].CompletionTime.Before(dropped[j].CompletionTime) })
+	arrivals := append([]Task(nil), tasks...)
+	sort.SliceStable(arrivals, func(i, j int) bool { return taskEnqueue(arrivals[i]).Before(taskEnqueue(arrivals[j])) })
+
+	waiting := make(map[int]Task)
+	result := make([]decision, 0, len(dispatched))
+	nextArrival, nextDrop := 0, 0
+	for _, chosen := range dispatched {
+		at, _ := taskDispatch(chosen)
+		for ; nextArrival < len(arrivals) && !taskEnqueue(arrivals[nextArrival]).After(at); nextArrival++ {
+			waiting[arrivals[nextArrival].TaskID] = arrivals[nextArrival]
+		}
+		for ; nextDrop < len(dropped) && !dropped[nextDrop].CompletionTime.After(at); nextDrop++ {
+			delete(waiting, dropped[nextDrop].TaskID)
+		}
+		delete(waiting, chosen.TaskID)
+
+		d := decision{Time: at, TaskID: chosen.TaskID, Priority: rank(chosen), PassedOver: make([]int, 0, len(waiting))}
+		for id, task := range waiting {
+			d.PassedOver = append(d.PassedOver, id)
+			if ahead(task, chosen) {
+				d.OutOfOrder = append(d.OutOfOrder, id)
+			}
+		}
+		sort.Ints(d.PassedOver)
+		sort.Ints(d.OutOfOrder)
+		result = append(result, d)
+	}
+	return result
+}
+
+// DecisionSummary describes how closely the dispatch decisions of a run followed the
+// policy's order
+type DecisionSummary struct {
+	Decisions    int
+	MaxQueueSize int
+	OutOfOrder   int // Decisions that passed over a task the policy ranks ahead
+	Inversions   int // Tasks passed over out of order, summed over the decisions
+}
+
+// summarizeDecisions counts the decisions and those that broke the policy's order
+func summarizeDecisions(log []decision) DecisionSummary {
+	s := DecisionSummary{Decisions: len(log)}
+	for _, d := range log {
+		s.MaxQueueSize = max(s.MaxQueueSize, d.QueueSize())
+		if len(d.OutOfOrder) > 0 {
+			s.OutOfOrder++
+			s.Inversions += len(d.OutOfOrder)
+		}
+	}
+	return s
+}
+
+// decisionLogFilename returns the decision log file that goes with a results file
+func decisionLogFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_decisions.csv"
+}
+
+// exportTaskLogs writes the per-task logs and latency windows of a run that are enabled
+// in the metrics section next to its results file. They need every task of the run,
+// including those whose client gave up.
+func exportTaskLogs(tasks []Task, policy SchedulingPolicy, resultsFile string) error {
+	if AppConfig.Metrics.DecisionLog {
+		if err := exportDecisionLog(tasks, policy, decisionLogFilename(resultsFile)); err != nil {
+			return err
+		}
+	}
+	if AppConfig.Metrics.Timeline != "off" {
+		if err := exportTimeline(tasks, AppConfig.Metrics.Timeline, resultsFile); err != nil {
+			return err
+		}
+	}
+	return reportLatencyWindows(latencyWindowsOf(tasks), resultsFile)
+}
+
+// exportDecisionLog writes the dispatch decisions of a run in order, and prints how many
+// of them broke the policy's order. Every task of the run is needed, including those
+// whose client gave up, as they were waiting too.
+func exportDecisionLog(tasks []Task, policy SchedulingPolicy, filename string) error {
+	log := decisions(tasks, policy.Priority)
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create decision log file: %w", err)
+	}
+	defer file.Close()
+
+	joinIDs := func(ids []int) string {
+		parts := make([]string, len(ids))
+		for i, id := range ids {
+			parts[i] = fmt.Sprintf("%d", id)
+		}
+		return strings.Join(parts, " ")
+	}
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"decision", "time", "task_id", "priority", "queue_size", "passed_over", "out_of_order"})
+	for i, d := range log {
+		writer.Write([]string{
+			fmt.Sprintf("%d", i),
+			d.Time.Format(time.RFC3339Nano),
+			fmt.Sprintf("%d", d.TaskID),
+			fmt.Sprintf("%d", d.Priority),
+			fmt.Sprintf("%d", d.QueueSize()),
+			joinIDs(d.PassedOver),
+			joinIDs(d.OutOfOrder),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write decision log file: %w", err)
+	}
+	fmt.Printf("Decision log exported to %s\n", filename)
+
+	s := summarizeDecisions(log)
+	fmt.Printf("\nDispatch decisions (%s):\n", policy.Name)
+	fmt.Printf("  %d decisions, up to %d tasks waiting\n", s.Decisions, s.MaxQueueSize)
+	if s.Decisions > 0 {
+		fmt.Printf("  %d decisions (%.1f%%) passed over a task the policy ranks ahead, %d tasks passed over out of order\n",
+			s.OutOfOrder, 100*float64(s.OutOfOrder)/float64(s.Decisions), s.Inversions)
+	}
+	return nil
+}
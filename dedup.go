@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// DedupStats counts what happened to duplicate requests during a run
+type DedupStats struct {
+	Suppressed     int           // Duplicates rejected by DBOS because the original was still active
+	Admitted       int           // Duplicates that ran because the original had already completed
+	SuppressedWork time.Duration // Service time saved by suppressed duplicates
+	AdmittedWork   time.Duration // Service time spent on admitted duplicates
+	OfferedWork    time.Duration // Service time of every generated request, duplicates included
+}
+
+// Print reports the duplicate counts and how deduplication changed the load on the queue
+func (d *DedupStats) Print(targetUtilization float64) {
+	executedWork := d.OfferedWork - d.SuppressedWork
+	effectiveUtilization := targetUtilization
+	if d.OfferedWork > 0 {
+		effectiveUtilization = targetUtilization * float64(executedWork) / float64(d.OfferedWork)
+	}
+
+	fmt.Printf("\nDeduplication:\n")
+	fmt.Printf("  Duplicates suppressed: %d (%v of work avoided)\n", d.Suppressed, d.SuppressedWork)
+	fmt.Printf("  Duplicates admitted: %d (%v of extra work)\n", d.Admitted, d.AdmittedWork)
+	fmt.Printf("  Effective utilization: %.1f%% (target %.0f%%)\n", effectiveUtilization*100, targetUtilization*100)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// departure is one task completion, with the time since the previous completion
+type departure struct {
+	TaskID     int
+	Completion time.Time
+	Gap        time.Duration // Inter-departure time, 0 for the first departure
+}
+
+// departures returns the completions of the tasks in completion order
+func departures(tasks []Task) []departure {
+	result := make([]departure, len(tasks))
+	for i, task := range tasks {
+		result[i] = departure{TaskID: task.TaskID, Completion: task.CompletionTime}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Completion.Before(result[j].Completion) })
+	for i := 1; i < len(result); i++ {
+		result[i].Gap = result[i].Completion.Sub(result[i-1].Completion)
+	}
+	return result
+}
+
+// DepartureSummary describes the departure process of a run next to its arrival process.
+// A coefficient of variation (CV) of 1 is what a Poisson process has; 0 means perfectly
+// regular gaps.
+type DepartureSummary struct {
+	InterDeparture metrics.Summary
+	DepartureCV    float64
+	InterArrival   metrics.Summary
+	ArrivalCV      float64
+}
+
+// summarizeDepartures computes the distribution of inter-departure and inter-arrival times
+func summarizeDepartures(tasks []Task) DepartureSummary {
+	var summary DepartureSummary
+	if len(tasks) < 2 {
+		return summary
+	}
+	var departureGaps []time.Duration
+	for _, d := range departures(tasks)[1:] {
+		departureGaps = append(departureGaps, d.Gap)
+	}
+	arrivals := make([]time.Time, len(tasks))
+	for i, task := range tasks {
+		arrivals[i] = task.ArrivalTime
+	}
+	sort.Slice(arrivals, func(i, j int) bool { return arrivals[i].Before(arrivals[j]) })
+	arrivalGaps := make([]time.Duration, 0, len(arrivals)-1)
+	for i := 1; i < len(arrivals); i++ {
+		arrivalGaps = append(arrivalGaps, arrivals[i].Sub(arrivals[i-1]))
+	}
+
+	summary.DepartureCV = metrics.CV(departureGaps)
+	summary.ArrivalCV = metrics.CV(arrivalGaps)
+	summary.InterDeparture = metrics.Summarize(departureGaps)
+	summary.InterArrival = metrics.Summarize(arrivalGaps)
+	return summary
+}
+
+// printDepartureReport prints the distribution of inter-departure times next to that of
+// inter-arrival times. In a stable queue the means match; the CVs show how the queue
+// reshapes the arrival process, which matters when departures feed a downstream queue.
+func printDepartureReport(tasks []Task) {
+	s := summarizeDepartures(tasks)
+	if s.InterDeparture.Count == 0 {
+		return
+	}
+	fmt.Printf("\nDeparture process:\n")
+	fmt.Printf("  Inter-departure time: mean %s ms, median %s ms, p99 %s ms, max %s ms, CV %.2f\n",
+		formatMs(s.InterDeparture.Mean), formatMs(s.InterDeparture.Median), formatMs(s.InterDeparture.P99),
+		formatMs(s.InterDeparture.Max), s.DepartureCV)
+	fmt.Printf("  Inter-arrival time: mean %s ms, median %s ms, p99 %s ms, max %s ms, CV %.2f\n",
+		formatMs(s.InterArrival.Mean), formatMs(s.InterArrival.Median), formatMs(s.InterArrival.P99),
+		formatMs(s.InterArrival.Max), s.ArrivalCV)
+}
+
+// departuresFilename returns the departure series file that goes with a results file
+func departuresFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_departures.csv"
+}
+
+// exportDepartures writes the departures of the tasks in completion order, with their
+// inter-departure times
+func exportDepartures(tasks []Task, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create departures CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"departure", "task_id", "completion_time", "inter_departure_ms"})
+	for i, d := range departures(tasks) {
+		writer.Write([]string{
+			fmt.Sprintf("%d", i),
+			fmt.Sprintf("%d", d.TaskID),
+			d.Completion.Format(time.RFC3339Nano),
+			fmt.Sprintf("%.3f", d.Gap.Seconds()*1000),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write departures CSV file: %w", err)
+	}
+	fmt.Printf("Departures exported to %s\n", filename)
+	return nil
+}
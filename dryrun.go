@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// dryRun generates the workload a run of the policy would enqueue, without touching the
+// database, and reports its offered load. The schedule is written as CSV to outPath, or
+// printed when outPath is empty. Its columns are those of a trace, so the schedule can be
+// replayed with workload.trace_file.
+func dryRun(policy SchedulingPolicy, queueCfg QueueConfig, outPath string) error {
+	if AppConfig.Autoscaler.Enabled {
+		queueCfg = AppConfig.Autoscaler.QueueConfig(queueCfg)
+	}
+	capacity := queueCfg.Capacity()
+	avgTaskDuration, interArrivalTime := shapeWorkload(queueCfg)
+	cfg := sizeWorkload(interArrivalTime)
+	printRunBanner(policy, queueCfg, avgTaskDuration, interArrivalTime)
+	fmt.Println("Dry run: nothing is enqueued")
+
+	// The schedule goes to the output file, or to the terminal
+	var out io.Writer = os.Stdout
+	if outPath != "" {
+		file, err := os.Create(outPath)
+		if err != nil {
+			return fmt.Errorf("failed to create schedule file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	} else {
+		fmt.Println()
+	}
+	writer := csv.NewWriter(out)
+	if err := writer.Write(workload.TraceHeader); err != nil {
+		return err
+	}
+
+	generator := workload.NewGenerator(cfg, interArrivalTime, time.Now().UnixNano())
+	var shortCount, duplicates int
+	var totalWork, lastArrival time.Duration
+	for range cfg.NumTasks {
+		task, offset := generator.Next()
+		if cfg.IsShort(task.Duration) {
+			shortCount++
+		}
+		// Duplicates are suppressed by DBOS while the original is pending, so they are
+		// not counted as work
+		if task.Duplicate {
+			duplicates++
+		} else {
+			totalWork += task.Duration
+		}
+		lastArrival = offset
+
+		// The deadline follows from the arrival, wherever it falls
+		deadline := ""
+		generator.Arrive(&task, time.Time{}.Add(offset))
+		if !task.Deadline.IsZero() {
+			deadline = fmt.Sprintf("%.3f", task.Deadline.Sub(task.ArrivalTime).Seconds()*1000)
+		}
+		row := []string{
+			strconv.Itoa(task.TaskID),
+			fmt.Sprintf("%.3f", offset.Seconds()*1000),
+			fmt.Sprintf("%.0f", float64(task.Duration.Milliseconds())),
+			strconv.FormatBool(task.Duplicate),
+			task.TenantID,
+			task.JobID,
+			deadline,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	// Offered load is the work arriving per unit of time, in busy task slots. The span
+	// includes one more inter-arrival time so a single task has a non-zero span.
+	span := lastArrival + interArrivalTime
+	offeredLoad := float64(totalWork) / float64(span)
+
+	fmt.Println("\n============================================================")
+	fmt.Println("Generated workload")
+	fmt.Println("============================================================")
+	fmt.Printf("  Tasks: %d (%d short, %d long, %d duplicates)\n", cfg.NumTasks, shortCount, cfg.NumTasks-shortCount, duplicates)
+	fmt.Printf("  Arrival span: %v\n", span)
+	fmt.Printf("  Total work: %v\n", totalWork)
+	fmt.Printf("  Offered load: %.2f task slots (capacity %d)\n", offeredLoad, capacity)
+	fmt.Printf("  Utilization: %.1f%% (target %.0f%%)\n", offeredLoad/float64(capacity)*100, cfg.TargetUtilization*100)
+	if outPath != "" {
+		fmt.Printf("  Schedule written to %s\n", outPath)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// enqueuer submits tasks to DBOS from a pool of goroutines, so several enqueue round
+// trips to Postgres are in flight at once and the arrival schedule isn't throttled by
+// the latency of a single insert. With one worker, tasks are enqueued in arrival order.
+type enqueuer struct {
+	queue    taskQueue
+	requests chan Task
+	wg       sync.WaitGroup
+
+	mu             sync.Mutex
+	runID          string
+	taskIDs        []int
+	dedup          *DedupStats
+	log            *enqueueLog // Records enqueued task IDs for resuming, if set
+	err            error
+	enqueued       int
+	totalLatency   time.Duration
+	firstEnqueue   time.Time
+	lastEnqueue    time.Time
+	maxEnqueueTime time.Duration
+}
+
+func newEnqueuer(queue taskQueue, runID string, workers int, dedup *DedupStats) *enqueuer {
+	e := &enqueuer{
+		queue:    queue,
+		runID:    runID,
+		requests: make(chan Task, workers*64),
+		dedup:    dedup,
+	}
+	for range workers {
+		e.wg.Add(1)
+		go e.worker()
+	}
+	return e
+}
+
+// Submit hands a task over to the enqueue workers
+func (e *enqueuer) Submit(task Task) {
+	e.requests <- task
+}
+
+// Err returns the first enqueue error, if any
+func (e *enqueuer) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Close waits for every submitted task to be enqueued and returns the IDs of the tasks
+// that made it into the queue
+func (e *enqueuer) Close() ([]int, error) {
+	close(e.requests)
+	e.wg.Wait()
+	return e.taskIDs, e.err
+}
+
+func (e *enqueuer) worker() {
+	defer e.wg.Done()
+	for task := range e.requests {
+		start := time.Now()
+		err := e.queue.Enqueue(task, taskWorkflowID(e.runID, task.TaskID))
+		latency := time.Since(start)
+
+		e.mu.Lock()
+		e.dedup.OfferedWork += task.Duration
+		if errors.Is(err, errTaskDeduplicated) {
+			// The original request is still queued or running: this one was dropped
+			e.dedup.Suppressed++
+			e.dedup.SuppressedWork += task.Duration
+		} else if err != nil {
+			if e.err == nil {
+				e.err = fmt.Errorf("failed to enqueue task %d: %w", task.TaskID, err)
+			}
+		} else {
+			if task.Duplicate {
+				e.dedup.Admitted++
+				e.dedup.AdmittedWork += task.Duration
+			}
+			e.taskIDs = append(e.taskIDs, task.TaskID)
+			if e.log != nil {
+				if err := e.log.Append(task.TaskID); err != nil && e.err == nil {
+					e.err = fmt.Errorf("failed to log enqueued task %d: %w", task.TaskID, err)
+				}
+			}
+		}
+		if e.enqueued == 0 {
+			e.firstEnqueue = start
+		}
+		e.enqueued++
+		e.totalLatency += latency
+		e.lastEnqueue = time.Now()
+		if latency > e.maxEnqueueTime {
+			e.maxEnqueueTime = latency
+		}
+		e.mu.Unlock()
+	}
+}
+
+// Print reports the enqueue latency and the arrival rate the producer actually sustained
+func (e *enqueuer) Print(interArrivalTime time.Duration) {
+	if e.enqueued == 0 {
+		return
+	}
+	fmt.Printf("\nEnqueue path:\n")
+	fmt.Printf("  Mean enqueue latency: %.3f ms\n", float64(e.totalLatency.Microseconds())/1000/float64(e.enqueued))
+	fmt.Printf("  Max enqueue latency: %.3f ms\n", float64(e.maxEnqueueTime.Microseconds())/1000)
+	if elapsed := e.lastEnqueue.Sub(e.firstEnqueue); elapsed > 0 && e.enqueued > 1 {
+		fmt.Printf("  Achieved arrival rate: %.1f tasks/s (target %.1f tasks/s)\n",
+			float64(e.enqueued-1)/elapsed.Seconds(), float64(time.Second)/float64(interArrivalTime))
+	}
+}
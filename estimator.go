@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"fifo-queue-demo/sched"
+)
+
+// activeObserver is the policy of the run in progress when it learns from completed
+// tasks, so executors can teach its estimator the duration of every task they complete,
+// tell its dispatcher the outcome, or charge the tenant for it
+var activeObserver atomic.Pointer[SchedulingPolicy]
+
+// defaultSJFPredictedAlpha is the weight of each completed task in the moving average of
+// SJF on predicted sizes when none is configured
+const defaultSJFPredictedAlpha = 0.2
+
+// taskFeatures returns what a size estimator knows of a task before it runs
+func taskFeatures(task Task) sched.Features {
+	return sched.Features{Class: taskClass(task), TenantID: task.TenantID, PayloadBytes: len(task.Payload)}
+}
+
+// sjfPredictedPolicy returns Shortest Job First on the sizes predicted by the configured
+// estimator. Each run gets a fresh estimator, so learning estimators start from their
+// prior.
+func sjfPredictedPolicy(cfg AlgorithmsConfig) SchedulingPolicy {
+	predicted := cfg.SJFPredicted
+	var estimator sched.SizeEstimator
+	switch predicted.Estimator {
+	case "history":
+		estimator = sched.NewHistory(predicted.Window, predicted.Quantile, AppConfig.Workload.MeanTaskDuration())
+	case "model":
+		// Validation reports models that don't load
+		model, _ := loadSizeModel(predicted.ModelFile)
+		estimator = model
+	default:
+		alpha := predicted.Alpha
+		if alpha == 0 {
+			alpha = defaultSJFPredictedAlpha
+		}
+		estimator = sched.NewMovingAverage(alpha, AppConfig.Workload.MeanTaskDuration())
+	}
+	return sched.SJFPredicted(estimator, taskFeatures)
+}
+
+// loadSizeModel loads a linear size model from a YAML file
+func loadSizeModel(path string) (*sched.LinearModel, error) {
+	model := &sched.LinearModel{Source: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return model, fmt.Errorf("failed to read size model: %w", err)
+	}
+	if err := yaml.Unmarshal(data, model); err != nil {
+		return model, fmt.Errorf("failed to parse size model %s: %w", path, err)
+	}
+	return model, nil
+}
+
+// PredictionSummary describes how far the predicted sizes of a class of tasks were from
+// their actual durations
+type PredictionSummary struct {
+	Class          string
+	Count          int
+	MeanActual     float64 // ms
+	MeanPredicted  float64 // ms
+	MeanAbsError   float64 // ms
+	MeanRelError   float64 // Mean of |predicted - actual| / actual
+	Underestimated int     // Tasks predicted shorter than they were
+	Overestimated  int     // Tasks predicted at least twice as long as they were
+}
+
+// summarizePredictions compares the predicted and actual sizes of the tasks, for all
+// tasks then per class
+func summarizePredictions(tasks []Task, policy SchedulingPolicy) []PredictionSummary {
+	var summaries []PredictionSummary
+	for _, class := range []string{"all", "short", "long"} {
+		s := PredictionSummary{Class: class}
+		for _, task := range tasks {
+			if class != "all" && taskClass(task) != class {
+				continue
+			}
+			actual := float64(task.Duration.Microseconds()) / 1000
+			predicted := float64(policy.Predicted(task).Microseconds()) / 1000
+			s.Count++
+			s.MeanActual += actual
+			s.MeanPredicted += predicted
+			s.MeanAbsError += math.Abs(predicted - actual)
+			if actual > 0 {
+				s.MeanRelError += math.Abs(predicted-actual) / actual
+			}
+			if predicted < actual {
+				s.Underestimated++
+			}
+			if predicted >= 2*actual {
+				s.Overestimated++
+			}
+		}
+		if s.Count == 0 {
+			continue
+		}
+		n := float64(s.Count)
+		s.MeanActual /= n
+		s.MeanPredicted /= n
+		s.MeanAbsError /= n
+		s.MeanRelError /= n
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+// predictionWindows is the number of equal spans of the arrivals that the estimation
+// error over time is reported in
+const predictionWindows = 10
+
+// PredictionWindow describes the size predictions of the tasks that arrived in one span
+// of the run, to show how a learning estimator converges
+type PredictionWindow struct {
+	Start        time.Duration // Of the span, from the first arrival
+	Count        int
+	MeanAbsError float64 // ms
+	MeanRelError float64
+}
+
+// summarizePredictionsOverTime computes the estimation error of the tasks arriving in
+// each of predictionWindows equal spans of the arrivals
+func summarizePredictionsOverTime(tasks []Task, policy SchedulingPolicy) []PredictionWindow {
+	if len(tasks) == 0 {
+		return nil
+	}
+	first, last := tasks[0].ArrivalTime, tasks[0].ArrivalTime
+	for _, task := range tasks {
+		if task.ArrivalTime.Before(first) {
+			first = task.ArrivalTime
+		}
+		if task.ArrivalTime.After(last) {
+			last = task.ArrivalTime
+		}
+	}
+	span := last.Sub(first)/predictionWindows + 1
+	windows := make([]PredictionWindow, predictionWindows)
+	for i := range windows {
+		windows[i].Start = time.Duration(i) * span
+	}
+	for _, task := range tasks {
+		w := &windows[task.ArrivalTime.Sub(first)/span]
+		actual := float64(task.Duration.Microseconds()) / 1000
+		absError := math.Abs(float64(policy.Predicted(task).Microseconds())/1000 - actual)
+		w.Count++
+		w.MeanAbsError += absError
+		if actual > 0 {
+			w.MeanRelError += absError / actual
+		}
+	}
+	for i := range windows {
+		if n := float64(windows[i].Count); n > 0 {
+			windows[i].MeanAbsError /= n
+			windows[i].MeanRelError /= n
+		}
+	}
+	return windows
+}
+
+// printPredictionReport prints how accurate the size predictions of the run were, per
+// class and over time. It prints nothing for policies that run on actual sizes.
+func printPredictionReport(tasks []Task, policy SchedulingPolicy) {
+	if policy.Estimator == nil || len(tasks) == 0 {
+		return
+	}
+	fmt.Printf("\nSize predictions (%s):\n", policy.Estimator)
+	fmt.Printf("  %-6s %6s %12s %12s %12s %10s %8s %8s\n", "Class", "Tasks", "Actual", "Predicted", "Abs error", "Rel error", "Under", "2x over")
+	for _, s := range summarizePredictions(tasks, policy) {
+		fmt.Printf("  %-6s %6d %12.1f %12.1f %12.1f %9.0f%% %8d %8d\n", s.Class, s.Count, s.MeanActual, s.MeanPredicted,
+			s.MeanAbsError, s.MeanRelError*100, s.Underestimated, s.Overestimated)
+	}
+	fmt.Println("  (means in ms)")
+	fmt.Printf("  Estimation error over time:\n")
+	fmt.Printf("  %10s %6s %12s %10s\n", "From", "Tasks", "Abs error", "Rel error")
+	for _, w := range summarizePredictionsOverTime(tasks, policy) {
+		fmt.Printf("  %10v %6d %12.1f %9.0f%%\n", w.Start.Round(time.Millisecond), w.Count, w.MeanAbsError, w.MeanRelError*100)
+	}
+}
+
+// predictionsFilename returns the size predictions file that goes with a results file
+func predictionsFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_predictions.csv"
+}
+
+// exportPredictions writes the predicted and actual size of every task in arrival order,
+// for policies that predict sizes. It writes nothing for the others.
+func exportPredictions(tasks []Task, policy SchedulingPolicy, filename string) error {
+	if policy.Estimator == nil || len(tasks) == 0 {
+		return nil
+	}
+	ordered := append([]Task(nil), tasks...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].ArrivalTime.Before(ordered[j].ArrivalTime) })
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create predictions CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"task_id", "arrival_offset_ms", "class", "actual_ms", "predicted_ms", "error_ms"})
+	for _, task := range ordered {
+		actual, predicted := task.Duration, policy.Predicted(task)
+		writer.Write([]string{
+			fmt.Sprintf("%d", task.TaskID),
+			fmt.Sprintf("%.3f", task.ArrivalTime.Sub(ordered[0].ArrivalTime).Seconds()*1000),
+			taskClass(task),
+			fmt.Sprintf("%.3f", actual.Seconds()*1000),
+			fmt.Sprintf("%.3f", predicted.Seconds()*1000),
+			fmt.Sprintf("%.3f", (predicted-actual).Seconds()*1000),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write predictions CSV file: %w", err)
+	}
+	fmt.Printf("Size predictions exported to %s\n", filename)
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// ExecutorStats describes the share of a run's work one executor took on
+type ExecutorStats struct {
+	ExecutorID   string
+	Tasks        int
+	Busy         time.Duration   // Time its worker slots spent running tasks, summed
+	BusyFraction float64         // Busy time over the run's span times its worker slots
+	IdleGaps     metrics.Summary // Gaps between two tasks on the same worker slot
+}
+
+// summarizeExecutors computes the load of each executor that ran tasks, in executor ID
+// order. The run's span goes from the first claim to the last completion of any executor,
+// and each executor has slots worker slots. Within an executor, tasks are placed on
+// worker slots as in the timeline.
+func summarizeExecutors(tasks []Task, slots int) []ExecutorStats {
+	byExecutor := make(map[string][]Task)
+	var first, last time.Time
+	for _, task := range tasks {
+		start, ran := taskDispatch(task)
+		if !ran || task.ExecutorID == "" {
+			continue
+		}
+		byExecutor[task.ExecutorID] = append(byExecutor[task.ExecutorID], task)
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if task.CompletionTime.After(last) {
+			last = task.CompletionTime
+		}
+	}
+	span := last.Sub(first)
+
+	var stats []ExecutorStats
+	for id, executorTasks := range byExecutor {
+		s := ExecutorStats{ExecutorID: id, Tasks: len(executorTasks)}
+		periods, _ := timeline(executorTasks)
+		freeAt := make(map[int]time.Time)
+		var gaps []time.Duration
+		for _, p := range periods {
+			s.Busy += p.End.Sub(p.Start)
+			if end, ok := freeAt[p.Slot]; ok {
+				gaps = append(gaps, p.Start.Sub(end))
+			}
+			freeAt[p.Slot] = p.End
+		}
+		if span > 0 && slots > 0 {
+			s.BusyFraction = float64(s.Busy) / (float64(span) * float64(slots))
+		}
+		s.IdleGaps = metrics.Summarize(gaps)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ExecutorID < stats[j].ExecutorID })
+	return stats
+}
+
+// executorsFilename returns the executor statistics file that goes with a results file
+func executorsFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_executors.csv"
+}
+
+// exportExecutorStats writes the load of each executor, one row per executor
+func exportExecutorStats(stats []ExecutorStats, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create executors CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"executor_id", "tasks", "busy_ms", "busy_fraction",
+		"idle_gaps", "idle_gap_mean_ms", "idle_gap_p50_ms", "idle_gap_p99_ms", "idle_gap_max_ms"})
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.3f", d.Seconds()*1000) }
+	for _, s := range stats {
+		writer.Write([]string{
+			s.ExecutorID,
+			fmt.Sprintf("%d", s.Tasks),
+			ms(s.Busy),
+			fmt.Sprintf("%.4f", s.BusyFraction),
+			fmt.Sprintf("%d", s.IdleGaps.Count),
+			ms(s.IdleGaps.Mean),
+			ms(s.IdleGaps.Median),
+			ms(s.IdleGaps.P99),
+			ms(s.IdleGaps.Max),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write executors CSV file: %w", err)
+	}
+	fmt.Printf("Executor statistics exported to %s\n", filename)
+	return nil
+}
+
+// printExecutorReport prints how evenly the executors of a run shared its work, and
+// exports their statistics next to the results file. It prints nothing unless at least
+// two executors ran tasks; the simulation doesn't model executors.
+func printExecutorReport(tasks []Task, queueCfg QueueConfig, resultsFile string) error {
+	stats := summarizeExecutors(tasks, queueCfg.WorkerConcurrency)
+	if len(stats) < 2 {
+		return nil
+	}
+	if err := exportExecutorStats(stats, executorsFilename(resultsFile)); err != nil {
+		return err
+	}
+	fmt.Printf("\nExecutors (%d worker slots each):\n", queueCfg.WorkerConcurrency)
+	total, most := 0, 0
+	minBusy, maxBusy := stats[0].BusyFraction, stats[0].BusyFraction
+	for _, s := range stats {
+		fmt.Printf("  %s: %d tasks, busy %.1f%%, idle gaps mean %s ms, p99 %s ms, max %s ms\n",
+			s.ExecutorID, s.Tasks, 100*s.BusyFraction, formatMs(s.IdleGaps.Mean), formatMs(s.IdleGaps.P99), formatMs(s.IdleGaps.Max))
+		total += s.Tasks
+		most = max(most, s.Tasks)
+		minBusy, maxBusy = min(minBusy, s.BusyFraction), max(maxBusy, s.BusyFraction)
+	}
+	fmt.Printf("  Balance: the busiest executor served %.2fx the mean number of tasks; busy from %.1f%% to %.1f%%\n",
+		float64(most)*float64(len(stats))/float64(total), 100*minBusy, 100*maxBusy)
+	return nil
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"fifo-queue-demo/sched"
+	"fifo-queue-demo/workload"
+)
+
+// SchedulingPolicy describes how a scheduling algorithm maps onto a DBOS queue, as
+// defined by the sched package
+type SchedulingPolicy = sched.Policy
+
+// runExperiment generates the configured workload, pushes it through the policy's queue
+// and exports the results. The label is appended to the policy name in the results file
+// so scenario runs can be told apart. It returns the completed tasks.
+func runExperiment(policy SchedulingPolicy, queueCfg QueueConfig, label string) ([]Task, error) {
+	tasks, err := runExperimentFrom(policy, queueCfg, label, nil)
+	completed, _ := splitCompleted(tasks)
+	return completed, err
+}
+
+// runExperimentFrom runs an experiment, continuing the interrupted run described by
+// resumed if it isn't nil: tasks the run already enqueued are skipped, and the remaining
+// ones keep their spacing, shifted to start now. It returns every task, including those
+// cancelled or abandoned by their client.
+func runExperimentFrom(policy SchedulingPolicy, queueCfg QueueConfig, label string, resumed *runState) ([]Task, error) {
+	// A run's agent, if its policy has one, stops with the run
+	defer closeAgent(policy)
+	if AppConfig.Database.Mode == "simulated" {
+		return simulateExperiment(policy, queueCfg, label)
+	}
+	run, err := newExperimentRun(policy, queueCfg, label, resumed)
+	if err != nil {
+		return nil, err
+	}
+	defer run.close()
+	if err := run.launch(resumed != nil); err != nil {
+		return nil, err
+	}
+	taskIDs, err := run.produce()
+	if err != nil {
+		return nil, err
+	}
+	if AppConfig.Producer.NoWait {
+		return nil, nil
+	}
+	return run.collect(taskIDs)
+}
+
+// cluster is the set of executors serving a policy's queue during a run
+type cluster struct {
+	executors     []dbos.DBOSContext
+	executorPools []*pgxpool.Pool // System database pool of each executor, which DBOS closes
+	queue         taskQueue       // Where the producer submits tasks
+	dispatchers   []*notifyDispatcher
+	pool          *pgxpool.Pool
+	ioWork        *ioWorker // Backend of the io work mode, nil in other modes
+	monitor       *poolMonitor
+	pipeline      *pipelineRun      // Stages tasks are forwarded through, nil outside pipeline runs
+	lock          *sharedLock       // Lock some tasks hold for their work, nil if none do
+	gate          *capacityGate     // Gate sharing the worker slots, nil without one
+	observer      *SchedulingPolicy // Policy told about completed tasks, nil if it doesn't observe them
+	coldStart     *coldStartPool    // Workers paying cold-start setups, nil when they pay none
+	producer      *pgxpool.Pool     // Connections the producer enqueues over, nil if it uses the first executor's
+	client        dbos.Client       // DBOS client the producer enqueues through, nil if none
+}
+
+// Shutdown stops the dispatchers, then every executor
+func (c *cluster) Shutdown() {
+	c.monitor.Stop()
+	for _, dispatcher := range c.dispatchers {
+		dispatcher.Stop()
+	}
+	for _, executor := range c.executors {
+		dbos.Shutdown(executor, 5*time.Second)
+	}
+	if c.pool != nil {
+		c.pool.Close()
+	}
+	if c.client != nil {
+		c.client.Shutdown(5 * time.Second)
+	} else if c.producer != nil {
+		c.producer.Close()
+	}
+	if c.ioWork != nil {
+		activeIOWork.CompareAndSwap(c.ioWork, nil)
+		c.ioWork.Close()
+	}
+	if c.pipeline != nil {
+		activePipeline.CompareAndSwap(c.pipeline, nil)
+	}
+	if c.lock != nil {
+		activeSharedLock.CompareAndSwap(c.lock, nil)
+		c.lock.Close()
+	}
+	if c.gate != nil {
+		activeGate.CompareAndSwap(c.gate, nil)
+	}
+	if c.observer != nil {
+		activeObserver.CompareAndSwap(c.observer, nil)
+	}
+	if c.coldStart != nil {
+		activeColdStart.CompareAndSwap(c.coldStart, nil)
+	}
+}
+
+// launchExecutors starts one DBOS context per configured executor. They share the same
+// application and queue, so global concurrency limits are enforced across all of them.
+// With notify dispatch, each executor also gets a dispatcher feeding it tasks.
+func launchExecutors(policy SchedulingPolicy, queueCfg QueueConfig) (*cluster, error) {
+	c := &cluster{monitor: newPoolMonitor()}
+	notify := queueCfg.Dispatch == "notify"
+	if policy.Lanes != nil {
+		// Lanes share worker slots through the capacity gate and forward nothing
+		switch {
+		case notify:
+			return nil, fmt.Errorf("%s needs polling dispatch: its lanes are DBOS queues", policy.Name)
+		case AppConfig.Autoscaler.Enabled:
+			return nil, fmt.Errorf("%s can't be autoscaled: its lanes already share worker slots through a gate", policy.Name)
+		case len(AppConfig.Pipeline.Stages) > 0:
+			return nil, fmt.Errorf("%s can't run as a pipeline", policy.Name)
+		}
+	}
+	if policy.Dispatcher != nil {
+		// The dispatcher picks among the tasks the executors dequeued, at the gate
+		switch {
+		case notify:
+			return nil, fmt.Errorf("%s needs polling dispatch: it picks among the tasks executors dequeue", policy.Name)
+		case AppConfig.Autoscaler.Enabled:
+			return nil, fmt.Errorf("%s can't be autoscaled: its tasks already wait for worker slots at a gate", policy.Name)
+		case len(AppConfig.Pipeline.Stages) > 0:
+			return nil, fmt.Errorf("%s can't run as a pipeline", policy.Name)
+		}
+	}
+	reservations := AppConfig.Reservations.Policy(queueCfg.Capacity())
+	if reservations != nil && (policy.Lanes != nil || policy.Dispatcher != nil) {
+		// Lanes and dispatchers hand out the slots of the gate their own way
+		return nil, fmt.Errorf("%s can't be combined with reservations", policy.Name)
+	}
+	if notify {
+		pool, err := newNotifyPool(context.Background(), AppConfig.Network.WorkerLatency())
+		if err != nil {
+			c.Shutdown()
+			return nil, err
+		}
+		c.pool = pool
+		c.monitor.Add("notify", pool)
+	}
+
+	if AppConfig.Workload.UsesCPU() {
+		calibrateCPUWork()
+	}
+	if AppConfig.Workload.UsesQueries() {
+		ioWork, err := newIOWorker(context.Background())
+		if err != nil {
+			c.Shutdown()
+			return nil, err
+		}
+		c.ioWork = ioWork
+		c.monitor.Add("io-work", ioWork.pool)
+		activeIOWork.Store(ioWork)
+	}
+	if AppConfig.Workload.LockProbability > 0 {
+		lock, err := newSharedLock(context.Background())
+		if err != nil {
+			c.Shutdown()
+			return nil, err
+		}
+		c.lock = lock
+		c.monitor.Add("shared-lock", lock.pool)
+		activeSharedLock.Store(lock)
+	}
+
+	// Every lane may hold all the worker slots, so the gate caps the tasks running at once
+	// and hands freed slots to the lanes
+	if policy.Lanes != nil {
+		c.gate = &capacityGate{limit: queueCfg.Capacity(), lanes: policy.Lanes, picker: policy.Lanes.NewPicker()}
+		activeGate.Store(c.gate)
+	}
+
+	// Executors dequeue more tasks than they have worker slots, and the dispatcher picks
+	// which of the tasks waiting at the gate takes each freed slot
+	dequeueCfg := queueCfg
+	if policy.Dispatcher != nil {
+		c.gate = &capacityGate{limit: queueCfg.Capacity(), dispatcher: policy.Dispatcher}
+		activeGate.Store(c.gate)
+		dequeueCfg = dispatcherQueueConfig(policy, queueCfg)
+	}
+
+	// With reservations, executors dequeue tasks ahead too, and the gate starts the most
+	// urgent task a reserved or shared slot is free for
+	if reservations != nil {
+		c.gate = &capacityGate{limit: queueCfg.Capacity(), priority: policy.Priority, slots: reservations.NewUsage()}
+		activeGate.Store(c.gate)
+		dequeueCfg = AppConfig.Reservations.QueueConfig(queueCfg)
+	}
+
+	// Policies that predict task sizes learn from the tasks the executors complete,
+	// dispatchers hear how the tasks they picked went, and fair-share policies charge
+	// tenants for them
+	if policy.Observes() {
+		c.observer = &policy
+		activeObserver.Store(c.observer)
+	}
+
+	// Workers pay a setup when they switch classes of tasks or go cold
+	if model := AppConfig.ColdStart.Policy(); model != nil {
+		c.coldStart = newColdStartPool(model)
+		activeColdStart.Store(c.coldStart)
+	}
+
+	// Pipeline runs forward tasks from stage to stage, each stage with its own queue
+	if len(AppConfig.Pipeline.Stages) > 0 {
+		c.pipeline = &pipelineRun{policy: policy, stages: AppConfig.Pipeline.WorkloadStages(queueCfg)}
+		activePipeline.Store(c.pipeline)
+	}
+
+	for i := range queueCfg.NumExecutors {
+		// Each executor gets its own pool, which DBOS closes on shutdown
+		executorID := fmt.Sprintf("executor-%d", i)
+		pool, err := newPoolWithLatency(context.Background(), AppConfig.Database, AppConfig.Network.WorkerLatency())
+		if err != nil {
+			c.Shutdown()
+			return nil, err
+		}
+		c.monitor.Add(executorID, pool)
+
+		dbosContext, err := dbos.NewDBOSContext(context.Background(), dbos.Config{
+			AppName:      policy.Name + "-queue-demo",
+			SystemDBPool: pool,
+			ExecutorID:   executorID,
+			// DBOS executors only dequeue workflows of their own application version,
+			// which keeps tagged runs apart
+			ApplicationVersion: queueCfg.RunTag,
+		})
+		if err != nil {
+			pool.Close()
+			c.Shutdown()
+			return nil, fmt.Errorf("initializing DBOS failed: %w", err)
+		}
+
+		// Every executor registers the same queues and workflow: the policy's queue, then
+		// the queue of each later stage in pipeline runs. Notify dispatch starts workflows
+		// directly, so it doesn't need the DBOS queue.
+		if policy.Lanes != nil {
+			for _, laneQueue := range policy.Lanes.QueueNames(policy.QueueName) {
+				dbos.NewWorkflowQueue(dbosContext, laneQueue, queueCfg.QueueOptions()...)
+			}
+		} else if !notify {
+			for k, stageQueue := range AppConfig.Pipeline.StageQueues(dequeueCfg) {
+				queueOptions := append([]dbos.QueueOption{}, policy.QueueOptions...)
+				queueOptions = append(queueOptions, stageQueue.QueueOptions()...)
+				dbos.NewWorkflowQueue(dbosContext, stageQueueName(policy, k), queueOptions...)
+			}
+		}
+		if AppConfig.Retry.DeadLetter {
+			dbos.NewWorkflowQueue(dbosContext, deadLetterQueueName)
+		}
+		dbos.RegisterWorkflow(dbosContext, processTask)
+		dbos.RegisterWorkflow(dbosContext, deadLetterTask)
+
+		if err := dbos.Launch(dbosContext); err != nil {
+			c.Shutdown()
+			return nil, fmt.Errorf("launching DBOS failed: %w", err)
+		}
+		c.executors = append(c.executors, dbosContext)
+		c.executorPools = append(c.executorPools, pool)
+
+		if notify {
+			dispatcher := newNotifyDispatcher(c.pool, dbosContext, policy, queueCfg)
+			if err := dispatcher.Start(); err != nil {
+				c.Shutdown()
+				return nil, err
+			}
+			c.dispatchers = append(c.dispatchers, dispatcher)
+		}
+	}
+
+	// With network latency, the producer enqueues over connections of its own, so it can
+	// sit at another distance from Postgres than the executors
+	if AppConfig.Network.Enabled() {
+		if err := c.connectProducer(policy, queueCfg); err != nil {
+			c.Shutdown()
+			return nil, err
+		}
+	} else if notify {
+		c.queue = &notifyTaskQueue{pool: c.pool, policy: policy, tag: queueCfg.RunTag}
+	} else {
+		c.queue = &dbosTaskQueue{ctx: c.executors[0], pool: c.executorPools[0], policy: policy}
+	}
+	return c, nil
+}
+
+// connectProducer gives the producer connections of its own, with the producer's network
+// latency: a notify pool with notify dispatch, and a DBOS client otherwise, which
+// enqueues under the executors' application version so that they dequeue the tasks
+func (c *cluster) connectProducer(policy SchedulingPolicy, queueCfg QueueConfig) error {
+	latency := AppConfig.Network.ProducerLatency()
+	if queueCfg.Dispatch == "notify" {
+		pool, err := newNotifyPool(context.Background(), latency)
+		if err != nil {
+			return err
+		}
+		c.producer = pool
+		c.monitor.Add("producer", pool)
+		c.queue = &notifyTaskQueue{pool: pool, policy: policy, tag: queueCfg.RunTag}
+		return nil
+	}
+	pool, err := newPoolWithLatency(context.Background(), AppConfig.Database, latency)
+	if err != nil {
+		return err
+	}
+	c.producer = pool
+	c.monitor.Add("producer", pool)
+	client, err := dbos.NewClient(context.Background(), dbos.ClientConfig{SystemDBPool: pool})
+	if err != nil {
+		return fmt.Errorf("initializing the producer's DBOS client failed: %w", err)
+	}
+	c.client = client
+	c.queue = &clientTaskQueue{client: client, pool: pool, queueName: policy.QueueName, policy: policy,
+		version: c.executors[0].GetApplicationVersion()}
+	return nil
+}
+
+// printRunBanner prints the policy title and the configuration a run is about to use
+func printRunBanner(policy SchedulingPolicy, queueCfg QueueConfig, avgTaskDuration, interArrivalTime time.Duration) {
+	cfg := AppConfig.Workload
+	fmt.Println("============================================================")
+	fmt.Println(policy.Title)
+	fmt.Println("============================================================")
+	fmt.Printf("Configuration:\n")
+	if cfg.RunDuration() > 0 {
+		fmt.Printf("  Run duration: %v (%d tasks)\n", cfg.RunDuration(), cfg.NumTasks)
+	} else {
+		fmt.Printf("  Number of tasks: %d\n", cfg.NumTasks)
+	}
+	if cfg.ServiceTimeSCV > 0 {
+		serviceTime := workload.NewServiceTime(avgTaskDuration, cfg.ServiceTimeSCV)
+		fmt.Printf("  Task durations: %s, C² %g (short up to %v)\n", serviceTime.Describe(), cfg.ServiceTimeSCV, cfg.ShortTaskDuration())
+	} else {
+		fmt.Printf("  Short task duration: %v\n", cfg.ShortTaskDuration())
+		fmt.Printf("  Long task duration: %v\n", cfg.LongTaskDuration())
+		fmt.Printf("  Short task probability: %.0f%%\n", cfg.ShortTaskProbability*100)
+	}
+	fmt.Printf("  Average task duration: %v\n", avgTaskDuration)
+	fmt.Printf("  Target utilization: %.0f%%\n", cfg.TargetUtilization*100)
+	fmt.Printf("  Average inter-arrival time: %v\n", interArrivalTime)
+	if cfg.FanOut > 1 {
+		fmt.Printf("  Fork-join: each request fans out into %d parallel tasks\n", cfg.FanOut)
+	}
+	if cfg.BurstSize > 1 {
+		fmt.Printf("  Bursts: %d tasks arrive at once\n", cfg.BurstSize)
+	}
+	if cfg.TraceFile != "" {
+		fmt.Printf("  Trace: %s, replayed at its own arrival times and durations\n", cfg.TraceFile)
+	}
+	if cfg.OverloadDurationMs > 0 {
+		fmt.Printf("  Overload: arrivals for %v, then the backlog drains\n", cfg.OverloadDuration())
+	}
+	if len(cfg.UtilizationSteps) > 0 {
+		fmt.Printf("  Load staircase: %d steps of %d tasks, utilization %s\n",
+			len(cfg.UtilizationSteps), cfg.TasksPerStep(), formatUtilizations(cfg.UtilizationSteps))
+	}
+	if len(cfg.Phases) > 0 {
+		fmt.Printf("  Phases: %s\n", formatPhases(cfg))
+	}
+	if cfg.LockProbability > 0 {
+		fmt.Printf("  Shared lock: held by %.0f%% of tasks for their work\n", cfg.LockProbability*100)
+	}
+	if cfg.FailureProbability > 0 || cfg.PermanentFailureProbability > 0 {
+		fmt.Printf("  Failures: %.0f%% of attempts, %.0f%% of tasks permanently, %d retries\n",
+			cfg.FailureProbability*100, cfg.PermanentFailureProbability*100, AppConfig.Retry.MaxRetries)
+	}
+	if cfg.CancelProbability > 0 {
+		fmt.Printf("  Cancellation: %.0f%% of tasks, after %v on average\n", cfg.CancelProbability*100, cfg.CancelDelay())
+	}
+	if cfg.PatienceMs > 0 {
+		fmt.Printf("  Patience: clients abandon tasks not started within %v\n", cfg.Patience())
+	}
+	if cfg.WorkMode != "sleep" {
+		fmt.Printf("  Work mode: %s (GOMAXPROCS %d)\n", cfg.WorkMode, runtime.GOMAXPROCS(0))
+	}
+	for _, class := range []struct {
+		name    string
+		profile WorkProfile
+	}{{"short", cfg.WorkProfiles.Short}, {"long", cfg.WorkProfiles.Long}} {
+		if class.profile.IsSet() {
+			fmt.Printf("  Work profile of %s tasks: %v CPU, %d queries, %v sleep\n",
+				class.name, class.profile.CPU(), class.profile.Queries, class.profile.Sleep())
+		}
+	}
+	if cfg.PayloadBytes > 0 {
+		fmt.Printf("  Task payload: %d bytes (%s)\n", cfg.PayloadBytes, cfg.PayloadFormat)
+	}
+	fmt.Printf("  Queue: %s\n", policy.Description)
+	fmt.Printf("  Executors: %d, worker concurrency: %d, global concurrency: %s\n",
+		queueCfg.NumExecutors, queueCfg.WorkerConcurrency, queueCfg.globalConcurrencyString())
+	if len(AppConfig.Pipeline.Stages) > 0 {
+		fmt.Printf("  Pipeline: %s\n", formatStages(AppConfig.Pipeline.WorkloadStages(queueCfg)))
+	}
+	fmt.Printf("  Dispatch: %s, polling interval: %v (max %v)\n", queueCfg.Dispatch, queueCfg.BasePollingInterval(), queueCfg.MaxPollingInterval())
+	if AppConfig.Network.Enabled() {
+		fmt.Printf("  Network latency to Postgres (round trip): producer %v, workers %v\n",
+			AppConfig.Network.ProducerLatency(), AppConfig.Network.WorkerLatency())
+	}
+	if AppConfig.Autoscaler.Enabled {
+		fmt.Printf("  Autoscaler: %s metric, capacity %d-%d\n", AppConfig.Autoscaler.Metric,
+			AppConfig.Autoscaler.MinCapacity, AppConfig.Autoscaler.MaxCapacity)
+	}
+	if AppConfig.Watchdog.Enabled {
+		fmt.Printf("  Watchdog: boost tasks waiting over %v, scan every %v\n", AppConfig.Watchdog.MaxWait(), AppConfig.Watchdog.ScanInterval())
+	}
+	fmt.Println("============================================================")
+}
+
+// resultsFilename returns a unique, timestamped CSV path in the results directory.
+// The label is appended to the policy name so scenario runs can be told apart.
+func resultsFilename(policyName, label string) (string, error) {
+	// Create results directory if it doesn't exist
+	resultsDir := "results"
+	if err := os.MkdirAll(resultsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create results directory: %w", err)
+	}
+
+	// Generate unique filename with timestamp
+	name := policyName
+	if label != "" {
+		name += "-" + label
+	}
+	timestamp := time.Now().Format("20060102_150405")
+	return filepath.Join(resultsDir, fmt.Sprintf("%s_results_%s.csv", name, timestamp)), nil
+}
+
+// exportResults writes the tasks to a new results CSV file and prints their summary. It
+// returns the name of the file.
+func exportResults(tasks []Task, policy SchedulingPolicy, label string) (string, error) {
+	filename, err := resultsFilename(policy.Name, label)
+	if err != nil {
+		return "", err
+	}
+	starvation := detectStarvation(tasks, AppConfig.Starvation, policy.Priority)
+	fmt.Printf("\nExporting results...\n")
+	if err := exportReport(newReport(tasks, policy, label, filename), false); err != nil {
+		return "", err
+	}
+	if err := exportDepartures(tasks, departuresFilename(filename)); err != nil {
+		return "", err
+	}
+	if err := exportSizes(tasks, filename); err != nil {
+		return "", err
+	}
+	if err := exportPredictions(tasks, policy, predictionsFilename(filename)); err != nil {
+		return "", err
+	}
+	if err := exportDeadLetters(tasks, deadLetterFilename(filename)); err != nil {
+		return "", err
+	}
+	printSummary(tasks, policy)
+	starvation.Print()
+	printDepartureReport(tasks)
+	printSlowdownBySizeReport(tasks)
+	printStepTimingReport(tasks)
+	return filename, nil
+}
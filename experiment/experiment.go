@@ -0,0 +1,263 @@
+// Package experiment runs scheduling experiments in-process: it generates a workload,
+// schedules it on an idealized queue under a policy and reports the latency of every
+// task. It needs no database, so other Go programs and tests can use it directly.
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/sched"
+	"fifo-queue-demo/workload"
+)
+
+// Experiment describes a scheduling experiment
+type Experiment struct {
+	Workload   workload.Config
+	Policy     sched.Policy
+	Capacity   int              // Number of tasks the queue runs at once
+	Stages     []workload.Stage // Stages of a pipeline run, in order; a single queue if empty
+	Retry      sched.RetryPolicy
+	Watchdog   *sched.Watchdog // Boosts tasks that wait too long, nil for none
+	DeadLetter bool            // Route tasks that fail for good to the dead-letter queue
+	// Clients that resend the tasks they time out on, nil for clients that wait
+	ClientRetry *sched.ClientRetry
+	// Per-tenant rate limit tasks are admitted through, nil for none. It keeps the
+	// throttling statistics of the run.
+	RateLimit *sched.RateLimiter
+	// Slots reserved for tenants or classes, with the others shared, nil for a single
+	// shared pool
+	Reservations *sched.Reservations
+	// Setup workers pay when they switch classes of tasks or go cold, nil for none
+	ColdStart *sched.ColdStart
+	Seed      int64     // Workload seed: the same seed generates the same workload
+	Start     time.Time // Arrival time of the first task, now if zero
+}
+
+// Report holds the outcome of an experiment
+type Report struct {
+	Policy       string
+	Seed         int64
+	Capacity     int
+	InterArrival time.Duration   // Average time between arrivals
+	Tasks        []workload.Task // Every task, cancelled and abandoned ones and client retries included, in task ID order
+	Duplicates   int             // Duplicate requests dropped, as DBOS suppresses them
+
+	Response      metrics.Summary // Response time of every task
+	Wait          metrics.Summary // Wait time of every task
+	ShortResponse metrics.Summary // Response time of short tasks
+	LongResponse  metrics.Summary // Response time of long tasks
+}
+
+// Validate reports the settings that can't produce a meaningful experiment
+func (e Experiment) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, "  - "+fmt.Sprintf(format, args...))
+		}
+	}
+	w := e.Workload
+	check(w.NumTasks > 0, "Workload.NumTasks must be positive, got %d", w.NumTasks)
+	check(w.ShortTaskDuration() > 0, "Workload.ShortTaskDurationMs or ShortTaskDurationUs must be positive, got %v", w.ShortTaskDuration())
+	check(w.LongTaskDuration() > 0, "Workload.LongTaskDurationMs or LongTaskDurationUs must be positive, got %v", w.LongTaskDuration())
+	check(w.ShortTaskProbability >= 0 && w.ShortTaskProbability <= 1,
+		"Workload.ShortTaskProbability must be between 0 and 1, got %g", w.ShortTaskProbability)
+	check(w.TargetUtilization > 0, "Workload.TargetUtilization must be positive, got %g", w.TargetUtilization)
+	check(e.Retry.MaxRetries >= 0, "Retry.MaxRetries can't be negative, got %d", e.Retry.MaxRetries)
+	check(e.Capacity > 0, "Capacity must be at least 1, got %d", e.Capacity)
+	check(e.Policy.Name != "", "Policy is not set")
+	check(len(e.Stages) == 0 || e.Policy.Lanes == nil, "Stages can't be combined with a policy with lanes")
+	check(len(e.Stages) == 0 || e.Watchdog == nil, "Stages can't be combined with a watchdog")
+	check(len(e.Stages) == 0 || (e.Workload.CancelProbability == 0 && e.Workload.PatienceMs == 0),
+		"Stages can't be combined with cancellations or client patience")
+	check(e.Watchdog == nil || e.Watchdog.MaxWait > 0, "Watchdog.MaxWait must be positive")
+	check(e.ClientRetry == nil || e.ClientRetry.Timeout > 0, "ClientRetry.Timeout must be positive")
+	check(len(e.Stages) == 0 || e.ClientRetry == nil, "Stages can't be combined with client retries")
+	if r := e.Reservations; r != nil {
+		check(r.Reserved()+r.Shared == e.Capacity, "Reservations must split the Capacity of %d, got %d reserved and %d shared slots",
+			e.Capacity, r.Reserved(), r.Shared)
+		check(len(e.Stages) == 0 && e.Policy.Lanes == nil && e.Policy.Dispatcher == nil && e.Watchdog == nil,
+			"Reservations can't be combined with stages, lanes, a dispatcher or a watchdog")
+	}
+	check(e.ColdStart == nil || len(e.Stages) == 0, "ColdStart can't be combined with stages")
+	for i, stage := range e.Stages {
+		check(stage.DurationFactor > 0, "Stages[%d].DurationFactor must be positive, got %g", i, stage.DurationFactor)
+		check(stage.Capacity > 0, "Stages[%d].Capacity must be at least 1, got %d", i, stage.Capacity)
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid experiment:\n" + strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// RunExperiment generates the experiment's workload and schedules it on an idealized
+// queue with Capacity worker slots: a free slot picks the next task by the policy's
+// priority, then arrival, the instant it is free, with no polling or dispatch overhead.
+// Nothing sleeps, so it returns right away.
+func RunExperiment(cfg Experiment) (Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return Report{}, err
+	}
+	if err := cfg.Workload.ResolveTrace(); err != nil {
+		return Report{}, err
+	}
+	start := cfg.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	_, interArrival := workload.Shape(cfg.Workload, cfg.Capacity)
+	interArrival = time.Duration(float64(interArrival) * workload.BottleneckFactor(cfg.Stages, cfg.Capacity))
+	if cfg.Workload.OverloadDurationMs > 0 {
+		cfg.Workload.NumTasks = cfg.Workload.OverloadTasks(interArrival)
+	}
+	cfg.Workload.ResolvePhases(interArrival)
+	report := Report{Policy: cfg.Policy.Name, Seed: cfg.Seed, Capacity: cfg.Capacity, InterArrival: interArrival}
+
+	// Generate the whole workload up front, at its arrival offsets from the start
+	generator := workload.NewGenerator(cfg.Workload, interArrival, cfg.Seed)
+	tasks := make([]workload.Task, 0, cfg.Workload.NumTasks)
+	for range cfg.Workload.NumTasks {
+		task, offset := generator.Next()
+		if task.Duplicate {
+			report.Duplicates++
+			continue
+		}
+		generator.Arrive(&task, start.Add(offset))
+		// Tasks the rate limit rejects never reach the queue, delayed ones reach it late
+		if cfg.RateLimit != nil {
+			delay, admitted := cfg.RateLimit.Admit(task)
+			if !admitted {
+				continue
+			}
+			task.ArrivalTime = task.ArrivalTime.Add(delay)
+			task.ThrottleDelay = delay
+		}
+		tasks = append(tasks, task)
+	}
+	// Clients may send every retry of their requests; the replay tells which they send
+	if cfg.ClientRetry != nil {
+		nextID := 0
+		for _, task := range tasks {
+			nextID = max(nextID, task.TaskID+1)
+		}
+		for _, task := range tasks {
+			for attempt, ok := cfg.ClientRetry.Resend(task, nextID); ok; attempt, ok = cfg.ClientRetry.Resend(attempt, nextID) {
+				tasks = append(tasks, attempt)
+				nextID++
+			}
+		}
+	}
+
+	var unsent []bool
+	if len(cfg.Stages) > 0 {
+		simulatePipeline(cfg, tasks)
+	} else {
+		// A task holds its worker slot through its retries, as DBOS retries steps in place
+		for i := range tasks {
+			tasks[i].Attempts, tasks[i].Failed, tasks[i].RetryDelay = cfg.Retry.Outcome(tasks[i])
+		}
+		// Tasks needing the shared lock wait for it in their worker slot
+		service := func(task workload.Task) time.Duration { return task.RetryDelay + task.Duration }
+		replay := sched.SimulatePolicy(tasks, cfg.Capacity, cfg.Policy, service,
+			func(task workload.Task) bool { return task.NeedsLock }, cfg.Watchdog, cfg.ClientRetry, cfg.Reservations, cfg.ColdStart)
+		unsent = replay.Unsent
+		for i := range tasks {
+			// Tasks cancelled or abandoned while waiting never ran: they complete when
+			// their client gives up. Tasks cancelled while running finish their work.
+			if replay.Cancelled[i] || replay.Abandoned[i] {
+				tasks[i].Cancelled, tasks[i].Abandoned = replay.Cancelled[i], replay.Abandoned[i]
+				tasks[i].Attempts, tasks[i].Failed, tasks[i].RetryDelay = 0, false, 0
+				tasks[i].CompletionTime = tasks[i].ArrivalTime.Add(replay.Waits[i])
+				continue
+			}
+			tasks[i].DequeueTime = tasks[i].ArrivalTime.Add(replay.Waits[i])
+			tasks[i].LockWait = replay.LockWaits[i]
+			tasks[i].Boosted = replay.Boosted[i]
+			tasks[i].Setup = replay.Setups[i]
+			tasks[i].CompletionTime = tasks[i].DequeueTime.Add(tasks[i].Setup + replay.LockWaits[i] + service(tasks[i]))
+			cancelAt := tasks[i].ArrivalTime.Add(tasks[i].CancelAfter)
+			tasks[i].Cancelled = tasks[i].CancelAfter > 0 && cancelAt.Before(tasks[i].CompletionTime)
+		}
+	}
+	for i := range tasks {
+		// The idealized queue enqueues and starts tasks instantly: all the wait is queueing
+		tasks[i].EnqueuedAt, tasks[i].StartedAt = tasks[i].ArrivalTime, tasks[i].DequeueTime
+		tasks[i].DeadLettered = cfg.DeadLetter && tasks[i].Failed
+	}
+	if cfg.ClientRetry != nil {
+		sent := tasks[:0]
+		for i, task := range tasks {
+			if !unsent[i] {
+				sent = append(sent, task)
+			}
+		}
+		tasks = sent
+		sched.MarkTimedOut(tasks)
+	}
+	report.Tasks = tasks
+
+	// Cancelled and abandoned tasks return no result, so they don't count in latency
+	completed := workload.Task.Completed
+	isShort := func(task workload.Task) bool { return completed(task) && cfg.Workload.IsShort(task.Duration) }
+	isLong := func(task workload.Task) bool { return completed(task) && !cfg.Workload.IsShort(task.Duration) }
+	report.Response = metrics.SummarizeTasks(tasks, completed, workload.Task.ResponseTime)
+	report.Wait = metrics.SummarizeTasks(tasks, completed, workload.Task.WaitTime)
+	report.ShortResponse = metrics.SummarizeTasks(tasks, isShort, workload.Task.ResponseTime)
+	report.LongResponse = metrics.SummarizeTasks(tasks, isLong, workload.Task.ResponseTime)
+	return report, nil
+}
+
+// simulatePipeline runs the tasks through the stages of a pipeline one stage at a time:
+// tasks arrive at a stage when they leave the previous one. Each stage fails and retries
+// its work like a single queue, and tasks that failed for good pass through the later
+// stages without doing their work.
+func simulatePipeline(cfg Experiment, tasks []workload.Task) {
+	for i := range tasks {
+		tasks[i].Stages = make([]workload.StageTiming, 0, len(cfg.Stages))
+	}
+	arrivals := make([]workload.Task, len(tasks))
+	for i, task := range tasks {
+		arrivals[i] = task
+	}
+	for s, stage := range cfg.Stages {
+		// Stage tasks carry the stage's duration, so SJF sees how long they run in it
+		services := make([]time.Duration, len(tasks))
+		for i := range arrivals {
+			arrivals[i].Duration = stage.Duration(tasks[i].Duration)
+			if tasks[i].Failed {
+				continue
+			}
+			// A task's attempts are its first attempt plus its retries in every stage
+			attempts, failed, retryDelay := cfg.Retry.Outcome(arrivals[i])
+			if s == 0 {
+				tasks[i].Attempts = attempts
+			} else {
+				tasks[i].Attempts += attempts - 1
+			}
+			tasks[i].Failed = failed
+			tasks[i].RetryDelay += retryDelay
+			services[i] = retryDelay + arrivals[i].Duration
+		}
+		index := make(map[int]int, len(arrivals))
+		for i, task := range arrivals {
+			index[task.TaskID] = i
+		}
+		service := func(task workload.Task) time.Duration { return services[index[task.TaskID]] }
+		waits := sched.Simulate(arrivals, stage.Capacity, cfg.Policy.Priority, service)
+		for i := range tasks {
+			timing := workload.StageTiming{EnqueuedAt: arrivals[i].ArrivalTime}
+			timing.DequeueTime = timing.EnqueuedAt.Add(waits[i])
+			timing.CompletionTime = timing.DequeueTime.Add(services[i])
+			tasks[i].Stages = append(tasks[i].Stages, timing)
+			if s == 0 {
+				tasks[i].DequeueTime = timing.DequeueTime
+			}
+			tasks[i].CompletionTime = timing.CompletionTime
+			arrivals[i].ArrivalTime = timing.CompletionTime
+		}
+	}
+}
@@ -1,205 +1,289 @@
 package main
 
 import (
-	"encoding/csv"
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
-	"sort"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"fifo-queue-demo/metrics"
 )
 
-// Export results to CSV file
-func exportToCSV(tasks []Task, filename string) error {
-	file, err := os.Create(filename)
+// resultsWriter streams task rows to a results CSV file, and reports the file once it is
+// closed
+type resultsWriter struct {
+	*metrics.ResultsWriter
+}
+
+// newResultsWriter creates the CSV file and writes its header
+func newResultsWriter(filename string) (*resultsWriter, error) {
+	writer, err := metrics.NewResultsWriter(filename)
 	if err != nil {
-		return fmt.Errorf("failed to create CSV file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	return &resultsWriter{writer}, nil
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// Close finalizes the CSV file. It is safe to call more than once.
+func (w *resultsWriter) Close() error {
+	closed, err := w.ResultsWriter.Close()
+	if err != nil {
+		return err
+	}
+	if closed {
+		fmt.Printf("\nResults exported to %s (%d rows)\n", w.Filename(), w.Rows())
+	}
+	return nil
+}
 
-	// Write header
-	header := []string{"task_id", "duration_ms", "arrival_time", "dequeue_time",
-		"completion_time", "wait_time_ms", "response_time_ms"}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("failed to write CSV header: %w", err)
+// Export results to CSV file
+func exportToCSV(tasks []Task, filename string) error {
+	writer, err := newResultsWriter(filename)
+	if err != nil {
+		return err
 	}
+	for _, task := range tasks {
+		if err := writer.Write(task); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
 
-	// Calculate statistics
-	var totalWaitTime, totalResponseTime time.Duration
-	var minWaitTime, maxWaitTime, minResponseTime, maxResponseTime time.Duration
-	waitTimes := make([]time.Duration, 0, len(tasks))
-	responseTimes := make([]time.Duration, 0, len(tasks))
-	firstTask := true
+// printSummary prints response time statistics for all tasks, then per group of tasks
+// as chosen by metrics.group_by
+func printSummary(tasks []Task, policy SchedulingPolicy) {
+	if len(tasks) == 0 {
+		return
+	}
+	printLatencySummary("All Tasks", summarizeResponseTimes(tasks, nil))
 
-	// Write task data
+	grouping := taskGroupings[AppConfig.Metrics.GroupBy]
+	groups := make(map[string][]time.Duration)
 	for _, task := range tasks {
-		waitTime := task.DequeueTime.Sub(task.ArrivalTime)
-		responseTime := task.CompletionTime.Sub(task.ArrivalTime)
-
-		// Update statistics
-		totalWaitTime += waitTime
-		totalResponseTime += responseTime
-		waitTimes = append(waitTimes, waitTime)
-		responseTimes = append(responseTimes, responseTime)
-
-		if firstTask {
-			minWaitTime = waitTime
-			maxWaitTime = waitTime
-			minResponseTime = responseTime
-			maxResponseTime = responseTime
-			firstTask = false
-		} else {
-			if waitTime < minWaitTime {
-				minWaitTime = waitTime
-			}
-			if waitTime > maxWaitTime {
-				maxWaitTime = waitTime
-			}
-			if responseTime < minResponseTime {
-				minResponseTime = responseTime
-			}
-			if responseTime > maxResponseTime {
-				maxResponseTime = responseTime
-			}
+		key := grouping.key(task, policy)
+		groups[key] = append(groups[key], task.ResponseTime())
+	}
+	for i, key := range grouping.sort(sortedKeys(groups)) {
+		if i == maxSummaryGroups {
+			fmt.Printf("\n(%d more groups by %s not shown)\n", len(groups)-i, AppConfig.Metrics.GroupBy)
+			break
 		}
+		summary := metrics.Summarize(groups[key])
+		printLatencySummary(fmt.Sprintf("%s, n=%d", grouping.title(key), summary.Count), summary)
+	}
+}
 
-		row := []string{
-			fmt.Sprintf("%d", task.TaskID),
-			fmt.Sprintf("%.0f", float64(task.Duration.Milliseconds())),
-			task.ArrivalTime.Format(time.RFC3339Nano),
-			task.DequeueTime.Format(time.RFC3339Nano),
-			task.CompletionTime.Format(time.RFC3339Nano),
-			fmt.Sprintf("%.3f", waitTime.Seconds()*1000),
-			fmt.Sprintf("%.3f", responseTime.Seconds()*1000),
-		}
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write CSV row: %w", err)
-		}
+// printLatencySummary prints the response time statistics of a group of tasks under the
+// given heading
+func printLatencySummary(heading string, s ResponseSummary) {
+	fmt.Printf("\nSummary Statistics (%s):\n", heading)
+	fmt.Printf("  Mean response time: %.3f ms\n", float64(s.Mean.Microseconds())/1000)
+	fmt.Printf("  Median response time: %.3f ms\n", float64(s.Median.Milliseconds()))
+	fmt.Printf("  Min response time: %.3f ms\n", float64(s.Min.Milliseconds()))
+	fmt.Printf("  Max response time: %.3f ms\n", float64(s.Max.Milliseconds()))
+	fmt.Printf("  P90 response time: %.3f ms\n", float64(s.P90.Milliseconds()))
+	fmt.Printf("  P99 response time: %.3f ms\n", float64(s.P99.Milliseconds()))
+}
+
+// Report is what a run hands to its exporters
+type Report struct {
+	Run      string // Name of the run: the base name of its results file
+	Policy   string
+	Label    string
+	Filename string // Results CSV file; the other file exporters write next to it
+	Tasks    []Task // Completed tasks
+}
+
+// newReport returns the report of a run whose results go to the given CSV file
+func newReport(tasks []Task, policy SchedulingPolicy, label, filename string) Report {
+	return Report{
+		Run:      strings.TrimSuffix(filepath.Base(filename), ".csv"),
+		Policy:   policy.Name,
+		Label:    label,
+		Filename: filename,
+		Tasks:    tasks,
 	}
+}
 
-	fmt.Printf("\nResults exported to %s\n", filename)
+// Exporter writes the results of a run somewhere
+type Exporter interface {
+	Write(run Report) error
+}
 
-	// Print summary statistics
-	numTasks := len(tasks)
-	if numTasks > 0 {
-		// Sort times for percentile calculations
-		sort.Slice(waitTimes, func(i, j int) bool { return waitTimes[i] < waitTimes[j] })
-		sort.Slice(responseTimes, func(i, j int) bool { return responseTimes[i] < responseTimes[j] })
+// exporters lists the available exporters by name, for metrics.exporters
+var exporters = map[string]Exporter{
+	"csv":      csvExporter{},
+	"json":     jsonExporter{},
+	"parquet":  parquetExporter{},
+	"postgres": postgresExporter{},
+}
 
-		// Calculate percentiles
-		p90Index := int(float64(numTasks) * 0.90)
-		if p90Index >= numTasks {
-			p90Index = numTasks - 1
+// exportReport hands the run to every configured exporter, except csv if the run already
+// streamed its CSV file
+func exportReport(run Report, streamed bool) error {
+	for _, name := range AppConfig.Metrics.ExporterNames() {
+		if name == "csv" && streamed {
+			continue
 		}
-		p99Index := int(float64(numTasks) * 0.99)
-		if p99Index >= numTasks {
-			p99Index = numTasks - 1
+		if err := exporters[name].Write(run); err != nil {
+			return fmt.Errorf("failed to export results to %s: %w", name, err)
 		}
+	}
+	return nil
+}
 
-		// Calculate median
-		var medianResponseTime time.Duration
-		if numTasks%2 == 0 {
-			// Even number of tasks: average of two middle values
-			medianResponseTime = (responseTimes[numTasks/2-1] + responseTimes[numTasks/2]) / 2
-		} else {
-			// Odd number of tasks: middle value
-			medianResponseTime = responseTimes[numTasks/2]
-		}
+// csvExporter writes the results CSV file
+type csvExporter struct{}
+
+func (csvExporter) Write(run Report) error {
+	return exportToCSV(run.Tasks, run.Filename)
+}
+
+// jsonExporter writes the run and its tasks to a JSON file next to the CSV, with the
+// columns of the CSV as typed fields, in the same order. Unknown values are null.
+type jsonExporter struct{}
 
-		fmt.Printf("\nSummary Statistics (All Tasks):\n")
-		/* print queueing delay if interested
-		fmt.Printf("  Mean wait time: %.3f ms\n", float64(totalWaitTime.Milliseconds())/float64(numTasks))
-		fmt.Printf("  Min wait time: %.3f ms\n", float64(minWaitTime.Milliseconds()))
-		fmt.Printf("  Max wait time: %.3f ms\n", float64(maxWaitTime.Milliseconds()))
-		fmt.Printf("  P90 wait time: %.3f ms\n", float64(waitTimes[p90Index].Milliseconds()))
-		fmt.Printf("  P99 wait time: %.3f ms\n", float64(waitTimes[p99Index].Milliseconds()))
-		*/
-		fmt.Printf("  Mean response time: %.3f ms\n", float64(totalResponseTime.Milliseconds())/float64(numTasks))
-		fmt.Printf("  Median response time: %.3f ms\n", float64(medianResponseTime.Milliseconds()))
-		fmt.Printf("  Min response time: %.3f ms\n", float64(minResponseTime.Milliseconds()))
-		fmt.Printf("  Max response time: %.3f ms\n", float64(maxResponseTime.Milliseconds()))
-		fmt.Printf("  P90 response time: %.3f ms\n", float64(responseTimes[p90Index].Milliseconds()))
-		fmt.Printf("  P99 response time: %.3f ms\n", float64(responseTimes[p99Index].Milliseconds()))
-
-		// Separate tasks by type (short vs long)
-		cfg := AppConfig.Workload
-		shortDuration := cfg.ShortTaskDuration()
-		longDuration := cfg.LongTaskDuration()
-		
-		var shortTasks, longTasks []Task
-		for _, task := range tasks {
-			if task.Duration == shortDuration {
-				shortTasks = append(shortTasks, task)
-			} else if task.Duration == longDuration {
-				longTasks = append(longTasks, task)
+func (jsonExporter) Write(run Report) error {
+	filename := strings.TrimSuffix(run.Filename, ".csv") + ".json"
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON file: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	header, err := json.Marshal(struct {
+		Run    string `json:"run"`
+		Policy string `json:"policy"`
+		Label  string `json:"label"`
+	}{run.Run, run.Policy, run.Label})
+	if err != nil {
+		file.Close()
+		return err
+	}
+	// The header object is reopened to append the tasks to it
+	w.Write(header[:len(header)-1])
+	w.WriteString(`,"tasks":[`)
+	for i, task := range run.Tasks {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString("\n{")
+		for j, column := range metrics.ResultColumns {
+			value, err := json.Marshal(column.Value(task))
+			if err != nil {
+				file.Close()
+				return err
+			}
+			if j > 0 {
+				w.WriteByte(',')
 			}
+			fmt.Fprintf(w, "%q:%s", column.Name, value)
 		}
+		w.WriteByte('}')
+	}
+	w.WriteString("\n]}\n")
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close JSON file: %w", err)
+	}
+	fmt.Printf("Results exported to %s (%d rows)\n", filename, len(run.Tasks))
+	return nil
+}
 
-		// Helper function to calculate and print statistics for a task group
-		printTaskTypeStats := func(taskType string, taskList []Task) {
-			if len(taskList) == 0 {
-				return
-			}
+// parquetExporter writes the tasks to a Parquet file next to the CSV, with the columns
+// of the CSV as typed columns
+type parquetExporter struct{}
 
-			var totalRespTime time.Duration
-			var minRespTime, maxRespTime time.Duration
-			respTimes := make([]time.Duration, 0, len(taskList))
-			firstTask := true
-
-			for _, task := range taskList {
-				respTime := task.CompletionTime.Sub(task.ArrivalTime)
-				totalRespTime += respTime
-				respTimes = append(respTimes, respTime)
-
-				if firstTask {
-					minRespTime = respTime
-					maxRespTime = respTime
-					firstTask = false
-				} else {
-					if respTime < minRespTime {
-						minRespTime = respTime
-					}
-					if respTime > maxRespTime {
-						maxRespTime = respTime
-					}
-				}
-			}
+func (parquetExporter) Write(run Report) error {
+	filename := strings.TrimSuffix(run.Filename, ".csv") + ".parquet"
+	if err := metrics.WriteParquet(filename, metrics.ResultColumns, run.Tasks); err != nil {
+		return err
+	}
+	fmt.Printf("Results exported to %s (%d rows)\n", filename, len(run.Tasks))
+	return nil
+}
 
-			// Sort for percentile calculations
-			sort.Slice(respTimes, func(i, j int) bool { return respTimes[i] < respTimes[j] })
+// resultsSchema holds the results table of the postgres exporter. Its task columns are
+// added from the results columns, so the table follows new columns.
+const resultsSchema = `
+CREATE TABLE IF NOT EXISTS schedq_results (
+    run         TEXT NOT NULL,
+    policy      TEXT NOT NULL,
+    label       TEXT NOT NULL,
+    exported_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS schedq_results_run ON schedq_results (run);
+`
 
-			numTypeTasks := len(taskList)
-			p90Idx := int(float64(numTypeTasks) * 0.90)
-			if p90Idx >= numTypeTasks {
-				p90Idx = numTypeTasks - 1
-			}
-			p99Idx := int(float64(numTypeTasks) * 0.99)
-			if p99Idx >= numTypeTasks {
-				p99Idx = numTypeTasks - 1
-			}
+// postgresColumnTypes are the Postgres types of the results columns
+var postgresColumnTypes = map[metrics.ColumnType]string{
+	metrics.IntColumn:    "BIGINT",
+	metrics.FloatColumn:  "DOUBLE PRECISION",
+	metrics.BoolColumn:   "BOOLEAN",
+	metrics.StringColumn: "TEXT",
+	metrics.TimeColumn:   "TIMESTAMPTZ",
+}
 
-			var medianRespTime time.Duration
-			if numTypeTasks%2 == 0 {
-				medianRespTime = (respTimes[numTypeTasks/2-1] + respTimes[numTypeTasks/2]) / 2
-			} else {
-				medianRespTime = respTimes[numTypeTasks/2]
-			}
+// postgresExporter writes the tasks to the schedq_results table of the database, a row
+// per task tagged with the run. Exporting a run again replaces its rows.
+type postgresExporter struct{}
 
-			fmt.Printf("\nSummary Statistics (%s Tasks, n=%d):\n", taskType, numTypeTasks)
-			fmt.Printf("  Mean response time: %.3f ms\n", float64(totalRespTime.Milliseconds())/float64(numTypeTasks))
-			fmt.Printf("  Median response time: %.3f ms\n", float64(medianRespTime.Milliseconds()))
-			fmt.Printf("  Min response time: %.3f ms\n", float64(minRespTime.Milliseconds()))
-			fmt.Printf("  Max response time: %.3f ms\n", float64(maxRespTime.Milliseconds()))
-			fmt.Printf("  P90 response time: %.3f ms\n", float64(respTimes[p90Idx].Milliseconds()))
-			fmt.Printf("  P99 response time: %.3f ms\n", float64(respTimes[p99Idx].Milliseconds()))
-		}
+func (postgresExporter) Write(run Report) error {
+	ctx := context.Background()
+	cfg := AppConfig.Database
+	cfg.PoolMaxConns, cfg.PoolMinConns = 1, 0
+	pool, err := newPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
 
-		// Print statistics for short and long tasks
-		printTaskTypeStats("Short", shortTasks)
-		printTaskTypeStats("Long", longTasks)
+	schema := resultsSchema
+	for _, column := range metrics.ResultColumns {
+		schema += fmt.Sprintf("ALTER TABLE schedq_results ADD COLUMN IF NOT EXISTS %s %s;\n",
+			column.Name, postgresColumnTypes[column.Type])
+	}
+	if _, err := pool.Exec(ctx, schema); err != nil {
+		return fmt.Errorf("failed to create results table: %w", err)
 	}
 
+	names := []string{"run", "policy", "label"}
+	for _, column := range metrics.ResultColumns {
+		names = append(names, column.Name)
+	}
+	rows := make([][]any, len(run.Tasks))
+	for i, task := range run.Tasks {
+		row := []any{run.Run, run.Policy, run.Label}
+		for _, column := range metrics.ResultColumns {
+			row = append(row, column.Value(task))
+		}
+		rows[i] = row
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM schedq_results WHERE run = $1`, run.Run); err != nil {
+		return fmt.Errorf("failed to replace the rows of run %s: %w", run.Run, err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"schedq_results"}, names, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy results: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("Results exported to table schedq_results (run %s, %d rows)\n", run.Run, len(run.Tasks))
 	return nil
 }
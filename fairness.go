@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+)
+
+// TenantStats holds the slowdown of one tenant's tasks
+type TenantStats struct {
+	TenantID     string
+	Tasks        int
+	MeanSlowdown float64 // Mean of response time / task duration
+}
+
+// FairnessSummary compares how evenly tenants were served
+type FairnessSummary struct {
+	Tenants []TenantStats
+	// Jain's fairness index of the per-tenant mean slowdowns: 1 when every tenant sees the
+	// same slowdown, down to 1/n when a single tenant gets all the slowdown
+	JainIndex     float64
+	SlowdownRatio float64 // Highest over lowest per-tenant mean slowdown
+}
+
+// slowdown returns a task's response time relative to its duration
+func slowdown(task Task) float64 {
+	if task.Duration <= 0 {
+		return 1
+	}
+	return float64(task.CompletionTime.Sub(task.ArrivalTime)) / float64(task.Duration)
+}
+
+// summarizeFairness computes per-tenant slowdowns. It returns false when the tasks don't
+// carry tenant IDs or belong to a single tenant.
+func summarizeFairness(tasks []Task) (FairnessSummary, bool) {
+	totals := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, task := range tasks {
+		if task.TenantID == "" {
+			continue
+		}
+		totals[task.TenantID] += slowdown(task)
+		counts[task.TenantID]++
+	}
+	if len(counts) < 2 {
+		return FairnessSummary{}, false
+	}
+
+	var summary FairnessSummary
+	var sum, sumSquares float64
+	minSlowdown, maxSlowdown := 0.0, 0.0
+	for _, tenant := range sortedKeys(counts) {
+		mean := totals[tenant] / float64(counts[tenant])
+		summary.Tenants = append(summary.Tenants, TenantStats{TenantID: tenant, Tasks: counts[tenant], MeanSlowdown: mean})
+		sum += mean
+		sumSquares += mean * mean
+		if minSlowdown == 0 || mean < minSlowdown {
+			minSlowdown = mean
+		}
+		maxSlowdown = max(maxSlowdown, mean)
+	}
+	summary.JainIndex = sum * sum / (float64(len(summary.Tenants)) * sumSquares)
+	summary.SlowdownRatio = maxSlowdown / minSlowdown
+	return summary, true
+}
+
+// printFairnessReport prints per-tenant slowdowns, Jain's index and the slowdown ratio
+func printFairnessReport(tasks []Task) {
+	summary, ok := summarizeFairness(tasks)
+	if !ok {
+		return
+	}
+	fmt.Printf("\nTenant fairness (%d tenants):\n", len(summary.Tenants))
+	for _, tenant := range summary.Tenants {
+		fmt.Printf("  %s: %d tasks, mean slowdown %.2fx\n", tenant.TenantID, tenant.Tasks, tenant.MeanSlowdown)
+	}
+	fmt.Printf("  Jain's fairness index: %.4f\n", summary.JainIndex)
+	fmt.Printf("  Max/min slowdown ratio: %.2f\n", summary.SlowdownRatio)
+}
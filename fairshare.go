@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// defaultFairShareHalfLifeMs is the half-life of the tenants' recent usage when none is
+// configured
+const defaultFairShareHalfLifeMs = 10000
+
+// fairSharePolicy returns decay-usage fair-share scheduling with the configured
+// half-life. Each run starts with no usage.
+func fairSharePolicy(cfg AlgorithmsConfig) SchedulingPolicy {
+	return sched.FairSharePolicy(sched.NewFairShare(cfg.FairShare.HalfLife()))
+}
+
+// maxMinShares splits capacity between demands max-min fairly: demands below an equal
+// split get all they ask for, and the rest is split equally between the others
+func maxMinShares(demands []float64, capacity float64) []float64 {
+	order := make([]int, len(demands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return demands[order[a]] < demands[order[b]] })
+	shares := make([]float64, len(demands))
+	for k, i := range order {
+		shares[i] = min(demands[i], capacity/float64(len(order)-k))
+		capacity -= shares[i]
+	}
+	return shares
+}
+
+// ShareSpan is how the worker time of one span of a run was split between tenants
+type ShareSpan struct {
+	End          time.Duration // End of the span, from the first arrival
+	Served       []float64     // Share of the span's worker time each tenant got
+	Misallocated float64       // Share of the worker time that went to another tenant than under max-min fairness
+}
+
+// summarizeShares splits the run into spans and compares, in each span, the share of the
+// worker time every tenant got with its max-min fair share of it, given the work it had
+// waiting. Under fair-share scheduling, the misallocated share shrinks as usage builds
+// up, so the spans show how fast the shares converge.
+func summarizeShares(tasks []Task, tenants []string, spans int) []ShareSpan {
+	if len(tasks) == 0 || len(tenants) < 2 {
+		return nil
+	}
+	index := make(map[string]int, len(tenants))
+	for i, tenant := range tenants {
+		index[tenant] = i
+	}
+	first, last := tasks[0].ArrivalTime, tasks[0].CompletionTime
+	for _, task := range tasks {
+		if task.ArrivalTime.Before(first) {
+			first = task.ArrivalTime
+		}
+		if task.CompletionTime.After(last) {
+			last = task.CompletionTime
+		}
+	}
+	width := last.Sub(first) / time.Duration(spans)
+	if width <= 0 {
+		return nil
+	}
+
+	// Work arrived by the end of each span, and worker time served within each span
+	arrived := make([][]float64, spans)
+	served := make([][]float64, spans)
+	for s := range spans {
+		arrived[s] = make([]float64, len(tenants))
+		served[s] = make([]float64, len(tenants))
+	}
+	for _, task := range tasks {
+		i := index[task.TenantID]
+		span := min(int(task.ArrivalTime.Sub(first)/width), spans-1)
+		for s := span; s < spans; s++ {
+			arrived[s][i] += task.Duration.Seconds()
+		}
+		for s := range spans {
+			start, end := first.Add(time.Duration(s)*width), first.Add(time.Duration(s+1)*width)
+			overlap := minTime(end, task.CompletionTime).Sub(maxTime(start, task.DequeueTime))
+			if overlap > 0 {
+				served[s][i] += overlap.Seconds()
+			}
+		}
+	}
+
+	var result []ShareSpan
+	done := make([]float64, len(tenants)) // Worker time served before the span
+	for s := range spans {
+		var capacity float64
+		demands := make([]float64, len(tenants))
+		for i := range tenants {
+			capacity += served[s][i]
+			demands[i] = max(arrived[s][i]-done[i], 0)
+		}
+		span := ShareSpan{End: time.Duration(s+1) * width, Served: make([]float64, len(tenants))}
+		if capacity > 0 {
+			fair := maxMinShares(demands, capacity)
+			for i := range tenants {
+				span.Served[i] = served[s][i] / capacity
+				span.Misallocated += math.Abs(served[s][i]-fair[i]) / capacity / 2
+			}
+		}
+		for i := range tenants {
+			done[i] += served[s][i]
+		}
+		result = append(result, span)
+	}
+	return result
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// printFairShareReport prints how the worker time was split between tenants over ten
+// spans of the run, against their max-min fair shares. It prints nothing for other
+// policies, or without tenants.
+func printFairShareReport(tasks []Task, policy SchedulingPolicy) {
+	if policy.FairShare == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		seen[task.TenantID] = true
+	}
+	tenants := sortedKeys(seen)
+	spans := summarizeShares(tasks, tenants, 10)
+	if spans == nil {
+		return
+	}
+	fmt.Printf("\nFair share (half-life %v): share of the worker time per span\n", policy.FairShare.HalfLife)
+	shown := tenants[:min(len(tenants), 6)]
+	fmt.Printf("  %8s", "Until")
+	for _, tenant := range shown {
+		fmt.Printf(" %9s", tenant)
+	}
+	if len(shown) < len(tenants) {
+		fmt.Printf(" %9s", "others")
+	}
+	fmt.Printf(" %13s\n", "Misallocated")
+	for _, span := range spans {
+		fmt.Printf("  %7.1fs", span.End.Seconds())
+		var others float64
+		for i, share := range span.Served {
+			if i < len(shown) {
+				fmt.Printf(" %8.1f%%", 100*share)
+			} else {
+				others += share
+			}
+		}
+		if len(shown) < len(tenants) {
+			fmt.Printf(" %8.1f%%", 100*others)
+		}
+		fmt.Printf(" %12.1f%%\n", 100*span.Misallocated)
+	}
+	fmt.Println("  (misallocated: share of the span's worker time that max-min fairness on the waiting work")
+	fmt.Println("   would have given to other tenants; it shrinks as the shares converge)")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// configField is one scalar configuration field, addressable from the command line
+type configField struct {
+	Name  string        // Flag name, e.g. "num-tasks" or "producer-enqueue-workers"
+	Key   string        // Key in config.yaml, e.g. "workload.num_tasks"
+	Value reflect.Value // The field itself, settable
+}
+
+// unprefixedSections are the config sections whose fields are set on the command line
+// without the section name, e.g. -num-tasks instead of -workload-num-tasks
+var unprefixedSections = map[string]bool{"workload": true, "queue": true}
+
+// configFields lists the scalar fields of every config section, including nested
+// sections such as algorithms.sjf. Lists and maps, like slos and profiles, can only be
+// set in the file.
+func configFields(cfg *Config) []configField {
+	var fields []configField
+	root := reflect.ValueOf(cfg).Elem()
+	for i := range root.NumField() {
+		section := root.Field(i)
+		sectionName := yamlName(root.Type().Field(i))
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		namePrefix := strings.ReplaceAll(sectionName, "_", "-") + "-"
+		if unprefixedSections[sectionName] {
+			namePrefix = ""
+		}
+		fields = appendConfigFields(fields, section, namePrefix, sectionName+".")
+	}
+	return fields
+}
+
+// appendConfigFields appends the scalar fields of a config section, with the given flag
+// name and key prefixes, descending into nested sections
+func appendConfigFields(fields []configField, section reflect.Value, namePrefix, keyPrefix string) []configField {
+	for j := range section.NumField() {
+		field := section.Field(j)
+		key := yamlName(section.Type().Field(j))
+		name := namePrefix + strings.ReplaceAll(key, "_", "-")
+		switch field.Kind() {
+		case reflect.Int, reflect.Float64, reflect.Bool, reflect.String:
+			fields = append(fields, configField{Name: name, Key: keyPrefix + key, Value: field})
+		case reflect.Struct:
+			fields = appendConfigFields(fields, field, name+"-", keyPrefix+key+".")
+		}
+	}
+	return fields
+}
+
+// envPrefix starts the name of every configuration environment variable
+const envPrefix = "SCHEDQ_"
+
+// EnvName returns the environment variable setting the field, e.g. SCHEDQ_NUM_TASKS
+func (f configField) EnvName() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+}
+
+// applyEnvConfig overrides configuration fields with the SCHEDQ_* environment variables
+// that are set. They take precedence over the config file but not over flags.
+func applyEnvConfig(cfg *Config) error {
+	for _, field := range configFields(cfg) {
+		value, ok := os.LookupEnv(field.EnvName())
+		if !ok {
+			continue
+		}
+		if err := setField(field.Value, value); err != nil {
+			return fmt.Errorf("%s: %w", field.EnvName(), err)
+		}
+	}
+	return nil
+}
+
+// yamlName returns the YAML key of a struct field
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+// setField parses value into a configuration field
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.String:
+		field.SetString(value)
+	}
+	return nil
+}
+
+// configFlag is a flag.Value writing straight into a configuration field
+type configFlag struct {
+	field reflect.Value
+}
+
+func (f configFlag) String() string {
+	if !f.field.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.field.Interface())
+}
+
+func (f configFlag) Set(value string) error {
+	return setField(f.field, value)
+}
+
+func (f configFlag) IsBoolFlag() bool {
+	return f.field.IsValid() && f.field.Kind() == reflect.Bool
+}
+
+// registerConfigFlags adds a flag for every scalar configuration field to fs. Flags
+// write into AppConfig when parsed, so they take precedence over the config file and
+// environment variables.
+func registerConfigFlags(fs *flag.FlagSet) {
+	for _, field := range configFields(&AppConfig) {
+		// flag shows the back-quoted word as the value type; boolean flags take none
+		var kind string
+		switch field.Value.Kind() {
+		case reflect.Int:
+			kind = " (`int`)"
+		case reflect.Float64:
+			kind = " (`float`)"
+		case reflect.String:
+			kind = " (`string`)"
+		}
+		usage := fmt.Sprintf("Override %s%s from the config file (env %s)", field.Key, kind, field.EnvName())
+		fs.Var(configFlag{field.Value}, field.Name, usage)
+	}
+	fs.Var(configFlag{reflect.ValueOf(&AppConfig.Database.Mode).Elem()}, "db",
+		"Shorthand for -database-mode: `external` (DBOS_SYSTEM_DATABASE_URL) or embedded (ephemeral local Postgres)")
+}
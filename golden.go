@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"fifo-queue-demo/experiment"
+)
+
+// defaultGoldenPath is the file holding the golden metrics of the golden workload
+const defaultGoldenPath = "golden.yaml"
+
+// goldenWorkload is the fixed workload of golden runs. It doesn't follow config.yaml, so
+// the golden metrics only change when the scheduling code does.
+var goldenWorkload = WorkloadConfig{
+	NumTasks:             2000,
+	ShortTaskDurationMs:  100,
+	LongTaskDurationMs:   2000,
+	ShortTaskProbability: 0.8,
+	TargetUtilization:    0.8,
+	DeadlineFactor:       3,
+}
+
+// goldenAlgorithms tunes the algorithms of golden runs, pinned like the workload.
+// Algorithms without a section here fall back to their built-in settings.
+var goldenAlgorithms = AlgorithmsConfig{
+	SJFLanes: SJFLanesConfig{Mode: "weighted", ShortWeight: 3, LongWeight: 1},
+}
+
+// Golden runs use a fixed seed and capacity. They start now: EDF priorities count from
+// process start, and metrics are relative to arrival times anyway.
+const (
+	goldenSeed     = 1
+	goldenCapacity = 4
+)
+
+// goldenFile holds the golden metrics of every algorithm, and how far a run may drift
+// from them
+type goldenFile struct {
+	Tolerance  float64                  `yaml:"tolerance"` // Relative, e.g. 0.02 for 2%
+	Algorithms map[string]goldenMetrics `yaml:"algorithms"`
+}
+
+// goldenMetrics are the key response time metrics of an algorithm on the golden workload
+type goldenMetrics struct {
+	MeanMs     float64 `yaml:"mean_ms"`
+	P99Ms      float64 `yaml:"p99_ms"`
+	ShortP99Ms float64 `yaml:"short_p99_ms"`
+	LongP99Ms  float64 `yaml:"long_p99_ms"`
+}
+
+// runGolden runs every algorithm on the golden workload with the simulation backend, and
+// compares the results with the golden metrics in path. It fails if any metric drifted
+// further than the tolerance, in either direction: an unexpected improvement deserves a
+// look too. With update, it writes the current metrics to path instead.
+func runGolden(path string, update bool) error {
+	golden := goldenFile{Tolerance: 0.02}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, &golden); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !update {
+		return fmt.Errorf("failed to read golden metrics: %w (create them with -golden-update)", err)
+	}
+
+	// Algorithms are built with the golden settings on the golden workload
+	AppConfig.Workload = goldenWorkload
+	AppConfig.Algorithms = goldenAlgorithms
+
+	current := make(map[string]goldenMetrics, len(algorithms))
+	for _, name := range sortedKeys(algorithms) {
+		policy, err := lookupPolicy(name)
+		if err != nil {
+			return err
+		}
+		report, err := experiment.RunExperiment(experiment.Experiment{
+			Workload: goldenWorkload,
+			Policy:   policy,
+			Capacity: goldenCapacity,
+			Seed:     goldenSeed,
+		})
+		if err != nil {
+			return err
+		}
+		current[name] = goldenMetrics{
+			MeanMs:     roundMs(report.Response.Mean),
+			P99Ms:      roundMs(report.Response.P99),
+			ShortP99Ms: roundMs(report.ShortResponse.P99),
+			LongP99Ms:  roundMs(report.LongResponse.P99),
+		}
+	}
+
+	if update {
+		golden.Algorithms = current
+		data, err := yaml.Marshal(golden)
+		if err != nil {
+			return err
+		}
+		header := "# Golden metrics of the fixed-seed golden workload, checked by `go run . -golden`.\n" +
+			"# Regenerate with `go run . -golden-update` after an intended scheduling change.\n"
+		if err := os.WriteFile(path, append([]byte(header), data...), 0644); err != nil {
+			return fmt.Errorf("failed to write golden metrics: %w", err)
+		}
+		fmt.Printf("Golden metrics of %d algorithms written to %s\n", len(current), path)
+		return nil
+	}
+
+	fmt.Printf("Golden run: %d tasks, capacity %d, seed %d, tolerance %.1f%%\n\n",
+		goldenWorkload.NumTasks, goldenCapacity, goldenSeed, golden.Tolerance*100)
+	fmt.Printf("%-6s %-14s %12s %12s %9s  %s\n", "Algo", "Metric", "Golden", "Current", "Change", "Status")
+	failures := 0
+	for _, name := range sortedKeys(current) {
+		want, ok := golden.Algorithms[name]
+		if !ok {
+			fmt.Printf("%-6s no golden metrics (add them with -golden-update)\n", name)
+			failures++
+			continue
+		}
+		got := current[name]
+		for _, metric := range []struct {
+			name      string
+			want, got float64
+		}{
+			{"mean", want.MeanMs, got.MeanMs},
+			{"p99", want.P99Ms, got.P99Ms},
+			{"short p99", want.ShortP99Ms, got.ShortP99Ms},
+			{"long p99", want.LongP99Ms, got.LongP99Ms},
+		} {
+			change := 0.0
+			if metric.want != 0 {
+				change = (metric.got - metric.want) / metric.want
+			} else if metric.got != 0 {
+				change = math.Inf(1)
+			}
+			status := "ok"
+			if math.Abs(change) > golden.Tolerance {
+				status = "IMPROVED"
+				if change > 0 {
+					status = "REGRESSED"
+				}
+				failures++
+			}
+			fmt.Printf("%-6s %-14s %12.1f %12.1f %+8.1f%%  %s\n", name, metric.name, metric.want, metric.got, change*100, status)
+		}
+	}
+	for _, name := range sortedKeys(golden.Algorithms) {
+		if _, ok := current[name]; !ok {
+			fmt.Printf("%-6s has golden metrics but is no longer an algorithm\n", name)
+		}
+	}
+
+	if failures > 0 {
+		return errors.New("golden run drifted from the golden metrics; if the change is intended, run -golden-update")
+	}
+	fmt.Println("\nGolden run matches the golden metrics")
+	return nil
+}
+
+// roundMs converts a latency to milliseconds, rounded to the microsecond
+func roundMs(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())) / 1000
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxSummaryGroups bounds the groups printed in the summary, since some attributes, like
+// EDF priorities, give nearly every task its own group
+const maxSummaryGroups = 20
+
+// taskGrouping is a task attribute the summary statistics can be broken down by
+type taskGrouping struct {
+	key   func(task Task, policy SchedulingPolicy) string // Group of a task
+	title func(key string) string                         // Heading of a group, e.g. "Short Tasks"
+	order []string                                        // Groups listed first, in this order
+}
+
+// taskGroupings lists the groupings by their metrics.group_by name
+var taskGroupings = map[string]taskGrouping{
+	"class": {
+		key:   func(task Task, _ SchedulingPolicy) string { return taskClass(task) },
+		title: func(key string) string { return strings.ToUpper(key[:1]) + key[1:] + " Tasks" },
+		order: []string{"short", "long"},
+	},
+	"priority": {
+		key: func(task Task, policy SchedulingPolicy) string {
+			if policy.Priority == nil {
+				return ""
+			}
+			return strconv.FormatUint(uint64(policy.Priority(task)), 10)
+		},
+		title: func(key string) string {
+			if key == "" {
+				return "Unprioritized Tasks"
+			}
+			return "Priority " + key + " Tasks"
+		},
+	},
+	"tenant": {
+		key: func(task Task, _ SchedulingPolicy) string { return task.TenantID },
+		title: func(key string) string {
+			if key == "" {
+				return "Tasks Without Tenant"
+			}
+			return "Tenant " + key + " Tasks"
+		},
+	},
+	"queue": {
+		// Tasks go through the queue of the policy they were run with
+		key:   func(_ Task, policy SchedulingPolicy) string { return policy.QueueName },
+		title: func(key string) string { return "Queue " + key + " Tasks" },
+	},
+}
+
+// sort sorts the groups of a grouping in display order: the groups of its order first,
+// then the others with numbers in natural order, e.g. tenant-2 before tenant-10
+func (g taskGrouping) sort(keys []string) []string {
+	rank := func(key string) int {
+		if i := slices.Index(g.order, key); i >= 0 {
+			return i
+		}
+		return len(g.order)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return keys
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"fifo-queue-demo/api"
+)
+
+// grpcTaskServer serves the TaskQueue gRPC service of api/tasks.proto on top of the
+// same taskServer as the HTTP API, so clients in any language can submit tasks and
+// stream their completions
+type grpcTaskServer struct {
+	api.UnimplementedTaskQueueServer
+	server *taskServer
+}
+
+// Submit enqueues a task and returns its ID without waiting for it to complete
+func (g *grpcTaskServer) Submit(ctx context.Context, req *api.SubmitRequest) (*api.SubmitResponse, error) {
+	duration, err := taskDuration(req.DurationMs, req.Class)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var deadline time.Time
+	if req.Deadline != nil {
+		deadline = req.Deadline.AsTime()
+	}
+	task, _, err := g.server.submit(duration, uint(req.Priority), deadline, false)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &api.SubmitResponse{TaskId: int64(task.TaskID)}, nil
+}
+
+// Completions streams the tasks that complete. When the request lists tasks, it streams
+// only those, including the ones that completed before it started, so a client that
+// submits and then subscribes doesn't miss fast tasks, and it ends once they all completed.
+func (g *grpcTaskServer) Completions(req *api.CompletionsRequest, stream grpc.ServerStreamingServer[api.Completion]) error {
+	var taskIDs []int
+	wanted := make(map[int]bool)
+	for _, id := range req.TaskIds {
+		taskIDs = append(taskIDs, int(id))
+		wanted[int(id)] = true
+	}
+	ch := g.server.subscribe(taskIDs)
+	defer g.server.unsubscribe(ch)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case task, ok := <-ch:
+			if !ok {
+				if stream.Context().Err() == nil && !g.server.Stopped() {
+					return status.Error(codes.ResourceExhausted, "stream fell too far behind the completions")
+				}
+				return nil
+			}
+			if len(taskIDs) > 0 && !wanted[task.TaskID] {
+				continue
+			}
+			if err := stream.Send(completionEvent(task)); err != nil {
+				return err
+			}
+			delete(wanted, task.TaskID)
+			if len(taskIDs) > 0 && len(wanted) == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func completionEvent(task Task) *api.Completion {
+	event := &api.Completion{
+		TaskId:      int64(task.TaskID),
+		Status:      "completed",
+		ArrivalTime: timestamppb.New(task.ArrivalTime),
+	}
+	if task.Cancelled {
+		event.Status = "cancelled"
+	}
+	if !task.CompletionTime.IsZero() {
+		event.CompletionTime = timestamppb.New(task.CompletionTime)
+		event.WaitMs = task.WaitTime().Seconds() * 1000
+		event.ResponseMs = task.ResponseTime().Seconds() * 1000
+	}
+	return event
+}
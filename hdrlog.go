@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// hdrLogWriter writes wait and response time distributions in the HdrHistogram log
+// format (version 1.3), one interval histogram per tag and interval, so the results can
+// be analyzed with standard HdrHistogram tooling. Tasks are grouped into intervals by
+// the time their result was collected. Values are in microseconds.
+type hdrLogWriter struct {
+	file          *os.File
+	filename      string
+	cfg           MetricsConfig
+	start         time.Time
+	intervalStart time.Time
+	response      *metrics.Histogram
+	wait          *metrics.Histogram
+	intervals     int
+}
+
+// newHdrLogWriter creates the log file and writes its header
+func newHdrLogWriter(filename string, cfg MetricsConfig, start time.Time) (*hdrLogWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create histogram log: %w", err)
+	}
+	w := &hdrLogWriter{file: file, filename: filename, cfg: cfg, start: start, intervalStart: time.Now()}
+	w.reset()
+
+	seconds := float64(start.UnixNano()) / 1e9
+	fmt.Fprintf(file, "#[Histogram log format version 1.3]\n")
+	fmt.Fprintf(file, "#[StartTime: %.3f (seconds since epoch), %s]\n", seconds, start.Format(time.UnixDate))
+	fmt.Fprintf(file, "#[BaseTime: %.3f (seconds since epoch)]\n", seconds)
+	fmt.Fprintf(file, "\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n")
+	return w, nil
+}
+
+func (w *hdrLogWriter) reset() {
+	w.response = metrics.NewHistogram(w.cfg.HistogramMax(), w.cfg.HistogramSignificantFigures)
+	w.wait = metrics.NewHistogram(w.cfg.HistogramMax(), w.cfg.HistogramSignificantFigures)
+}
+
+// Record adds a completed task, first closing the current interval if it is over
+func (w *hdrLogWriter) Record(task Task) error {
+	if time.Since(w.intervalStart) >= w.cfg.HdrLogInterval() {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.response.Record(task.CompletionTime.Sub(task.ArrivalTime))
+	w.wait.Record(task.DequeueTime.Sub(task.ArrivalTime))
+	return nil
+}
+
+// flush writes the current interval histograms and starts a new interval
+func (w *hdrLogWriter) flush() error {
+	now := time.Now()
+	if w.response.Count() > 0 {
+		for _, tagged := range []struct {
+			tag       string
+			histogram *metrics.Histogram
+		}{{"response", w.response}, {"wait", w.wait}} {
+			encoded, err := tagged.histogram.EncodeCompressed()
+			if err != nil {
+				return err
+			}
+			// Interval_Max is reported in milliseconds
+			_, err = fmt.Fprintf(w.file, "Tag=%s,%.3f,%.3f,%.3f,%s\n", tagged.tag,
+				w.intervalStart.Sub(w.start).Seconds(), now.Sub(w.intervalStart).Seconds(),
+				float64(tagged.histogram.Max().Microseconds())/1000, encoded)
+			if err != nil {
+				return fmt.Errorf("failed to write histogram log: %w", err)
+			}
+		}
+		w.intervals++
+	}
+	w.intervalStart = now
+	w.reset()
+	return nil
+}
+
+// Close writes the last interval and closes the file
+func (w *hdrLogWriter) Close() error {
+	if err := w.flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close histogram log: %w", err)
+	}
+	fmt.Printf("Histogram log exported to %s (%d intervals)\n", w.filename, w.intervals)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/metrics"
+)
+
+// streamStats accumulates response and wait time histograms as tasks complete, for runs
+// too large to keep every task in memory
+type streamStats struct {
+	allResponse *metrics.Histogram
+	response    map[string]*metrics.Histogram // Per group of metrics.group_by
+	wait        map[string]*metrics.Histogram
+	cfg         MetricsConfig
+	policy      SchedulingPolicy
+	grouping    taskGrouping
+}
+
+func newStreamStats(cfg MetricsConfig, policy SchedulingPolicy) *streamStats {
+	return &streamStats{
+		allResponse: metrics.NewHistogram(cfg.HistogramMax(), cfg.HistogramSignificantFigures),
+		response:    make(map[string]*metrics.Histogram),
+		wait:        make(map[string]*metrics.Histogram),
+		cfg:         cfg,
+		policy:      policy,
+		grouping:    taskGroupings[cfg.GroupBy],
+	}
+}
+
+func (s *streamStats) histogram(histograms map[string]*metrics.Histogram, group string) *metrics.Histogram {
+	if histograms[group] == nil {
+		histograms[group] = metrics.NewHistogram(s.cfg.HistogramMax(), s.cfg.HistogramSignificantFigures)
+	}
+	return histograms[group]
+}
+
+// Record adds a completed task to the histograms
+func (s *streamStats) Record(task Task) {
+	response := task.CompletionTime.Sub(task.ArrivalTime)
+	wait := task.DequeueTime.Sub(task.ArrivalTime)
+	group := s.grouping.key(task, s.policy)
+	s.allResponse.Record(response)
+	s.histogram(s.response, group).Record(response)
+	s.histogram(s.wait, group).Record(wait)
+}
+
+// Print prints the same response time statistics as printSummary, from the histograms
+func (s *streamStats) Print() {
+	if s.allResponse.Count() == 0 {
+		return
+	}
+	digits := s.cfg.HistogramSignificantFigures
+	printLatencySummary(fmt.Sprintf("All Tasks, n=%d, %d significant digits", s.allResponse.Count(), digits),
+		histogramSummary(s.allResponse))
+	for i, group := range s.grouping.sort(sortedKeys(s.response)) {
+		if i == maxSummaryGroups {
+			fmt.Printf("\n(%d more groups by %s not shown)\n", len(s.response)-i, s.cfg.GroupBy)
+			break
+		}
+		h := s.response[group]
+		printLatencySummary(fmt.Sprintf("%s, n=%d, %d significant digits", s.grouping.title(group), h.Count(), digits),
+			histogramSummary(h))
+	}
+}
+
+// histogramSummary returns the statistics of a histogram
+func histogramSummary(h *metrics.Histogram) ResponseSummary {
+	return ResponseSummary{
+		Count:  int(h.Count()),
+		Mean:   h.Mean(),
+		Median: h.ValueAtPercentile(50),
+		P90:    h.ValueAtPercentile(90),
+		P99:    h.ValueAtPercentile(99),
+		Min:    h.Min(),
+		Max:    h.Max(),
+	}
+}
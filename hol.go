@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// HOLSummary describes the head-of-line blocking of one class of tasks: the part of their
+// wait during which every worker slot was busy with a task longer than them. With a
+// single worker, it is the wait spent behind a longer task; a policy that ran the tasks
+// shortest first would have spared them most of it.
+type HOLSummary struct {
+	Class   string
+	Tasks   int
+	Blocked int           // Tasks that spent part of their wait blocked
+	Cost    time.Duration // Wait spent blocked, summed over the tasks
+	Wait    time.Duration // Wait of every task, for scale
+}
+
+// Share returns the share of the class's wait spent blocked behind longer tasks
+func (s HOLSummary) Share() float64 {
+	if s.Wait == 0 {
+		return 0
+	}
+	return float64(s.Cost) / float64(s.Wait)
+}
+
+// holBlocking returns how long each task waited while every worker slot was busy with a
+// longer task, in task order. Every enqueue and leave is a segment boundary, so the wait
+// of a task covers whole segments; tasks are taken longest first, adding the segments
+// whose shortest running task is longer than them as they go.
+func holBlocking(tasks []Task) []time.Duration {
+	blocked := make([]time.Duration, len(tasks))
+	segments, slots := occupancy(tasks, func(Task) bool { return false })
+	if slots == 0 {
+		return blocked
+	}
+	full := make([]int, 0, len(segments)) // Segments with every slot busy, by their shortest running task, longest first
+	for i, s := range segments {
+		if s.Running >= slots {
+			full = append(full, i)
+		}
+	}
+	sort.Slice(full, func(i, j int) bool { return segments[full[i]].MinRunning > segments[full[j]].MinRunning })
+	order := make([]int, len(tasks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return tasks[order[i]].Duration > tasks[order[j]].Duration })
+
+	// Fenwick tree of the blocking segments' lengths, by segment index
+	tree := make([]time.Duration, len(segments)+1)
+	add := func(i int, d time.Duration) {
+		for i++; i < len(tree); i += i & -i {
+			tree[i] += d
+		}
+	}
+	sum := func(i int) time.Duration { // Of the segments before i
+		var total time.Duration
+		for ; i > 0; i -= i & -i {
+			total += tree[i]
+		}
+		return total
+	}
+	index := func(t time.Time) int {
+		return sort.Search(len(segments), func(i int) bool { return !segments[i].Start.Before(t) })
+	}
+
+	next := 0
+	for _, i := range order {
+		for ; next < len(full) && segments[full[next]].MinRunning > tasks[i].Duration; next++ {
+			s := segments[full[next]]
+			add(full[next], s.End.Sub(s.Start))
+		}
+		blocked[i] = sum(index(taskLeave(tasks[i]))) - sum(index(taskEnqueue(tasks[i])))
+	}
+	return blocked
+}
+
+// summarizeHOL sums up the head-of-line blocking of all tasks, then of short and long ones
+func summarizeHOL(tasks []Task, isShort func(Task) bool) []HOLSummary {
+	blocked := holBlocking(tasks)
+	var summaries []HOLSummary
+	for _, class := range []string{"all", "short", "long"} {
+		summary := HOLSummary{Class: class}
+		for i, task := range tasks {
+			if (class == "short" && !isShort(task)) || (class == "long" && isShort(task)) {
+				continue
+			}
+			summary.Tasks++
+			summary.Wait += taskLeave(task).Sub(taskEnqueue(task))
+			if blocked[i] > 0 {
+				summary.Blocked++
+				summary.Cost += blocked[i]
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// printHOLReport prints the wait tasks spent blocked behind longer ones, the cost of
+// head-of-line blocking that SJF exists to cut. Pipeline tasks run in several queues, so
+// it prints nothing for pipelines.
+func printHOLReport(tasks []Task, policy SchedulingPolicy) {
+	if len(AppConfig.Pipeline.Stages) > 0 {
+		return
+	}
+	isShort := func(task Task) bool { return taskClass(task) == "short" }
+	fmt.Printf("\nHead-of-line blocking (%s, wait while every worker slot ran a longer task):\n", policy.Name)
+	for _, s := range summarizeHOL(tasks, isShort) {
+		if s.Tasks == 0 {
+			continue
+		}
+		fmt.Printf("  %s tasks: %.3f s blocked (%.1f%% of their wait), %d/%d tasks blocked, %s ms per task\n",
+			s.Class, s.Cost.Seconds(), 100*s.Share(), s.Blocked, s.Tasks, formatMs(s.Cost/time.Duration(s.Tasks)))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ioWorkSchema holds the scratch table tasks read and write in the io work mode
+const ioWorkSchema = `
+CREATE TABLE IF NOT EXISTS schedq_scratch (
+    id         BIGSERIAL PRIMARY KEY,
+    owner      TEXT NOT NULL, -- Workflow ID of the task that wrote the row
+    data       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+CREATE INDEX IF NOT EXISTS schedq_scratch_owner ON schedq_scratch (owner);
+`
+
+// ioWorkCalibrationQueries is the number of queries timed to calibrate the io work mode
+const ioWorkCalibrationQueries = 20
+
+// activeIOWork is the io work backend of the executors in this process, nil unless the
+// work mode is "io"
+var activeIOWork atomic.Pointer[ioWorker]
+
+// ioWorker runs the Postgres queries of io-bound tasks over its own connection pool, so
+// task work and the scheduler's queue traffic compete for the same database
+type ioWorker struct {
+	pool      *pgxpool.Pool
+	queryTime time.Duration // Latency of one task query on an idle database
+}
+
+// newIOWorker connects to Postgres, creates the scratch table and calibrates the latency
+// of a task query, before any task runs
+func newIOWorker(ctx context.Context) (*ioWorker, error) {
+	pool, err := newPoolWithLatency(ctx, AppConfig.Database, AppConfig.Network.WorkerLatency())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, ioWorkSchema); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to create scratch table: %w", err)
+	}
+	w := &ioWorker{pool: pool}
+
+	// The median latency of a few queries filters out connection setup and hiccups
+	owner := fmt.Sprintf("calibration-%d", os.Getpid())
+	latencies := make([]time.Duration, ioWorkCalibrationQueries)
+	for i := range latencies {
+		start := time.Now()
+		if err := w.query(ctx, owner, i); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to calibrate io work: %w", err)
+		}
+		latencies[i] = time.Since(start)
+	}
+	if _, err := pool.Exec(ctx, `DELETE FROM schedq_scratch WHERE owner = $1`, owner); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to clean up io work calibration: %w", err)
+	}
+	slices.Sort(latencies)
+	w.queryTime = max(latencies[len(latencies)/2], time.Microsecond)
+	fmt.Printf("IO work calibrated: %v per query on an idle database\n", w.queryTime)
+	return w, nil
+}
+
+// query runs the i-th query of a task: even queries write a row to the scratch table,
+// odd ones read back an aggregate over the task's rows
+func (w *ioWorker) query(ctx context.Context, owner string, i int) error {
+	if i%2 == 0 {
+		_, err := w.pool.Exec(ctx, `INSERT INTO schedq_scratch (owner, data) VALUES ($1, md5(random()::text))`, owner)
+		return err
+	}
+	var count int
+	var latest time.Time
+	return w.pool.QueryRow(ctx, `SELECT count(*), coalesce(max(created_at), now()) FROM schedq_scratch WHERE owner = $1`,
+		owner).Scan(&count, &latest)
+}
+
+// Queries returns the number of queries a task of the given duration runs: as many as
+// fit in its duration on an idle database, and at least one
+func (w *ioWorker) Queries(duration time.Duration) int {
+	return max(int(duration/w.queryTime), 1)
+}
+
+// Work runs the given number of queries for the task with the given workflow ID, one
+// after the other, then removes its rows. Under database load, the queries slow down and
+// so does the task.
+func (w *ioWorker) Work(ctx context.Context, workflowID string, queries int) error {
+	for i := range queries {
+		if err := w.query(ctx, workflowID, i); err != nil {
+			return fmt.Errorf("io work query failed: %w", err)
+		}
+	}
+	if _, err := w.pool.Exec(ctx, `DELETE FROM schedq_scratch WHERE owner = $1`, workflowID); err != nil {
+		return fmt.Errorf("failed to clean up io work: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection pool
+func (w *ioWorker) Close() {
+	w.pool.Close()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// JobSummary holds job-level metrics of a workload made of multi-task jobs
+type JobSummary struct {
+	Jobs       int
+	Makespan   time.Duration   // From the first arrival to the last completion of the run
+	Completion ResponseSummary // Job completion time: last task completion - job arrival
+	// Job completion time relative to the job's longest task, the least it could take if
+	// all its tasks started on arrival. Jobs are fork-join: their tasks have no dependencies
+	// between them.
+	MeanSlowdown float64
+	MaxSlowdown  float64
+	// Job completion time relative to the mean response time of its tasks: how much
+	// longer than its typical task a job takes because it joins on the slowest one
+	MeanJoinInflation float64
+}
+
+// summarizeJobs computes job-level metrics. It returns false when the tasks don't belong
+// to jobs.
+func summarizeJobs(tasks []Task) (JobSummary, bool) {
+	type job struct {
+		arrival, completion time.Time
+		longestTask         time.Duration
+		totalResponse       time.Duration
+		tasks               int
+	}
+	jobs := make(map[string]*job)
+	var first, last time.Time
+	for _, task := range tasks {
+		if first.IsZero() || task.ArrivalTime.Before(first) {
+			first = task.ArrivalTime
+		}
+		if task.CompletionTime.After(last) {
+			last = task.CompletionTime
+		}
+		if task.JobID == "" {
+			continue
+		}
+		j := jobs[task.JobID]
+		if j == nil {
+			j = &job{arrival: task.ArrivalTime, completion: task.CompletionTime}
+			jobs[task.JobID] = j
+		}
+		if task.ArrivalTime.Before(j.arrival) {
+			j.arrival = task.ArrivalTime
+		}
+		if task.CompletionTime.After(j.completion) {
+			j.completion = task.CompletionTime
+		}
+		j.longestTask = max(j.longestTask, task.Duration)
+		j.totalResponse += task.ResponseTime()
+		j.tasks++
+	}
+	if len(jobs) == 0 {
+		return JobSummary{}, false
+	}
+
+	summary := JobSummary{Jobs: len(jobs), Makespan: last.Sub(first)}
+	completions := make([]time.Duration, 0, len(jobs))
+	var totalSlowdown, totalInflation float64
+	for _, j := range jobs {
+		completion := j.completion.Sub(j.arrival)
+		completions = append(completions, completion)
+		if j.longestTask > 0 {
+			slowdown := float64(completion) / float64(j.longestTask)
+			totalSlowdown += slowdown
+			summary.MaxSlowdown = max(summary.MaxSlowdown, slowdown)
+		}
+		if j.totalResponse > 0 {
+			meanResponse := float64(j.totalResponse) / float64(j.tasks)
+			totalInflation += float64(completion) / meanResponse
+		}
+	}
+	summary.Completion = metrics.Summarize(completions)
+	summary.MeanSlowdown = totalSlowdown / float64(len(jobs))
+	summary.MeanJoinInflation = totalInflation / float64(len(jobs))
+	return summary, true
+}
+
+// printJobReport prints job completion times, makespan and slowdown over the longest task
+func printJobReport(tasks []Task) {
+	summary, ok := summarizeJobs(tasks)
+	if !ok {
+		return
+	}
+	fmt.Printf("\nJobs (%d jobs):\n", summary.Jobs)
+	fmt.Printf("  Makespan: %s ms\n", formatMs(summary.Makespan))
+	fmt.Printf("  Job completion time: mean %s ms, median %s ms, p99 %s ms\n",
+		formatMs(summary.Completion.Mean), formatMs(summary.Completion.Median), formatMs(summary.Completion.P99))
+	fmt.Printf("  Slowdown (job completion over its longest task): mean %.2fx, max %.2fx\n", summary.MeanSlowdown, summary.MaxSlowdown)
+	fmt.Printf("  Join inflation (job completion over the mean response of its tasks): mean %.2fx\n", summary.MeanJoinInflation)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"fifo-queue-demo/metrics"
+)
+
+// killSwitch aborts a run whose backlog or tail latency grows past its bounds, so a
+// misconfigured overload run doesn't keep hammering a shared Postgres for hours. Every
+// check, it compares the number of waiting tasks with its bound, and at the end of every
+// window the p99 response time of the tasks that completed in it with its own. Once
+// either is exceeded, it trips: the producer stops enqueuing, every check from then on
+// cancels the run's tasks still waiting in Postgres, and the collection stops waiting
+// for the tasks in flight after the drain timeout, so the run exports what completed.
+type killSwitch struct {
+	cfg    KillSwitchConfig
+	pool   *pgxpool.Pool
+	queue  taskQueue
+	runID  string
+	notify bool
+	queues []string // Queues holding the run's waiting tasks
+
+	mu          sync.Mutex
+	response    *metrics.Histogram // Of the tasks completed in the current window
+	windowStart time.Time
+	maxBacklog  int // Largest backlog seen
+	maxP99      time.Duration
+	reason      string // Why the kill switch tripped, empty until it does
+	cancelled   int    // Waiting tasks cancelled since
+	err         error
+
+	tripped chan struct{} // Closed when the kill switch trips
+	abort   chan struct{} // Closed once the drain timeout has passed since
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// startKillSwitch connects to Postgres and starts watching the run's backlog and latency
+func startKillSwitch(cfg KillSwitchConfig, policy SchedulingPolicy, queueCfg QueueConfig, runID string, queue taskQueue, monitor *poolMonitor) (*killSwitch, error) {
+	pool, err := newPool(context.Background(), AppConfig.Database)
+	if err != nil {
+		return nil, err
+	}
+	monitor.Add("kill-switch", pool)
+	k := &killSwitch{
+		cfg:         cfg,
+		pool:        pool,
+		queue:       queue,
+		runID:       runID,
+		notify:      queueCfg.Dispatch == "notify",
+		queues:      policyQueueNames(policy, policy.QueueName),
+		windowStart: time.Now(),
+		tripped:     make(chan struct{}),
+		abort:       make(chan struct{}),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	k.resetWindow()
+	fmt.Printf("Kill switch: aborting the run past %s\n", cfg.Bounds())
+	go k.run()
+	return k, nil
+}
+
+// register makes the kill switch follow the run: it stops the arrivals and then the
+// collection when it trips, watches the latency of the tasks completed, and reports
+// whether it tripped
+func (k *killSwitch) register(r *experimentRun) {
+	r.stopArrivals = k.Tripped()
+	r.abortCollection = k.Abort()
+	r.OnCompleted(k.Record)
+	r.OnCollected(func([]Task) error {
+		k.Stop()
+		if err := k.Err(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return nil
+	})
+	r.OnReport(func(outcome *runOutcome) error {
+		k.Print(outcome.numTasks, outcome.enqueued, outcome.unfinished)
+		return nil
+	})
+	r.OnClose(k.Stop)
+}
+
+// resetWindow starts a new latency window. Callers hold the lock.
+func (k *killSwitch) resetWindow() {
+	cfg := AppConfig.Metrics
+	k.response = metrics.NewHistogram(cfg.HistogramMax(), cfg.HistogramSignificantFigures)
+}
+
+// Record adds a task that completed to the current latency window
+func (k *killSwitch) Record(task Task) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.response.Record(max(0, task.ResponseTime()))
+}
+
+// Tripped returns a channel closed when the kill switch trips, which is never for runs
+// without a kill switch
+func (k *killSwitch) Tripped() <-chan struct{} {
+	if k == nil {
+		return nil
+	}
+	return k.tripped
+}
+
+// Abort returns a channel closed once the tasks in flight when the kill switch tripped
+// had their drain timeout to finish, which is never for runs without a kill switch
+func (k *killSwitch) Abort() <-chan struct{} {
+	if k == nil {
+		return nil
+	}
+	return k.abort
+}
+
+func (k *killSwitch) run() {
+	defer close(k.done)
+	ticker := time.NewTicker(k.cfg.CheckInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			k.check()
+		}
+	}
+}
+
+// check compares the backlog, and the p99 response time at the end of a window, with
+// their bounds, and cancels the waiting tasks once the kill switch tripped. The tasks
+// enqueued while the producer stops are caught by the next checks.
+func (k *killSwitch) check() {
+	select {
+	case <-k.tripped:
+		k.cancelBacklog()
+		return
+	default:
+	}
+
+	backlog, err := k.queue.Depth()
+	now := time.Now()
+	k.mu.Lock()
+	if err != nil {
+		k.fail(fmt.Errorf("kill switch failed to read the backlog: %w", err))
+	} else {
+		k.maxBacklog = max(k.maxBacklog, backlog)
+		if k.cfg.MaxBacklog > 0 && backlog > k.cfg.MaxBacklog {
+			k.trip(fmt.Sprintf("backlog of %d tasks over the bound of %d", backlog, k.cfg.MaxBacklog))
+		}
+	}
+	if now.Sub(k.windowStart) >= k.cfg.Window() {
+		if k.response.Count() > 0 {
+			p99 := k.response.ValueAtPercentile(99)
+			k.maxP99 = max(k.maxP99, p99)
+			if k.cfg.MaxP99Ms > 0 && p99 > k.cfg.MaxP99() {
+				k.trip(fmt.Sprintf("p99 response time of %s ms over the last %v, over the bound of %d ms",
+					formatMs(p99), k.cfg.Window(), k.cfg.MaxP99Ms))
+			}
+		}
+		k.resetWindow()
+		k.windowStart = now
+	}
+	tripped := k.reason != ""
+	k.mu.Unlock()
+
+	if tripped {
+		k.cancelBacklog()
+	}
+}
+
+// trip records why the kill switch tripped and lets the producer and the collection know.
+// Callers hold the lock.
+func (k *killSwitch) trip(reason string) {
+	if k.reason != "" {
+		return
+	}
+	k.reason = reason
+	fmt.Printf("\nKill switch tripped: %s. Stopping arrivals, cancelling the waiting tasks and giving the running ones %v to finish.\n",
+		reason, k.cfg.DrainTimeout())
+	close(k.tripped)
+	time.AfterFunc(k.cfg.DrainTimeout(), func() { close(k.abort) })
+}
+
+// cancelBacklog cancels the run's tasks still waiting in Postgres, in a single statement
+// that leaves the running ones alone: listing the waiting workflows to cancel each one
+// with DBOS would also cancel those that started in between. Cancelled DBOS workflows are
+// collected like the ones their client gave up on; tasks removed from the notify task
+// table never start a workflow, so the collection stops waiting for them at the drain
+// timeout.
+func (k *killSwitch) cancelBacklog() {
+	var cancelled int64
+	var err error
+	if k.notify {
+		var tag pgconn.CommandTag
+		tag, err = k.pool.Exec(context.Background(), `
+			DELETE FROM schedq_tasks
+			WHERE queue_name = ANY($1) AND claimed_at IS NULL AND starts_with(workflow_id, $2)`,
+			k.queues, tagPrefix(k.runID))
+		cancelled = tag.RowsAffected()
+	} else {
+		cancelled, err = cancelEnqueuedRun(context.Background(), k.pool, k.queues, tagPrefix(k.runID))
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err != nil {
+		k.fail(fmt.Errorf("kill switch failed to cancel the waiting tasks: %w", err))
+		return
+	}
+	k.cancelled += int(cancelled)
+}
+
+// fail records the first error; k.mu must be held
+func (k *killSwitch) fail(err error) {
+	if k.err == nil {
+		k.err = err
+	}
+}
+
+// Stop stops watching the run, sweeps the waiting tasks a last time if the kill switch
+// tripped, and closes its connections
+func (k *killSwitch) Stop() {
+	if k == nil {
+		return
+	}
+	select {
+	case <-k.stop:
+		return
+	default:
+		close(k.stop)
+	}
+	<-k.done
+	select {
+	case <-k.tripped:
+		k.cancelBacklog()
+	default:
+	}
+	k.pool.Close()
+}
+
+// Err returns the first error a check ran into
+func (k *killSwitch) Err() error {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.err
+}
+
+// Print reports whether the kill switch tripped, and if so how much of the run was left
+// out: the tasks that never arrived, those cancelled while waiting, and those still in
+// flight at the drain timeout
+func (k *killSwitch) Print(numTasks, enqueued, unfinished int) {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.reason == "" {
+		fmt.Printf("\nKill switch: not tripped (largest backlog %d tasks, worst window p99 %s ms)\n", k.maxBacklog, formatMs(k.maxP99))
+		return
+	}
+	fmt.Printf("\nRUN ABORTED by the kill switch: %s\n", k.reason)
+	fmt.Printf("  %d of %d tasks enqueued, %d cancelled while waiting, %d still unfinished at the drain timeout\n",
+		enqueued, numTasks, k.cancelled, unfinished)
+	fmt.Printf("  The results only cover the tasks that completed, so they understate the overload.\n")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// windowSignificantFigures is the precision of the histograms of latency windows, lower
+// than that of the run's own histograms: a long run has hundreds of windows, and window
+// percentiles within 1% are enough to spot transients
+const windowSignificantFigures = 2
+
+// windowTransientFactor is how many times the p99 response time of the median window a
+// window's must reach to be reported as a transient
+const windowTransientFactor = 2
+
+// newLatencyWindows creates the latency windows of a run starting at origin, or returns
+// nil if they're off
+func newLatencyWindows(origin time.Time) *metrics.Windows {
+	cfg := AppConfig.Metrics
+	if cfg.Window() <= 0 {
+		return nil
+	}
+	return metrics.NewWindows(origin, cfg.Window(), cfg.HistogramMax(), min(cfg.HistogramSignificantFigures, windowSignificantFigures))
+}
+
+// latencyWindowsOf returns the latency windows of the completed tasks of a run, starting
+// at its first arrival, or nil if they're off
+func latencyWindowsOf(tasks []Task) *metrics.Windows {
+	if AppConfig.Metrics.Window() <= 0 || len(tasks) == 0 {
+		return nil
+	}
+	origin := tasks[0].ArrivalTime
+	for _, task := range tasks {
+		if task.ArrivalTime.Before(origin) {
+			origin = task.ArrivalTime
+		}
+	}
+	windows := newLatencyWindows(origin)
+	for _, task := range tasks {
+		if task.Completed() {
+			windows.Record(task)
+		}
+	}
+	return windows
+}
+
+// latencyWindowsFilename returns the latency windows file that goes with a results file
+func latencyWindowsFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_latency_windows.csv"
+}
+
+// reportLatencyWindows prints how the response and wait time percentiles varied from
+// window to window, and the windows whose p99 response time stood out, then exports every
+// window next to the results file
+func reportLatencyWindows(windows *metrics.Windows, resultsFile string) error {
+	if windows == nil {
+		return nil
+	}
+	var stats []metrics.WindowStats
+	for _, s := range windows.Stats() {
+		if s.Count > 0 {
+			stats = append(stats, s)
+		}
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	span := func(s metrics.WindowStats) string {
+		return fmt.Sprintf("%v-%v", s.Start, s.End)
+	}
+	fmt.Printf("\nLatency windows (%v each, %d with completed tasks):\n", windows.Length(), len(stats))
+	p99 := len(metrics.WindowPercentiles) - 1
+	var medianP99 time.Duration
+	for _, series := range []struct {
+		name  string
+		value func(metrics.WindowStats, int) time.Duration
+	}{
+		{"Response", func(s metrics.WindowStats, i int) time.Duration { return s.Response[i] }},
+		{"Wait", func(s metrics.WindowStats, i int) time.Duration { return s.Wait[i] }},
+	} {
+		for i, p := range metrics.WindowPercentiles {
+			sorted := append([]metrics.WindowStats(nil), stats...)
+			sort.SliceStable(sorted, func(a, b int) bool { return series.value(sorted[a], i) < series.value(sorted[b], i) })
+			best, median, worst := sorted[0], sorted[len(sorted)/2], sorted[len(sorted)-1]
+			fmt.Printf("  %s p%g: median window %s ms, best %s ms (%s), worst %s ms (%s)\n", series.name, p,
+				formatMs(series.value(median, i)), formatMs(series.value(best, i)), span(best), formatMs(series.value(worst, i)), span(worst))
+			if series.name == "Response" && i == p99 {
+				medianP99 = series.value(median, i)
+			}
+		}
+	}
+
+	var transients []string
+	for _, s := range stats {
+		if medianP99 > 0 && s.Response[p99] > windowTransientFactor*medianP99 {
+			transients = append(transients, fmt.Sprintf("%s (%s ms)", span(s), formatMs(s.Response[p99])))
+		}
+	}
+	if len(transients) > 0 {
+		listed := transients[:min(len(transients), 5)]
+		more := ""
+		if len(transients) > len(listed) {
+			more = fmt.Sprintf(" and %d more", len(transients)-len(listed))
+		}
+		fmt.Printf("  Transients: %d windows with a p99 response time over %d times the median window's: %s%s\n",
+			len(transients), windowTransientFactor, strings.Join(listed, ", "), more)
+	}
+
+	filename := latencyWindowsFilename(resultsFile)
+	if err := windows.WriteCSV(filename); err != nil {
+		return err
+	}
+	fmt.Printf("  Windows exported to %s\n", filename)
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"fifo-queue-demo/metrics"
+)
+
+// sharedLockName names the advisory lock tasks contend on; its hash is the lock key
+const sharedLockName = "schedq_shared_lock"
+
+// activeSharedLock is the shared lock of the executors in this process, nil unless some
+// tasks take it
+var activeSharedLock atomic.Pointer[sharedLock]
+
+// sharedLock holds a Postgres advisory lock shared by every task that needs it, across
+// all executors. Each holder runs in a transaction on its own connection, so the lock is
+// released when the work ends, even if it fails.
+type sharedLock struct {
+	pool *pgxpool.Pool
+}
+
+func newSharedLock(ctx context.Context) (*sharedLock, error) {
+	pool, err := newPoolWithLatency(ctx, AppConfig.Database, AppConfig.Network.WorkerLatency())
+	if err != nil {
+		return nil, err
+	}
+	return &sharedLock{pool: pool}, nil
+}
+
+// Hold runs work while holding the lock, and returns how long it waited for the lock.
+// Postgres grants the lock to waiters in the order they asked for it. Waiting for a
+// connection of the lock's pool counts as waiting for the lock.
+func (l *sharedLock) Hold(ctx context.Context, work func() error) (time.Duration, error) {
+	start := time.Now()
+	tx, err := l.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start the lock transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, sharedLockName); err != nil {
+		return 0, fmt.Errorf("failed to take the shared lock: %w", err)
+	}
+	wait := time.Since(start)
+	if err := work(); err != nil {
+		return wait, err
+	}
+	return wait, tx.Commit(ctx)
+}
+
+func (l *sharedLock) Close() {
+	l.pool.Close()
+}
+
+// LockSummary describes how tasks contended on the shared lock
+type LockSummary struct {
+	Locked     int             // Tasks that took the lock
+	Blocked    int             // Tasks that had to wait for it
+	Wait       ResponseSummary // Lock wait of the tasks that took the lock
+	ShortWait  ResponseSummary
+	Inversions int // Tasks blocked by a holder the policy gives a lower priority
+	BehindLong int // Short tasks blocked by a long holder
+}
+
+// summarizeLocks computes lock contention, and finds which task held the lock when each
+// blocked task asked for it. A task asks for the lock when it is dequeued, gets it after
+// its lock wait and releases it when it completes.
+func summarizeLocks(tasks []Task, policy SchedulingPolicy) LockSummary {
+	var holders []Task
+	for _, task := range tasks {
+		if task.NeedsLock {
+			holders = append(holders, task)
+		}
+	}
+	acquired := func(task Task) time.Time { return task.DequeueTime.Add(task.LockWait) }
+	sort.Slice(holders, func(i, j int) bool { return acquired(holders[i]).Before(acquired(holders[j])) })
+
+	summary := LockSummary{Locked: len(holders)}
+	summary.Wait = metrics.SummarizeTasks(holders, nil, func(task Task) time.Duration { return task.LockWait })
+	summary.ShortWait = metrics.SummarizeTasks(holders, func(task Task) bool { return taskClass(task) == "short" },
+		func(task Task) time.Duration { return task.LockWait })
+	for _, task := range holders {
+		if task.LockWait <= 0 {
+			continue
+		}
+		summary.Blocked++
+
+		// The holder is the last task to get the lock before this one asked for it
+		i := sort.Search(len(holders), func(i int) bool { return acquired(holders[i]).After(task.DequeueTime) }) - 1
+		if i < 0 || !holders[i].CompletionTime.After(task.DequeueTime) {
+			continue
+		}
+		holder := holders[i]
+		if policy.Priority != nil && policy.Priority(holder) > policy.Priority(task) {
+			summary.Inversions++
+		}
+		if taskClass(task) == "short" && taskClass(holder) == "long" {
+			summary.BehindLong++
+		}
+	}
+	return summary
+}
+
+// printLockReport prints how long tasks waited for the shared lock and how often a task
+// was blocked by one the policy ranks below it. It prints nothing when no task took it.
+func printLockReport(tasks []Task, policy SchedulingPolicy) {
+	summary := summarizeLocks(tasks, policy)
+	if summary.Locked == 0 {
+		return
+	}
+	fmt.Printf("\nShared lock:\n")
+	fmt.Printf("  %d tasks took the lock, %d had to wait for it\n", summary.Locked, summary.Blocked)
+	fmt.Printf("  Lock wait: mean %s ms, p99 %s ms (short tasks: mean %s ms, p99 %s ms)\n",
+		formatMs(summary.Wait.Mean), formatMs(summary.Wait.P99), formatMs(summary.ShortWait.Mean), formatMs(summary.ShortWait.P99))
+	if policy.Priority != nil {
+		fmt.Printf("  Priority inversions: %d tasks blocked by a lower-priority holder\n", summary.Inversions)
+	}
+	fmt.Printf("  Short tasks blocked by a long holder: %d\n", summary.BehindLong)
+}
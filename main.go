@@ -1,31 +1,80 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func main() {
-	// Load configuration
-	if err := LoadConfig(); err != nil {
+	// Load configuration, from the file and profile selected on the command line
+	configPath, profile, rest := configFlags(os.Args[1:])
+	os.Args = append(os.Args[:1], rest...)
+	if err := LoadConfig(configPath, profile); err != nil {
 		fmt.Printf("Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Parse command-line flags
-	algo := flag.String("algo", "fcfs", "Scheduling algorithm to use (fcfs, sjf)")
-	flag.Parse()
-
-	// Run the appropriate algorithm
-	switch *algo {
-	case "fcfs":
-		FCFS()
-	case "sjf":
-		SJF()
-	default:
-		fmt.Printf("Unknown algorithm: %s\n", *algo)
-		fmt.Println("Available algorithms: fcfs, sjf")
+	// Without a command, the flags apply to run, so "go run . -algo sjf" keeps working
+	name := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage()
+		return
+	}
+	command, ok := commands[name]
+	if !ok {
+		fmt.Printf("Unknown command: %s\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+	err := command.Run(args)
+	stopEmbeddedDatabase()
+	if err != nil {
+		fmt.Printf("%s failed: %v\n", name, err)
 		os.Exit(1)
 	}
 }
+
+// printUsage lists the commands
+func printUsage() {
+	fmt.Println("Usage: fifo-queue-demo [command] [flags]")
+	fmt.Println("\nCommands:")
+	for _, name := range sortedKeys(commands) {
+		fmt.Printf("  %-16s %s\n", name, commands[name].Description)
+	}
+	fmt.Println("  help             Show this list")
+	fmt.Println("\nWithout a command, the flags are passed to run. Every command accepts -config <path>")
+	fmt.Println("and -profile <name>; use <command> -h to list its flags.")
+}
+
+// configFlags extracts the -config and -profile flags, which every command accepts, from
+// the command line. It returns the config file path, the profile and the other arguments.
+// SCHEDQ_CONFIG and SCHEDQ_PROFILE provide defaults for the flags.
+func configFlags(args []string) (configPath, profile string, rest []string) {
+	configPath = defaultConfigPath
+	if path := os.Getenv(envPrefix + "CONFIG"); path != "" {
+		configPath = path
+	}
+	profile = os.Getenv(envPrefix + "PROFILE")
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || (name != "config" && name != "profile") {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if name == "config" {
+			configPath = value
+		} else {
+			profile = value
+		}
+	}
+	return configPath, profile, rest
+}
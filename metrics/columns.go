@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// ColumnType is the type of the values of a results column
+type ColumnType int
+
+const (
+	IntColumn    ColumnType = iota // int64
+	FloatColumn                    // float64, milliseconds for durations
+	BoolColumn                     // bool
+	StringColumn                   // string
+	TimeColumn                     // time.Time
+)
+
+// Column is a column of the results as typed exporters write them, with values rather
+// than the text of the CSV. Names match the CSV header.
+type Column struct {
+	Name  string
+	Type  ColumnType
+	Value func(task workload.Task) any // nil when the task has no value
+}
+
+// ResultColumns lists the columns of the results, in the order of the CSV header
+var ResultColumns = []Column{
+	{"task_id", IntColumn, func(t workload.Task) any { return int64(t.TaskID) }},
+	{"duration_ms", FloatColumn, func(t workload.Task) any { return float64(t.Duration) / float64(time.Millisecond) }},
+	{"arrival_time", TimeColumn, func(t workload.Task) any { return t.ArrivalTime }},
+	{"dequeue_time", TimeColumn, func(t workload.Task) any { return t.DequeueTime }},
+	{"completion_time", TimeColumn, func(t workload.Task) any { return t.CompletionTime }},
+	{"wait_time_ms", FloatColumn, func(t workload.Task) any { return ms(t.WaitTime()) }},
+	{"response_time_ms", FloatColumn, func(t workload.Task) any { return ms(t.ResponseTime()) }},
+	{"backpressure_delay_ms", FloatColumn, func(t workload.Task) any { return ms(t.BackpressureDelay) }},
+	{"tenant_id", StringColumn, func(t workload.Task) any { return t.TenantID }},
+	{"starved", BoolColumn, func(t workload.Task) any { return t.Starved }},
+	{"job_id", StringColumn, func(t workload.Task) any { return t.JobID }},
+	{"deadline", TimeColumn, func(t workload.Task) any { return optionalTime(t.Deadline) }},
+	{"lateness_ms", FloatColumn, func(t workload.Task) any {
+		if t.Deadline.IsZero() {
+			return nil
+		}
+		return ms(t.Lateness())
+	}},
+	{"attempts", IntColumn, func(t workload.Task) any { return int64(t.Attempts) }},
+	{"failed", BoolColumn, func(t workload.Task) any { return t.Failed }},
+	{"retry_delay_ms", FloatColumn, func(t workload.Task) any { return ms(t.RetryDelay) }},
+	{"enqueued_at", TimeColumn, func(t workload.Task) any { return optionalTime(t.EnqueuedAt) }},
+	{"started_at", TimeColumn, func(t workload.Task) any { return optionalTime(t.StartedAt) }},
+	{"enqueue_ms", FloatColumn, func(t workload.Task) any { return ms(t.EnqueueDelay()) }},
+	{"queueing_ms", FloatColumn, func(t workload.Task) any { return ms(t.QueueingDelay()) }},
+	{"startup_ms", FloatColumn, func(t workload.Task) any { return ms(t.StartupDelay()) }},
+	{"needs_lock", BoolColumn, func(t workload.Task) any { return t.NeedsLock }},
+	{"lock_wait_ms", FloatColumn, func(t workload.Task) any { return ms(t.LockWait) }},
+	{"boosted", BoolColumn, func(t workload.Task) any { return t.Boosted }},
+	{"dead_lettered", BoolColumn, func(t workload.Task) any { return t.DeadLettered }},
+	{"request_id", IntColumn, func(t workload.Task) any { return int64(t.Request()) }},
+	{"client_attempt", IntColumn, func(t workload.Task) any { return int64(t.ClientAttempt) }},
+	{"timed_out", BoolColumn, func(t workload.Task) any { return t.TimedOut }},
+	{"executor_id", StringColumn, func(t workload.Task) any { return t.ExecutorID }},
+	{"throttle_delay_ms", FloatColumn, func(t workload.Task) any { return ms(t.ThrottleDelay) }},
+	{"setup_ms", FloatColumn, func(t workload.Task) any { return ms(t.Setup) }},
+	{"scheduled_arrival_time", TimeColumn, func(t workload.Task) any { return optionalTime(t.ScheduledArrival) }},
+	{"arrival_drift_ms", FloatColumn, func(t workload.Task) any { return ms(t.ArrivalDrift) }},
+	{"dequeue_step_ms", FloatColumn, func(t workload.Task) any { return ms(t.DequeueStep) }},
+	{"work_step_ms", FloatColumn, func(t workload.Task) any { return ms(t.WorkStep) }},
+	{"completion_step_ms", FloatColumn, func(t workload.Task) any { return ms(t.CompletionStep) }},
+	{"step_gaps_ms", FloatColumn, func(t workload.Task) any { return ms(t.StepGaps) }},
+}
+
+// ms returns a duration in milliseconds
+func ms(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
+
+// optionalTime returns a timestamp, or nil if it is unknown
+func optionalTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
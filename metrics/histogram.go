@@ -0,0 +1,256 @@
+// Package metrics records, summarizes and exports the latencies of scheduling experiments:
+// summary statistics of any series of durations, HDR histograms of wait and response
+// times, and the results CSV files with one row per task.
+package metrics
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Histogram is a high dynamic range histogram of latencies in microseconds. It uses the
+// bucket layout of HdrHistogram: values are tracked with a fixed number of significant
+// decimal digits, so memory stays constant however many values are recorded and any
+// percentile is accurate to that precision.
+type Histogram struct {
+	lowest             int64
+	highest            int64
+	significantFigures int
+
+	unitMagnitude               int
+	subBucketHalfCountMagnitude int
+	subBucketCount              int
+	subBucketHalfCount          int
+	subBucketMask               int64
+	bucketCount                 int
+
+	counts     []int64
+	totalCount int64
+	total      float64 // Sum of recorded values, for the mean
+	min        int64
+	max        int64
+}
+
+// NewHistogram creates a histogram tracking values from 1µs up to highest with the given
+// number of significant digits (1 to 5). Larger values are clamped to highest.
+func NewHistogram(highest time.Duration, significantFigures int) *Histogram {
+	h := &Histogram{
+		lowest:             1,
+		highest:            max(2, highest.Microseconds()),
+		significantFigures: min(max(significantFigures, 1), 5),
+		min:                math.MaxInt64,
+	}
+
+	largestSingleUnit := 2 * int64(math.Pow10(h.significantFigures))
+	subBucketCountMagnitude := int(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	h.subBucketHalfCountMagnitude = max(subBucketCountMagnitude, 1) - 1
+	h.unitMagnitude = int(math.Floor(math.Log2(float64(h.lowest))))
+	h.subBucketCount = 1 << (h.subBucketHalfCountMagnitude + 1)
+	h.subBucketHalfCount = h.subBucketCount / 2
+	h.subBucketMask = int64(h.subBucketCount-1) << h.unitMagnitude
+
+	// Each bucket doubles the range covered by the previous one
+	smallestUntrackable := int64(h.subBucketCount) << h.unitMagnitude
+	h.bucketCount = 1
+	for smallestUntrackable <= h.highest {
+		if smallestUntrackable > math.MaxInt64/2 {
+			h.bucketCount++
+			break
+		}
+		smallestUntrackable <<= 1
+		h.bucketCount++
+	}
+	h.counts = make([]int64, (h.bucketCount+1)*h.subBucketHalfCount)
+	return h
+}
+
+// Record adds a latency to the histogram. Negative latencies are recorded as zero.
+func (h *Histogram) Record(d time.Duration) {
+	value := min(max(d.Microseconds(), 0), h.highest)
+	h.counts[h.countsIndex(value)]++
+	h.totalCount++
+	h.total += float64(value)
+	h.min = min(h.min, value)
+	h.max = max(h.max, value)
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() int64 {
+	return h.totalCount
+}
+
+// Mean returns the exact mean of the recorded values
+func (h *Histogram) Mean() time.Duration {
+	if h.totalCount == 0 {
+		return 0
+	}
+	return time.Duration(h.total/float64(h.totalCount)) * time.Microsecond
+}
+
+// Min returns the smallest recorded value
+func (h *Histogram) Min() time.Duration {
+	if h.totalCount == 0 {
+		return 0
+	}
+	return time.Duration(h.min) * time.Microsecond
+}
+
+// Max returns the largest recorded value
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// ValueAtPercentile returns the value below which the given percentage of values fall,
+// within the histogram's precision
+func (h *Histogram) ValueAtPercentile(percentile float64) time.Duration {
+	if h.totalCount == 0 {
+		return 0
+	}
+	countAtPercentile := max(int64(percentile/100*float64(h.totalCount)+0.5), 1)
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= countAtPercentile {
+			value := min(h.highestEquivalentValue(h.valueFromIndex(i)), h.max)
+			return time.Duration(value) * time.Microsecond
+		}
+	}
+	return h.Max()
+}
+
+func (h *Histogram) bucketIndex(value int64) int {
+	pow2Ceiling := 64 - bits.LeadingZeros64(uint64(value|h.subBucketMask))
+	return pow2Ceiling - h.unitMagnitude - (h.subBucketHalfCountMagnitude + 1)
+}
+
+func (h *Histogram) subBucketIndex(value int64, bucketIdx int) int {
+	return int(value >> (bucketIdx + h.unitMagnitude))
+}
+
+func (h *Histogram) countsIndex(value int64) int {
+	bucketIdx := h.bucketIndex(value)
+	subBucketIdx := h.subBucketIndex(value, bucketIdx)
+	bucketBaseIdx := (bucketIdx + 1) << h.subBucketHalfCountMagnitude
+	return bucketBaseIdx + subBucketIdx - h.subBucketHalfCount
+}
+
+// valueFromIndex returns the lowest value counted at index i of the counts array
+func (h *Histogram) valueFromIndex(i int) int64 {
+	bucketIdx := (i >> h.subBucketHalfCountMagnitude) - 1
+	subBucketIdx := (i & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucketIdx < 0 {
+		subBucketIdx -= h.subBucketHalfCount
+		bucketIdx = 0
+	}
+	return int64(subBucketIdx) << (bucketIdx + h.unitMagnitude)
+}
+
+// highestEquivalentValue returns the largest value counted in the same slot as value
+func (h *Histogram) highestEquivalentValue(value int64) int64 {
+	bucketIdx := h.bucketIndex(value)
+	subBucketIdx := h.subBucketIndex(value, bucketIdx)
+	adjustedBucket := bucketIdx
+	if subBucketIdx >= h.subBucketCount {
+		adjustedBucket++
+	}
+	lowest := int64(subBucketIdx) << (bucketIdx + h.unitMagnitude)
+	return lowest + int64(1)<<(h.unitMagnitude+adjustedBucket) - 1
+}
+
+// HdrHistogram V2 encoding cookies. The 0x10 bit marks the zero-run-length encoding of
+// the counts array.
+const (
+	hdrEncodingCookie           = 0x1c849303 | 0x10
+	hdrCompressedEncodingCookie = 0x1c849304 | 0x10
+)
+
+// EncodeCompressed returns the histogram in the compressed, base64 V2 encoding used by
+// HdrHistogram logs
+func (h *Histogram) EncodeCompressed() (string, error) {
+	// Counts are encoded as ZigZag LEB128 values, with runs of zeros as negative lengths
+	var payload bytes.Buffer
+	limit := 0
+	if h.totalCount > 0 {
+		limit = h.countsIndex(h.max) + 1
+	}
+	for i := 0; i < limit; {
+		count := h.counts[i]
+		i++
+		zeros := int64(0)
+		if count == 0 {
+			zeros = 1
+			for i < limit && h.counts[i] == 0 {
+				zeros++
+				i++
+			}
+		}
+		if zeros > 1 {
+			putZigZag(&payload, -zeros)
+		} else {
+			putZigZag(&payload, count)
+		}
+	}
+
+	var encoded bytes.Buffer
+	err := writeBigEndian(&encoded,
+		int32(hdrEncodingCookie),
+		int32(payload.Len()),
+		int32(0), // Normalizing index offset
+		int32(h.significantFigures),
+		h.lowest,
+		h.highest,
+		float64(1), // Integer to double conversion ratio
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode histogram header: %w", err)
+	}
+	encoded.Write(payload.Bytes())
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(encoded.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to compress histogram: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress histogram: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := writeBigEndian(&out, int32(hdrCompressedEncodingCookie), int32(compressed.Len())); err != nil {
+		return "", fmt.Errorf("failed to encode histogram header: %w", err)
+	}
+	out.Write(compressed.Bytes())
+	return base64.StdEncoding.EncodeToString(out.Bytes()), nil
+}
+
+// writeBigEndian writes fixed-size values in the big-endian order of HdrHistogram headers
+func writeBigEndian(w io.Writer, values ...any) error {
+	for _, v := range values {
+		if err := binary.Write(w, binary.BigEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putZigZag writes a value in the ZigZag LEB128 encoding of HdrHistogram, where the
+// ninth byte, if needed, carries 8 bits
+func putZigZag(buf *bytes.Buffer, value int64) {
+	v := uint64((value << 1) ^ (value >> 63))
+	for i := 0; i < 8; i++ {
+		if v < 0x80 {
+			buf.WriteByte(byte(v))
+			return
+		}
+		buf.WriteByte(byte(v&0x7f) | 0x80)
+		v >>= 7
+	}
+	buf.WriteByte(byte(v))
+}
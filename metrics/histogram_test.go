@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// usSeries returns a series of durations given in microseconds
+func usSeries(values ...int) []time.Duration {
+	series := make([]time.Duration, len(values))
+	for i, v := range values {
+		series[i] = time.Duration(v) * time.Microsecond
+	}
+	return series
+}
+
+// exactPercentile returns the recorded value a histogram of unlimited precision would
+// return for the percentile, with the same rank rule as ValueAtPercentile
+func exactPercentile(series []time.Duration, percentile float64) time.Duration {
+	sorted := slices.Clone(series)
+	slices.Sort(sorted)
+	rank := max(int64(percentile/100*float64(len(sorted))+0.5), 1)
+	return sorted[min(rank, int64(len(sorted)))-1]
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram(time.Minute, 3)
+	if h.Count() != 0 || h.Mean() != 0 || h.Min() != 0 || h.Max() != 0 {
+		t.Errorf("empty histogram: count %d, mean %v, min %v, max %v, want zeros", h.Count(), h.Mean(), h.Min(), h.Max())
+	}
+	for _, p := range []float64{0, 50, 99, 100} {
+		if got := h.ValueAtPercentile(p); got != 0 {
+			t.Errorf("empty histogram: p%g = %v, want 0", p, got)
+		}
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	uniform := make([]time.Duration, 10000)
+	for i := range uniform {
+		uniform[i] = time.Duration(i+1) * time.Microsecond
+	}
+	// 90% fast tasks and 10% slow ones, like the short and long classes of a workload
+	bimodal := append(slices.Repeat(usSeries(300_000), 900), slices.Repeat(usSeries(2_000_000), 100)...)
+	// Values spread over eight orders of magnitude, across many buckets
+	var geometric []time.Duration
+	for v := 1.0; v < 1e8; v *= 1.37 {
+		geometric = append(geometric, time.Duration(v)*time.Microsecond)
+	}
+
+	tests := []struct {
+		name               string
+		series             []time.Duration
+		significantFigures int
+	}{
+		{"uniform 1", uniform, 1},
+		{"uniform 2", uniform, 2},
+		{"uniform 3", uniform, 3},
+		{"uniform 5", uniform, 5},
+		{"constant", slices.Repeat(usSeries(1234), 100), 3},
+		{"bimodal 2", bimodal, 2},
+		{"bimodal 3", bimodal, 3},
+		{"geometric 3", geometric, 3},
+		{"geometric 4", geometric, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistogram(time.Hour, tt.significantFigures)
+			var total time.Duration
+			for _, d := range tt.series {
+				h.Record(d)
+				total += d
+			}
+			if h.Count() != int64(len(tt.series)) {
+				t.Errorf("Count() = %d, want %d", h.Count(), len(tt.series))
+			}
+			// The mean, min and max are exact, to the microsecond
+			if want := (total / time.Duration(len(tt.series))).Truncate(time.Microsecond); h.Mean() != want {
+				t.Errorf("Mean() = %v, want %v", h.Mean(), want)
+			}
+			if want := slices.Min(tt.series); h.Min() != want {
+				t.Errorf("Min() = %v, want %v", h.Min(), want)
+			}
+			if want := slices.Max(tt.series); h.Max() != want {
+				t.Errorf("Max() = %v, want %v", h.Max(), want)
+			}
+
+			// Percentiles are the highest value equivalent to the exact one, so never below
+			// it and above it by at most the histogram's precision, and never above the max
+			precision := math.Pow10(-tt.significantFigures)
+			for _, p := range []float64{0, 1, 25, 50, 75, 90, 99, 99.9, 100} {
+				want := exactPercentile(tt.series, p)
+				got := h.ValueAtPercentile(p)
+				if got < want || float64(got-want) > precision*float64(want) || got > h.Max() {
+					t.Errorf("p%g = %v, want %v within %g", p, got, want, precision)
+				}
+			}
+		})
+	}
+}
+
+func TestHistogramMatchesHdrHistogram(t *testing.T) {
+	// The bucket layout is HdrHistogram's, so every percentile must match the reference
+	// implementation's, clamped to the exact max (the reference's Max is the largest value
+	// of its slot)
+	var series []time.Duration
+	for i := range 5000 {
+		series = append(series, time.Duration(i*i%7919+i*37)*time.Microsecond)
+	}
+	for _, significantFigures := range []int{1, 2, 3, 4, 5} {
+		h := NewHistogram(time.Hour, significantFigures)
+		ref := hdrhistogram.New(1, time.Hour.Microseconds(), significantFigures)
+		for _, d := range series {
+			h.Record(d)
+			if err := ref.RecordValue(d.Microseconds()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, p := range []float64{1, 10, 50, 90, 99, 99.9, 99.99, 100} {
+			want := min(time.Duration(ref.ValueAtPercentile(p))*time.Microsecond, slices.Max(series))
+			if got := h.ValueAtPercentile(p); got != want {
+				t.Errorf("%d significant figures: p%g = %v, want %v", significantFigures, p, got, want)
+			}
+		}
+	}
+}
+
+func TestHistogramExactBelowSubBucketCount(t *testing.T) {
+	// Values below the first bucket's 2048 slots at 3 significant figures are counted
+	// one per slot, so percentiles are exact
+	h := NewHistogram(time.Second, 3)
+	for _, d := range usSeries(10, 20, 30, 40, 2000) {
+		h.Record(d)
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 10 * time.Microsecond},
+		{20, 10 * time.Microsecond},
+		{50, 30 * time.Microsecond},
+		{80, 40 * time.Microsecond},
+		{100, 2000 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		if got := h.ValueAtPercentile(tt.p); got != tt.want {
+			t.Errorf("p%g = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestHistogramClamping(t *testing.T) {
+	tests := []struct {
+		name              string
+		highest           time.Duration
+		series            []time.Duration
+		wantMin, wantMax  time.Duration
+		wantMean, wantP50 time.Duration
+		want              int64
+	}{
+		// Values above highest are recorded as highest
+		{name: "above highest", highest: time.Second, series: []time.Duration{5 * time.Second, 10 * time.Second},
+			wantMin: time.Second, wantMax: time.Second, wantMean: time.Second, wantP50: time.Second, want: 2},
+		// Negative values, such as those of clocks that disagree, are recorded as zero
+		{name: "negative", highest: time.Second, series: []time.Duration{-5 * time.Millisecond, -time.Microsecond},
+			want: 2},
+		{name: "zero", highest: time.Second, series: []time.Duration{0, 0, 0}, want: 3},
+		// Durations are counted in whole microseconds
+		{name: "below a microsecond", highest: time.Second, series: []time.Duration{500 * time.Nanosecond}, want: 1},
+		{name: "zero and positive", highest: time.Second, series: []time.Duration{-time.Millisecond, 0, 4 * time.Microsecond, 8 * time.Microsecond},
+			wantMax: 8 * time.Microsecond, wantMean: 3 * time.Microsecond, wantP50: 0, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistogram(tt.highest, 3)
+			for _, d := range tt.series {
+				h.Record(d)
+			}
+			if h.Count() != tt.want {
+				t.Errorf("Count() = %d, want %d", h.Count(), tt.want)
+			}
+			if h.Min() != tt.wantMin || h.Max() != tt.wantMax {
+				t.Errorf("Min(), Max() = %v, %v, want %v, %v", h.Min(), h.Max(), tt.wantMin, tt.wantMax)
+			}
+			if h.Mean() != tt.wantMean {
+				t.Errorf("Mean() = %v, want %v", h.Mean(), tt.wantMean)
+			}
+			if got := h.ValueAtPercentile(50); got != tt.wantP50 {
+				t.Errorf("p50 = %v, want %v", got, tt.wantP50)
+			}
+			if got := h.ValueAtPercentile(100); got != tt.wantMax {
+				t.Errorf("p100 = %v, want the max %v", got, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestHistogramEncodeCompressedRoundTrip(t *testing.T) {
+	var spread []time.Duration
+	for v := 1.0; v < 3e9; v *= 1.11 {
+		spread = append(spread, time.Duration(v)*time.Microsecond)
+	}
+
+	tests := []struct {
+		name               string
+		series             []time.Duration
+		repeat             int
+		significantFigures int
+	}{
+		{name: "empty", significantFigures: 3},
+		{name: "single value", series: usSeries(1500), repeat: 1, significantFigures: 3},
+		{name: "zeros", series: []time.Duration{0}, repeat: 10, significantFigures: 2},
+		// Long runs of empty slots, encoded as negative run lengths
+		{name: "spread", series: spread, repeat: 1, significantFigures: 3},
+		{name: "spread 5", series: spread, repeat: 1, significantFigures: 5},
+		// Counts too large for a single LEB128 byte
+		{name: "large counts", series: usSeries(7, 420, 99_000), repeat: 100_000, significantFigures: 2},
+		{name: "clamped", series: []time.Duration{2 * time.Hour}, repeat: 3, significantFigures: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistogram(time.Hour, tt.significantFigures)
+			for range tt.repeat {
+				for _, d := range tt.series {
+					h.Record(d)
+				}
+			}
+			encoded, err := h.EncodeCompressed()
+			if err != nil {
+				t.Fatalf("EncodeCompressed() error: %v", err)
+			}
+			decoded, err := hdrhistogram.Decode([]byte(encoded))
+			if err != nil {
+				t.Fatalf("hdrhistogram.Decode() error: %v", err)
+			}
+
+			if decoded.SignificantFigures() != int64(h.significantFigures) ||
+				decoded.LowestTrackableValue() != h.lowest || decoded.HighestTrackableValue() != h.highest {
+				t.Errorf("decoded layout = %d figures, %d..%d, want %d figures, %d..%d",
+					decoded.SignificantFigures(), decoded.LowestTrackableValue(), decoded.HighestTrackableValue(),
+					h.significantFigures, h.lowest, h.highest)
+			}
+			if decoded.TotalCount() != h.Count() {
+				t.Errorf("decoded TotalCount() = %d, want %d", decoded.TotalCount(), h.Count())
+			}
+			if counts := decoded.Export().Counts; !slices.Equal(counts, h.counts) {
+				t.Errorf("decoded counts differ: %d slots, want %d", len(counts), len(h.counts))
+			}
+			if h.Count() == 0 {
+				return
+			}
+			for _, p := range []float64{50, 90, 99, 99.9, 100} {
+				got := min(time.Duration(decoded.ValueAtPercentile(p))*time.Microsecond, h.Max())
+				if want := h.ValueAtPercentile(p); got != want {
+					t.Errorf("decoded p%g = %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPutZigZag(t *testing.T) {
+	tests := []struct {
+		value int64
+		want  []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x02}},
+		{-1, []byte{0x01}},
+		{63, []byte{0x7e}},
+		{64, []byte{0x80, 0x01}},
+		{-65, []byte{0x81, 0x01}},
+		{300, []byte{0xd8, 0x04}},
+		// The ninth byte carries all 8 of the remaining bits
+		{math.MaxInt64, []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{math.MinInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		putZigZag(&buf, tt.value)
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("putZigZag(%d) = % x, want % x", tt.value, buf.Bytes(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"fifo-queue-demo/workload"
+)
+
+// parquetBatchSize is how many rows the Parquet writer is handed at once
+const parquetBatchSize = 1024
+
+// parquetColumns is the root of a Parquet schema of results columns. It keeps the columns
+// in the order of the CSV header, where parquet.Group would sort them by name.
+type parquetColumns struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+// parquetField is a column of parquetColumns
+type parquetField struct {
+	parquet.Node
+	name string
+}
+
+func (f parquetField) Name() string { return f.name }
+
+func (f parquetField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
+
+func (g parquetColumns) Fields() []parquet.Field { return g.fields }
+
+// ParquetSchema returns the Parquet schema of the given columns: one optional column
+// each, so unknown values are nulls, with timestamps in microseconds
+func ParquetSchema(columns []Column) *parquet.Schema {
+	root := parquetColumns{Group: parquet.Group{}}
+	for _, column := range columns {
+		var node parquet.Node
+		switch column.Type {
+		case IntColumn:
+			node = parquet.Int(64)
+		case FloatColumn:
+			node = parquet.Leaf(parquet.DoubleType)
+		case BoolColumn:
+			node = parquet.Leaf(parquet.BooleanType)
+		case TimeColumn:
+			node = parquet.Timestamp(parquet.Microsecond)
+		default:
+			node = parquet.String()
+		}
+		node = parquet.Optional(node)
+		root.Group[column.Name] = node
+		root.fields = append(root.fields, parquetField{Node: node, name: column.Name})
+	}
+	return parquet.NewSchema("results", root)
+}
+
+// WriteParquet writes the tasks to a Parquet file with the given columns
+func WriteParquet(filename string, columns []Column, tasks []workload.Task) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create Parquet file: %w", err)
+	}
+	if err := writeParquet(file, columns, tasks); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write Parquet file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close Parquet file: %w", err)
+	}
+	return nil
+}
+
+func writeParquet(w io.Writer, columns []Column, tasks []workload.Task) error {
+	writer := parquet.NewWriter(w, ParquetSchema(columns), parquet.CreatedBy("fifo-queue-demo", "", ""))
+	rows := make([]parquet.Row, 0, parquetBatchSize)
+	for start := 0; start < len(tasks); start += parquetBatchSize {
+		rows = rows[:0]
+		for _, task := range tasks[start:min(start+parquetBatchSize, len(tasks))] {
+			row := make(parquet.Row, len(columns))
+			for i, column := range columns {
+				value := parquetValue(column.Value(task))
+				defined := 1
+				if value.IsNull() {
+					defined = 0
+				}
+				row[i] = value.Level(0, defined, i)
+			}
+			rows = append(rows, row)
+		}
+		if _, err := writer.WriteRows(rows); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// parquetValue converts the value of a column to its Parquet value, nil to a null
+func parquetValue(value any) parquet.Value {
+	switch v := value.(type) {
+	case int64:
+		return parquet.Int64Value(v)
+	case float64:
+		return parquet.DoubleValue(v)
+	case bool:
+		return parquet.BooleanValue(v)
+	case time.Time:
+		return parquet.Int64Value(v.UnixMicro())
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	default:
+		return parquet.Value{}
+	}
+}
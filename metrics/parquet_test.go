@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"fifo-queue-demo/workload"
+)
+
+// TestWriteParquetRoundTrip writes results with WriteParquet and reads them back with the
+// Parquet library's reader, checking the schema and every value
+func TestWriteParquetRoundTrip(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 678901000, time.UTC)
+	tasks := []workload.Task{
+		{
+			TaskID:         1,
+			Duration:       100 * time.Millisecond,
+			ArrivalTime:    start,
+			DequeueTime:    start.Add(20 * time.Millisecond),
+			CompletionTime: start.Add(125 * time.Millisecond),
+			TenantID:       "tenant-a",
+			Deadline:       start.Add(300 * time.Millisecond),
+			Attempts:       2,
+			Starved:        true,
+		},
+		// No deadline, so its deadline and lateness are nulls
+		{
+			TaskID:         2,
+			Duration:       2 * time.Second,
+			ArrivalTime:    start.Add(time.Second),
+			DequeueTime:    start.Add(1500 * time.Millisecond),
+			CompletionTime: start.Add(3600 * time.Millisecond),
+			Failed:         true,
+		},
+	}
+	// Enough tasks to span several batches
+	for i := 3; i <= 2*parquetBatchSize+10; i++ {
+		arrival := start.Add(time.Duration(i) * time.Millisecond)
+		tasks = append(tasks, workload.Task{TaskID: i, Duration: time.Millisecond, ArrivalTime: arrival,
+			DequeueTime: arrival, CompletionTime: arrival.Add(time.Millisecond)})
+	}
+
+	filename := filepath.Join(t.TempDir(), "results.parquet")
+	if err := WriteParquet(filename, ResultColumns, tasks); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		t.Fatalf("the Parquet reader rejected the file: %v", err)
+	}
+	if pf.NumRows() != int64(len(tasks)) {
+		t.Fatalf("file has %d rows, want %d", pf.NumRows(), len(tasks))
+	}
+
+	// The columns are optional and in the order of the CSV header
+	fields := pf.Schema().Fields()
+	if len(fields) != len(ResultColumns) {
+		t.Fatalf("schema has %d columns, want %d", len(fields), len(ResultColumns))
+	}
+	for i, column := range ResultColumns {
+		field := fields[i]
+		if field.Name() != column.Name {
+			t.Errorf("column %d is %q, want %q", i, field.Name(), column.Name)
+		}
+		if !field.Optional() {
+			t.Errorf("column %q isn't optional", column.Name)
+		}
+		kind, logical := field.Type().Kind(), field.Type().LogicalType()
+		var want parquet.Kind
+		switch column.Type {
+		case IntColumn, TimeColumn:
+			want = parquet.Int64
+		case FloatColumn:
+			want = parquet.Double
+		case BoolColumn:
+			want = parquet.Boolean
+		case StringColumn:
+			want = parquet.ByteArray
+		}
+		if kind != want {
+			t.Errorf("column %q is %v, want %v", column.Name, kind, want)
+		}
+		if column.Type == TimeColumn && (logical == nil || logical.Timestamp == nil || logical.Timestamp.Unit.Micros == nil) {
+			t.Errorf("column %q isn't a timestamp in microseconds: %v", column.Name, logical)
+		}
+		if column.Type == StringColumn && (logical == nil || logical.UTF8 == nil) {
+			t.Errorf("column %q isn't a UTF-8 string: %v", column.Name, logical)
+		}
+	}
+
+	reader := parquet.NewReader(pf)
+	defer reader.Close()
+	rows := make([]parquet.Row, len(tasks))
+	read := 0
+	for read < len(rows) {
+		n, err := reader.ReadRows(rows[read:])
+		read += n
+		if err != nil {
+			break
+		}
+	}
+	if read != len(tasks) {
+		t.Fatalf("read %d rows, want %d", read, len(tasks))
+	}
+	for r, task := range tasks {
+		if len(rows[r]) != len(ResultColumns) {
+			t.Fatalf("row %d has %d values, want %d", r, len(rows[r]), len(ResultColumns))
+		}
+		for _, value := range rows[r] {
+			column := ResultColumns[value.Column()]
+			checkParquetValue(t, task, column, value)
+		}
+	}
+}
+
+// checkParquetValue compares a value read back with the task's value in the column
+func checkParquetValue(t *testing.T, task workload.Task, column Column, value parquet.Value) {
+	t.Helper()
+	want := column.Value(task)
+	if want == nil {
+		if !value.IsNull() {
+			t.Errorf("task %d: %s = %v, want null", task.TaskID, column.Name, value)
+		}
+		return
+	}
+	if value.IsNull() {
+		t.Errorf("task %d: %s is null, want %v", task.TaskID, column.Name, want)
+		return
+	}
+	var got any
+	switch want := want.(type) {
+	case int64:
+		got = value.Int64()
+	case float64:
+		got = value.Double()
+	case bool:
+		got = value.Boolean()
+	case string:
+		got = value.String()
+	case time.Time:
+		// Timestamps are stored in microseconds
+		got, want := time.UnixMicro(value.Int64()), want.Truncate(time.Microsecond)
+		if !got.Equal(want) {
+			t.Errorf("task %d: %s = %v, want %v", task.TaskID, column.Name, got, want)
+		}
+		return
+	}
+	if got != want {
+		t.Errorf("task %d: %s = %v, want %v", task.TaskID, column.Name, got, want)
+	}
+}
@@ -0,0 +1,270 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// csvHeader lists the columns of the results CSV
+var csvHeader = []string{"task_id", "duration_ms", "arrival_time", "dequeue_time",
+	"completion_time", "wait_time_ms", "response_time_ms", "backpressure_delay_ms", "tenant_id", "starved", "job_id", "deadline", "lateness_ms",
+	"attempts", "failed", "retry_delay_ms", "enqueued_at", "started_at", "enqueue_ms", "queueing_ms", "startup_ms",
+	"needs_lock", "lock_wait_ms", "boosted", "dead_lettered", "request_id", "client_attempt", "timed_out", "executor_id", "throttle_delay_ms",
+	"setup_ms", "scheduled_arrival_time", "arrival_drift_ms", "dequeue_step_ms", "work_step_ms", "completion_step_ms", "step_gaps_ms"}
+
+// ResultsWriter streams task rows to a results CSV file as tasks complete. Every row is
+// flushed right away so partial results survive a crash of a long experiment.
+type ResultsWriter struct {
+	file     *os.File
+	writer   *csv.Writer
+	filename string
+	rows     int
+	closed   bool
+}
+
+// NewResultsWriter creates the CSV file and writes its header
+func NewResultsWriter(filename string) (*ResultsWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSV file: %w", err)
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(csvHeader); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	writer.Flush()
+	return &ResultsWriter{file: file, writer: writer, filename: filename}, nil
+}
+
+// Write appends a completed task to the CSV file
+func (w *ResultsWriter) Write(task workload.Task) error {
+	if err := w.writer.Write(csvRow(task)); err != nil {
+		return fmt.Errorf("failed to write CSV row: %w", err)
+	}
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV row: %w", err)
+	}
+	w.rows++
+	return nil
+}
+
+// Filename returns the path of the CSV file
+func (w *ResultsWriter) Filename() string {
+	return w.filename
+}
+
+// Rows returns the number of tasks written so far
+func (w *ResultsWriter) Rows() int {
+	return w.rows
+}
+
+// Close finalizes the CSV file. It is safe to call more than once; only the first call
+// reports closed as true.
+func (w *ResultsWriter) Close() (closed bool, err error) {
+	if w.closed {
+		return false, nil
+	}
+	w.closed = true
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return true, fmt.Errorf("failed to flush CSV file: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return true, fmt.Errorf("failed to close CSV file: %w", err)
+	}
+	return true, nil
+}
+
+// csvRow formats a task as a row of the results CSV
+func csvRow(task workload.Task) []string {
+	// Deadline columns stay empty for tasks without a deadline
+	var deadline, latenessMs string
+	if !task.Deadline.IsZero() {
+		deadline = task.Deadline.Format(time.RFC3339Nano)
+		latenessMs = fmt.Sprintf("%.3f", task.Lateness().Seconds()*1000)
+	}
+
+	return []string{
+		fmt.Sprintf("%d", task.TaskID),
+		strconv.FormatFloat(float64(task.Duration)/float64(time.Millisecond), 'f', -1, 64),
+		task.ArrivalTime.Format(time.RFC3339Nano),
+		task.DequeueTime.Format(time.RFC3339Nano),
+		task.CompletionTime.Format(time.RFC3339Nano),
+		fmt.Sprintf("%.3f", task.WaitTime().Seconds()*1000),
+		fmt.Sprintf("%.3f", task.ResponseTime().Seconds()*1000),
+		fmt.Sprintf("%.3f", task.BackpressureDelay.Seconds()*1000),
+		task.TenantID,
+		strconv.FormatBool(task.Starved),
+		task.JobID,
+		deadline,
+		latenessMs,
+		strconv.Itoa(task.Attempts),
+		strconv.FormatBool(task.Failed),
+		fmt.Sprintf("%.3f", task.RetryDelay.Seconds()*1000),
+		formatOptionalTime(task.EnqueuedAt),
+		formatOptionalTime(task.StartedAt),
+		fmt.Sprintf("%.3f", task.EnqueueDelay().Seconds()*1000),
+		fmt.Sprintf("%.3f", task.QueueingDelay().Seconds()*1000),
+		fmt.Sprintf("%.3f", task.StartupDelay().Seconds()*1000),
+		strconv.FormatBool(task.NeedsLock),
+		fmt.Sprintf("%.3f", task.LockWait.Seconds()*1000),
+		strconv.FormatBool(task.Boosted),
+		strconv.FormatBool(task.DeadLettered),
+		strconv.Itoa(task.Request()),
+		strconv.Itoa(task.ClientAttempt),
+		strconv.FormatBool(task.TimedOut),
+		task.ExecutorID,
+		fmt.Sprintf("%.3f", task.ThrottleDelay.Seconds()*1000),
+		fmt.Sprintf("%.3f", task.Setup.Seconds()*1000),
+		formatOptionalTime(task.ScheduledArrival),
+		fmt.Sprintf("%.3f", task.ArrivalDrift.Seconds()*1000),
+		fmt.Sprintf("%.3f", task.DequeueStep.Seconds()*1000),
+		fmt.Sprintf("%.3f", task.WorkStep.Seconds()*1000),
+		fmt.Sprintf("%.3f", task.CompletionStep.Seconds()*1000),
+		fmt.Sprintf("%.3f", task.StepGaps.Seconds()*1000),
+	}
+}
+
+// formatOptionalTime formats a timestamp for the CSV, leaving unknown ones empty
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// RewriteResults replaces a results CSV file with the given tasks. It fills in columns
+// only known once the run is over, such as the starvation flag, in streamed files.
+func RewriteResults(tasks []workload.Task, filename string) error {
+	tmp := filename + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	writer := csv.NewWriter(file)
+	writer.Write(csvHeader)
+	for _, task := range tasks {
+		writer.Write(csvRow(task))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close CSV file: %w", err)
+	}
+	return os.Rename(tmp, filename)
+}
+
+// ReadResults loads the tasks of a results CSV file. Columns are matched by name, so
+// files written before a column was added can still be read.
+func ReadResults(filename string) ([]workload.Task, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open results file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header of %s: %w", filename, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"task_id", "duration_ms", "arrival_time", "dequeue_time", "completion_time"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s is not a results file: missing %s column", filename, name)
+		}
+	}
+
+	var tasks []workload.Task
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		parseTime := func(name string) time.Time {
+			if err != nil || field(name) == "" {
+				return time.Time{}
+			}
+			var t time.Time
+			t, err = time.Parse(time.RFC3339Nano, field(name))
+			return t
+		}
+		parseMs := func(name string) time.Duration {
+			if err != nil || field(name) == "" {
+				return 0
+			}
+			var ms float64
+			ms, err = strconv.ParseFloat(field(name), 64)
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+
+		var task workload.Task
+		task.TaskID, err = strconv.Atoi(field("task_id"))
+		task.Duration = parseMs("duration_ms")
+		task.ArrivalTime = parseTime("arrival_time")
+		task.DequeueTime = parseTime("dequeue_time")
+		task.CompletionTime = parseTime("completion_time")
+		task.BackpressureDelay = parseMs("backpressure_delay_ms")
+		task.Deadline = parseTime("deadline")
+		task.TenantID = field("tenant_id")
+		task.JobID = field("job_id")
+		task.Starved = field("starved") == "true"
+		task.Failed = field("failed") == "true"
+		task.RetryDelay = parseMs("retry_delay_ms")
+		task.EnqueuedAt = parseTime("enqueued_at")
+		task.StartedAt = parseTime("started_at")
+		task.NeedsLock = field("needs_lock") == "true"
+		task.LockWait = parseMs("lock_wait_ms")
+		task.Boosted = field("boosted") == "true"
+		task.DeadLettered = field("dead_lettered") == "true"
+		task.TimedOut = field("timed_out") == "true"
+		task.ExecutorID = field("executor_id")
+		task.ThrottleDelay = parseMs("throttle_delay_ms")
+		task.Setup = parseMs("setup_ms")
+		task.ScheduledArrival = parseTime("scheduled_arrival_time")
+		task.ArrivalDrift = parseMs("arrival_drift_ms")
+		task.DequeueStep = parseMs("dequeue_step_ms")
+		task.WorkStep = parseMs("work_step_ms")
+		task.CompletionStep = parseMs("completion_step_ms")
+		task.StepGaps = parseMs("step_gaps_ms")
+		if field("attempts") != "" && err == nil {
+			task.Attempts, err = strconv.Atoi(field("attempts"))
+		}
+		if field("client_attempt") != "" && err == nil {
+			task.ClientAttempt, err = strconv.Atoi(field("client_attempt"))
+		}
+		if task.ClientAttempt > 0 && err == nil {
+			task.RequestID, err = strconv.Atoi(field("request_id"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", filename, line, err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// Summary holds latency statistics (response or wait time) of a group of tasks
+type Summary struct {
+	Count  int
+	Mean   time.Duration
+	Median time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Min    time.Duration
+	Max    time.Duration
+}
+
+// Summarize computes statistics of a series of durations, e.g. latencies, job completion
+// times or benchmark timings. It sorts the slice in place.
+func Summarize(latencies []time.Duration) Summary {
+	n := len(latencies)
+	if n == 0 {
+		return Summary{}
+	}
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	sortDurations(latencies)
+	// With an even number of latencies, the median is the average of the two middle ones
+	median := latencies[n/2]
+	if n%2 == 0 {
+		median = (latencies[n/2-1] + latencies[n/2]) / 2
+	}
+	return Summary{
+		Count:  n,
+		Mean:   total / time.Duration(n),
+		Median: median,
+		P90:    percentileOfSorted(latencies, 90),
+		P99:    percentileOfSorted(latencies, 99),
+		Min:    latencies[0],
+		Max:    latencies[n-1],
+	}
+}
+
+// CV returns the coefficient of variation of a series of durations: its standard
+// deviation over its mean, or 0 if the mean is 0
+func CV(series []time.Duration) float64 {
+	if len(series) == 0 {
+		return 0
+	}
+	var sum, sumSquares float64
+	for _, d := range series {
+		sum += float64(d)
+		sumSquares += float64(d) * float64(d)
+	}
+	n := float64(len(series))
+	mean := sum / n
+	if mean == 0 {
+		return 0
+	}
+	variance := max(sumSquares/n-mean*mean, 0)
+	return math.Sqrt(variance) / mean
+}
+
+// Percentile returns the p-th percentile (0 to 100) of a series of durations, or 0 if
+// it is empty. It sorts the slice in place.
+func Percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sortDurations(latencies)
+	return percentileOfSorted(latencies, p)
+}
+
+// percentileOfSorted returns the p-th percentile of a sorted, non-empty series: the
+// value below which p% of the series falls
+func percentileOfSorted(sorted []time.Duration, p float64) time.Duration {
+	return sorted[min(int(float64(len(sorted))*p/100), len(sorted)-1)]
+}
+
+func sortDurations(latencies []time.Duration) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+}
+
+// SummarizeTasks computes statistics of metric over the tasks matching filter, or over
+// every task if filter is nil. Metrics are usually Task.ResponseTime or Task.WaitTime.
+func SummarizeTasks(tasks []workload.Task, filter func(workload.Task) bool, metric func(workload.Task) time.Duration) Summary {
+	latencies := make([]time.Duration, 0, len(tasks))
+	for _, task := range tasks {
+		if filter != nil && !filter(task) {
+			continue
+		}
+		latencies = append(latencies, metric(task))
+	}
+	return Summarize(latencies)
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// msSeries returns a series of durations given in milliseconds
+func msSeries(values ...int) []time.Duration {
+	series := make([]time.Duration, len(values))
+	for i, v := range values {
+		series[i] = time.Duration(v) * time.Millisecond
+	}
+	return series
+}
+
+// rangeSeries returns the series 1 ms, 2 ms, ..., n ms
+func rangeSeries(n int) []time.Duration {
+	series := make([]time.Duration, n)
+	for i := range series {
+		series[i] = time.Duration(i+1) * time.Millisecond
+	}
+	return series
+}
+
+func TestSummarize(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name   string
+		series []time.Duration
+		want   Summary
+	}{
+		{"empty", nil, Summary{}},
+		{"single", msSeries(42), Summary{Count: 1, Mean: 42 * ms, Median: 42 * ms, P90: 42 * ms, P99: 42 * ms, Min: 42 * ms, Max: 42 * ms}},
+		{"odd length", msSeries(30, 10, 20), Summary{Count: 3, Mean: 20 * ms, Median: 20 * ms, P90: 30 * ms, P99: 30 * ms, Min: 10 * ms, Max: 30 * ms}},
+		// The median of an even-length series is the mean of its two middle values
+		{"even length", msSeries(40, 10, 30, 20), Summary{Count: 4, Mean: 25 * ms, Median: 25 * ms, P90: 40 * ms, P99: 40 * ms, Min: 10 * ms, Max: 40 * ms}},
+		{"even length, equal middle", msSeries(5, 7, 7, 100), Summary{Count: 4, Mean: 29750 * time.Microsecond, Median: 7 * ms, P90: 100 * ms, P99: 100 * ms, Min: 5 * ms, Max: 100 * ms}},
+		{"ten", rangeSeries(10), Summary{Count: 10, Mean: 5500 * time.Microsecond, Median: 5500 * time.Microsecond, P90: 10 * ms, P99: 10 * ms, Min: 1 * ms, Max: 10 * ms}},
+		{"hundred", rangeSeries(100), Summary{Count: 100, Mean: 50500 * time.Microsecond, Median: 50500 * time.Microsecond, P90: 91 * ms, P99: 100 * ms, Min: 1 * ms, Max: 100 * ms}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarize(tt.series); got != tt.want {
+				t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name   string
+		series []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 99, 0},
+		{"single p0", msSeries(7), 0, 7 * ms},
+		{"single p99", msSeries(7), 99, 7 * ms},
+		// Below 100 values, p99 is the largest value
+		{"two p99", msSeries(9, 1), 99, 9 * ms},
+		{"ten p99", rangeSeries(10), 99, 10 * ms},
+		{"ninety-nine p99", rangeSeries(99), 99, 99 * ms},
+		// p99 is the value 99% of the series falls below
+		{"hundred p99", rangeSeries(100), 99, 100 * ms},
+		{"two hundred p99", rangeSeries(200), 99, 199 * ms},
+		{"ten p50", rangeSeries(10), 50, 6 * ms},
+		{"ten p90", rangeSeries(10), 90, 10 * ms},
+		{"ten p0", rangeSeries(10), 0, 1 * ms},
+		{"ten p100", rangeSeries(10), 100, 10 * ms},
+		{"unsorted", msSeries(50, 10, 40, 20, 30), 50, 30 * ms},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.series, tt.p); got != tt.want {
+				t.Errorf("Percentile(p%g) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCV(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []time.Duration
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"zero mean", msSeries(0, 0), 0},
+		{"constant", msSeries(5, 5, 5), 0},
+		{"two values", msSeries(10, 30), 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CV(tt.series); got < tt.want-1e-9 || got > tt.want+1e-9 {
+				t.Errorf("CV() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeTasks(t *testing.T) {
+	var tasks []workload.Task
+	for i, d := range msSeries(100, 2000, 300, 100) {
+		tasks = append(tasks, workload.Task{TaskID: i, Duration: d})
+	}
+	duration := func(task workload.Task) time.Duration { return task.Duration }
+	short := func(task workload.Task) bool { return task.Duration < time.Second }
+
+	if got, want := SummarizeTasks(tasks, nil, duration), Summarize(msSeries(100, 2000, 300, 100)); got != want {
+		t.Errorf("SummarizeTasks() without filter = %+v, want %+v", got, want)
+	}
+	if got, want := SummarizeTasks(tasks, short, duration), Summarize(msSeries(100, 300, 100)); got != want {
+		t.Errorf("SummarizeTasks() of short tasks = %+v, want %+v", got, want)
+	}
+	if got := SummarizeTasks(nil, short, duration); got != (Summary{}) {
+		t.Errorf("SummarizeTasks() of no tasks = %+v, want the zero Summary", got)
+	}
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// WindowPercentiles are the percentiles reported for each window
+var WindowPercentiles = []float64{50, 95, 99}
+
+// Windows splits the tasks of a run into consecutive windows of a fixed length by
+// completion time, with a histogram of response and wait times per window, so that
+// transients a single summary over the run averages away show up. Windows only hold
+// histograms, so they can follow streamed runs.
+type Windows struct {
+	origin             time.Time
+	length             time.Duration
+	highest            time.Duration
+	significantFigures int
+	windows            map[int]*window
+}
+
+type window struct {
+	response *Histogram
+	wait     *Histogram
+}
+
+// WindowStats holds the statistics of one window
+type WindowStats struct {
+	Start       time.Duration // Since the origin
+	End         time.Duration
+	Count       int64
+	Response    []time.Duration // At WindowPercentiles
+	Wait        []time.Duration
+	MaxResponse time.Duration
+}
+
+// NewWindows creates windows of the given length starting at origin, whose histograms
+// track latencies up to highest with the given number of significant digits
+func NewWindows(origin time.Time, length, highest time.Duration, significantFigures int) *Windows {
+	return &Windows{
+		origin:             origin,
+		length:             length,
+		highest:            highest,
+		significantFigures: significantFigures,
+		windows:            make(map[int]*window),
+	}
+}
+
+// Record adds a completed task to the window it completed in. Tasks completing before the
+// origin count in the first window.
+func (w *Windows) Record(task workload.Task) {
+	i := max(0, int(task.CompletionTime.Sub(w.origin)/w.length))
+	win := w.windows[i]
+	if win == nil {
+		win = &window{
+			response: NewHistogram(w.highest, w.significantFigures),
+			wait:     NewHistogram(w.highest, w.significantFigures),
+		}
+		w.windows[i] = win
+	}
+	win.response.Record(task.ResponseTime())
+	win.wait.Record(task.WaitTime())
+}
+
+// Length returns the length of the windows
+func (w *Windows) Length() time.Duration {
+	return w.length
+}
+
+// Stats returns the statistics of every window from the first to the last with a task in
+// it, in order; windows without tasks have a zero count
+func (w *Windows) Stats() []WindowStats {
+	if len(w.windows) == 0 {
+		return nil
+	}
+	indices := make([]int, 0, len(w.windows))
+	for i := range w.windows {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	stats := make([]WindowStats, 0, indices[len(indices)-1]-indices[0]+1)
+	for i := indices[0]; i <= indices[len(indices)-1]; i++ {
+		s := WindowStats{Start: time.Duration(i) * w.length, End: time.Duration(i+1) * w.length}
+		if win := w.windows[i]; win != nil {
+			s.Count = win.response.Count()
+			s.MaxResponse = win.response.Max()
+			for _, p := range WindowPercentiles {
+				s.Response = append(s.Response, win.response.ValueAtPercentile(p))
+				s.Wait = append(s.Wait, win.wait.ValueAtPercentile(p))
+			}
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// WriteCSV writes one row per window: its bounds in seconds since the origin, its number
+// of tasks, and its response and wait time percentiles in milliseconds, left empty for
+// windows without tasks
+func (w *Windows) WriteCSV(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create windows file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := []string{"window_start_s", "window_end_s", "tasks"}
+	for _, metric := range []string{"response", "wait"} {
+		for _, p := range WindowPercentiles {
+			header = append(header, fmt.Sprintf("%s_p%g_ms", metric, p))
+		}
+	}
+	header = append(header, "response_max_ms")
+	writer.Write(header)
+	formatMs := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+	}
+	for _, s := range w.Stats() {
+		row := []string{
+			strconv.FormatFloat(s.Start.Seconds(), 'f', -1, 64),
+			strconv.FormatFloat(s.End.Seconds(), 'f', -1, 64),
+			strconv.FormatInt(s.Count, 10),
+		}
+		if s.Count == 0 {
+			for range 2*len(WindowPercentiles) + 1 {
+				row = append(row, "")
+			}
+		} else {
+			for _, series := range [][]time.Duration{s.Response, s.Wait} {
+				for _, d := range series {
+					row = append(row, formatMs(d))
+				}
+			}
+			row = append(row, formatMs(s.MaxResponse))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write windows file: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// latencyReadSize is the most bytes a latency connection takes off the wire at once
+const latencyReadSize = 32 * 1024
+
+// latencyBuffer is how many chunks a latency connection holds in flight each way
+const latencyBuffer = 64
+
+// latencyConn delays the traffic of a connection to Postgres both ways by half of a round
+// trip, as if the database were in another region. Writes return at once and go out
+// after their delay, in order; reads are taken off the wire as soon as they arrive and
+// handed over once their delay has passed. Each message is delayed once however it is
+// split, back-to-back messages aren't delayed behind each other, and notifications
+// arriving while the connection is idle are delayed too.
+type latencyConn struct {
+	net.Conn
+	delay  time.Duration // One way
+	reads  chan latencyChunk
+	writes chan latencyChunk
+	closed chan struct{}
+	once   sync.Once
+
+	mu              sync.Mutex
+	readDeadline    time.Time
+	deadlineChanged chan struct{} // Closed and replaced whenever the read deadline changes
+	writeErr        error         // First failure to send a write
+
+	current latencyChunk // Rest of the chunk being read, only touched by Read
+}
+
+// latencyChunk is data received or to send once due, or the error that ended the reads
+type latencyChunk struct {
+	data []byte
+	due  time.Time
+	err  error
+}
+
+// newLatencyConn wraps a connection to add a round-trip latency to its traffic
+func newLatencyConn(conn net.Conn, roundTrip time.Duration) *latencyConn {
+	c := &latencyConn{
+		Conn:            conn,
+		delay:           roundTrip / 2,
+		reads:           make(chan latencyChunk, latencyBuffer),
+		writes:          make(chan latencyChunk, latencyBuffer),
+		closed:          make(chan struct{}),
+		deadlineChanged: make(chan struct{}),
+	}
+	go c.receive()
+	go c.send()
+	return c
+}
+
+// receive takes data off the wire as it arrives, stamped with when it is due
+func (c *latencyConn) receive() {
+	for {
+		buf := make([]byte, latencyReadSize)
+		n, err := c.Conn.Read(buf)
+		due := time.Now().Add(c.delay)
+		var chunks []latencyChunk
+		if n > 0 {
+			chunks = append(chunks, latencyChunk{data: buf[:n], due: due})
+		}
+		if err != nil {
+			chunks = append(chunks, latencyChunk{err: err, due: due})
+		}
+		for _, chunk := range chunks {
+			select {
+			case c.reads <- chunk:
+			case <-c.closed:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// send puts the writes on the wire once they are due. Writes after a failure are
+// dropped; the failure is returned by the next Write.
+func (c *latencyConn) send() {
+	for {
+		select {
+		case chunk := <-c.writes:
+			c.mu.Lock()
+			failed := c.writeErr != nil
+			c.mu.Unlock()
+			if failed {
+				continue
+			}
+			time.Sleep(time.Until(chunk.due))
+			if _, err := c.Conn.Write(chunk.data); err != nil {
+				c.mu.Lock()
+				c.writeErr = err
+				c.mu.Unlock()
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *latencyConn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		deadline, changed := c.readDeadline, c.deadlineChanged
+		c.mu.Unlock()
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			expired = time.After(time.Until(deadline))
+		}
+
+		if len(c.current.data) == 0 && c.current.err == nil {
+			select {
+			case c.current = <-c.reads:
+			case <-expired:
+				return 0, os.ErrDeadlineExceeded
+			case <-changed:
+				continue
+			case <-c.closed:
+				return 0, net.ErrClosed
+			}
+		}
+		if wait := time.Until(c.current.due); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-expired:
+				return 0, os.ErrDeadlineExceeded
+			case <-changed:
+				continue
+			case <-c.closed:
+				return 0, net.ErrClosed
+			}
+		}
+		if len(c.current.data) == 0 {
+			return 0, c.current.err
+		}
+		n := copy(p, c.current.data)
+		c.current.data = c.current.data[n:]
+		return n, nil
+	}
+}
+
+func (c *latencyConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	err := c.writeErr
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case c.writes <- latencyChunk{data: bytes.Clone(p), due: time.Now().Add(c.delay)}:
+		return len(p), nil
+	case <-c.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+// Close drops the traffic still in flight and closes the connection
+func (c *latencyConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+// SetDeadline sets the read deadline, which Read enforces itself as data already
+// received may still be in flight, and the write deadline of the connection
+func (c *latencyConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *latencyConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	close(c.deadlineChanged)
+	c.deadlineChanged = make(chan struct{})
+	return nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// notifyFallbackInterval bounds how long an idle dispatcher waits without a notification
+// before checking the queue anyway, in case a notification was missed
+const notifyFallbackInterval = time.Second
+
+// notifySchema holds the task table used by the notify dispatch path. Rows live from
+// enqueue until the task's workflow finishes, so the table only holds active tasks.
+const notifySchema = `
+CREATE TABLE IF NOT EXISTS schedq_tasks (
+    workflow_id TEXT PRIMARY KEY,
+    queue_name  TEXT NOT NULL,
+    priority    INTEGER NOT NULL DEFAULT 0,
+    dedup_id    TEXT,
+    task        JSONB NOT NULL,
+    enqueued_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+    claimed_by  TEXT,
+    claimed_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS schedq_tasks_unclaimed
+    ON schedq_tasks (queue_name, priority, enqueued_at) WHERE claimed_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS schedq_tasks_dedup
+    ON schedq_tasks (queue_name, dedup_id) WHERE dedup_id IS NOT NULL;
+`
+
+// notifyChannel returns the LISTEN/NOTIFY channel of a queue
+func notifyChannel(queueName string) string {
+	return "schedq_" + queueName
+}
+
+// newNotifyPool connects to Postgres, over connections adding the given round-trip
+// latency, and makes sure the notify task table exists
+func newNotifyPool(ctx context.Context, latency time.Duration) (*pgxpool.Pool, error) {
+	pool, err := newPoolWithLatency(ctx, AppConfig.Database, latency)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, notifySchema); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to create notify task table: %w", err)
+	}
+	return pool, nil
+}
+
+// notifyTaskQueue submits tasks to the notify task table. Each insert notifies the
+// queue's channel in the same statement, so listening dispatchers wake up as soon as the
+// enqueue commits instead of waiting for their next poll.
+type notifyTaskQueue struct {
+	pool   *pgxpool.Pool
+	policy SchedulingPolicy
+	tag    string // Run tag of the tasks counted in the depth, if any
+}
+
+func (q *notifyTaskQueue) Enqueue(task Task, workflowID string) error {
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+	var priority uint
+	if q.policy.Priority != nil {
+		priority = taskPriority(q.policy, task)
+	}
+	var dedupID *string
+	if task.DedupID != "" {
+		dedupID = &task.DedupID
+	}
+
+	_, err = q.pool.Exec(context.Background(), `
+		WITH inserted AS (
+		    INSERT INTO schedq_tasks (workflow_id, queue_name, priority, dedup_id, task)
+		    VALUES ($1, $2, $3, $4, $5)
+		    RETURNING workflow_id
+		)
+		SELECT pg_notify($6, workflow_id) FROM inserted`,
+		workflowID, q.policy.QueueName, int(priority), dedupID, payload, notifyChannel(q.policy.QueueName))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "schedq_tasks_dedup" {
+		return errTaskDeduplicated
+	}
+	return err
+}
+
+func (q *notifyTaskQueue) Depth() (int, error) {
+	var depth int
+	err := q.pool.QueryRow(context.Background(),
+		`SELECT count(*) FROM schedq_tasks WHERE queue_name = $1 AND claimed_at IS NULL AND starts_with(workflow_id, $2)`,
+		q.policy.QueueName, tagPrefix(q.tag)).Scan(&depth)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read queue depth: %w", err)
+	}
+	return depth, nil
+}
+
+// notifyDispatcher runs tasks from the notify task table on one executor. It LISTENs on
+// the queue's channel and claims tasks (by priority, then arrival) whenever it is woken
+// up and has a free worker slot, so dispatch doesn't wait for a polling interval.
+type notifyDispatcher struct {
+	pool       *pgxpool.Pool
+	ctx        dbos.DBOSContext
+	policy     SchedulingPolicy
+	queueCfg   QueueConfig
+	executorID string
+
+	slots  chan struct{} // One token per running task
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newNotifyDispatcher(pool *pgxpool.Pool, ctx dbos.DBOSContext, policy SchedulingPolicy, queueCfg QueueConfig) *notifyDispatcher {
+	return &notifyDispatcher{
+		pool:       pool,
+		ctx:        ctx,
+		policy:     policy,
+		queueCfg:   queueCfg,
+		executorID: ctx.GetExecutorID(),
+		slots:      make(chan struct{}, queueCfg.WorkerConcurrency),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Start begins listening for notifications and dispatching tasks
+func (d *notifyDispatcher) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to acquire listen connection: %w", err)
+	}
+	channel := pgx.Identifier{notifyChannel(d.policy.QueueName)}.Sanitize()
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		conn.Release()
+		cancel()
+		return fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	d.wg.Add(2)
+	go d.listen(ctx, conn)
+	go d.dispatchLoop(ctx)
+	return nil
+}
+
+// Stop stops dispatching new tasks. Tasks already running finish in their workflows.
+func (d *notifyDispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (d *notifyDispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *notifyDispatcher) listen(ctx context.Context, conn *pgxpool.Conn) {
+	defer d.wg.Done()
+	defer conn.Release()
+	for {
+		if _, err := conn.Conn().WaitForNotification(ctx); err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("Warning: notify listener on %s stopped: %v\n", d.executorID, err)
+			}
+			return
+		}
+		d.signal()
+	}
+}
+
+func (d *notifyDispatcher) dispatchLoop(ctx context.Context) {
+	defer d.wg.Done()
+	for {
+		d.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-time.After(notifyFallbackInterval):
+		}
+	}
+}
+
+// drain claims and starts tasks until the queue is empty or every worker slot is busy
+func (d *notifyDispatcher) drain(ctx context.Context) {
+	for {
+		select {
+		case d.slots <- struct{}{}:
+		default:
+			return // All worker slots are busy
+		}
+
+		workflowID, task, ok, err := d.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("Warning: failed to claim task on %s: %v\n", d.executorID, err)
+		}
+		if !ok {
+			<-d.slots
+			return
+		}
+		go d.run(workflowID, task)
+	}
+}
+
+// claim takes the next unclaimed task of the queue for this executor. With a global
+// concurrency limit, claims are serialized per queue so the limit holds across executors.
+func (d *notifyDispatcher) claim(ctx context.Context) (string, Task, bool, error) {
+	var task Task
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return "", task, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if d.queueCfg.GlobalConcurrency > 0 {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, d.policy.QueueName); err != nil {
+			return "", task, false, err
+		}
+		var running int
+		err := tx.QueryRow(ctx, `SELECT count(*) FROM schedq_tasks WHERE queue_name = $1 AND claimed_at IS NOT NULL AND starts_with(workflow_id, $2)`,
+			d.policy.QueueName, d.queueCfg.RunTagPrefix()).Scan(&running)
+		if err != nil {
+			return "", task, false, err
+		}
+		if running >= d.queueCfg.GlobalConcurrency {
+			return "", task, false, nil
+		}
+	}
+
+	var workflowID string
+	var payload []byte
+	var enqueuedAt, claimedAt time.Time
+	err = tx.QueryRow(ctx, `
+		UPDATE schedq_tasks SET claimed_by = $1, claimed_at = clock_timestamp()
+		WHERE workflow_id = (
+		    SELECT workflow_id FROM schedq_tasks
+		    WHERE queue_name = $2 AND claimed_at IS NULL AND starts_with(workflow_id, $3)
+		    ORDER BY priority, enqueued_at
+		    LIMIT 1
+		    FOR UPDATE SKIP LOCKED
+		)
+		RETURNING workflow_id, task, enqueued_at, claimed_at`, d.executorID, d.policy.QueueName, d.queueCfg.RunTagPrefix()).Scan(&workflowID, &payload, &enqueuedAt, &claimedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", task, false, nil
+	}
+	if err != nil {
+		return "", task, false, err
+	}
+	if err := json.Unmarshal(payload, &task); err != nil {
+		return "", task, false, fmt.Errorf("failed to decode task %s: %w", workflowID, err)
+	}
+	// The task's workflow only exists once claimed, so the wait is timed by the task table
+	task.EnqueuedAt, task.StartedAt = enqueuedAt, claimedAt
+	if err := tx.Commit(ctx); err != nil {
+		return "", task, false, err
+	}
+	return workflowID, task, true, nil
+}
+
+// run executes a claimed task's workflow, then frees its row and worker slot
+func (d *notifyDispatcher) run(workflowID string, task Task) {
+	handle, err := dbos.RunWorkflow(d.ctx, processTask, task, dbos.WithWorkflowID(workflowID))
+	if err == nil {
+		_, err = handle.GetResult()
+	}
+	if err != nil {
+		fmt.Printf("Warning: task workflow %s failed: %v\n", workflowID, err)
+	}
+
+	// Deleting the row frees a global concurrency slot: wake up the other executors
+	_, err = d.pool.Exec(context.Background(), `
+		WITH deleted AS (DELETE FROM schedq_tasks WHERE workflow_id = $1 RETURNING queue_name)
+		SELECT pg_notify($2, 'done') FROM deleted`, workflowID, notifyChannel(d.policy.QueueName))
+	if err != nil {
+		fmt.Printf("Warning: failed to release task %s: %v\n", workflowID, err)
+	}
+	<-d.slots
+	d.signal()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// sizeWorkload sets the number of tasks of an overload or soak run to the arrivals that
+// fit in its duration, of a multi-phase run to the tasks of its phases, and of a trace
+// replay to the tasks of the trace, and returns the workload configuration of the run
+func sizeWorkload(interArrival time.Duration) WorkloadConfig {
+	if AppConfig.Workload.OverloadDurationMs > 0 {
+		AppConfig.Workload.NumTasks = AppConfig.Workload.OverloadTasks(interArrival)
+	}
+	if duration := AppConfig.Workload.RunDuration(); duration > 0 {
+		AppConfig.Workload.NumTasks = max(1, int(duration/interArrival))
+	}
+	AppConfig.Workload.ResolvePhases(interArrival)
+	// The trace was checked when the configuration was validated
+	AppConfig.Workload.ResolveTrace()
+	return AppConfig.Workload
+}
+
+// OverloadSummary describes how the backlog of an overload run built up and drained
+type OverloadSummary struct {
+	Arrivals   time.Duration // From the first arrival to the last
+	Backlog    int           // Tasks arrived but not completed when arrivals stopped
+	GrowthRate float64       // Backlog growth while tasks arrived, in tasks per second
+	DrainTime  time.Duration // From the last arrival to the last completion
+
+	Response      ResponseSummary
+	ShortResponse ResponseSummary
+	LongResponse  ResponseSummary
+}
+
+// summarizeOverload computes the backlog growth and drain of a run from its tasks
+func summarizeOverload(tasks []Task) OverloadSummary {
+	var summary OverloadSummary
+	if len(tasks) == 0 {
+		return summary
+	}
+	first, last := tasks[0].ArrivalTime, tasks[0].ArrivalTime
+	var lastCompletion time.Time
+	for _, task := range tasks {
+		if task.ArrivalTime.Before(first) {
+			first = task.ArrivalTime
+		}
+		if task.ArrivalTime.After(last) {
+			last = task.ArrivalTime
+		}
+		if task.CompletionTime.After(lastCompletion) {
+			lastCompletion = task.CompletionTime
+		}
+	}
+	for _, task := range tasks {
+		if task.CompletionTime.After(last) {
+			summary.Backlog++
+		}
+	}
+	summary.Arrivals = last.Sub(first)
+	if summary.Arrivals > 0 {
+		summary.GrowthRate = float64(summary.Backlog) / summary.Arrivals.Seconds()
+	}
+	summary.DrainTime = max(lastCompletion.Sub(last), 0)
+	summary.Response = summarizeResponseTimes(tasks, nil)
+	summary.ShortResponse = summarizeResponseTimes(tasks, func(task Task) bool { return taskClass(task) == "short" })
+	summary.LongResponse = summarizeResponseTimes(tasks, func(task Task) bool { return taskClass(task) == "long" })
+	return summary
+}
+
+// printOverloadReport prints the backlog growth and drain of an overload run. It prints
+// nothing for runs without an overload duration.
+func printOverloadReport(tasks []Task) {
+	if AppConfig.Workload.OverloadDurationMs == 0 {
+		return
+	}
+	s := summarizeOverload(tasks)
+	fmt.Printf("\nOverload:\n")
+	fmt.Printf("  Arrivals for %v, backlog of %d tasks when they stopped (%.2f tasks/s growth)\n",
+		s.Arrivals.Round(time.Millisecond), s.Backlog, s.GrowthRate)
+	fmt.Printf("  Drain time: %v\n", s.DrainTime.Round(time.Millisecond))
+}
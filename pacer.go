@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/workload"
+)
+
+// arrivalBuffer is how many arrived tasks the pacer holds for the producer. A producer
+// that falls further behind blocks the pacer, and later arrivals drift.
+const arrivalBuffer = 4096
+
+// How far the producer can stray from the workload's schedule before the run is flagged:
+// the rate tasks arrived at, or were handed to the queue at, off by more than
+// arrivalRateTolerance, or tasks late by more than arrivalDelayTolerance of the mean
+// inter-arrival time on average, which bunches arrivals that should have been spread
+const (
+	arrivalRateTolerance  = 0.05
+	arrivalDelayTolerance = 0.5
+)
+
+// arrivalPacer generates the tasks of a run on their own goroutine, each at its arrival
+// time, and hands them to the producer through a channel. The producer's enqueues, rate
+// limits and backpressure don't hold up the arrivals behind them: tasks keep arriving on
+// schedule, and a slow enqueue shows as enqueue delay rather than late arrivals.
+type arrivalPacer struct {
+	tasks   chan Task
+	stop    context.CancelFunc
+	precise bool // Whether arrivals are timed to the microsecond
+
+	// How late tasks arrived after their scheduled arrival, written by the pacer's
+	// goroutine and read once tasks is closed
+	drift    *metrics.Histogram
+	maxDrift time.Duration
+	// How long arrived tasks waited for the producer to pick them up
+	lag    *metrics.Histogram
+	maxLag time.Duration
+
+	// When tasks were scheduled to arrive, actually arrived, and were picked up by the
+	// producer. The first two are written by the pacer's goroutine.
+	scheduled, arrived, received arrivalSpan
+}
+
+// arrivalSpan is the span of a series of events, for the rate they happened at
+type arrivalSpan struct {
+	first, last time.Time
+	count       int
+}
+
+func (s *arrivalSpan) add(at time.Time) {
+	if s.count == 0 {
+		s.first = at
+	}
+	s.last = at
+	s.count++
+}
+
+// Rate returns the events per second over the span, 0 if unknown
+func (s arrivalSpan) Rate() float64 {
+	span := s.last.Sub(s.first)
+	if s.count < 2 || span <= 0 {
+		return 0
+	}
+	return float64(s.count-1) / span.Seconds()
+}
+
+// startArrivals starts generating the tasks from..count-1 of the workload, the first of
+// them due at start. A resumed run starts after the tasks it already enqueued, shifted
+// so the first of the remaining ones is due right away.
+func startArrivals(generator workload.Generator, from, count int, start time.Time, cfg MetricsConfig, precise bool) *arrivalPacer {
+	ctx, stop := context.WithCancel(context.Background())
+	p := &arrivalPacer{
+		tasks:   make(chan Task, arrivalBuffer),
+		stop:    stop,
+		precise: precise,
+		drift:   metrics.NewHistogram(cfg.HistogramMax(), cfg.HistogramSignificantFigures),
+		lag:     metrics.NewHistogram(cfg.HistogramMax(), cfg.HistogramSignificantFigures),
+	}
+	go p.run(ctx, generator, from, count, start)
+	return p
+}
+
+func (p *arrivalPacer) run(ctx context.Context, generator workload.Generator, from, count int, start time.Time) {
+	defer close(p.tasks)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for i := from; i < count; i++ {
+		task, offset := generator.Next()
+		if i == from && from > 0 {
+			start = start.Add(-offset)
+		}
+
+		// Sleep until the task is due, then stamp it with the time it actually arrived.
+		// High-resolution runs spin through the end of the wait, timers being too coarse.
+		scheduled := start.Add(offset)
+		wait := scheduled.Sub(monotonicNow())
+		if p.precise {
+			wait -= spinMargin
+		}
+		if wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if p.precise {
+			sleepPrecisely(scheduled.Sub(monotonicNow()))
+		}
+		generator.Arrive(&task, monotonicNow())
+		task.ScheduledArrival = scheduled
+		task.ArrivalDrift = max(0, task.ArrivalTime.Sub(scheduled))
+		p.drift.Record(task.ArrivalDrift)
+		p.maxDrift = max(p.maxDrift, task.ArrivalDrift)
+		p.scheduled.add(scheduled)
+		p.arrived.add(task.ArrivalTime)
+
+		select {
+		case p.tasks <- task:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Tasks returns the channel of arrived tasks, in arrival order, closed after the last one
+func (p *arrivalPacer) Tasks() <-chan Task {
+	return p.tasks
+}
+
+// Received records how long the task waited for the producer to pick it up
+func (p *arrivalPacer) Received(task Task) {
+	now := monotonicNow()
+	lag := max(0, now.Sub(task.ArrivalTime))
+	p.received.add(now)
+	p.lag.Record(lag)
+	p.maxLag = max(p.maxLag, lag)
+}
+
+// Stop stops generating arrivals, when the producer gives up before the last one
+func (p *arrivalPacer) Stop() {
+	p.stop()
+}
+
+// Print audits the arrivals against the workload's schedule: how late tasks arrived and
+// how far behind them the producer fell, and the offered load that resulted, flagging
+// runs where the producer's delays materially changed it. Call it once every task has
+// been received.
+func (p *arrivalPacer) Print() {
+	if p.drift.Count() == 0 {
+		return
+	}
+	fmt.Printf("\nArrivals:\n")
+	fmt.Printf("  Drift from the schedule: mean %s ms, p99 %s ms, max %s ms\n",
+		formatPreciseMs(p.drift.Mean()), formatPreciseMs(p.drift.ValueAtPercentile(99)), formatPreciseMs(p.maxDrift))
+	fmt.Printf("  Producer lag behind arrivals: mean %s ms, p99 %s ms, max %s ms\n",
+		formatPreciseMs(p.lag.Mean()), formatPreciseMs(p.lag.ValueAtPercentile(99)), formatPreciseMs(p.maxLag))
+
+	intended := p.scheduled.Rate()
+	if intended == 0 {
+		return
+	}
+	change := func(rate float64) float64 { return rate/intended - 1 }
+	fmt.Printf("  Arrival rate: %.1f tasks/s scheduled, %.1f arrived (%+.1f%%), %.1f handed to the queue (%+.1f%%)\n",
+		intended, p.arrived.Rate(), 100*change(p.arrived.Rate()), p.received.Rate(), 100*change(p.received.Rate()))
+
+	var reasons []string
+	for _, rate := range []struct {
+		name string
+		rate float64
+	}{{"arrival", p.arrived.Rate()}, {"hand-off", p.received.Rate()}} {
+		if math.Abs(change(rate.rate)) > arrivalRateTolerance {
+			reasons = append(reasons, fmt.Sprintf("the %s rate is %+.1f%% off the schedule", rate.name, 100*change(rate.rate)))
+		}
+	}
+	interArrival := time.Duration(float64(time.Second) / intended)
+	if delay := p.drift.Mean() + p.lag.Mean(); delay > time.Duration(arrivalDelayTolerance*float64(interArrival)) {
+		reasons = append(reasons, fmt.Sprintf("tasks reached the queue %s ms late on average, %.0f%% of the %s ms between arrivals",
+			formatPreciseMs(delay), 100*float64(delay)/float64(interArrival), formatPreciseMs(interArrival)))
+	}
+	if len(reasons) == 0 {
+		return
+	}
+	fmt.Printf("  Warning: the producer changed the offered load, the run didn't see the workload it was configured with:\n")
+	for _, reason := range reasons {
+		fmt.Printf("    - %s\n", reason)
+	}
+	fmt.Printf("  Raise producer.enqueue_workers, or lower the arrival rate.\n")
+}
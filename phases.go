@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PhaseSummary describes the tasks of one phase of a multi-phase run
+type PhaseSummary struct {
+	Name        string
+	Utilization float64
+	Arrivals    time.Duration // From the first arrival of the phase to the last
+	Response    ResponseSummary
+	Wait        ResponseSummary
+	Short       ResponseSummary
+	Long        ResponseSummary
+}
+
+// summarizePhases computes response and wait time statistics for each phase of the
+// workload, in order. Like steps, phases are assigned by task ID, so tasks count in the
+// phase they arrived in even if they completed during a later one.
+func summarizePhases(tasks []Task) []PhaseSummary {
+	cfg := AppConfig.Workload
+	var phases []PhaseSummary
+	for i, phase := range cfg.Phases {
+		inPhase := func(task Task) bool { return cfg.Phase(task.TaskID) == i }
+		summary := PhaseSummary{
+			Name:        cfg.PhaseName(i),
+			Utilization: cfg.PhaseUtilization(phase),
+			Response:    summarizeResponseTimes(tasks, inPhase),
+			Wait:        summarizeWaitTimes(tasks, inPhase),
+			Short:       summarizeResponseTimes(tasks, func(task Task) bool { return inPhase(task) && taskClass(task) == "short" }),
+			Long:        summarizeResponseTimes(tasks, func(task Task) bool { return inPhase(task) && taskClass(task) == "long" }),
+		}
+		var first, last time.Time
+		for _, task := range tasks {
+			if !inPhase(task) {
+				continue
+			}
+			if first.IsZero() || task.ArrivalTime.Before(first) {
+				first = task.ArrivalTime
+			}
+			if task.ArrivalTime.After(last) {
+				last = task.ArrivalTime
+			}
+		}
+		summary.Arrivals = last.Sub(first)
+		phases = append(phases, summary)
+	}
+	return phases
+}
+
+// printPhaseReport prints the latency of each phase of a multi-phase run, so the effect
+// of each load level and mix shows in one run. It prints nothing without phases.
+func printPhaseReport(tasks []Task) {
+	phases := summarizePhases(tasks)
+	if len(phases) == 0 {
+		return
+	}
+	fmt.Printf("\nPhases:\n")
+	fmt.Printf("  %-16s %6s %6s %10s %12s %12s %12s %12s %12s\n", "Phase", "Util", "Tasks", "Arrivals",
+		"Resp mean", "Resp p99", "Short p99", "Long p99", "Wait p99")
+	for _, s := range phases {
+		fmt.Printf("  %-16s %5.0f%% %6d %10v %12s %12s %12s %12s %12s\n", s.Name, s.Utilization*100, s.Response.Count,
+			s.Arrivals.Round(time.Millisecond), formatMs(s.Response.Mean), formatMs(s.Response.P99),
+			formatMs(s.Short.P99), formatMs(s.Long.P99), formatMs(s.Wait.P99))
+	}
+	fmt.Println("  (times in ms)")
+}
+
+// formatPhases describes the phases of a workload in one line, e.g.
+// "warmup 200 tasks at 50%, spike 10s at 120%"
+func formatPhases(cfg WorkloadConfig) string {
+	parts := make([]string, len(cfg.Phases))
+	for i, phase := range cfg.Phases {
+		size := fmt.Sprintf("%d tasks", phase.NumTasks)
+		if phase.DurationMs > 0 {
+			size = phase.Duration().String()
+		}
+		parts[i] = fmt.Sprintf("%s %s at %.0f%%", cfg.PhaseName(i), size, cfg.PhaseUtilization(phase)*100)
+		if phase.ShortTaskProbability != nil {
+			parts[i] += fmt.Sprintf(" with %.0f%% short", *phase.ShortTaskProbability*100)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
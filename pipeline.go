@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/workload"
+)
+
+// pipelineRun is the pipeline the executors of this process forward tasks through: the
+// policy of its queues and its stages. It is nil outside pipeline runs.
+type pipelineRun struct {
+	policy SchedulingPolicy
+	stages []workload.Stage
+}
+
+var activePipeline atomic.Pointer[pipelineRun]
+
+// stage returns the stage a task is in, or a single stage running tasks for their
+// duration outside pipeline runs
+func (p *pipelineRun) stage(task Task) workload.Stage {
+	if p == nil {
+		return workload.Stage{DurationFactor: 1}
+	}
+	return p.stages[task.Stage]
+}
+
+// last reports whether a task is in the last stage, which it leaves completed
+func (p *pipelineRun) last(task Task) bool {
+	return p == nil || task.Stage == len(p.stages)-1
+}
+
+// stageQueueName returns the name of the DBOS queue of stage k: tasks enter the pipeline
+// through the policy's queue and move on to one queue per later stage
+func stageQueueName(policy SchedulingPolicy, k int) string {
+	if k == 0 {
+		return policy.QueueName
+	}
+	return fmt.Sprintf("%s_stage%d", policy.QueueName, k)
+}
+
+// stageWorkflowID returns the workflow ID of a task in stage k, given its workflow ID in
+// the first stage
+func stageWorkflowID(workflowID string, k int) string {
+	if k == 0 {
+		return workflowID
+	}
+	return fmt.Sprintf("%s.stage%d", workflowID, k)
+}
+
+// resultWorkflowID returns the workflow ID whose output is the result of a task: the
+// workflow of its last stage in pipeline runs
+func resultWorkflowID(runID string, taskID int) string {
+	return stageWorkflowID(taskWorkflowID(runID, taskID), max(0, len(AppConfig.Pipeline.Stages)-1))
+}
+
+// shapeWorkload returns the average task duration and the average inter-arrival time of
+// the configured workload on the queue. In pipeline runs, arrivals are spaced out so the
+// slowest stage runs at the target utilization.
+func shapeWorkload(queueCfg QueueConfig) (time.Duration, time.Duration) {
+	avgTaskDuration, interArrivalTime := workload.Shape(AppConfig.Workload, queueCfg.Capacity())
+	factor := workload.BottleneckFactor(AppConfig.Pipeline.WorkloadStages(queueCfg), queueCfg.Capacity())
+	return avgTaskDuration, time.Duration(float64(interArrivalTime) * factor)
+}
+
+// StageSummary describes the time tasks spent in one stage of a pipeline
+type StageSummary struct {
+	Stage   int
+	Wait    metrics.Summary
+	Sojourn metrics.Summary
+}
+
+// summarizeStages computes wait and sojourn time statistics for each stage tasks went
+// through, in order
+func summarizeStages(tasks []Task) []StageSummary {
+	var stages []StageSummary
+	for k := 0; ; k++ {
+		var waits, sojourns []time.Duration
+		for _, task := range tasks {
+			if k < len(task.Stages) {
+				waits = append(waits, task.Stages[k].WaitTime())
+				sojourns = append(sojourns, task.Stages[k].SojournTime())
+			}
+		}
+		if len(waits) == 0 {
+			return stages
+		}
+		stages = append(stages, StageSummary{Stage: k + 1, Wait: metrics.Summarize(waits), Sojourn: metrics.Summarize(sojourns)})
+	}
+}
+
+// printPipelineReport prints the time tasks spent in each stage of a pipeline and end to
+// end, so the bottleneck stage stands out. It prints nothing outside pipeline runs.
+func printPipelineReport(tasks []Task) {
+	stages := summarizeStages(tasks)
+	if len(stages) == 0 {
+		return
+	}
+	fmt.Printf("\nPipeline stages:\n")
+	fmt.Printf("  %5s %6s %12s %12s %12s %12s\n", "Stage", "Tasks", "Wait mean", "Wait p99", "Stage mean", "Stage p99")
+	for _, s := range stages {
+		fmt.Printf("  %5d %6d %12s %12s %12s %12s\n", s.Stage, s.Wait.Count,
+			formatMs(s.Wait.Mean), formatMs(s.Wait.P99), formatMs(s.Sojourn.Mean), formatMs(s.Sojourn.P99))
+	}
+	response := summarizeResponseTimes(tasks, nil)
+	fmt.Printf("  End to end: mean %s, p99 %s\n", formatMs(response.Mean), formatMs(response.P99))
+	fmt.Println("  (times in ms; stage time is the wait plus the work in the stage)")
+}
+
+// formatStages describes the stages of a pipeline for the run banner, e.g.
+// "3 stages, duration x1 x2 x1, worker slots 2 4 2"
+func formatStages(stages []workload.Stage) string {
+	factors := make([]string, len(stages))
+	slots := make([]string, len(stages))
+	for i, stage := range stages {
+		factors[i] = fmt.Sprintf("x%g", stage.DurationFactor)
+		slots[i] = fmt.Sprintf("%d", stage.Capacity)
+	}
+	return fmt.Sprintf("%d stages, duration %s, worker slots %s", len(stages), strings.Join(factors, " "), strings.Join(slots, " "))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// plotCommand plots results CSV files with plot_results.py. Without files, it plots the
+// latest results of each algorithm found in the results directory.
+func plotCommand(args []string) error {
+	fs := flag.NewFlagSet("plot", flag.ExitOnError)
+	python := fs.String("python", "python3", "Python interpreter with pandas and matplotlib (e.g. \"uv run python\")")
+	script := fs.String("script", "plot_results.py", "Plotting script")
+	fs.Parse(args)
+
+	files := fs.Args()
+	if len(files) == 0 {
+		var err error
+		if files, err = latestResults("results"); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no results files given and none found in results/")
+		}
+	}
+
+	command := append(strings.Fields(*python), *script)
+	cmd := exec.Command(command[0], append(command[1:], files...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// latestResults returns the most recent results file of each algorithm (and label) in
+// the directory. Result file names end with a sortable timestamp.
+func latestResults(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*_results_*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	latest := make(map[string]string)
+	for _, file := range files {
+		// Capacity series of autoscaled runs, departure series, decision logs, timelines,
+		// executor statistics and size predictions sit next to the results
+		if strings.HasSuffix(file, "_capacity.csv") || strings.HasSuffix(file, "_departures.csv") ||
+			strings.HasSuffix(file, "_decisions.csv") || strings.HasSuffix(file, "_timeline.csv") ||
+			strings.HasSuffix(file, "_executors.csv") || strings.HasSuffix(file, "_predictions.csv") {
+			continue
+		}
+		name, _, _ := strings.Cut(filepath.Base(file), "_results_")
+		latest[name] = file
+	}
+	var result []string
+	for _, name := range sortedKeys(latest) {
+		result = append(result, latest[name])
+	}
+	return result, nil
+}
@@ -237,6 +237,51 @@ def plot_response_time_comparison(csv_files):
     print("="*80 + "\n")
 
 
+def plot_slowdown_vs_size(csv_files):
+    """Plot response time against task size, and the mean slowdown by size decile."""
+    fig, (ax1, ax2) = plt.subplots(1, 2, figsize=(16, 6))
+    fig.suptitle('Response Time vs. Task Size', fontsize=16, fontweight='bold')
+
+    for csv_file in csv_files:
+        if not Path(csv_file).exists():
+            continue
+        df = pd.read_csv(csv_file)
+        df = df[df['duration_ms'] > 0]
+        if df.empty:
+            continue
+        algo_name = extract_algorithm_name(csv_file)
+        slowdown = df['response_time_ms'] / df['duration_ms']
+
+        # Scatter of every task
+        ax1.scatter(df['duration_ms'], df['response_time_ms'], s=6, alpha=0.4, label=algo_name)
+
+        # Mean slowdown per size decile; workloads with few distinct sizes get fewer bins
+        bins = pd.qcut(df['duration_ms'], 10, duplicates='drop')
+        curve = pd.DataFrame({'size': df['duration_ms'], 'slowdown': slowdown}).groupby(bins, observed=True).mean()
+        ax2.plot(curve['size'], curve['slowdown'], marker='o', label=algo_name)
+
+    ax1.set_xscale('log')
+    ax1.set_yscale('log')
+    ax1.set_xlabel('Service time (ms)', fontsize=12)
+    ax1.set_ylabel('Response time (ms)', fontsize=12)
+    ax1.legend(loc='upper left')
+    ax1.grid(True, alpha=0.3, linestyle='--')
+
+    ax2.set_xscale('log')
+    ax2.set_yscale('log')
+    ax2.set_xlabel('Service time (ms), mean of each size decile', fontsize=12)
+    ax2.set_ylabel('Mean slowdown (response / service time)', fontsize=12)
+    ax2.axhline(1, color='gray', linewidth=1)
+    ax2.legend(loc='upper right')
+    ax2.grid(True, alpha=0.3, linestyle='--')
+
+    plt.tight_layout()
+    output_file = 'slowdown_vs_size.png'
+    plt.savefig(output_file, dpi=300, bbox_inches='tight')
+    print(f"✓ Slowdown plot saved as: {output_file}")
+    plt.show()
+
+
 def main():
     if len(sys.argv) < 2:
         print("Usage: python plot_results.py <csv_file1> <csv_file2> ...")
@@ -248,6 +293,7 @@ def main():
     
     print(f"Loading data from {len(csv_files)} CSV file(s)...")
     plot_response_time_comparison(csv_files)
+    plot_slowdown_vs_size(csv_files)
 
 
 if __name__ == "__main__":
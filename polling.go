@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// printPollingReport estimates how much of the measured wait time comes from DBOS
+// polling the queue rather than from tasks actually waiting for a worker. It replays the
+// run's arrivals and measured service times through an event-driven queue with the same
+// policy and capacity: the difference between the measured and replayed waits is the
+// dispatch latency added by polling (plus any other dispatch overhead).
+func printPollingReport(tasks []Task, policy SchedulingPolicy, queueCfg QueueConfig) {
+	if len(tasks) == 0 {
+		return
+	}
+	meanMeasured, meanReplayed := replayWaits(tasks, policy, queueCfg)
+	pollingWait := meanMeasured - meanReplayed
+
+	if queueCfg.Dispatch == "notify" {
+		fmt.Printf("\nDispatch latency (notify dispatch, fallback poll every %v):\n", notifyFallbackInterval)
+	} else {
+		fmt.Printf("\nPolling-induced latency (base interval %v, max interval %v):\n",
+			queueCfg.BasePollingInterval(), queueCfg.MaxPollingInterval())
+	}
+	fmt.Printf("  Mean wait time (measured): %.3f ms\n", float64(meanMeasured.Microseconds())/1000)
+	fmt.Printf("  Mean wait time (event-driven replay): %.3f ms\n", float64(meanReplayed.Microseconds())/1000)
+	fmt.Printf("  Estimated polling-induced wait: %.3f ms per task", float64(pollingWait.Microseconds())/1000)
+	if meanMeasured > 0 {
+		fmt.Printf(" (%.1f%% of measured wait)", 100*float64(pollingWait)/float64(meanMeasured))
+	}
+	fmt.Println()
+	if queueCfg.Dispatch == "notify" {
+		return
+	}
+	// A task arriving at a random time waits half a polling interval on average to be seen
+	fmt.Printf("  Expected from polling alone: ~%.3f ms per dispatch\n", float64(queueCfg.BasePollingInterval().Microseconds())/2000)
+}
+
+// replayWaits returns the mean measured wait of tasks and their mean wait in an
+// event-driven replay of the run's arrivals and measured service times, with the same
+// policy and capacity. Measured service time includes the workflow's own step overhead,
+// so only the dispatch path differs between the run and the replay.
+func replayWaits(tasks []Task, policy SchedulingPolicy, queueCfg QueueConfig) (time.Duration, time.Duration) {
+	service := func(task Task) time.Duration { return task.CompletionTime.Sub(task.DequeueTime) }
+	// The replay reuses the priorities the run gave its tasks: it neither learns sizes nor
+	// switches policies
+	policy.Estimator, policy.Adaptive = nil, nil
+	replayed := sched.SimulatePolicy(tasks, queueCfg.Capacity(), policy, service, nil, AppConfig.Watchdog.Policy(), nil, nil, nil).Waits
+
+	var measuredWait, replayedWait time.Duration
+	for i, task := range tasks {
+		measuredWait += task.DequeueTime.Sub(task.ArrivalTime)
+		replayedWait += replayed[i]
+	}
+	n := time.Duration(len(tasks))
+	return measuredWait / n, replayedWait / n
+}
+
+// dispatchLatency estimates the mean wait per task added by dispatch: the measured mean
+// wait minus the replayed one. Tasks replayed in simulation have none.
+func dispatchLatency(tasks []Task, policy SchedulingPolicy, queueCfg QueueConfig) time.Duration {
+	if len(tasks) == 0 {
+		return 0
+	}
+	measured, replayed := replayWaits(tasks, policy, queueCfg)
+	return measured - replayed
+}
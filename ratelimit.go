@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/sched"
+)
+
+// clientResponseTime returns the response time the client of a task saw, including the
+// time its tenant's rate limit held it
+func clientResponseTime(task Task) time.Duration {
+	return task.ResponseTime() + task.ThrottleDelay
+}
+
+// printRateLimitReport prints, for each tenant, its token bucket, how many of its tasks
+// were rejected or delayed, and the latency of the tasks that ran, in the queue and as
+// their client saw it. It prints nothing without a rate limit.
+func printRateLimitReport(limiter *sched.RateLimiter, tasks []Task) {
+	if limiter == nil {
+		return
+	}
+	mode := "reject"
+	if limiter.Delay {
+		mode = "delay"
+	}
+	fmt.Printf("\nRate limiting (token bucket per tenant, %s when empty):\n", mode)
+	fmt.Printf("  %-12s %8s %6s %8s %14s %8s %10s %10s %12s %12s\n", "Tenant", "Rate/s", "Burst", "Offered",
+		"Rejected", "Delayed", "Delay mean", "Delay max", "Resp p99", "Client p99")
+	stats := limiter.Stats()
+	var offered, rejected, delayed int
+	for _, tenant := range sortedKeys(stats) {
+		s := stats[tenant]
+		offered += s.Offered
+		rejected += s.Rejected
+		delayed += s.Delayed
+		rate, meanDelay := "-", 0.0
+		if s.Bucket.Rate > 0 {
+			rate = fmt.Sprintf("%g", s.Bucket.Rate)
+		}
+		if s.Delayed > 0 {
+			meanDelay = float64(s.TotalDelay.Microseconds()) / 1000 / float64(s.Delayed)
+		}
+		ran := func(task Task) bool { return task.TenantID == tenant }
+		response := summarizeResponseTimes(tasks, ran)
+		client := metrics.SummarizeTasks(tasks, ran, clientResponseTime)
+		name := tenant
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("  %-12s %8s %6d %8d %6d (%4.1f%%) %8d %10.1f %10.1f %12s %12s\n", name, rate, s.Bucket.Burst, s.Offered,
+			s.Rejected, 100*float64(s.Rejected)/float64(s.Offered), s.Delayed, meanDelay,
+			float64(s.MaxDelay.Microseconds())/1000, formatMs(response.P99), formatMs(client.P99))
+	}
+	if offered > 0 {
+		fmt.Printf("  Total: %d offered, %d rejected (%.1f%%), %d delayed\n", offered, rejected,
+			100*float64(rejected)/float64(offered), delayed)
+	}
+	fmt.Println("  (times in ms; client latency includes the time the rate limit held the task)")
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/sched"
+)
+
+// registerReservationReport reports at the end of the run how well the reservations
+// were honored
+func registerReservationReport(r *experimentRun, reservations *sched.Reservations) {
+	r.OnReport(func(outcome *runOutcome) error {
+		printReservationReport(reservations, outcome.completed)
+		return nil
+	})
+}
+
+// reservationEvent is a task arriving, starting or completing, for replaying how the
+// run used its slots
+type reservationEvent struct {
+	at   time.Time
+	kind int // reservationFinish, reservationArrive or reservationStart
+	task int
+}
+
+// Events at the same time replay slots freeing up first, then arrivals, then starts, so
+// that a task starting the instant it arrives finds the slot it took free
+const (
+	reservationFinish = iota
+	reservationArrive
+	reservationStart
+)
+
+// reservationStats is how one group of tasks fared under the reservations
+type reservationStats struct {
+	tasks    int
+	headroom int // Tasks that arrived while a reserved slot of their group was free for them
+	honored  int // Of those, tasks that started within the tolerance
+	spilled  int // Tasks that ran in a shared slot
+
+	busy   time.Duration // Reserved slot-time in use
+	wasted time.Duration // Reserved slot-time idle while tasks of other groups waited
+}
+
+// printReservationReport replays the run's slot usage from the tasks' arrival, start and
+// completion times, and prints, for each group with reserved slots, how often its
+// reservation was honored: how many of its tasks arrived with a reserved slot free for
+// them, and how many of those started within the tolerance. It also prints how many tasks
+// spilled over to the shared slots, and how much reserved capacity stayed idle while
+// tasks of other groups waited. It prints nothing without reservations.
+func printReservationReport(reservations *sched.Reservations, tasks []Task) {
+	if reservations == nil || len(tasks) == 0 {
+		return
+	}
+	cfg := AppConfig.Reservations
+	tolerance := time.Duration(cfg.ToleranceMs) * time.Millisecond
+
+	events := make([]reservationEvent, 0, 3*len(tasks))
+	for i, task := range tasks {
+		events = append(events,
+			reservationEvent{at: task.ArrivalTime, kind: reservationArrive, task: i},
+			reservationEvent{at: task.DequeueTime, kind: reservationStart, task: i},
+			reservationEvent{at: task.CompletionTime, kind: reservationFinish, task: i})
+	}
+	slices.SortStableFunc(events, func(a, b reservationEvent) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return a.kind - b.kind
+	})
+
+	usage := reservations.NewUsage()
+	stats := make(map[string]*reservationStats)
+	inUse := make(map[string]int) // Reserved slots in use, by group
+	inReserved := make([]bool, len(tasks))
+	waiting := make(map[string]int) // Tasks arrived but not started, by group
+	totalWaiting := 0
+	last := events[0].at
+	var span time.Duration
+	for _, event := range events {
+		// Account for the slot-time since the last event
+		if elapsed := event.at.Sub(last); elapsed > 0 {
+			span += elapsed
+			for group, slots := range reservations.Slots {
+				s := stats[group]
+				if s == nil {
+					s = &reservationStats{}
+					stats[group] = s
+				}
+				s.busy += time.Duration(inUse[group]) * elapsed
+				if totalWaiting > waiting[group] {
+					s.wasted += time.Duration(slots-inUse[group]) * elapsed
+				}
+			}
+			last = event.at
+		}
+
+		task := tasks[event.task]
+		group := reservations.Group(task)
+		s := stats[group]
+		if s == nil {
+			s = &reservationStats{}
+			stats[group] = s
+		}
+		switch event.kind {
+		case reservationArrive:
+			s.tasks++
+			if inUse[group]+waiting[group] < reservations.Slots[group] {
+				s.headroom++
+				if task.WaitTime() <= tolerance {
+					s.honored++
+				}
+			}
+			waiting[group]++
+			totalWaiting++
+		case reservationStart:
+			waiting[group]--
+			totalWaiting--
+			if usage.Start(task) {
+				inUse[group]++
+				inReserved[event.task] = true
+			} else {
+				s.spilled++
+			}
+		case reservationFinish:
+			if inReserved[event.task] {
+				inUse[group]--
+			}
+			usage.Finish(task)
+		}
+	}
+
+	fmt.Printf("\nReservations (by %s; %s; honored within %d ms):\n", cfg.By, reservations, cfg.ToleranceMs)
+	fmt.Printf("  %-14s %6s %7s %9s %16s %16s %8s %10s\n", "Group", "Slots", "Tasks", "Headroom",
+		"Honored", "Spilled", "Busy", "Wait p99")
+	var reservedTime, wasted time.Duration
+	for _, group := range sortedKeys(stats) {
+		s := stats[group]
+		slots := reservations.Slots[group]
+		wait := metrics.SummarizeTasks(tasks, func(task Task) bool { return reservations.Group(task) == group }, Task.WaitTime)
+		name := group
+		if name == "" {
+			name = "(none)"
+		}
+		honored, busy := "-", "-"
+		if slots > 0 {
+			honored = fmt.Sprintf("%d (%5.1f%%)", s.honored, percentOf(s.honored, s.headroom))
+			busy = fmt.Sprintf("%.1f%%", 100*s.busy.Seconds()/(float64(slots)*span.Seconds()))
+			reservedTime += time.Duration(slots) * span
+			wasted += s.wasted
+		}
+		fmt.Printf("  %-14s %6d %7d %9d %16s %8d (%4.1f%%) %8s %10s\n", name, slots, s.tasks, s.headroom, honored,
+			s.spilled, percentOf(s.spilled, s.tasks), busy, formatMs(wait.P99))
+	}
+	if reservedTime > 0 {
+		fmt.Printf("  Wasted capacity: %.1f of %.1f reserved slot-seconds (%.1f%%) idle while other groups' tasks waited\n",
+			wasted.Seconds(), reservedTime.Seconds(), 100*wasted.Seconds()/reservedTime.Seconds())
+	}
+	fmt.Println("  (headroom: tasks that arrived with a reserved slot free for them; honored: those that waited")
+	fmt.Println("   at most the tolerance; spilled: tasks that ran in a shared slot)")
+}
+
+// percentOf returns part as a percentage of total, or 0 when total is 0
+func percentOf(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// resourceSampleInterval is how often the resource monitor samples the memory of the
+// process
+const resourceSampleInterval = 500 * time.Millisecond
+
+// resourceTopStatements is how many statements the resource report lists
+const resourceTopStatements = 5
+
+// processCPUMetrics are the runtime metrics whose sum is the CPU time the process spent
+// running Go code, collecting garbage and returning memory to the OS
+var processCPUMetrics = []string{
+	"/cpu/classes/user:cpu-seconds",
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/scavenge/total:cpu-seconds",
+}
+
+// Memory metrics of the process: the live heap, and all the memory the Go runtime mapped
+const (
+	heapMetric    = "/memory/classes/heap/objects:bytes"
+	runtimeMetric = "/memory/classes/total:bytes"
+)
+
+// databaseStats are the counters of pg_stat_database for the queue's database
+type databaseStats struct {
+	Transactions int64
+	Inserted     int64
+	Updated      int64
+	Deleted      int64
+	Fetched      int64
+	BlocksRead   int64
+	BlocksHit    int64
+}
+
+// statementStats are the counters of pg_stat_statements for one statement
+type statementStats struct {
+	Query  string
+	Calls  int64
+	TimeMs float64
+	Rows   int64
+}
+
+// resourceSnapshot is the resource usage counters at one point of a run
+type resourceSnapshot struct {
+	at         time.Time
+	database   databaseStats
+	walLSN     string                   // Current WAL position, empty if unknown
+	statements map[int64]statementStats // By query ID, nil if pg_stat_statements isn't available
+	tables     map[string]tableStats    // Queue tables by name, nil if unknown
+	cpuSeconds float64                  // CPU time of the process so far
+}
+
+// resourceMonitor captures what a run cost the database and the process: the deltas of
+// the database's statistics and of pg_stat_statements between the start and the end of
+// the run, the WAL it wrote, how the queue tables grew and got vacuumed, and the CPU time
+// and peak memory of the process. The
+// database counters cover every session on the database, and Postgres publishes them
+// with a delay of up to a second, so they are approximate.
+type resourceMonitor struct {
+	pool     *pgxpool.Pool
+	start    resourceSnapshot
+	end      resourceSnapshot
+	wal      int64 // WAL written during the run
+	walErr   error // Why the WAL written is unknown
+	stmErr   error // Why pg_stat_statements is unavailable
+	endErr   error // Why the snapshot of the end failed
+	tableErr error // Why the statistics of the queue tables are unknown
+
+	mu          sync.Mutex
+	peakHeap    uint64
+	peakRuntime uint64
+	tablePeaks  map[string]tablePeak
+	tables      map[string]tableStats // At the last sample
+	vacuums     []vacuumSample
+	stop        chan struct{}
+	done        chan struct{}
+	stopped     bool
+}
+
+// startResourceMonitor takes the snapshot of the start of a run and starts sampling the
+// memory of the process
+func startResourceMonitor(ctx context.Context) (*resourceMonitor, error) {
+	cfg := AppConfig.Database
+	cfg.PoolMaxConns, cfg.PoolMinConns = 1, 0
+	pool, err := newPool(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	m := &resourceMonitor{pool: pool, stop: make(chan struct{}), done: make(chan struct{})}
+	if m.start, err = m.snapshot(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	go m.sample()
+	return m, nil
+}
+
+// snapshot reads the counters. Failing to read the WAL position or pg_stat_statements is
+// recorded rather than returned, as managed databases often restrict them.
+func (m *resourceMonitor) snapshot(ctx context.Context) (resourceSnapshot, error) {
+	s := resourceSnapshot{at: time.Now(), cpuSeconds: processCPUSeconds()}
+	err := m.pool.QueryRow(ctx, `
+		SELECT xact_commit + xact_rollback, tup_inserted, tup_updated, tup_deleted, tup_fetched, blks_read, blks_hit
+		FROM pg_stat_database WHERE datname = current_database()`).Scan(
+		&s.database.Transactions, &s.database.Inserted, &s.database.Updated, &s.database.Deleted,
+		&s.database.Fetched, &s.database.BlocksRead, &s.database.BlocksHit)
+	if err != nil {
+		return s, fmt.Errorf("failed to read pg_stat_database: %w", err)
+	}
+	if err := m.pool.QueryRow(ctx, `SELECT pg_current_wal_lsn()::text`).Scan(&s.walLSN); err != nil {
+		m.walErr = err
+	}
+	if s.tables, err = m.readQueueTables(ctx); err != nil {
+		m.tableErr = err
+	}
+
+	rows, err := m.pool.Query(ctx, `
+		SELECT coalesce(queryid, 0), left(regexp_replace(coalesce(query, ''), '\s+', ' ', 'g'), 100), calls, total_exec_time, rows
+		FROM pg_stat_statements WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())`)
+	if err != nil {
+		m.stmErr = err
+		return s, nil
+	}
+	defer rows.Close()
+	s.statements = make(map[int64]statementStats)
+	for rows.Next() {
+		var id int64
+		var stmt statementStats
+		if err := rows.Scan(&id, &stmt.Query, &stmt.Calls, &stmt.TimeMs, &stmt.Rows); err != nil {
+			m.stmErr = err
+			s.statements = nil
+			return s, nil
+		}
+		// Statements without a query ID are counted together
+		prev := s.statements[id]
+		stmt.Calls += prev.Calls
+		stmt.TimeMs += prev.TimeMs
+		stmt.Rows += prev.Rows
+		s.statements[id] = stmt
+	}
+	if err := rows.Err(); err != nil {
+		m.stmErr = err
+		s.statements = nil
+	}
+	return s, nil
+}
+
+// sample records the peak memory of the process, and the size of the queue tables and
+// the vacuums running on them, until the monitor stops
+func (m *resourceMonitor) sample() {
+	defer close(m.done)
+	ctx := context.Background()
+	ticker := time.NewTicker(resourceSampleInterval)
+	defer ticker.Stop()
+	m.sampleMemory()
+	for tick := 1; ; tick++ {
+		select {
+		case <-m.stop:
+			m.sampleMemory()
+			return
+		case <-ticker.C:
+			m.sampleMemory()
+			if tick%vacuumSampleTicks == 0 {
+				m.sampleVacuum(ctx)
+			}
+			if tick%tableSampleTicks == 0 {
+				m.sampleTables(ctx)
+			}
+		}
+	}
+}
+
+// sampleMemory records the peak memory of the process
+func (m *resourceMonitor) sampleMemory() {
+	samples := []metrics.Sample{{Name: heapMetric}, {Name: runtimeMetric}}
+	metrics.Read(samples)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		m.peakHeap = max(m.peakHeap, samples[0].Value.Uint64())
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		m.peakRuntime = max(m.peakRuntime, samples[1].Value.Uint64())
+	}
+}
+
+// heapBytes returns the size of the live heap of the process
+func heapBytes() uint64 {
+	samples := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return samples[0].Value.Uint64()
+}
+
+// processCPUSeconds returns the CPU time the process spent so far, as estimated by the Go
+// runtime. It leaves out time blocked in system calls.
+func processCPUSeconds() float64 {
+	samples := make([]metrics.Sample, len(processCPUMetrics))
+	for i, name := range processCPUMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	total := 0.0
+	for _, sample := range samples {
+		if sample.Value.Kind() == metrics.KindFloat64 {
+			total += sample.Value.Float64()
+		}
+	}
+	return total
+}
+
+// Stop takes the snapshot of the end of the run, stops sampling and closes the
+// monitor's connection. Stopping again, or a nil monitor, does nothing.
+func (m *resourceMonitor) Stop(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	if m.stopped {
+		return m.endErr
+	}
+	m.stopped = true
+	close(m.stop)
+	<-m.done
+	defer m.pool.Close()
+	if m.end, m.endErr = m.snapshot(ctx); m.endErr != nil {
+		return m.endErr
+	}
+	if m.walErr == nil {
+		m.walErr = m.pool.QueryRow(ctx, `SELECT pg_wal_lsn_diff($1::text::pg_lsn, $2::text::pg_lsn)::bigint`,
+			m.end.walLSN, m.start.walLSN).Scan(&m.wal)
+	}
+	return nil
+}
+
+// Print stops the monitor if it still runs, and reports the resource usage of the run,
+// in total and per completed task. The tasks, when known, tell the wait of the tasks that
+// started during a vacuum. A nil monitor prints nothing.
+func (m *resourceMonitor) Print(completed int, tasks []Task) {
+	if m == nil {
+		return
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		fmt.Printf("\nResource usage: %v\n", err)
+		return
+	}
+	perTask := func(value float64) float64 {
+		if completed == 0 {
+			return 0
+		}
+		return value / float64(completed)
+	}
+	start, end := m.start.database, m.end.database
+	transactions := end.Transactions - start.Transactions
+	written := (end.Inserted - start.Inserted) + (end.Updated - start.Updated) + (end.Deleted - start.Deleted)
+	read, hit := end.BlocksRead-start.BlocksRead, end.BlocksHit-start.BlocksHit
+	hitRatio := 0.0
+	if read+hit > 0 {
+		hitRatio = 100 * float64(hit) / float64(read+hit)
+	}
+
+	fmt.Printf("\nResource usage over %.1fs (%d completed tasks):\n", m.end.at.Sub(m.start.at).Seconds(), completed)
+	fmt.Printf("  Postgres: %d transactions (%.1f per task), tuples inserted %d, updated %d, deleted %d (%.1f written per task), fetched %d\n",
+		transactions, perTask(float64(transactions)), end.Inserted-start.Inserted, end.Updated-start.Updated,
+		end.Deleted-start.Deleted, perTask(float64(written)), end.Fetched-start.Fetched)
+	fmt.Printf("  Buffers: %d blocks read, %d hit (%.1f%% cache hits)\n", read, hit, hitRatio)
+	if m.walErr != nil {
+		fmt.Printf("  WAL: unknown (%s)\n", firstLine(m.walErr.Error()))
+	} else {
+		fmt.Printf("  WAL: %.2f MB written (%.1f KB per task)\n", float64(m.wal)/1e6, perTask(float64(m.wal))/1e3)
+	}
+	m.printStatements()
+	m.printTableReport(completed, tasks)
+	m.mu.Lock()
+	peakHeap, peakRuntime := m.peakHeap, m.peakRuntime
+	m.mu.Unlock()
+	cpu := m.end.cpuSeconds - m.start.cpuSeconds
+	fmt.Printf("  Process: %.2fs CPU (%.2f ms per task), peak heap %.1f MB, peak runtime memory %.1f MB\n",
+		cpu, perTask(cpu*1000), float64(peakHeap)/1e6, float64(peakRuntime)/1e6)
+	fmt.Println("  (database counters cover every session on the database and lag by up to a second; process")
+	fmt.Println("   CPU is the Go runtime's estimate, without time blocked in system calls)")
+}
+
+// printStatements prints the calls and execution time of pg_stat_statements during the
+// run, and the statements that took the most time
+func (m *resourceMonitor) printStatements() {
+	if m.start.statements == nil || m.end.statements == nil {
+		reason := "unavailable"
+		if m.stmErr != nil {
+			reason = firstLine(m.stmErr.Error())
+		}
+		fmt.Printf("  Statements: pg_stat_statements %s\n", reason)
+		return
+	}
+	var deltas []statementStats
+	var calls int64
+	var timeMs float64
+	for id, stmt := range m.end.statements {
+		prev := m.start.statements[id]
+		delta := statementStats{Query: stmt.Query, Calls: stmt.Calls - prev.Calls, TimeMs: stmt.TimeMs - prev.TimeMs, Rows: stmt.Rows - prev.Rows}
+		if delta.Calls <= 0 {
+			continue
+		}
+		calls += delta.Calls
+		timeMs += delta.TimeMs
+		deltas = append(deltas, delta)
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].TimeMs > deltas[j].TimeMs })
+	fmt.Printf("  Statements: %d calls, %.1f ms of execution, %d distinct statements\n", calls, timeMs, len(deltas))
+	for _, stmt := range deltas[:min(resourceTopStatements, len(deltas))] {
+		fmt.Printf("    %8d calls %10.1f ms %9d rows  %s\n", stmt.Calls, stmt.TimeMs, stmt.Rows, stmt.Query)
+	}
+}
+
+// firstLine returns the first line of a message
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return line
+}
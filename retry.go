@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// RetrySummary describes the retries of a group of tasks and their latency contribution
+type RetrySummary struct {
+	Tasks    int
+	Retried  int // Tasks that needed more than one attempt
+	Failed   int // Tasks that failed on their last attempt
+	Attempts int
+
+	TotalRetryDelay    time.Duration // Time spent in failed attempts and their backoff
+	TotalResponseTime  time.Duration
+	RetriedResponse    metrics.Summary // Response time of retried tasks
+	FirstTryResponse   metrics.Summary // Response time of tasks that succeeded on their first attempt
+	MeanRetriedRetries float64         // Mean retries of retried tasks
+}
+
+// RetryShare returns the share of the total response time spent in retries
+func (s RetrySummary) RetryShare() float64 {
+	if s.TotalResponseTime == 0 {
+		return 0
+	}
+	return float64(s.TotalRetryDelay) / float64(s.TotalResponseTime)
+}
+
+// summarizeRetries computes retry statistics of the tasks matching filter
+func summarizeRetries(tasks []Task, filter func(Task) bool) RetrySummary {
+	var summary RetrySummary
+	retried := func(task Task) bool { return (filter == nil || filter(task)) && task.Attempts > 1 }
+	firstTry := func(task Task) bool { return (filter == nil || filter(task)) && task.Attempts <= 1 && !task.Failed }
+	retries := 0
+	for _, task := range tasks {
+		if filter != nil && !filter(task) {
+			continue
+		}
+		summary.Tasks++
+		summary.Attempts += max(task.Attempts, 1)
+		summary.TotalRetryDelay += task.RetryDelay
+		summary.TotalResponseTime += task.ResponseTime()
+		if task.Attempts > 1 {
+			summary.Retried++
+			retries += task.Attempts - 1
+		}
+		if task.Failed {
+			summary.Failed++
+		}
+	}
+	if summary.Retried > 0 {
+		summary.MeanRetriedRetries = float64(retries) / float64(summary.Retried)
+	}
+	summary.RetriedResponse = metrics.SummarizeTasks(tasks, retried, Task.ResponseTime)
+	summary.FirstTryResponse = metrics.SummarizeTasks(tasks, firstTry, Task.ResponseTime)
+	return summary
+}
+
+// printRetryReport prints how many tasks were retried or failed, and how much of the
+// response time retries account for, overall and per class. It prints nothing when no
+// task was retried or failed.
+func printRetryReport(tasks []Task) {
+	all := summarizeRetries(tasks, nil)
+	if all.Retried == 0 && all.Failed == 0 {
+		return
+	}
+	fmt.Printf("\nRetries:\n")
+	for _, group := range []struct {
+		name    string
+		summary RetrySummary
+	}{
+		{"All", all},
+		{"Short", summarizeRetries(tasks, func(task Task) bool { return taskClass(task) == "short" })},
+		{"Long", summarizeRetries(tasks, func(task Task) bool { return taskClass(task) == "long" })},
+	} {
+		s := group.summary
+		if s.Tasks == 0 {
+			continue
+		}
+		fmt.Printf("  %s tasks: %d attempts for %d tasks, %d retried (%.1f retries each), %d failed, retries are %.1f%% of response time\n",
+			group.name, s.Attempts, s.Tasks, s.Retried, s.MeanRetriedRetries, s.Failed, s.RetryShare()*100)
+		if s.Retried > 0 {
+			fmt.Printf("    p99 response: %s ms retried vs %s ms first try\n",
+				formatMs(s.RetriedResponse.P99), formatMs(s.FirstTryResponse.P99))
+		}
+	}
+}
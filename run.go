@@ -0,0 +1,694 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/sched"
+	"fifo-queue-demo/workload"
+)
+
+// activeRun is the run in progress in this process, whose add-ons executors hand the
+// tasks they complete to, nil outside runs
+var activeRun atomic.Pointer[experimentRun]
+
+// experimentRun is a run of the workload against Postgres, from launching its executors
+// to printing its reports. Optional parts of a run, such as the kill switch, the soak
+// monitor or the autoscaler, aren't wired into it one by one: each registers hooks for
+// the stages of the run it follows when it starts, and the run calls the hooks of each
+// stage in the order they were registered.
+type experimentRun struct {
+	policy           SchedulingPolicy
+	queueCfg         QueueConfig
+	label            string
+	state            runState
+	cfg              WorkloadConfig // Workload sized for the run's arrival rate
+	avgTaskDuration  time.Duration
+	interArrivalTime time.Duration
+	cluster          *cluster
+	resources        *resourceMonitor
+	cancels          *canceller     // Cancels or abandons tasks for their clients, nil if none do
+	retrier          *clientRetrier // Resends the tasks that time out, nil if clients don't
+	backpressure     *Backpressure
+	rateLimit        *sched.RateLimiter // Holds tenants to their rate limits, nil without one
+	startTime        time.Time          // When tasks started arriving
+
+	// Closed by an add-on to stop the arrivals early, and to stop waiting for the tasks
+	// in flight. Without one they are nil, so never closed.
+	stopArrivals    <-chan struct{}
+	abortCollection <-chan struct{}
+
+	completedHooks []func(task Task)                 // An executor completed a task
+	arrivedHooks   []func()                          // The last task was enqueued
+	collectedHooks []func(tasks []Task) error        // Every task was collected; errors fail the run
+	reportHooks    []func(outcome *runOutcome) error // After the run's own reports
+	closeHooks     []func()                          // The run ended, successfully or not
+}
+
+// runOutcome is how a run ended, as its report hooks see it
+type runOutcome struct {
+	filename      string  // Results file
+	completed     []Task  // Tasks that completed, nil for streamed runs
+	numTasks      int     // Tasks of the workload
+	enqueued      int     // Tasks enqueued, fewer than the workload's if arrivals stopped early
+	unfinished    int     // Enqueued tasks the run stopped waiting for
+	workerSeconds float64 // Slot-seconds provisioned, which hooks that scale the capacity set
+}
+
+// OnCompleted registers a hook called by executors, concurrently, for every task they
+// complete. Hooks must be registered before the run starts enqueuing.
+func (r *experimentRun) OnCompleted(hook func(task Task)) {
+	r.completedHooks = append(r.completedHooks, hook)
+}
+
+// OnArrived registers a hook called once the last task was enqueued
+func (r *experimentRun) OnArrived(hook func()) {
+	r.arrivedHooks = append(r.arrivedHooks, hook)
+}
+
+// OnCollected registers a hook called with every task once they were all collected. An
+// error fails the run.
+func (r *experimentRun) OnCollected(hook func(tasks []Task) error) {
+	r.collectedHooks = append(r.collectedHooks, hook)
+}
+
+// OnReport registers a hook printing a report, after the run's own reports
+func (r *experimentRun) OnReport(hook func(outcome *runOutcome) error) {
+	r.reportHooks = append(r.reportHooks, hook)
+}
+
+// OnClose registers a hook called when the run ends, even if it failed, before the
+// hooks registered earlier
+func (r *experimentRun) OnClose(hook func()) {
+	r.closeHooks = append(r.closeHooks, hook)
+}
+
+// completed hands a task an executor completed to the run's hooks
+func (r *experimentRun) completed(task Task) {
+	for _, hook := range r.completedHooks {
+		hook(task)
+	}
+}
+
+// newExperimentRun shapes the workload of a run and records its state, or takes up the
+// state of the interrupted run resumed if it isn't nil
+func newExperimentRun(policy SchedulingPolicy, queueCfg QueueConfig, label string, resumed *runState) (*experimentRun, error) {
+	// Autoscaled runs launch the queue with the maximum capacity and scale within it
+	if AppConfig.Autoscaler.Enabled {
+		queueCfg = AppConfig.Autoscaler.QueueConfig(queueCfg)
+	}
+	r := &experimentRun{policy: policy, queueCfg: queueCfg, label: label}
+
+	// Offered load is spread over every worker slot the queue can use at once
+	r.avgTaskDuration, r.interArrivalTime = shapeWorkload(queueCfg)
+	r.cfg = sizeWorkload(r.interArrivalTime)
+	printRunBanner(policy, queueCfg, r.avgTaskDuration, r.interArrivalTime)
+
+	// Every run is identified by a run ID, which prefixes the workflow IDs of its tasks.
+	// A resumed run keeps the ID and the workload seed of the original run.
+	runName := policy.Name
+	if label != "" {
+		runName += "-" + label
+	}
+	now := time.Now()
+	r.state = runState{
+		RunID:     queueCfg.RunTagPrefix() + fmt.Sprintf("%s-%s", runName, now.Format("20060102T150405.000")),
+		Algorithm: policy.Name,
+		Label:     label,
+		Seed:      AppConfig.Workload.RunSeed(now),
+		StartTime: now,
+		Config:    AppConfig,
+	}
+	if resumed != nil {
+		r.state = *resumed
+	} else if err := saveRunState(r.state); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Run ID: %s (continue an interrupted run with -resume %s)\n", r.state.RunID, r.state.RunID)
+
+	// Untagged runs are tagged with their run ID so their executors only run their own
+	// tasks. Detached producers leave their tasks to executors started separately, which
+	// can only match an explicit tag.
+	if r.queueCfg.RunTag == "" && !AppConfig.Producer.NoWait {
+		r.queueCfg.RunTag = r.state.RunID
+	}
+	return r, nil
+}
+
+// launch starts the run's executors, calibrates the arrival rate if it is to be, and
+// starts the add-ons the configuration enables
+func (r *experimentRun) launch(resumed bool) error {
+	cluster, err := launchExecutors(r.policy, r.queueCfg)
+	if err != nil {
+		return err
+	}
+	r.cluster = cluster
+	r.OnClose(cluster.Shutdown)
+	runID := r.state.RunID
+
+	// The autoscaler's gate holds calibration tasks to the minimum capacity too. It only
+	// starts scaling once the run's other add-ons are up.
+	var autoscaler *Autoscaler
+	if AppConfig.Autoscaler.Enabled {
+		autoscaler = newAutoscaler(AppConfig.Autoscaler, r.policy.Priority)
+		autoscaler.register(r)
+	}
+
+	// Calibrated runs space arrivals so the load includes the queue's own overhead
+	if !resumed && AppConfig.Calibration.Enabled {
+		calibration, err := calibrate(cluster, runID, r.queueCfg, AppConfig.Calibration.NumTasks)
+		if err != nil {
+			return err
+		}
+		r.state.Calibration = &calibration
+		if err := saveRunState(r.state); err != nil {
+			return err
+		}
+	}
+	if r.state.Calibration != nil {
+		r.state.Calibration.Print(r.avgTaskDuration, r.interArrivalTime)
+		r.interArrivalTime = r.state.Calibration.InterArrival(r.avgTaskDuration, r.interArrivalTime)
+		r.cfg = sizeWorkload(r.interArrivalTime)
+	}
+
+	if adaptive := startAdaptiveMonitor(r.policy, cluster.queue, r.queueCfg.Capacity()); adaptive != nil {
+		adaptive.register(r)
+	}
+	if reservations := AppConfig.Reservations.Policy(r.queueCfg.Capacity()); reservations != nil {
+		registerReservationReport(r, reservations)
+	}
+	if AppConfig.Watchdog.Enabled {
+		watchdog, err := startWatchdog(AppConfig.Watchdog, r.policy, r.queueCfg, runID, cluster.monitor)
+		if err != nil {
+			return err
+		}
+		watchdog.register(r)
+	}
+	if AppConfig.KillSwitch.Enabled() {
+		kill, err := startKillSwitch(AppConfig.KillSwitch, r.policy, r.queueCfg, runID, cluster.queue, cluster.monitor)
+		if err != nil {
+			return err
+		}
+		kill.register(r)
+	}
+
+	// The first executor doubles as the producer
+	if r.cfg.CancelProbability > 0 || r.cfg.PatienceMs > 0 {
+		r.cancels, err = newCanceller(cluster.executors[0], runID, r.cfg.PatienceMs > 0, cluster.monitor)
+		if err != nil {
+			return err
+		}
+		r.OnClose(func() { r.cancels.Stop() })
+	}
+
+	// What the run costs the database and the process is measured from here until every
+	// task completed
+	r.resources, err = startResourceMonitor(context.Background())
+	if err != nil {
+		fmt.Printf("Warning: resource usage won't be reported: %v\n", err)
+	}
+	r.OnClose(func() { r.resources.Stop(context.Background()) })
+
+	// Soak runs are followed window by window while they run, rather than only once every
+	// task completed
+	if r.cfg.RunDuration() > 0 {
+		soak, err := startSoakMonitor(AppConfig.Soak, runID, monotonicNow(), cluster.queue, cluster.monitor, r.resources)
+		if err != nil {
+			return err
+		}
+		soak.register(r)
+	}
+	if autoscaler != nil {
+		autoscaler.Start(cluster.queue)
+	}
+
+	// Executors hand the tasks they complete to the add-ons from now on
+	activeRun.Store(r)
+	r.OnClose(func() { activeRun.CompareAndSwap(r, nil) })
+	return nil
+}
+
+// close ends the run, stopping what it started in the reverse order
+func (r *experimentRun) close() {
+	for _, hook := range slices.Backward(r.closeHooks) {
+		hook()
+	}
+}
+
+// produce generates the workload and enqueues its tasks at their arrival times, skipping
+// those a resumed run already enqueued. It returns the IDs of every task enqueued.
+func (r *experimentRun) produce() ([]int, error) {
+	cfg, cluster, runID := r.cfg, r.cluster, r.state.RunID
+
+	// Tasks arrive on their own goroutine, at their arrival times, and the producer hands
+	// them to the enqueuer
+	fmt.Printf("\nEnqueueing tasks to %s with respect to arrival times...\n", r.policy.QueueName)
+	r.startTime = monotonicNow()
+	shortCount := 0
+	longCount := 0
+	dedup := DedupStats{}
+	enqueuedIDs, err := readEnqueueLog(runID)
+	if err != nil {
+		return nil, err
+	}
+	enqueuer := newEnqueuer(cluster.queue, runID, AppConfig.Producer.EnqueueWorkers, &dedup)
+	if enqueuer.log, err = openEnqueueLog(runID); err != nil {
+		return nil, err
+	}
+	defer enqueuer.log.Close()
+	progressInterval := r.progressInterval()
+	r.backpressure = newBackpressure(AppConfig.Producer, cluster.queue)
+	generator := workload.NewGenerator(cfg, r.interArrivalTime, r.state.Seed)
+
+	// A resumed run regenerates the tasks it already enqueued and picks up after the last
+	// one. Tasks lost in a crash between being enqueued and logged aren't collected.
+	next, firstRetryID := 0, cfg.NumTasks
+	for _, taskID := range enqueuedIDs {
+		if taskID < cfg.NumTasks {
+			next = max(next, taskID+1)
+		}
+		firstRetryID = max(firstRetryID, taskID+1)
+	}
+	for range next {
+		task, _ := generator.Next()
+		if cfg.IsShort(task.Duration) {
+			shortCount++
+		} else {
+			longCount++
+		}
+	}
+	if next > 0 {
+		fmt.Printf("  %d tasks already enqueued, continuing from task %d\n", len(enqueuedIDs), next)
+	}
+
+	// Clients that time out resend their tasks under new IDs, above those of the workload
+	if clientRetry := AppConfig.ClientRetry.Policy(); clientRetry != nil {
+		r.retrier = newClientRetrier(cluster.executors[0], runID, clientRetry, enqueuer, firstRetryID)
+	}
+
+	// submit hands an admitted task over to the queue
+	submit := func(task Task) error {
+		// A client with backpressure checks the backlog before submitting
+		admitted, delay, err := r.backpressure.Admit(task.Duration)
+		if err != nil || !admitted {
+			return err
+		}
+		if delay > 0 {
+			task.ArrivalTime = monotonicNow()
+			task.BackpressureDelay = delay
+		}
+
+		enqueuer.Submit(task)
+		if err := enqueuer.Err(); err != nil {
+			return err
+		}
+		if r.cancels != nil {
+			r.cancels.Schedule(task)
+		}
+		if r.retrier != nil {
+			r.retrier.Track(task)
+		}
+		return nil
+	}
+
+	// Tasks their tenant's rate limit delays are held, in the order they are due, and
+	// submitted when their token is, between the arrivals of the other tasks
+	r.rateLimit = AppConfig.RateLimit.Policy()
+	var held []Task
+	release := func(until time.Time) error {
+		for len(held) > 0 && !held[0].ArrivalTime.After(until) {
+			task := held[0]
+			held = held[1:]
+			now := monotonicNow()
+			task.ThrottleDelay += now.Sub(task.ArrivalTime)
+			task.ArrivalTime = now
+			if err := submit(task); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	arrivals := startArrivals(generator, next, cfg.NumTasks, r.startTime, AppConfig.Metrics, cfg.HighResolution())
+	defer arrivals.Stop()
+	incoming, received := arrivals.Tasks(), next
+	for incoming != nil || len(held) > 0 {
+		// Wait for the next arrival, or for the first held task to come due
+		var due <-chan time.Time
+		if len(held) > 0 {
+			due = time.After(held[0].ArrivalTime.Sub(monotonicNow()))
+		}
+		var task Task
+		select {
+		case <-r.stopArrivals:
+			// No more tasks arrive, and those held are dropped
+			incoming, held = nil, nil
+			continue
+		case <-due:
+			if err := release(monotonicNow()); err != nil {
+				return nil, err
+			}
+			continue
+		case arrived, ok := <-incoming:
+			if !ok {
+				incoming = nil
+				continue
+			}
+			task = arrived
+		}
+		arrivals.Received(task)
+		if cfg.IsShort(task.Duration) {
+			shortCount++
+		} else {
+			longCount++
+		}
+		if received++; received%progressInterval == 0 {
+			fmt.Printf("  Generated %d/%d tasks...\n", received, cfg.NumTasks)
+		}
+		if err := release(task.ArrivalTime); err != nil {
+			return nil, err
+		}
+
+		// The tenant's rate limit rejects the task, or holds it until its token is due
+		if r.rateLimit != nil {
+			delay, admitted := r.rateLimit.Admit(task)
+			if !admitted {
+				continue
+			}
+			if delay > 0 {
+				task.ThrottleDelay = delay
+				task.ArrivalTime = task.ArrivalTime.Add(delay)
+				at, _ := slices.BinarySearchFunc(held, task, func(a, b Task) int { return a.ArrivalTime.Compare(b.ArrivalTime) })
+				held = slices.Insert(held, at, task)
+				continue
+			}
+		}
+
+		if err := submit(task); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wait for the last client retries and in-flight enqueues before reporting
+	if r.retrier != nil {
+		if err := r.retrier.Wait(); err != nil {
+			return nil, err
+		}
+	}
+	taskIDs, err := enqueuer.Close()
+	if err != nil {
+		return nil, err
+	}
+	taskIDs = append(enqueuedIDs, taskIDs...)
+
+	// Producers that don't wait leave collection to the collect command
+	if AppConfig.Producer.NoWait {
+		fmt.Printf("\nAll %d tasks enqueued. Not waiting for results; collect them later with:\n", len(taskIDs))
+		fmt.Printf("  go run . collect -algo %s -run %s -wait\n", r.policy.Name, runID)
+		return taskIDs, nil
+	}
+
+	for _, hook := range r.arrivedHooks {
+		hook()
+	}
+	select {
+	case <-r.stopArrivals:
+		fmt.Printf("\n%d of %d tasks enqueued before the arrivals were stopped. Processing the ones in flight...\n", len(taskIDs), cfg.NumTasks)
+	default:
+		fmt.Printf("\nAll %d tasks enqueued (%d short, %d long). Processing...\n", cfg.NumTasks, shortCount, longCount)
+	}
+	enqueuer.Print(r.interArrivalTime)
+	arrivals.Print()
+	if cfg.DuplicateProbability > 0 {
+		dedup.Print(cfg.TargetUtilization)
+	}
+	return taskIDs, nil
+}
+
+// progressInterval is how many tasks go by between progress lines
+func (r *experimentRun) progressInterval() int {
+	return max(10, r.cfg.NumTasks/10)
+}
+
+// collect waits for the tasks to complete, streaming their results to the CSV file as
+// they finish, then reports on the run. It returns every task, including those cancelled
+// or abandoned by their client, or none for streamed runs.
+func (r *experimentRun) collect(taskIDs []int) ([]Task, error) {
+	policy, runID := r.policy, r.state.RunID
+
+	// Stream results to the CSV file as tasks finish
+	filename, err := resultsFilename(policy.Name, r.label)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := newResultsWriter(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Close()
+
+	// Wait for all tasks to complete and collect results as they finish. Large runs are
+	// streamed: tasks only go to the CSV file and to latency histograms.
+	streaming := AppConfig.Metrics.Streaming(len(taskIDs))
+	stats := newStreamStats(AppConfig.Metrics, policy)
+	collector := newResultCollector(r.cluster.executors[0])
+	collector.discard = streaming
+	collector.abort = r.abortCollection
+	// The timings of the tasks' workflow steps are read from the DBOS system tables, over
+	// a connection of their own
+	stepsDB := AppConfig.Database
+	stepsDB.PoolMaxConns, stepsDB.PoolMinConns = 1, 0
+	if collector.steps, err = newPool(context.Background(), stepsDB); err != nil {
+		return nil, err
+	}
+	defer collector.steps.Close()
+	r.cluster.monitor.Add("step-timings", collector.steps)
+	var hdrLog *hdrLogWriter
+	if AppConfig.Metrics.HdrLog {
+		hdrLog, err = newHdrLogWriter(strings.TrimSuffix(filename, ".csv")+".hlog", AppConfig.Metrics, r.startTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Streamed runs keep no tasks to cut into latency windows afterwards
+	var windows *metrics.Windows
+	if streaming {
+		windows = newLatencyWindows(r.startTime)
+	}
+	webhook := newWebhookNotifier(AppConfig.Webhook, runID, policy.Name)
+	// Cancelled and abandoned tasks returned no result to their client, so they are left
+	// out of the results file and latency stats, but downstream systems hear about them
+	completedCount := 0
+	onResult := func(task Task) error {
+		if webhook != nil {
+			webhook.Notify(task)
+		}
+		if !task.Completed() {
+			return nil
+		}
+		completedCount++
+		stats.Record(task)
+		if windows != nil {
+			windows.Record(task)
+		}
+		if hdrLog != nil {
+			if err := hdrLog.Record(task); err != nil {
+				return err
+			}
+		}
+		return writer.Write(task)
+	}
+	progressInterval := r.progressInterval()
+	nextProgress := progressInterval
+	collected := 0
+	allTasks, err := collector.Collect(runID, taskIDs, onResult, func(done, total int) {
+		collected = done
+		for done >= nextProgress {
+			fmt.Printf("  Completed %d/%d tasks...\n", nextProgress, total)
+			nextProgress += progressInterval
+		}
+	})
+	if webhook != nil {
+		webhook.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range r.collectedHooks {
+		if err := hook(allTasks); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.resources.Stop(context.Background()); err != nil {
+		fmt.Printf("Warning: failed to measure the resource usage of the run: %v\n", err)
+	}
+	if r.cancels != nil {
+		if err := r.cancels.Stop(); err != nil {
+			return nil, err
+		}
+		r.cancels.Mark(allTasks)
+	}
+	if r.retrier != nil {
+		sched.MarkTimedOut(allTasks)
+	}
+
+	// A run whose collection was aborted keeps its state, so the tasks still unfinished
+	// can be collected once they are, and it isn't reported as a success
+	unfinished := len(taskIDs) - collected
+	select {
+	case <-r.abortCollection:
+	default:
+		unfinished = 0
+	}
+	if unfinished > 0 {
+		fmt.Printf("\nCollection aborted: %d of %d tasks collected, %d still unfinished\n", collected, len(taskIDs), unfinished)
+		fmt.Printf("Run state kept; collect the unfinished tasks once they finish with:\n")
+		fmt.Printf("  go run . collect -algo %s -run %s -wait\n", policy.Name, runID)
+	} else {
+		fmt.Printf("\nAll %d tasks completed!\n", len(taskIDs))
+		removeRunState(runID)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if hdrLog != nil {
+		if err := hdrLog.Close(); err != nil {
+			return nil, err
+		}
+	}
+	outcome := &runOutcome{
+		filename:   filename,
+		numTasks:   r.cfg.NumTasks,
+		enqueued:   len(taskIDs),
+		unfinished: unfinished,
+	}
+	if streaming {
+		stats.Print()
+		r.cluster.monitor.Print()
+		r.resources.Print(completedCount, nil)
+		if err := reportLatencyWindows(windows, filename); err != nil {
+			return nil, err
+		}
+		if err := r.runReportHooks(outcome); err != nil {
+			return nil, err
+		}
+		fmt.Printf("\nStreamed run: per-task reports (SLOs, fairness, jobs, deadlines, starvation, departures, pipeline stages, locks, watchdog, dead letters, cancellations, abandonment, client retries, convoys, head-of-line blocking, executors, decision log, timeline, polling, cost) and exporters other than csv were skipped; analyze %s for them.\n", filename)
+		printDemoCompleted(outcome)
+		return nil, nil
+	}
+	if err := r.report(allTasks, outcome, completedCount); err != nil {
+		return nil, err
+	}
+	printDemoCompleted(outcome)
+	return allTasks, nil
+}
+
+// runReportHooks calls the report hooks of the run
+func (r *experimentRun) runReportHooks(outcome *runOutcome) error {
+	for _, hook := range r.reportHooks {
+		if err := hook(outcome); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// report exports the results of a run that kept its tasks and prints its reports. Reports
+// cover the tasks that completed; the cancellation and abandonment reports cover them all.
+func (r *experimentRun) report(allTasks []Task, outcome *runOutcome, completedCount int) error {
+	policy, queueCfg, cfg, filename := r.policy, r.queueCfg, r.cfg, outcome.filename
+	if r.backpressure.Enabled() {
+		r.backpressure.Print(allTasks)
+	}
+	completedTasks, _ := splitCompleted(allTasks)
+	outcome.completed = completedTasks
+	outcome.workerSeconds = fixedWorkerSeconds(completedTasks, queueCfg.Capacity())
+
+	// Print the summary over every task. Starved, boosted and timed-out tasks are only
+	// known now, so the CSV file is rewritten with their flags if there are any.
+	starvation := detectStarvation(completedTasks, AppConfig.Starvation, policy.Priority)
+	boosted := slices.ContainsFunc(completedTasks, func(task Task) bool { return task.Boosted })
+	if starvation.Starved > 0 || boosted || r.retrier != nil {
+		if err := metrics.RewriteResults(completedTasks, filename); err != nil {
+			return err
+		}
+	}
+	if err := exportReport(newReport(completedTasks, policy, r.label, filename), true); err != nil {
+		return err
+	}
+	if err := exportDepartures(completedTasks, departuresFilename(filename)); err != nil {
+		return err
+	}
+	if err := exportSizes(completedTasks, filename); err != nil {
+		return err
+	}
+	if err := exportPredictions(completedTasks, policy, predictionsFilename(filename)); err != nil {
+		return err
+	}
+	if err := exportTaskLogs(allTasks, policy, filename); err != nil {
+		return err
+	}
+	if err := printExecutorReport(allTasks, queueCfg, filename); err != nil {
+		return err
+	}
+	if err := exportDeadLetters(completedTasks, deadLetterFilename(filename)); err != nil {
+		return err
+	}
+	printSummary(completedTasks, policy)
+	printWaitBreakdown(completedTasks)
+	printDepartureReport(completedTasks)
+	printSlowdownBySizeReport(completedTasks)
+	printStepTimingReport(completedTasks)
+	printPipelineReport(completedTasks)
+	printLockReport(completedTasks, policy)
+	sloResults := evaluateSLOs(completedTasks, AppConfig.SLOs)
+	printSLOReport(sloResults)
+	printFairnessReport(completedTasks)
+	printJobReport(completedTasks)
+	printDeadlineReport(completedTasks)
+	printRetryReport(completedTasks)
+	printDeadLetterReport(completedTasks)
+	printCancellationReport(allTasks, cfg.TargetUtilization)
+	printAbandonmentReport(allTasks)
+	printClientRetryReport(completedTasks, AppConfig.ClientRetry, cfg.TargetUtilization)
+	printStepReport(completedTasks)
+	printPhaseReport(completedTasks)
+	printOverloadReport(completedTasks)
+	printConvoyReport(allTasks, policy)
+	printHOLReport(allTasks, policy)
+	printPredictionReport(completedTasks, policy)
+	printAgentReport(policy)
+	printBatchReport(policy)
+	printFairShareReport(completedTasks, policy)
+	printRateLimitReport(r.rateLimit, completedTasks)
+	printColdStartReport(AppConfig.ColdStart.Policy(), completedTasks)
+	starvation.Print()
+	printWatchdogReport(completedTasks, AppConfig.Watchdog)
+	printPollingReport(completedTasks, policy, queueCfg)
+	r.cluster.monitor.Print()
+	r.resources.Print(completedCount, completedTasks)
+	if err := r.runReportHooks(outcome); err != nil {
+		return err
+	}
+	if AppConfig.Cost.Enabled() {
+		computeCost(AppConfig.Cost, completedTasks, outcome.workerSeconds, sloResults).Print()
+	}
+	return nil
+}
+
+// printDemoCompleted prints the banner closing a run, which only reports success if the
+// run waited for every task
+func printDemoCompleted(outcome *runOutcome) {
+	fmt.Println("\n============================================================")
+	if outcome.unfinished > 0 {
+		fmt.Printf("Demo aborted: results cover %d of %d enqueued tasks\n", outcome.enqueued-outcome.unfinished, outcome.enqueued)
+	} else {
+		fmt.Println("Demo completed successfully!")
+	}
+	fmt.Println("============================================================")
+}
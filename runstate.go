@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runStateDir holds the state of the runs that haven't completed yet
+var runStateDir = filepath.Join("results", "runs")
+
+// runState is what a run persists so it can be resumed after an interruption. The seed
+// regenerates the exact same workload, and the configuration is the one the run started
+// with, whatever the config file says by the time the run is resumed.
+type runState struct {
+	RunID     string    `yaml:"run_id"`
+	Algorithm string    `yaml:"algorithm"`
+	Label     string    `yaml:"label"`
+	Seed      int64     `yaml:"seed"`
+	StartTime time.Time `yaml:"start_time"`
+	Config    Config    `yaml:"config"`
+	// Overhead measured by the calibration phase, nil if the run wasn't calibrated. A
+	// resumed run keeps it, so its arrivals keep their spacing.
+	Calibration *Calibration `yaml:"calibration,omitempty"`
+}
+
+func runStatePath(runID string) string {
+	return filepath.Join(runStateDir, runID+".yaml")
+}
+
+// enqueueLogPath is the file listing the IDs of the tasks of a run enqueued so far
+func enqueueLogPath(runID string) string {
+	return filepath.Join(runStateDir, runID+".enqueued")
+}
+
+// saveRunState writes the state of a new run
+func saveRunState(state runState) error {
+	if err := os.MkdirAll(runStateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create run state directory: %w", err)
+	}
+	state.Config.Profiles = nil
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode run state: %w", err)
+	}
+	return os.WriteFile(runStatePath(state.RunID), data, 0644)
+}
+
+// loadRunState reads the state of an interrupted run
+func loadRunState(runID string) (*runState, error) {
+	data, err := os.ReadFile(runStatePath(runID))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no state for run %s in %s; it completed or never started", runID, runStateDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state: %w", err)
+	}
+	var state runState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse run state: %w", err)
+	}
+	return &state, nil
+}
+
+// removeRunState deletes the state of a run once its results are collected
+func removeRunState(runID string) {
+	os.Remove(runStatePath(runID))
+	os.Remove(enqueueLogPath(runID))
+}
+
+// enqueueLog appends the ID of every enqueued task to the run's log, so a resumed run
+// knows which tasks to collect and where to pick up. Every line is flushed right away;
+// the enqueuer serializes calls.
+type enqueueLog struct {
+	file *os.File
+}
+
+func openEnqueueLog(runID string) (*enqueueLog, error) {
+	file, err := os.OpenFile(enqueueLogPath(runID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open enqueue log: %w", err)
+	}
+	return &enqueueLog{file: file}, nil
+}
+
+func (l *enqueueLog) Append(taskID int) error {
+	_, err := l.file.WriteString(strconv.Itoa(taskID) + "\n")
+	return err
+}
+
+func (l *enqueueLog) Close() error {
+	return l.file.Close()
+}
+
+// readEnqueueLog returns the IDs of the tasks of a run enqueued so far. A line cut short
+// by a crash is ignored.
+func readEnqueueLog(runID string) ([]int, error) {
+	file, err := os.Open(enqueueLogPath(runID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read enqueue log: %w", err)
+	}
+	defer file.Close()
+
+	var taskIDs []int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		taskID, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			continue
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	return taskIDs, scanner.Err()
+}
+
+// resumeRun continues an interrupted run: it enqueues the tasks the run didn't get to,
+// with the configuration the run started with, then collects the results of every task
+func resumeRun(runID string) error {
+	state, err := loadRunState(runID)
+	if err != nil {
+		return err
+	}
+	AppConfig = state.Config
+	if err := validateForRun(); err != nil {
+		return err
+	}
+	policy, err := lookupPolicy(state.Algorithm)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Resuming run %s, started %s, with its original configuration\n", state.RunID, state.StartTime.Format(time.RFC3339))
+	_, err = runExperimentFrom(policy, AppConfig.Queue, state.Label, state)
+	return err
+}
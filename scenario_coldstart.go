@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// coldStartSwitchesMs are the setups of switching classes swept by the cold-start
+// scenario, from free switches to switches costing a good part of a short task
+var coldStartSwitchesMs = []int{0, 25, 50, 100, 200}
+
+// coldStartScenario runs FCFS, SJF and class-affinity batching as switching classes gets
+// more expensive for the workers. FCFS and SJF switch whenever the next task is of the
+// other class; batching runs tasks of a class back to back, trading some ordering for
+// fewer setups.
+func coldStartScenario() error {
+	savedColdStart := AppConfig.ColdStart
+	defer func() { AppConfig.ColdStart = savedColdStart }()
+
+	type result struct {
+		policy     string
+		switchMs   int
+		response   ResponseSummary
+		shortP99   time.Duration
+		setupShare float64 // Share of the worker time spent on setups
+	}
+	var results []result
+
+	for _, switchMs := range coldStartSwitchesMs {
+		AppConfig.ColdStart.SwitchMs = switchMs
+		policies := []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF), batchPolicy(AppConfig.Algorithms)}
+		for _, policy := range policies {
+			tasks, err := runExperiment(policy, AppConfig.Queue, fmt.Sprintf("switch%dms", switchMs))
+			if err != nil {
+				return fmt.Errorf("%s with %d ms switches: %w", policy.Name, switchMs, err)
+			}
+			var setup, busy time.Duration
+			for _, task := range tasks {
+				setup += task.Setup
+				busy += task.CompletionTime.Sub(task.DequeueTime)
+			}
+			r := result{
+				policy:   policy.Name,
+				switchMs: switchMs,
+				response: summarizeResponseTimes(tasks, nil),
+				shortP99: summarizeResponseTimes(tasks, func(task Task) bool { return taskClass(task) == "short" }).P99,
+			}
+			if busy > 0 {
+				r.setupShare = setup.Seconds() / busy.Seconds()
+			}
+			results = append(results, r)
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Cold starts (utilization %.0f%%, batches of up to %d tasks)\n",
+		AppConfig.Workload.TargetUtilization*100, AppConfig.Algorithms.Batch.withDefaults().Size)
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %10s %12s %12s %12s %8s\n", "Policy", "Switch ms", "Resp mean", "Resp p99", "Short p99", "Setup")
+	for _, r := range results {
+		fmt.Printf("%-8s %10d %12s %12s %12s %7.1f%%\n", r.policy, r.switchMs,
+			formatMs(r.response.Mean), formatMs(r.response.P99), formatMs(r.shortP99), 100*r.setupShare)
+	}
+	fmt.Println("(response times in ms; setup: share of the worker time spent on setups)")
+	return nil
+}
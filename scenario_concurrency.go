@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/sched"
+)
+
+// globalConcurrencyScenario runs every policy twice with the same total capacity spread
+// over several executors: once with per-worker limits only, and once with a single global
+// limit. Per-worker limits let each executor dequeue on its own, while a global limit makes
+// all executors share one budget, which changes how strictly priorities are honored.
+func globalConcurrencyScenario() error {
+	base := AppConfig.Queue
+	if base.NumExecutors < 2 {
+		base.NumExecutors = 2
+	}
+	capacity := base.WorkerConcurrency * base.NumExecutors
+
+	perWorker := base
+	perWorker.GlobalConcurrency = 0
+
+	global := base
+	global.WorkerConcurrency = capacity
+	global.GlobalConcurrency = capacity
+
+	modes := []struct {
+		label string
+		queue QueueConfig
+	}{
+		{"worker", perWorker},
+		{"global", global},
+	}
+
+	type result struct {
+		policy string
+		mode   string
+		short  ResponseSummary
+		long   ResponseSummary
+		cost   CostBreakdown
+	}
+	var results []result
+
+	isShort := func(task Task) bool { return AppConfig.Workload.IsShort(task.Duration) }
+	isLong := func(task Task) bool { return !AppConfig.Workload.IsShort(task.Duration) }
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		for _, mode := range modes {
+			tasks, err := runExperiment(policy, mode.queue, mode.label)
+			if err != nil {
+				return fmt.Errorf("%s with %s limits: %w", policy.Name, mode.label, err)
+			}
+			results = append(results, result{
+				policy: policy.Name,
+				mode:   mode.label,
+				short:  summarizeResponseTimes(tasks, isShort),
+				long:   summarizeResponseTimes(tasks, isLong),
+				cost: computeCost(AppConfig.Cost, tasks, fixedWorkerSeconds(tasks, mode.queue.Capacity()),
+					evaluateSLOs(tasks, AppConfig.SLOs)),
+			})
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Global vs per-worker concurrency (%d executors, capacity %d)\n", base.NumExecutors, capacity)
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %-8s %12s %12s %12s %12s %10s\n", "Policy", "Limits", "Short p50", "Short p99", "Long p50", "Long p99", "Cost")
+	for _, r := range results {
+		fmt.Printf("%-8s %-8s %12s %12s %12s %12s %10.4f\n", r.policy, r.mode,
+			formatMs(r.short.Median), formatMs(r.short.P99), formatMs(r.long.Median), formatMs(r.long.P99), r.cost.Total)
+	}
+	fmt.Println("(response times in ms)")
+	return nil
+}
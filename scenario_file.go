@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"fifo-queue-demo/workload"
+)
+
+// ScenarioFile is a scenario defined in a YAML file rather than in code: a sequence of
+// phases run back to back in one run of each algorithm, on the current configuration
+// with the file's overrides
+type ScenarioFile struct {
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	Algorithms  []string         `yaml:"algorithms"` // Every algorithm when empty
+	Config      Config           `yaml:"config"`     // Merged over the configuration, like a profile
+	Phases      []workload.Phase `yaml:"phases"`
+}
+
+// isScenarioFile tells whether a -scenario value names a scenario file rather than a
+// built-in scenario
+func isScenarioFile(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
+
+// loadScenarioFile reads and parses a scenario file
+func loadScenarioFile(path string) (ScenarioFile, error) {
+	var s ScenarioFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("failed to read scenario %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if len(s.Phases) == 0 {
+		return s, fmt.Errorf("scenario %s has no phases", path)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(strings.TrimSuffix(path, ".yaml"), ".yml")
+	}
+	return s, nil
+}
+
+// runScenarioFile runs the phases of a scenario file on each of its algorithms, and
+// compares the latency of every phase. The configuration is restored afterwards.
+func runScenarioFile(path string) error {
+	s, err := loadScenarioFile(path)
+	if err != nil {
+		return err
+	}
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	mergeConfig(&AppConfig, s.Config)
+	AppConfig.Workload.Phases = s.Phases
+	AppConfig.Workload.UtilizationSteps = nil
+	AppConfig.Workload.OverloadDurationMs = 0
+	if err := AppConfig.Validate(); err != nil {
+		return fmt.Errorf("scenario %s: %w", path, err)
+	}
+
+	names := s.Algorithms
+	if len(names) == 0 {
+		names = sortedKeys(algorithms)
+	}
+	policies := make([]SchedulingPolicy, len(names))
+	for i, name := range names {
+		if policies[i], err = lookupPolicy(name); err != nil {
+			return fmt.Errorf("scenario %s: %w", path, err)
+		}
+	}
+
+	fmt.Printf("Scenario %s", s.Name)
+	if s.Description != "" {
+		fmt.Printf(": %s", s.Description)
+	}
+	fmt.Printf("\n  Phases: %s\n", formatPhases(AppConfig.Workload))
+
+	type result struct {
+		algorithm string
+		phases    []PhaseSummary
+	}
+	var results []result
+	for i, policy := range policies {
+		tasks, err := runExperiment(policy, AppConfig.Queue, s.Name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", names[i], err)
+		}
+		results = append(results, result{names[i], summarizePhases(tasks)})
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Scenario %s\n", s.Name)
+	fmt.Println("============================================================")
+	fmt.Printf("%-16s %-10s %6s %6s %12s %12s %12s %12s\n", "Phase", "Algorithm", "Util", "Tasks",
+		"Resp mean", "Resp p99", "Short p99", "Wait p99")
+	for phase := range s.Phases {
+		for _, r := range results {
+			p := r.phases[phase]
+			fmt.Printf("%-16s %-10s %5.0f%% %6d %12s %12s %12s %12s\n", p.Name, r.algorithm, p.Utilization*100,
+				p.Response.Count, formatMs(p.Response.Mean), formatMs(p.Response.P99), formatMs(p.Short.P99), formatMs(p.Wait.P99))
+		}
+	}
+	fmt.Println("(times in ms)")
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// Load of the foreground/background scenario: the interactive class offers a steady
+// share of the worker slots, while the batch class offers more and more on top
+const foregroundInteractiveUtilization = 0.3
+
+var foregroundBatchUtilizations = []float64{0.1, 0.3, 0.5, 0.65}
+
+// foregroundMinSlots is the fewest worker slots the foreground/background scenario runs
+// with, so that a quarter of them can be held back for interactive tasks
+const foregroundMinSlots = 4
+
+// foregroundMinTasks is the fewest tasks of each run, so that even at the highest batch
+// load there are enough interactive tasks for their p99
+const foregroundMinTasks = 1000
+
+// foregroundDeadlineFactor is the deadline of interactive tasks, in task durations, when
+// the workload sets none
+const foregroundDeadlineFactor = 5
+
+// foregroundScenario plays an interactive class of short tasks with tight deadlines and
+// a batch class of long tasks sharing the same workers, and raises the batch load while
+// the interactive load stays the same. It compares sharing one FCFS queue with serving
+// interactive tasks first, on one queue with priorities or on a queue of their own
+// weighted against the batch queue, and with holding back a quarter of the worker slots
+// as headroom only interactive tasks can use, in arrival order or with priorities. Each
+// policy is judged by the p99 response time and deadline misses of interactive tasks,
+// and what it costs the batch class.
+func foregroundScenario() error {
+	savedWorkload, savedQueue, savedReservations := AppConfig.Workload, AppConfig.Queue, AppConfig.Reservations
+	defer func() {
+		AppConfig.Workload, AppConfig.Queue, AppConfig.Reservations = savedWorkload, savedQueue, savedReservations
+	}()
+	w := &AppConfig.Workload
+	w.ServiceTimeMeanMs, w.ServiceTimeMeanUs, w.ServiceTimeSCV = 0, 0, 0
+	w.OverloadDurationMs, w.Phases, w.UtilizationSteps, w.Duration = 0, nil, nil, ""
+	w.NumTasks = max(w.NumTasks, foregroundMinTasks)
+	if w.DeadlineFactor == 0 {
+		w.DeadlineFactor = foregroundDeadlineFactor
+	}
+	// Every run gets the same arrivals for the same batch load
+	w.Seed = int(w.RunSeed(time.Now()))
+	if AppConfig.Queue.Capacity() < foregroundMinSlots {
+		AppConfig.Queue.WorkerConcurrency = (foregroundMinSlots + AppConfig.Queue.NumExecutors - 1) / AppConfig.Queue.NumExecutors
+		AppConfig.Queue.GlobalConcurrency = 0
+	}
+	// Lanes and reservations both need the executors to poll DBOS queues
+	AppConfig.Queue.Dispatch = "polling"
+	capacity := AppConfig.Queue.Capacity()
+	headroom := max(1, capacity/4)
+	interactive := func(task Task) bool { return taskClass(task) == "short" }
+	batch := func(task Task) bool { return taskClass(task) == "long" }
+
+	weighted := AppConfig.Algorithms
+	weighted.SJFLanes.Mode = "weighted"
+	runs := []struct {
+		name     string
+		policy   SchedulingPolicy
+		headroom bool
+	}{
+		{"shared fcfs", sched.FCFS(), false},
+		{"priority", sjfPolicy(AppConfig.Algorithms.SJF), false},
+		{"two queues " + sjfLanesPolicy(weighted).Lanes.Mode(), sjfLanesPolicy(weighted), false},
+		{"headroom fcfs", sched.FCFS(), true},
+		{"headroom priority", sjfPolicy(AppConfig.Algorithms.SJF), true},
+	}
+
+	type result struct {
+		batchLoad   float64
+		policy      string
+		interactive ResponseSummary
+		deadlines   DeadlineSummary
+		batch       ResponseSummary
+		throughput  float64 // Batch tasks completed per second
+	}
+	var results []result
+
+	for i, batchLoad := range foregroundBatchUtilizations {
+		// The short/long mix whose classes offer the interactive and the batch load
+		shortRate := foregroundInteractiveUtilization / float64(w.ShortTaskDuration())
+		longRate := batchLoad / float64(w.LongTaskDuration())
+		w.ShortTaskProbability = shortRate / (shortRate + longRate)
+		w.TargetUtilization = foregroundInteractiveUtilization + batchLoad
+
+		for _, run := range runs {
+			AppConfig.Reservations = savedReservations
+			AppConfig.Reservations.Slots = ""
+			if run.headroom {
+				AppConfig.Reservations.By = "class"
+				AppConfig.Reservations.Slots = fmt.Sprintf("short=%d", headroom)
+			}
+			label := fmt.Sprintf("fg%d-%s", i+1, strings.ReplaceAll(run.name, " ", "-"))
+			tasks, err := runExperiment(run.policy, AppConfig.Queue, label)
+			if err != nil {
+				return fmt.Errorf("%s at batch load %.0f%%: %w", run.name, batchLoad*100, err)
+			}
+			r := result{
+				batchLoad:   batchLoad,
+				policy:      run.name,
+				interactive: summarizeResponseTimes(tasks, interactive),
+				deadlines:   summarizeDeadlines(tasks, interactive),
+				batch:       summarizeResponseTimes(tasks, batch),
+			}
+			var first, last time.Time
+			completed := 0
+			for _, task := range tasks {
+				if first.IsZero() || task.ArrivalTime.Before(first) {
+					first = task.ArrivalTime
+				}
+				if !batch(task) {
+					continue
+				}
+				completed++
+				if task.CompletionTime.After(last) {
+					last = task.CompletionTime
+				}
+			}
+			if span := last.Sub(first); span > 0 {
+				r.throughput = float64(completed) / span.Seconds()
+			}
+			results = append(results, r)
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Foreground/background (interactive load %.0f%%, %d worker slots, %d held back as headroom)\n",
+		foregroundInteractiveUtilization*100, capacity, headroom)
+	fmt.Println("============================================================")
+	fmt.Printf("%-10s %-24s %12s %12s %10s %12s %12s\n", "Batch load", "Policy", "Inter. p50", "Inter. p99",
+		"Missed", "Batch mean", "Batch/s")
+	for i, r := range results {
+		if i > 0 && r.batchLoad != results[i-1].batchLoad {
+			fmt.Println()
+		}
+		fmt.Printf("%9.0f%% %-24s %12s %12s %9.1f%% %12s %12.2f\n", r.batchLoad*100, r.policy, formatMs(r.interactive.Median),
+			formatMs(r.interactive.P99), 100*r.deadlines.MissRatio(), formatMs(r.batch.Mean), r.throughput)
+	}
+	fmt.Printf("(response times in ms; interactive tasks are the short ones, due %gx their duration after they arrive,\n", w.DeadlineFactor)
+	fmt.Println(" and missed is the share that completed after; batch/s is the batch tasks completed per second)")
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/sched"
+)
+
+// defaultForkJoinFanOut is the fan-out of the fork-join scenario when workload.fan_out
+// isn't set
+const defaultForkJoinFanOut = 4
+
+// forkJoinScenario runs every policy twice on the same offered load: once with each
+// request as a single task, then with each request fanned out into parallel tasks that
+// join. It compares the request latency of both, so the parallel speedup can be weighed
+// against the cost of waiting for the slowest task of each request.
+func forkJoinScenario() error {
+	savedWorkload := AppConfig.Workload
+	defer func() { AppConfig.Workload = savedWorkload }()
+	fanOut := AppConfig.Workload.FanOut
+	if fanOut <= 1 {
+		fanOut = defaultForkJoinFanOut
+	}
+	AppConfig.Workload.TasksPerJob = 0
+
+	type result struct {
+		policy   string
+		single   ResponseSummary // Response time of requests run as one task
+		forkJoin ResponseSummary // Completion time of requests run as fork-join jobs
+		join     float64         // Mean join inflation of the fork-join jobs
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		// The baseline runs as many requests as the fork-join run, as single tasks
+		AppConfig.Workload.FanOut = 0
+		AppConfig.Workload.NumTasks = savedWorkload.NumTasks / fanOut
+		singleTasks, err := runExperiment(policy, AppConfig.Queue, "single")
+		if err != nil {
+			return fmt.Errorf("%s with single tasks: %w", policy.Name, err)
+		}
+
+		AppConfig.Workload.FanOut = fanOut
+		AppConfig.Workload.NumTasks = savedWorkload.NumTasks / fanOut * fanOut
+		forkJoinTasks, err := runExperiment(policy, AppConfig.Queue, fmt.Sprintf("fanout%d", fanOut))
+		if err != nil {
+			return fmt.Errorf("%s with fan-out %d: %w", policy.Name, fanOut, err)
+		}
+		jobs, _ := summarizeJobs(forkJoinTasks)
+		results = append(results, result{policy.Name, summarizeResponseTimes(singleTasks, nil), jobs.Completion, jobs.MeanJoinInflation})
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Fork-join (fan-out %d, %d worker slots, utilization %.0f%%)\n",
+		fanOut, AppConfig.Queue.Capacity(), AppConfig.Workload.TargetUtilization*100)
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %12s %12s %12s %12s %10s %10s\n", "Policy", "Single mean", "Single p99", "Fork mean", "Fork p99", "p99 ratio", "Join infl")
+	for _, r := range results {
+		ratio := 0.0
+		if r.single.P99 > 0 {
+			ratio = float64(r.forkJoin.P99) / float64(r.single.P99)
+		}
+		fmt.Printf("%-8s %12s %12s %12s %12s %9.2fx %9.2fx\n", r.policy,
+			formatMs(r.single.Mean), formatMs(r.single.P99), formatMs(r.forkJoin.Mean), formatMs(r.forkJoin.P99), ratio, r.join)
+	}
+	fmt.Println("(request latency in ms: response time of single tasks, completion time of fork-join jobs;")
+	fmt.Println(" join inflation is job completion time over the mean response of its tasks)")
+	return nil
+}
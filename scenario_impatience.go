@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// defaultImpatiencePatience is how long clients of the impatience scenario wait for their
+// task to start when workload.patience_ms isn't set
+const defaultImpatiencePatience = 5 * time.Second
+
+// impatienceScenario overloads every policy for the same time, like the overload
+// scenario, with clients that abandon tasks not started within their patience. A policy
+// that lets the backlog build up in front of short tasks loses them to abandonment, so
+// policies are ranked by abandonment rate.
+func impatienceScenario() error {
+	savedWorkload := AppConfig.Workload
+	defer func() { AppConfig.Workload = savedWorkload }()
+	AppConfig.Workload.TargetUtilization = overloadUtilization
+	if AppConfig.Workload.OverloadDurationMs == 0 {
+		AppConfig.Workload.OverloadDurationMs = int(defaultOverloadDuration.Milliseconds())
+	}
+	AppConfig.Workload.UtilizationSteps = nil
+	if AppConfig.Workload.PatienceMs == 0 {
+		AppConfig.Workload.PatienceMs = int(defaultImpatiencePatience.Milliseconds())
+	}
+
+	type result struct {
+		policy    string
+		all       AbandonmentSummary
+		short     AbandonmentSummary
+		long      AbandonmentSummary
+		shortResp ResponseSummary
+		longResp  ResponseSummary
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		tasks, err := runExperimentFrom(policy, AppConfig.Queue, "impatient", nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", policy.Name, err)
+		}
+		summaries := summarizeAbandonment(tasks)
+		completed, _ := splitCompleted(tasks)
+		results = append(results, result{
+			policy:    policy.Name,
+			all:       summaries[0],
+			short:     summaries[1],
+			long:      summaries[2],
+			shortResp: summarizeResponseTimes(completed, func(task Task) bool { return taskClass(task) == "short" }),
+			longResp:  summarizeResponseTimes(completed, func(task Task) bool { return taskClass(task) == "long" }),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].all.Rate() < results[j].all.Rate() })
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Impatient clients (patience %v, utilization %.0f%% for %v, then drain)\n",
+		AppConfig.Workload.Patience(), overloadUtilization*100, AppConfig.Workload.OverloadDuration())
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %11s %11s %11s %12s %12s\n", "Policy", "Abandoned", "Short ab.", "Long ab.", "Short p99", "Long p99")
+	for _, r := range results {
+		fmt.Printf("%-8s %10.1f%% %10.1f%% %10.1f%% %12s %12s\n", r.policy,
+			100*r.all.Rate(), 100*r.short.Rate(), 100*r.long.Rate(), formatMs(r.shortResp.P99), formatMs(r.longResp.P99))
+	}
+	fmt.Println("(abandoned tasks count as failed requests; response times in ms over completed tasks, policies from fewest to most abandoned)")
+	return nil
+}
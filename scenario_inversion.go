@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/sched"
+)
+
+// defaultInversionLockProbability is the share of tasks taking the shared lock in the
+// priority inversion scenario when workload.lock_probability isn't set
+const defaultInversionLockProbability = 0.5
+
+// priorityInversionScenario runs every policy with and without a lock shared by part of
+// the tasks. Without the lock, SJF's priority queue lets short tasks overtake long ones;
+// with it, a short task dequeued first still waits for the long task holding the lock,
+// so the benefit of the priority-aware queue shrinks.
+func priorityInversionScenario() error {
+	savedWorkload := AppConfig.Workload
+	defer func() { AppConfig.Workload = savedWorkload }()
+	lockProbability := AppConfig.Workload.LockProbability
+	if lockProbability == 0 {
+		lockProbability = defaultInversionLockProbability
+	}
+
+	type result struct {
+		policy      string
+		locked      bool
+		short       ResponseSummary
+		locks       LockSummary
+		hasPriority bool
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		for _, probability := range []float64{0, lockProbability} {
+			AppConfig.Workload.LockProbability = probability
+			label := "nolock"
+			if probability > 0 {
+				label = fmt.Sprintf("lock%g", probability)
+			}
+			tasks, err := runExperiment(policy, AppConfig.Queue, label)
+			if err != nil {
+				return fmt.Errorf("%s with lock probability %g: %w", policy.Name, probability, err)
+			}
+			short := summarizeResponseTimes(tasks, func(task Task) bool { return taskClass(task) == "short" })
+			results = append(results, result{policy.Name, probability > 0, short, summarizeLocks(tasks, policy), policy.Priority != nil})
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Priority inversion (%.0f%% of tasks share a lock, utilization %.0f%%)\n",
+		lockProbability*100, AppConfig.Workload.TargetUtilization*100)
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %-6s %12s %12s %14s %11s %11s\n", "Policy", "Lock", "Short mean", "Short p99", "Short lock p99", "Inversions", "Behind long")
+	for _, r := range results {
+		lock, inversions := "no", "-"
+		if r.locked {
+			lock = "yes"
+			if r.hasPriority {
+				inversions = fmt.Sprintf("%d", r.locks.Inversions)
+			}
+		}
+		fmt.Printf("%-8s %-6s %12s %12s %14s %11s %11d\n", r.policy, lock,
+			formatMs(r.short.Mean), formatMs(r.short.P99), formatMs(r.locks.ShortWait.P99), inversions, r.locks.BehindLong)
+	}
+	fmt.Println("(short task response and lock wait in ms; inversions are tasks blocked by a lower-priority")
+	fmt.Println(" lock holder, behind long counts short tasks blocked by a long one)")
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/sched"
+)
+
+// notifyLoadUtilization is the offered load of the notify-vs-polling scenario. At low load
+// most tasks find an idle worker, so dispatch latency dominates their wait time.
+const notifyLoadUtilization = 0.2
+
+// notifyVsPollingScenario runs every policy at low load with polling dispatch and with
+// LISTEN/NOTIFY dispatch, and compares the wait times
+func notifyVsPollingScenario() error {
+	savedWorkload := AppConfig.Workload
+	defer func() { AppConfig.Workload = savedWorkload }()
+	AppConfig.Workload.TargetUtilization = min(AppConfig.Workload.TargetUtilization, notifyLoadUtilization)
+
+	type result struct {
+		policy   string
+		dispatch string
+		wait     ResponseSummary
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		for _, dispatch := range []string{"polling", "notify"} {
+			queueCfg := AppConfig.Queue
+			queueCfg.Dispatch = dispatch
+			tasks, err := runExperiment(policy, queueCfg, dispatch)
+			if err != nil {
+				return fmt.Errorf("%s with %s dispatch: %w", policy.Name, dispatch, err)
+			}
+			results = append(results, result{policy.Name, dispatch, summarizeWaitTimes(tasks, nil)})
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Polling vs LISTEN/NOTIFY dispatch (utilization %.0f%%, polling interval %v)\n",
+		AppConfig.Workload.TargetUtilization*100, AppConfig.Queue.BasePollingInterval())
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %-8s %12s %12s %12s\n", "Policy", "Dispatch", "Wait mean", "Wait p50", "Wait p99")
+	for _, r := range results {
+		fmt.Printf("%-8s %-8s %12s %12s %12s\n", r.policy, r.dispatch,
+			formatMs(r.wait.Mean), formatMs(r.wait.Median), formatMs(r.wait.P99))
+	}
+	fmt.Println("(wait times in ms)")
+	return nil
+}
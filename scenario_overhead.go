@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/metrics"
+	"fifo-queue-demo/workload"
+)
+
+// Task durations of the scheduling-overhead scenario: near zero, so that the response
+// time of a task is almost all scheduling and dispatch, yet distinct, so that policies
+// ranking tasks by size still have two classes to order
+const (
+	overheadShortTaskMs = 1
+	overheadLongTaskMs  = 2
+)
+
+// overheadUtilization is the offered load of the scheduling-overhead scenario, on the
+// nominal task durations. Low enough that tasks rarely wait for a worker slot behind
+// another task, so their waits are the queue's own overhead.
+const overheadUtilization = 0.05
+
+// schedulingOverheadScenario runs every algorithm on tasks doing next to no work, and
+// compares the overhead of each policy's implementation: how long tasks took on top of
+// their work, split into enqueueing, waiting to be claimed and starting, and how many
+// tasks per second got through. Policies that keep several queues, update priorities or
+// hold tasks back in a dispatcher show what that machinery costs per task.
+func schedulingOverheadScenario() error {
+	savedWorkload, savedColdStart := AppConfig.Workload, AppConfig.ColdStart
+	defer func() { AppConfig.Workload, AppConfig.ColdStart = savedWorkload, savedColdStart }()
+	w := &AppConfig.Workload
+	w.ShortTaskDurationMs, w.LongTaskDurationMs = overheadShortTaskMs, overheadLongTaskMs
+	w.ShortTaskDurationUs, w.LongTaskDurationUs = 0, 0
+	w.ServiceTimeMeanMs, w.ServiceTimeMeanUs, w.ServiceTimeSCV = 0, 0, 0
+	w.TargetUtilization = overheadUtilization
+	w.OverloadDurationMs, w.Phases, w.UtilizationSteps = 0, nil, nil
+	w.FanOut, w.LockProbability = 0, 0
+	// Tasks only sleep through their work, and workers pay no setup
+	w.WorkMode, w.WorkProfiles = "sleep", workload.WorkProfiles{}
+	AppConfig.ColdStart = ColdStartConfig{}
+	// Every algorithm runs the same workload
+	w.Seed = int(w.RunSeed(time.Now()))
+
+	type result struct {
+		algorithm  string
+		overhead   ResponseSummary // Response time beyond the work
+		enqueue    ResponseSummary
+		queueing   ResponseSummary
+		startup    ResponseSummary
+		throughput float64 // Tasks completed per second, from the first arrival to the last completion
+	}
+	var results []result
+
+	for _, name := range sortedKeys(algorithms) {
+		policy, err := lookupPolicy(name)
+		if err != nil {
+			return err
+		}
+		tasks, err := runExperiment(policy, AppConfig.Queue, "overhead")
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		r := result{
+			algorithm: name,
+			overhead:  metrics.SummarizeTasks(tasks, nil, func(task Task) time.Duration { return task.ResponseTime() - task.Duration }),
+			enqueue:   metrics.SummarizeTasks(tasks, nil, Task.EnqueueDelay),
+			queueing:  metrics.SummarizeTasks(tasks, nil, Task.QueueingDelay),
+			startup:   metrics.SummarizeTasks(tasks, nil, Task.StartupDelay),
+		}
+		if len(tasks) > 0 {
+			first, last := tasks[0].ArrivalTime, tasks[0].CompletionTime
+			for _, task := range tasks {
+				if task.ArrivalTime.Before(first) {
+					first = task.ArrivalTime
+				}
+				if task.CompletionTime.After(last) {
+					last = task.CompletionTime
+				}
+			}
+			if span := last.Sub(first); span > 0 {
+				r.throughput = float64(len(tasks)) / span.Seconds()
+			}
+		}
+		results = append(results, r)
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Scheduling overhead (tasks of %d or %d ms, utilization %.0f%%, %d worker slots)\n",
+		overheadShortTaskMs, overheadLongTaskMs, overheadUtilization*100, AppConfig.Queue.Capacity())
+	fmt.Println("============================================================")
+	fmt.Printf("%-14s %12s %12s %12s %12s %12s %12s %10s\n", "Algorithm", "Overhead", "Overhead p50",
+		"Overhead p99", "Enqueue", "Queueing", "Startup", "Tasks/s")
+	for _, r := range results {
+		fmt.Printf("%-14s %12s %12s %12s %12s %12s %12s %10.1f\n", r.algorithm, formatMs(r.overhead.Mean),
+			formatMs(r.overhead.Median), formatMs(r.overhead.P99), formatMs(r.enqueue.Mean), formatMs(r.queueing.Mean),
+			formatMs(r.startup.Mean), r.throughput)
+	}
+	fmt.Println("(times in ms, means unless noted; overhead: response time beyond the task's work; enqueue, queueing")
+	fmt.Println(" and startup: the parts of the wait before the task was eligible, claimed and running)")
+	if AppConfig.Database.Mode == "simulated" {
+		fmt.Println("The simulation dispatches tasks instantly; run on Postgres to measure the overhead.")
+	}
+	return nil
+}
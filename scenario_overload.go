@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// overloadUtilization is the offered load of the overload scenario
+const overloadUtilization = 1.2
+
+// defaultOverloadDuration is how long the overload scenario overloads the queue when
+// workload.overload_duration_ms isn't set
+const defaultOverloadDuration = time.Minute
+
+// overloadScenario overloads every policy for the same time, then lets the backlog drain,
+// and compares how the backlog grew, how long it took to drain and what it did to latency.
+// Policies are ranked by mean response time, which by Little's law tracks the mean
+// backlog, so the first one degrades most gracefully.
+func overloadScenario() error {
+	savedWorkload := AppConfig.Workload
+	defer func() { AppConfig.Workload = savedWorkload }()
+	AppConfig.Workload.TargetUtilization = overloadUtilization
+	if AppConfig.Workload.OverloadDurationMs == 0 {
+		AppConfig.Workload.OverloadDurationMs = int(defaultOverloadDuration.Milliseconds())
+	}
+	AppConfig.Workload.UtilizationSteps = nil
+
+	type result struct {
+		policy  string
+		summary OverloadSummary
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		tasks, err := runExperiment(policy, AppConfig.Queue, "overload")
+		if err != nil {
+			return fmt.Errorf("%s: %w", policy.Name, err)
+		}
+		results = append(results, result{policy.Name, summarizeOverload(tasks)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].summary.Response.Mean < results[j].summary.Response.Mean
+	})
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Overload (utilization %.0f%% for %v, then drain)\n",
+		overloadUtilization*100, AppConfig.Workload.OverloadDuration())
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %10s %10s %12s %12s %12s %12s\n", "Policy", "Growth/s", "Drain s", "Resp mean", "Resp p99", "Short p99", "Long p99")
+	for _, r := range results {
+		s := r.summary
+		fmt.Printf("%-8s %10.2f %10.1f %12s %12s %12s %12s\n", r.policy, s.GrowthRate, s.DrainTime.Seconds(),
+			formatMs(s.Response.Mean), formatMs(s.Response.P99), formatMs(s.ShortResponse.P99), formatMs(s.LongResponse.P99))
+	}
+	fmt.Println("(response times in ms, policies from most to least graceful by mean response time)")
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/sched"
+)
+
+// rampUtilizations are the steps of the load-ramp scenario, from 50% to 95% utilization
+var rampUtilizations = []float64{0.5, 0.55, 0.6, 0.65, 0.7, 0.75, 0.8, 0.85, 0.9, 0.95}
+
+// rampMinTasksPerStep keeps the per-step percentiles meaningful when num_tasks is small
+const rampMinTasksPerStep = 100
+
+// loadRampScenario runs every policy once on a load staircase stepping from 50% to 95%
+// utilization, and compares the latency of each step. Latency stays flat at low load
+// and climbs steeply as utilization nears 100%, the classic hockey stick.
+func loadRampScenario() error {
+	savedWorkload := AppConfig.Workload
+	defer func() { AppConfig.Workload = savedWorkload }()
+	AppConfig.Workload.UtilizationSteps = rampUtilizations
+	AppConfig.Workload.NumTasks = max(AppConfig.Workload.NumTasks, rampMinTasksPerStep*len(rampUtilizations))
+
+	type result struct {
+		policy string
+		steps  []StepSummary
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		tasks, err := runExperiment(policy, AppConfig.Queue, "ramp")
+		if err != nil {
+			return fmt.Errorf("%s: %w", policy.Name, err)
+		}
+		results = append(results, result{policy.Name, summarizeSteps(tasks)})
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Load ramp (%d steps of %d tasks)\n", len(rampUtilizations), AppConfig.Workload.TasksPerStep())
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %6s %12s %12s %12s\n", "Policy", "Util", "Resp mean", "Resp p50", "Resp p99")
+	for _, r := range results {
+		for _, s := range r.steps {
+			fmt.Printf("%-8s %5.0f%% %12s %12s %12s\n", r.policy, s.Utilization*100,
+				formatMs(s.Response.Mean), formatMs(s.Response.Median), formatMs(s.Response.P99))
+		}
+	}
+	fmt.Println("(response times in ms)")
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// regionsLatencyMs is the round trip to a remote region the multi-region scenario uses
+// when the network section sets none, about that between two US coasts
+const regionsLatencyMs = 60
+
+// regionsScenario runs every algorithm with the producer and the workers in the database's
+// region, then with either or both of them in a remote one, and compares how the round
+// trips to Postgres add up in the dispatch of tasks: enqueueing pays the producer's,
+// while polling, claiming and checkpointing the steps of a task pay the workers' several
+// times over. Policies that take more queries per task, like those keeping several
+// queues, pay more for a remote worker.
+func regionsScenario() error {
+	savedNetwork := AppConfig.Network
+	defer func() { AppConfig.Network = savedNetwork }()
+	latencyMs := max(savedNetwork.ProducerLatencyMs, savedNetwork.WorkerLatencyMs)
+	if latencyMs == 0 {
+		latencyMs = regionsLatencyMs
+	}
+	// Every algorithm runs the same workload
+	savedSeed := AppConfig.Workload.Seed
+	defer func() { AppConfig.Workload.Seed = savedSeed }()
+	AppConfig.Workload.Seed = int(AppConfig.Workload.RunSeed(time.Now()))
+
+	placements := []struct {
+		name    string
+		label   string
+		network NetworkConfig
+	}{
+		{"same region", "local", NetworkConfig{}},
+		{"remote producer", "remoteproducer", NetworkConfig{ProducerLatencyMs: latencyMs}},
+		{"remote workers", "remoteworkers", NetworkConfig{WorkerLatencyMs: latencyMs}},
+		{"all remote", "remote", NetworkConfig{ProducerLatencyMs: latencyMs, WorkerLatencyMs: latencyMs}},
+	}
+
+	type result struct {
+		algorithm string
+		placement string
+		enqueue   ResponseSummary
+		queueing  ResponseSummary
+		startup   ResponseSummary
+		overhead  ResponseSummary // Response time beyond the work
+	}
+	var results []result
+
+	for _, name := range sortedKeys(algorithms) {
+		policy, err := lookupPolicy(name)
+		if err != nil {
+			return err
+		}
+		for _, placement := range placements {
+			AppConfig.Network = placement.network
+			tasks, err := runExperiment(policy, AppConfig.Queue, placement.label)
+			if err != nil {
+				return fmt.Errorf("%s, %s: %w", name, placement.name, err)
+			}
+			results = append(results, result{
+				algorithm: name,
+				placement: placement.name,
+				enqueue:   metrics.SummarizeTasks(tasks, nil, Task.EnqueueDelay),
+				queueing:  metrics.SummarizeTasks(tasks, nil, Task.QueueingDelay),
+				startup:   metrics.SummarizeTasks(tasks, nil, Task.StartupDelay),
+				overhead:  metrics.SummarizeTasks(tasks, nil, func(task Task) time.Duration { return task.ResponseTime() - task.Duration }),
+			})
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Multi-region deployment (%d ms round trip to a remote region, utilization %.0f%%)\n",
+		latencyMs, AppConfig.Workload.TargetUtilization*100)
+	fmt.Println("============================================================")
+	fmt.Printf("%-14s %-16s %10s %10s %10s %12s %12s %10s %8s\n", "Algorithm", "Placement", "Enqueue", "Queueing",
+		"Startup", "Overhead", "Overhead p99", "Added", "RTTs")
+	var local result
+	for _, r := range results {
+		if r.placement == placements[0].name {
+			local = r
+		}
+		added := r.overhead.Mean - local.overhead.Mean
+		rtts := "-"
+		if r.placement != placements[0].name {
+			rtts = fmt.Sprintf("%.1f", float64(added)/float64(time.Duration(latencyMs)*time.Millisecond))
+		}
+		fmt.Printf("%-14s %-16s %10s %10s %10s %12s %12s %10s %8s\n", r.algorithm, r.placement, formatMs(r.enqueue.Mean),
+			formatMs(r.queueing.Mean), formatMs(r.startup.Mean), formatMs(r.overhead.Mean), formatMs(r.overhead.P99),
+			formatMs(added), rtts)
+	}
+	fmt.Println("(times in ms, means unless noted; overhead: response time beyond the task's work; added: mean overhead")
+	fmt.Println(" beyond the same algorithm in the same region, and RTTs: how many round trips to the remote region that is)")
+	if AppConfig.Database.Mode == "simulated" {
+		fmt.Println("The simulation has no network; run on Postgres to measure the effect of latency.")
+	}
+	return nil
+}
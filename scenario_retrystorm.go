@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/sched"
+)
+
+// retryStormUtilization is the lowest utilization of the retry storm scenario: below it,
+// few tasks wait long enough to time out
+const retryStormUtilization = 0.8
+
+// defaultRetryStormTimeout is the client timeout of the retry storm scenario when
+// client_retry.timeout_ms isn't set
+const defaultRetryStormTimeout = 3 * time.Second
+
+// retryStormScenario runs every policy with clients that wait as long as it takes, then
+// with clients that time out and resend their tasks, and compares the load the retries
+// add and the response time clients see. Retries lengthen the queue, which makes more
+// tasks time out: how fast that loop runs away depends on which tasks the policy delays.
+func retryStormScenario() error {
+	savedWorkload, savedClientRetry := AppConfig.Workload, AppConfig.ClientRetry
+	defer func() { AppConfig.Workload, AppConfig.ClientRetry = savedWorkload, savedClientRetry }()
+	AppConfig.Workload.TargetUtilization = max(AppConfig.Workload.TargetUtilization, retryStormUtilization)
+	timeoutMs := AppConfig.ClientRetry.TimeoutMs
+	if timeoutMs == 0 {
+		timeoutMs = int(defaultRetryStormTimeout.Milliseconds())
+	}
+
+	type result struct {
+		policy  string
+		retries bool
+		summary ClientRetrySummary
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		for _, retries := range []bool{false, true} {
+			AppConfig.ClientRetry.TimeoutMs = 0
+			label := "noretry"
+			if retries {
+				AppConfig.ClientRetry.TimeoutMs = timeoutMs
+				label = "retrystorm"
+			}
+			tasks, err := runExperiment(policy, AppConfig.Queue, label)
+			if err != nil {
+				return fmt.Errorf("%s with client retries %v: %w", policy.Name, retries, err)
+			}
+			results = append(results, result{policy.Name, retries, summarizeClientRetries(tasks)})
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Retry storm (client timeout %v, up to %d retries, utilization %.0f%%)\n",
+		time.Duration(timeoutMs)*time.Millisecond, AppConfig.ClientRetry.MaxRetries, AppConfig.Workload.TargetUtilization*100)
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %-8s %10s %9s %9s %12s %12s\n", "Policy", "Retries", "Attempts", "Load", "Wasted", "Req mean", "Req p99")
+	for _, r := range results {
+		s := r.summary
+		retries := "off"
+		if r.retries {
+			retries = "on"
+		}
+		wasted := 0.0
+		if s.SentWork > 0 {
+			wasted = 100 * float64(s.WastedWork) / float64(s.SentWork)
+		}
+		fmt.Printf("%-8s %-8s %10d %8.2fx %8.1f%% %12s %12s\n", r.policy, retries, s.Attempts, s.Amplification(), wasted,
+			formatMs(s.Response.Mean), formatMs(s.Response.P99))
+	}
+	fmt.Println("(load is the work sent over the work of the requests, wasted the share spent on timed-out attempts;")
+	fmt.Println(" request response times in ms, from the first attempt to the result the client took)")
+	return nil
+}
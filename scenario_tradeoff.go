@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// tradeoffSwitchMs is the setup of switching classes in the throughput-vs-latency
+// scenario when cold_start isn't configured: without one, batching has nothing to gain
+const tradeoffSwitchMs = 100
+
+// tradeoffBatchSize is the batch size of the throughput-oriented mode, large enough that
+// workers rarely switch classes while a backlog of both waits
+const tradeoffBatchSize = 16
+
+// tradeoffMode is a configuration of the throughput-vs-latency scenario
+type tradeoffMode struct {
+	name   string
+	policy func() SchedulingPolicy
+}
+
+// throughputLatencyScenario overloads the queue like the overload scenario, with workers
+// paying a setup when they switch classes, and runs the same workload in a
+// throughput-oriented mode, large class batches, and a latency-oriented one, SJF. While
+// the backlog lasts, throughput is what the workers get through: batching spends less of
+// their time on setups and drains the backlog sooner, while SJF switches whenever a short
+// task waits, and buys short tasks low latency with it.
+func throughputLatencyScenario() error {
+	savedWorkload, savedColdStart, savedBatch := AppConfig.Workload, AppConfig.ColdStart, AppConfig.Algorithms.Batch
+	defer func() {
+		AppConfig.Workload, AppConfig.ColdStart, AppConfig.Algorithms.Batch = savedWorkload, savedColdStart, savedBatch
+	}()
+	AppConfig.Workload.TargetUtilization = overloadUtilization
+	if AppConfig.Workload.OverloadDurationMs == 0 {
+		AppConfig.Workload.OverloadDurationMs = int(defaultOverloadDuration.Milliseconds())
+	}
+	AppConfig.Workload.UtilizationSteps = nil
+	// Both modes run the same workload
+	AppConfig.Workload.Seed = int(AppConfig.Workload.RunSeed(time.Now()))
+	if !AppConfig.ColdStart.Enabled() {
+		AppConfig.ColdStart.SwitchMs = tradeoffSwitchMs
+	}
+	AppConfig.Algorithms.Batch.Size = tradeoffBatchSize
+
+	modes := []tradeoffMode{
+		{"throughput", func() SchedulingPolicy { return batchPolicy(AppConfig.Algorithms) }},
+		{"latency", func() SchedulingPolicy { return sjfPolicy(AppConfig.Algorithms.SJF) }},
+	}
+	type result struct {
+		mode       string
+		policy     string
+		throughput float64 // Tasks completed per second, from the first arrival to the last completion
+		setupShare float64 // Share of the worker time spent on setups
+		summary    OverloadSummary
+	}
+	var results []result
+
+	for _, mode := range modes {
+		policy := mode.policy()
+		tasks, err := runExperiment(policy, AppConfig.Queue, mode.name)
+		if err != nil {
+			return fmt.Errorf("%s mode (%s): %w", mode.name, policy.Name, err)
+		}
+		completed, _ := splitCompleted(tasks)
+		r := result{mode: mode.name, policy: policy.Name, summary: summarizeOverload(completed)}
+		if span := r.summary.Arrivals + r.summary.DrainTime; span > 0 {
+			r.throughput = float64(len(completed)) / span.Seconds()
+		}
+		var setup, busy time.Duration
+		for _, task := range completed {
+			setup += task.Setup
+			busy += task.CompletionTime.Sub(task.DequeueTime)
+		}
+		if busy > 0 {
+			r.setupShare = setup.Seconds() / busy.Seconds()
+		}
+		results = append(results, r)
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Throughput vs latency (utilization %.0f%% for %v, then drain; %d ms class switches)\n",
+		overloadUtilization*100, AppConfig.Workload.OverloadDuration(), AppConfig.ColdStart.SwitchMs)
+	fmt.Println("============================================================")
+	fmt.Printf("%-11s %-7s %11s %8s %9s %12s %12s %12s %12s\n", "Mode", "Policy", "Tasks/s", "Setup",
+		"Drain s", "Resp mean", "Resp p50", "Resp p99", "Short p99")
+	for _, r := range results {
+		s := r.summary
+		fmt.Printf("%-11s %-7s %11.2f %7.1f%% %9.1f %12s %12s %12s %12s\n", r.mode, r.policy, r.throughput,
+			100*r.setupShare, s.DrainTime.Seconds(), formatMs(s.Response.Mean), formatMs(s.Response.Median),
+			formatMs(s.Response.P99), formatMs(s.ShortResponse.P99))
+	}
+	fmt.Println("(response times in ms; setup: share of the worker time spent on setups)")
+
+	// The tradeoff: what the throughput mode gains in throughput, against what it costs in
+	// latency
+	throughput, latency := results[0], results[1]
+	if latency.throughput > 0 && latency.summary.Response.P99 > 0 && latency.summary.ShortResponse.P99 > 0 {
+		fmt.Printf("Tradeoff: the throughput mode completes %+.1f%% tasks per second, at %.2fx the p99 response time\n",
+			100*(throughput.throughput/latency.throughput-1),
+			float64(throughput.summary.Response.P99)/float64(latency.summary.Response.P99))
+		fmt.Printf("          and %.2fx the p99 response time of short tasks of the latency mode\n",
+			float64(throughput.summary.ShortResponse.P99)/float64(latency.summary.ShortResponse.P99))
+	}
+	return nil
+}
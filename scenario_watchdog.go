@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"fifo-queue-demo/sched"
+)
+
+// watchdogScenarioUtilization is the lowest utilization of the watchdog scenario: below
+// it, long tasks rarely wait long enough under SJF for the watchdog to matter
+const watchdogScenarioUtilization = 0.9
+
+// watchdogScenario runs every policy without and with the anti-starvation watchdog, and
+// compares how often the watchdog fires and what boosting costs short tasks and saves
+// long ones. Under FCFS the oldest task already runs first, so boosting only shows how
+// many tasks exceed the bound.
+func watchdogScenario() error {
+	savedWorkload, savedWatchdog := AppConfig.Workload, AppConfig.Watchdog
+	defer func() { AppConfig.Workload, AppConfig.Watchdog = savedWorkload, savedWatchdog }()
+	AppConfig.Workload.TargetUtilization = max(AppConfig.Workload.TargetUtilization, watchdogScenarioUtilization)
+
+	type result struct {
+		policy  string
+		enabled bool
+		tasks   int
+		boosted int
+		short   ResponseSummary
+		long    ResponseSummary
+		wait    ResponseSummary
+	}
+	var results []result
+
+	for _, policy := range []SchedulingPolicy{sched.FCFS(), sjfPolicy(AppConfig.Algorithms.SJF)} {
+		for _, enabled := range []bool{false, true} {
+			AppConfig.Watchdog.Enabled = enabled
+			label := "nowatchdog"
+			if enabled {
+				label = "watchdog"
+			}
+			tasks, err := runExperiment(policy, AppConfig.Queue, label)
+			if err != nil {
+				return fmt.Errorf("%s with watchdog %v: %w", policy.Name, enabled, err)
+			}
+			r := result{
+				policy:  policy.Name,
+				enabled: enabled,
+				tasks:   len(tasks),
+				short:   summarizeResponseTimes(tasks, func(task Task) bool { return taskClass(task) == "short" }),
+				long:    summarizeResponseTimes(tasks, func(task Task) bool { return taskClass(task) == "long" }),
+				wait:    summarizeWaitTimes(tasks, nil),
+			}
+			for _, task := range tasks {
+				if task.Boosted {
+					r.boosted++
+				}
+			}
+			results = append(results, r)
+		}
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Anti-starvation watchdog (boost after %v, utilization %.0f%%)\n",
+		AppConfig.Watchdog.MaxWait(), AppConfig.Workload.TargetUtilization*100)
+	fmt.Println("============================================================")
+	fmt.Printf("%-8s %-9s %9s %12s %12s %12s\n", "Policy", "Watchdog", "Boosted", "Short p99", "Long p99", "Max wait")
+	for _, r := range results {
+		watchdog, boosted := "off", "-"
+		if r.enabled {
+			watchdog = "on"
+			if r.tasks > 0 {
+				boosted = fmt.Sprintf("%.1f%%", 100*float64(r.boosted)/float64(r.tasks))
+			}
+		}
+		fmt.Printf("%-8s %-9s %9s %12s %12s %12s\n", r.policy, watchdog, boosted,
+			formatMs(r.short.P99), formatMs(r.long.P99), formatMs(r.wait.Max))
+	}
+	fmt.Println("(response times and waits in ms; boosted is the share of tasks the watchdog boosted)")
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// Scenario is a canned experiment built out of one or more runs
+type Scenario struct {
+	Description string
+	Run         func() error
+}
+
+// scenarios lists the available scenarios by name
+var scenarios = map[string]Scenario{
+	"cold-start": {
+		Description: "Compare FCFS, SJF and class-affinity batching as switching task classes gets more expensive for workers",
+		Run:         coldStartScenario,
+	},
+	"fork-join": {
+		Description: "Compare single-task requests with requests fanned out into parallel tasks that join, for each policy",
+		Run:         forkJoinScenario,
+	},
+	"foreground-background": {
+		Description: "Raise the batch load next to a steady interactive load, and compare shared, prioritized and headroom-reserving policies on interactive p99",
+		Run:         foregroundScenario,
+	},
+	"global-concurrency": {
+		Description: "Compare per-worker and global concurrency limits across executors for each policy",
+		Run:         globalConcurrencyScenario,
+	},
+	"heavytail": {
+		Description: "Standard: every algorithm on highly variable task durations (C² 20) at 70% utilization",
+		Run:         standardScenarioRunner("heavytail"),
+	},
+	"impatience": {
+		Description: "Overload each policy with clients that abandon tasks not started in time, and compare abandonment rates",
+		Run:         impatienceScenario,
+	},
+	"light": {
+		Description: "Standard: every algorithm on the short/long mix at 30% utilization",
+		Run:         standardScenarioRunner("light"),
+	},
+	"load-ramp": {
+		Description: "Step the offered load from 50% to 95% utilization within one run of each policy",
+		Run:         loadRampScenario,
+	},
+	"overload": {
+		Description: "Standard: overload FCFS and SJF (120% utilization) for one minute, then compare backlog growth and drain",
+		Run:         standardScenarioRunner("overload"),
+	},
+	"multi-region": {
+		Description: "Compare each algorithm with the producer, the workers or both in a region away from Postgres",
+		Run:         regionsScenario,
+	},
+	"multitenant": {
+		Description: "Standard: every algorithm on the short/long mix from 8 tenants at 70% utilization, with fairness",
+		Run:         standardScenarioRunner("multitenant"),
+	},
+	"notify-vs-polling": {
+		Description: "Compare polling and LISTEN/NOTIFY dispatch latency at low load for each policy",
+		Run:         notifyVsPollingScenario,
+	},
+	"priority-mechanisms": {
+		Description: "Compare SJF on one queue with per-workflow priorities against a queue per priority, strict and weighted",
+		Run:         priorityMechanismsScenario,
+	},
+	"priority-inversion": {
+		Description: "Compare each policy with and without a Postgres advisory lock shared by part of the tasks",
+		Run:         priorityInversionScenario,
+	},
+	"retry-storm": {
+		Description: "Compare each policy with patient clients and with clients that time out and resend their tasks",
+		Run:         retryStormScenario,
+	},
+	"scheduling-overhead": {
+		Description: "Run every algorithm on tasks doing next to no work, and compare the scheduling and dispatch overhead per task",
+		Run:         schedulingOverheadScenario,
+	},
+	"throughput-vs-latency": {
+		Description: "Overload a throughput-oriented mode (class batching) and a latency-oriented one (SJF) with class switch costs, and compare",
+		Run:         throughputLatencyScenario,
+	},
+	"variability": {
+		Description: "Sweep the variability of task durations at a constant mean for each policy",
+		Run:         variabilityScenario,
+	},
+	"watchdog": {
+		Description: "Compare each policy without and with the anti-starvation watchdog at high load",
+		Run:         watchdogScenario,
+	},
+}
+
+// scenarioNames returns the sorted list of scenario names
+func scenarioNames() []string {
+	return sortedKeys(scenarios)
+}
+
+// runScenario runs the named scenario. Standard scenarios can also be run in a given
+// version, e.g. heavytail-v1, and a name ending in .yaml runs a scenario file.
+func runScenario(name string) error {
+	if isScenarioFile(name) {
+		return runScenarioFile(name)
+	}
+	if _, ok := lookupStandardScenario(name); ok && scenarios[name].Run == nil {
+		return runStandardScenario(name)
+	}
+	scenario, ok := scenarios[name]
+	if !ok {
+		return fmt.Errorf("unknown scenario: %s (available scenarios: %s)", name, strings.Join(scenarioNames(), ", "))
+	}
+	return scenario.Run()
+}
+
+// ResponseSummary holds latency statistics of a group of tasks, as defined by the metrics
+// package
+type ResponseSummary = metrics.Summary
+
+// summarizeResponseTimes computes response time statistics for the tasks matching filter
+func summarizeResponseTimes(tasks []Task, filter func(Task) bool) ResponseSummary {
+	return metrics.SummarizeTasks(tasks, filter, Task.ResponseTime)
+}
+
+// summarizeWaitTimes computes wait time statistics for the tasks matching filter
+func summarizeWaitTimes(tasks []Task, filter func(Task) bool) ResponseSummary {
+	return metrics.SummarizeTasks(tasks, filter, Task.WaitTime)
+}
+
+// formatMs formats a duration as milliseconds for summary tables
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d.Microseconds())/1000)
+}
+
+// formatPreciseMs formats a duration as milliseconds to the microsecond
+func formatPreciseMs(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d.Nanoseconds())/1e6)
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// joinKeys returns the sorted keys of a string-keyed map as a comma-separated list
+func joinKeys[V any](m map[string]V) string {
+	return strings.Join(sortedKeys(m), ", ")
+}
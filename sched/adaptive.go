@@ -0,0 +1,139 @@
+package sched
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+
+	"fifo-queue-demo/workload"
+)
+
+// Adaptive switches between two policies with the observed load: tasks arriving while
+// the queue is shallow are prioritized by the low-load policy, tasks arriving while the
+// backlog is deep by the high-load policy. Thresholds are in queued tasks per worker
+// slot, and the gap between them keeps the mode from flapping.
+type Adaptive struct {
+	Low, High   Policy
+	Slots       int
+	HighBacklog float64           // Switch to High when the backlog per slot exceeds it
+	LowBacklog  float64           // Switch back to Low when the backlog per slot drops to it
+	OnSwitch    func(SwitchEvent) // Called on every switch, nil to only record them
+
+	mu                          sync.Mutex
+	high                        bool
+	events                      []SwitchEvent
+	modes                       map[int]bool // Whether each task was prioritized in high-load mode
+	firstObserved, lastObserved time.Time
+	highSince                   time.Time
+	timeInHigh                  time.Duration
+}
+
+// SwitchEvent records a switch between the policies of an adaptive policy
+type SwitchEvent struct {
+	At      time.Time
+	Backlog int    // Queued tasks when the switch happened
+	To      string // Name of the policy switched to
+}
+
+// Observe updates the mode with the backlog observed at the given time, and records the
+// switch if the mode changes
+func (a *Adaptive) Observe(at time.Time, backlog int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.firstObserved.IsZero() {
+		a.firstObserved = at
+	}
+	a.lastObserved = at
+	perSlot := float64(backlog) / float64(max(a.Slots, 1))
+	switch {
+	case !a.high && perSlot > a.HighBacklog:
+		a.high = true
+		a.highSince = at
+	case a.high && perSlot <= a.LowBacklog:
+		a.high = false
+		a.timeInHigh += at.Sub(a.highSince)
+	default:
+		return
+	}
+	event := SwitchEvent{At: at, Backlog: backlog, To: a.current().Name}
+	a.events = append(a.events, event)
+	if a.OnSwitch != nil {
+		a.OnSwitch(event)
+	}
+}
+
+// current returns the policy of the current mode. Callers hold the lock.
+func (a *Adaptive) current() Policy {
+	if a.high {
+		return a.High
+	}
+	return a.Low
+}
+
+// Events returns the switches so far, in order
+func (a *Adaptive) Events() []SwitchEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]SwitchEvent(nil), a.events...)
+}
+
+// TimeInHigh returns how long the high-load policy was in charge between the first and
+// the last observation
+func (a *Adaptive) TimeInHigh() (high, total time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	high = a.timeInHigh
+	if a.high {
+		high += a.lastObserved.Sub(a.highSince)
+	}
+	return high, a.lastObserved.Sub(a.firstObserved)
+}
+
+// PrioritizedInHigh reports whether the task was prioritized by the high-load policy
+func (a *Adaptive) PrioritizedInHigh(task workload.Task) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.modes[task.TaskID]
+}
+
+// policyPriority returns the priority of a task under a policy, 1 (the most urgent) for
+// policies without priorities so their tasks run in arrival order among the most urgent
+func policyPriority(policy Policy, task workload.Task) uint {
+	if policy.Priority == nil {
+		return 1
+	}
+	return policy.Priority(task)
+}
+
+// AdaptivePolicy returns a policy that prioritizes each task with the low-load or the
+// high-load policy of a, whichever is in charge when the task is first prioritized (at
+// enqueue). The task keeps that priority when the mode changes. Neither policy may have
+// lanes.
+func AdaptivePolicy(a *Adaptive) Policy {
+	a.modes = make(map[int]bool)
+	priorities := make(map[int]uint)
+	return Policy{
+		Name:      "adaptive",
+		Title:     "Adaptive: Policy Switching on the Observed Load Demo",
+		QueueName: "adaptive_queue",
+		Description: fmt.Sprintf("Priority queue, %s switching to %s above %g queued tasks per slot and back at %g",
+			a.Low.Name, a.High.Name, a.HighBacklog, a.LowBacklog),
+		QueueOptions: []dbos.QueueOption{
+			dbos.WithPriorityEnabled(),
+		},
+		Priority: func(task workload.Task) uint {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if priority, ok := priorities[task.TaskID]; ok {
+				return priority
+			}
+			priority := policyPriority(a.current(), task)
+			priorities[task.TaskID] = priority
+			a.modes[task.TaskID] = a.high
+			return priority
+		},
+		Adaptive: a,
+	}
+}
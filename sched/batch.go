@@ -0,0 +1,87 @@
+package sched
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// Batcher is a dispatcher running tasks of the same class back to back, to amortize the
+// setup workers pay when they switch classes: once it picks a task of a class, it keeps
+// picking the oldest waiting task of that class, up to Size tasks in a row. When the batch
+// is full or no task of its class waits, the oldest waiting task starts the next batch, so
+// no task waits behind more than a batch of another class.
+type Batcher struct {
+	Class func(task workload.Task) string // Class of a task
+	Size  int                             // Most tasks of a batch
+
+	mu      sync.Mutex
+	class   string // Class of the current batch
+	run     int    // Tasks picked in the current batch
+	batches map[string]int
+	tasks   map[string]int
+}
+
+// BatchStats counts the batches of one class
+type BatchStats struct {
+	Batches int
+	Tasks   int
+}
+
+// Batched returns a policy dispatching the tasks in batches of the same class
+func Batched(batcher *Batcher) Policy {
+	return Policy{
+		Name:        "batch",
+		Title:       "Batch: Class-Affinity Batching Demo",
+		QueueName:   "batch_queue",
+		Description: "Single queue, " + batcher.String(),
+		Dispatcher:  batcher,
+	}
+}
+
+func (b *Batcher) String() string {
+	return fmt.Sprintf("batches of up to %d tasks of a class, oldest first", b.Size)
+}
+
+func (b *Batcher) Pick(now time.Time, waiting []workload.Task) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.batches == nil {
+		b.batches = make(map[string]int)
+		b.tasks = make(map[string]int)
+	}
+	pick := -1
+	if b.run > 0 && b.run < b.Size {
+		for i, task := range waiting {
+			if b.Class(task) == b.class {
+				pick = i
+				break
+			}
+		}
+	}
+	if pick < 0 {
+		// The oldest task starts the next batch
+		pick = 0
+		b.class, b.run = b.Class(waiting[0]), 0
+		b.batches[b.class]++
+	}
+	b.run++
+	b.tasks[b.class]++
+	return pick
+}
+
+// Completed does nothing: batches only depend on the waiting tasks
+func (b *Batcher) Completed(workload.Task) {}
+
+// Stats returns the batches of each class the batcher started, by class
+func (b *Batcher) Stats() map[string]BatchStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]BatchStats, len(b.batches))
+	for class, batches := range b.batches {
+		stats[class] = BatchStats{Batches: batches, Tasks: b.tasks[class]}
+	}
+	return stats
+}
@@ -0,0 +1,60 @@
+package sched
+
+import (
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// ClientRetry describes clients that time out on their tasks and send them again: a
+// client that got no result Timeout after sending an attempt gives up on it and, after
+// the backoff of the retry, sends a new attempt of the same request. The attempt it gave
+// up on isn't cancelled, so it still loads the queue. After Backoff.MaxRetries retries,
+// the client waits for the last attempt as long as it takes.
+type ClientRetry struct {
+	Timeout time.Duration
+	Backoff RetryPolicy
+}
+
+// Resend returns the attempt a client sends when the given one times out, with the given
+// task ID, or false if the client has no retries left
+func (r *ClientRetry) Resend(attempt workload.Task, taskID int) (workload.Task, bool) {
+	if attempt.ClientAttempt >= r.Backoff.MaxRetries {
+		return workload.Task{}, false
+	}
+	retry := attempt
+	retry.TaskID = taskID
+	retry.RequestID = attempt.Request()
+	retry.ClientAttempt = attempt.ClientAttempt + 1
+	retry.ArrivalTime = r.ResendAt(attempt)
+	return retry, true
+}
+
+// ResendAt returns when the client sends the next attempt if the given one times out
+func (r *ClientRetry) ResendAt(attempt workload.Task) time.Time {
+	return attempt.ArrivalTime.Add(r.Timeout + r.Backoff.Delay(attempt.ClientAttempt+1))
+}
+
+// TimedOut reports whether an attempt that completed at the given time, or not yet if
+// the time is zero, timed out
+func (r *ClientRetry) TimedOut(attempt workload.Task, completion time.Time) bool {
+	return completion.IsZero() || completion.After(attempt.ArrivalTime.Add(r.Timeout))
+}
+
+// MarkTimedOut sets the TimedOut field of the attempts the client gave up on: those it
+// sent another attempt for
+func MarkTimedOut(tasks []workload.Task) {
+	type attempt struct{ request, number int }
+	index := make(map[attempt]int)
+	for i, task := range tasks {
+		index[attempt{task.Request(), task.ClientAttempt}] = i
+	}
+	for _, task := range tasks {
+		if task.ClientAttempt == 0 {
+			continue
+		}
+		if i, ok := index[attempt{task.Request(), task.ClientAttempt - 1}]; ok {
+			tasks[i].TimedOut = true
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package sched
+
+import (
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// ColdStart is the setup a worker pays before running a task, like loading a model or
+// warming a cache for its class of tasks. A worker that last ran another class pays the
+// class's setup; a worker that never ran a task, or idled longer than IdleAfter, is cold
+// and pays the warm-up on top. A warm worker running the class it last ran pays nothing.
+type ColdStart struct {
+	Class     func(task workload.Task) string // Class of a task
+	Switch    time.Duration                   // Setup of a class, for the classes without their own
+	Classes   map[string]time.Duration        // Setup of specific classes
+	WarmUp    time.Duration                   // Extra setup of a cold worker
+	IdleAfter time.Duration                   // Idle time after which a worker is cold, 0 for never
+}
+
+// Worker is what a worker last ran, for telling the setup of its next task
+type Worker struct {
+	Class  string    // Class of the last task it ran, empty if it never ran one
+	IdleAt time.Time // When it completed its last task
+}
+
+// Setup returns the setup the worker pays at now before running the task
+func (c *ColdStart) Setup(worker Worker, task workload.Task, now time.Time) time.Duration {
+	class := c.Class(task)
+	var setup time.Duration
+	cold := worker.Class == "" || c.IdleAfter > 0 && now.Sub(worker.IdleAt) > c.IdleAfter
+	if cold {
+		setup += c.WarmUp
+	}
+	if cold || worker.Class != class {
+		if classSetup, ok := c.Classes[class]; ok {
+			setup += classSetup
+		} else {
+			setup += c.Switch
+		}
+	}
+	return setup
+}
+
+// Pick returns the index of the free worker that runs the task with the least setup,
+// the one idle the shortest among equals, or -1 if there are no free workers
+func (c *ColdStart) Pick(free []Worker, task workload.Task, now time.Time) int {
+	best, bestSetup := -1, time.Duration(0)
+	for i, worker := range free {
+		setup := c.Setup(worker, task, now)
+		if best < 0 || setup < bestSetup || setup == bestSetup && worker.IdleAt.After(free[best].IdleAt) {
+			best, bestSetup = i, setup
+		}
+	}
+	return best
+}
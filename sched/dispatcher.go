@@ -0,0 +1,63 @@
+package sched
+
+import (
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// Dispatcher makes every dispatch decision of a policy itself, rather than ordering tasks
+// by a priority: whenever a worker slot frees up, it picks which of the waiting tasks
+// runs. It is told about every task that completes, possibly from several goroutines at
+// once, so it can learn from the outcome of its decisions.
+type Dispatcher interface {
+	// Pick returns the index of the task to run among the waiting tasks, oldest first.
+	// An index out of range runs the oldest.
+	Pick(now time.Time, waiting []workload.Task) int
+	Completed(task workload.Task)
+	String() string
+}
+
+// Delegated returns a policy handing every dispatch decision to the dispatcher. Tasks
+// are dequeued in arrival order into a gate in front of the worker slots, where the
+// dispatcher picks among them.
+func Delegated(dispatcher Dispatcher) Policy {
+	return Policy{
+		Name:        "agent",
+		Title:       "Agent: Dispatch Decisions by an External Agent Demo",
+		QueueName:   "agent_queue",
+		Description: "Single queue, dispatch decisions by " + dispatcher.String(),
+		Dispatcher:  dispatcher,
+	}
+}
+
+// dispatchQueue holds the waiting tasks in arrival order, and lets the dispatcher pick
+// which one each free server takes
+type dispatchQueue struct {
+	tasks      []workload.Task
+	dispatcher Dispatcher
+	items      []int
+}
+
+func newDispatchQueue(tasks []workload.Task, dispatcher Dispatcher) *dispatchQueue {
+	return &dispatchQueue{tasks: tasks, dispatcher: dispatcher}
+}
+
+func (q *dispatchQueue) Len() int { return len(q.items) }
+
+// Arrivals are pushed in time order, so items stay oldest first
+func (q *dispatchQueue) push(idx int) { q.items = append(q.items, idx) }
+
+func (q *dispatchQueue) pop(now time.Time) int {
+	waiting := make([]workload.Task, len(q.items))
+	for i, idx := range q.items {
+		waiting[i] = q.tasks[idx]
+	}
+	i := q.dispatcher.Pick(now, waiting)
+	if i < 0 || i >= len(q.items) {
+		i = 0
+	}
+	idx := q.items[i]
+	q.items = append(q.items[:i], q.items[i+1:]...)
+	return idx
+}
@@ -0,0 +1,209 @@
+package sched
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+
+	"fifo-queue-demo/workload"
+)
+
+// Features are what a scheduler can know about a task before it runs: its request type
+// (class), who sent it and how big its payload is, but not its duration
+type Features struct {
+	Class        string
+	TenantID     string
+	PayloadBytes int
+}
+
+// SizeEstimator predicts the duration of tasks from their features, for policies that
+// run on predicted rather than actual sizes. Estimators that learn are told the actual
+// duration of every task that completes, possibly from several goroutines at once.
+type SizeEstimator interface {
+	Estimate(f Features) time.Duration
+	Observe(f Features, duration time.Duration)
+	String() string
+}
+
+// MovingAverage predicts the duration of a task as the exponentially weighted moving
+// average of the durations of the completed tasks of its class. Classes without a
+// completed task yet get the prior.
+type MovingAverage struct {
+	Alpha float64       // Weight of each new observation, in (0, 1]
+	Prior time.Duration // Prediction for classes with no observation
+
+	mu      sync.Mutex
+	average map[string]float64
+}
+
+func NewMovingAverage(alpha float64, prior time.Duration) *MovingAverage {
+	return &MovingAverage{Alpha: alpha, Prior: prior, average: make(map[string]float64)}
+}
+
+func (m *MovingAverage) Estimate(f Features) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if average, ok := m.average[f.Class]; ok {
+		return time.Duration(average)
+	}
+	return m.Prior
+}
+
+func (m *MovingAverage) Observe(f Features, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	average, ok := m.average[f.Class]
+	if !ok {
+		m.average[f.Class] = float64(duration)
+		return
+	}
+	m.average[f.Class] = average + m.Alpha*(float64(duration)-average)
+}
+
+func (m *MovingAverage) String() string {
+	return fmt.Sprintf("moving average per class (alpha %g, prior %v)", m.Alpha, m.Prior.Round(time.Millisecond))
+}
+
+// History learns the distribution of the durations of each class online, from the last
+// Window completed tasks of the class, and predicts its Quantile: the median for 0.5, a
+// pessimistic estimate for higher quantiles. Classes without a completed task yet get
+// the prior.
+type History struct {
+	Window   int
+	Quantile float64
+	Prior    time.Duration
+
+	mu      sync.Mutex
+	history map[string]*durationWindow
+}
+
+// durationWindow holds the last durations observed for a class, oldest overwritten first
+type durationWindow struct {
+	durations []time.Duration
+	next      int
+}
+
+func NewHistory(window int, quantile float64, prior time.Duration) *History {
+	return &History{Window: window, Quantile: quantile, Prior: prior, history: make(map[string]*durationWindow)}
+}
+
+func (h *History) Estimate(f Features) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w, ok := h.history[f.Class]
+	if !ok {
+		return h.Prior
+	}
+	sorted := slices.Clone(w.durations)
+	slices.Sort(sorted)
+	i := min(int(h.Quantile*float64(len(sorted))), len(sorted)-1)
+	return sorted[i]
+}
+
+func (h *History) Observe(f Features, duration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w, ok := h.history[f.Class]
+	if !ok {
+		w = &durationWindow{}
+		h.history[f.Class] = w
+	}
+	if len(w.durations) < h.Window {
+		w.durations = append(w.durations, duration)
+		return
+	}
+	w.durations[w.next] = duration
+	w.next = (w.next + 1) % h.Window
+}
+
+func (h *History) String() string {
+	return fmt.Sprintf("p%g of the last %d durations per class (prior %v)", h.Quantile*100, h.Window, h.Prior.Round(time.Millisecond))
+}
+
+// LinearModel predicts the duration of a task with a fixed linear model, typically fit
+// offline on past runs: an intercept, plus a term for the task's class and its tenant,
+// plus a cost per payload byte. Predictions never go below 0. It doesn't learn.
+type LinearModel struct {
+	InterceptMs   float64            `yaml:"intercept_ms"`
+	ClassMs       map[string]float64 `yaml:"class_ms"`
+	TenantMs      map[string]float64 `yaml:"tenant_ms"`
+	PayloadByteMs float64            `yaml:"payload_byte_ms"`
+
+	Source string `yaml:"-"` // Where the model was loaded from, for descriptions
+}
+
+func (m *LinearModel) Estimate(f Features) time.Duration {
+	ms := m.InterceptMs + m.ClassMs[f.Class] + m.TenantMs[f.TenantID] + m.PayloadByteMs*float64(f.PayloadBytes)
+	return time.Duration(max(ms, 0) * float64(time.Millisecond))
+}
+
+func (m *LinearModel) Observe(Features, time.Duration) {}
+
+func (m *LinearModel) String() string {
+	return "linear model from " + m.Source
+}
+
+// SJFPredicted returns Shortest Job First on predicted sizes: a priority queue where the
+// task with the shortest predicted duration runs first. features tells what the
+// estimator knows of each task. A task's prediction is made once, when it is first
+// prioritized (at enqueue), so it keeps its priority as the estimator learns.
+func SJFPredicted(estimator SizeEstimator, features func(workload.Task) Features) Policy {
+	var mu sync.Mutex
+	predictions := make(map[int]uint)
+	return Policy{
+		Name:        "sjf-predicted",
+		Title:       "SJF (predicted): Shortest Job First on Predicted Sizes Demo",
+		QueueName:   "sjf_predicted_queue",
+		Description: "Priority queue (priority = predicted duration in ms), " + estimator.String(),
+		QueueOptions: []dbos.QueueOption{
+			dbos.WithPriorityEnabled(),
+		},
+		Priority: func(task workload.Task) uint {
+			mu.Lock()
+			defer mu.Unlock()
+			if priority, ok := predictions[task.TaskID]; ok {
+				return priority
+			}
+			// Priority 0 means no priority, so the shortest prediction gets 1
+			priority := uint(estimator.Estimate(features(task)).Milliseconds()) + 1
+			predictions[task.TaskID] = priority
+			return priority
+		},
+		Estimator: estimator,
+		Features:  features,
+	}
+}
+
+// Predicted returns the duration predicted for a task under a policy with an estimator,
+// from the priority it got
+func (p Policy) Predicted(task workload.Task) time.Duration {
+	return time.Duration(p.Priority(task)-1) * time.Millisecond
+}
+
+// Observes reports whether the policy needs to be told about completed tasks
+func (p Policy) Observes() bool {
+	return p.Estimator != nil || p.Dispatcher != nil || p.FairShare != nil
+}
+
+// Observe tells the policy's estimator the actual duration of a completed task, its
+// dispatcher that the task completed, and charges the task's worker time to its tenant
+// for fair-share policies. Estimators don't learn from failed tasks. It does nothing for
+// policies that don't observe.
+func (p Policy) Observe(task workload.Task) {
+	if p.Estimator != nil && !task.Failed {
+		p.Estimator.Observe(p.Features(task), task.Duration)
+	}
+	if p.Dispatcher != nil {
+		p.Dispatcher.Completed(task)
+	}
+	if p.FairShare != nil {
+		work := task.Duration
+		if !task.DequeueTime.IsZero() && task.CompletionTime.After(task.DequeueTime) {
+			work = task.CompletionTime.Sub(task.DequeueTime)
+		}
+		p.FairShare.Charge(task.TenantID, work, task.CompletionTime)
+	}
+}
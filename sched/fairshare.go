@@ -0,0 +1,100 @@
+package sched
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+
+	"fifo-queue-demo/workload"
+)
+
+// FairShare tracks the recent usage of each tenant for decay-usage fair-share
+// scheduling, like the fair-share schedulers of Unix: a tenant's usage is the worker time
+// of its completed tasks, decaying exponentially with HalfLife, so a tenant that used a
+// lot of the workers recently gets a lower priority until its usage decays.
+type FairShare struct {
+	HalfLife time.Duration
+
+	mu    sync.Mutex
+	usage map[string]float64 // Decayed usage of each tenant in seconds, as of at
+	at    time.Time
+}
+
+func NewFairShare(halfLife time.Duration) *FairShare {
+	return &FairShare{HalfLife: halfLife, usage: make(map[string]float64)}
+}
+
+// decay brings the usage of every tenant forward to now. Every tenant decays by the same
+// factor, so they share one timestamp. Callers hold the lock.
+func (f *FairShare) decay(now time.Time) {
+	if f.at.IsZero() {
+		f.at = now
+		return
+	}
+	if !now.After(f.at) {
+		return
+	}
+	factor := math.Exp2(-now.Sub(f.at).Seconds() / f.HalfLife.Seconds())
+	for tenant := range f.usage {
+		f.usage[tenant] *= factor
+	}
+	f.at = now
+}
+
+// Charge adds the worker time of a task of the tenant that completed at the given time.
+// Tasks completing out of order are charged as of the latest completion.
+func (f *FairShare) Charge(tenantID string, work time.Duration, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.decay(at)
+	f.usage[tenantID] += work.Seconds()
+}
+
+// Share returns the tenant's share of the recent usage of all tenants at the given time,
+// 0 before any usage
+func (f *FairShare) Share(tenantID string, now time.Time) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.decay(now)
+	var total float64
+	for _, usage := range f.usage {
+		total += usage
+	}
+	if total == 0 {
+		return 0
+	}
+	return f.usage[tenantID] / total
+}
+
+// FairSharePolicy returns decay-usage fair-share scheduling: a priority queue where a
+// task's priority is its tenant's share of the recent usage when it arrives, in tenths of
+// a percent, so tasks of the tenants that used the workers least run first. A task keeps
+// the priority it got at enqueue. Tasks without a tenant share one.
+func FairSharePolicy(f *FairShare) Policy {
+	var mu sync.Mutex
+	priorities := make(map[int]uint)
+	return Policy{
+		Name:        "fair-share",
+		Title:       "Fair Share: Decay-Usage Fair-Share Scheduling Demo",
+		QueueName:   "fair_share_queue",
+		Description: fmt.Sprintf("Priority queue (priority = tenant's share of recent usage, half-life %v)", f.HalfLife),
+		QueueOptions: []dbos.QueueOption{
+			dbos.WithPriorityEnabled(),
+		},
+		Priority: func(task workload.Task) uint {
+			mu.Lock()
+			defer mu.Unlock()
+			if priority, ok := priorities[task.TaskID]; ok {
+				return priority
+			}
+			// Priority 0 means no priority, so tenants without usage get 1
+			priority := uint(1000*f.Share(task.TenantID, task.ArrivalTime)) + 1
+			priorities[task.TaskID] = priority
+			return priority
+		},
+		FairShare: f,
+	}
+}
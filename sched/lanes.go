@@ -0,0 +1,144 @@
+package sched
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// Lanes back a policy with one DBOS queue per priority lane instead of a single queue
+// with per-workflow priorities. Every lane can hold the executors' worker slots, and a
+// freed slot goes to the lane picked by strict priority (the first lane with waiting
+// tasks) or by weighted round robin over the lanes with waiting tasks.
+type Lanes struct {
+	Lane    func(task workload.Task) int // Lane of a task, 0 being the most urgent
+	Weights []int                        // Share of freed slots of each lane in weighted mode
+	Strict  bool
+}
+
+// Count returns the number of lanes
+func (l *Lanes) Count() int {
+	return len(l.Weights)
+}
+
+// QueueName returns the name of the DBOS queue of lane k, given the queue name of the
+// policy
+func (l *Lanes) QueueName(queueName string, k int) string {
+	return fmt.Sprintf("%s_lane%d", queueName, k+1)
+}
+
+// QueueNames returns the names of the DBOS queues of every lane
+func (l *Lanes) QueueNames(queueName string) []string {
+	names := make([]string, l.Count())
+	for k := range names {
+		names[k] = l.QueueName(queueName, k)
+	}
+	return names
+}
+
+// Mode describes how lanes share worker slots, e.g. "strict" or "weighted 3:1"
+func (l *Lanes) Mode() string {
+	if l.Strict {
+		return "strict"
+	}
+	mode := "weighted "
+	for k, weight := range l.Weights {
+		if k > 0 {
+			mode += ":"
+		}
+		mode += fmt.Sprint(weight)
+	}
+	return mode
+}
+
+// LanePicker picks the lane that gets the next free worker slot. Weighted mode uses
+// smooth weighted round robin, so lanes take turns in proportion to their weights
+// instead of in bursts.
+type LanePicker struct {
+	lanes   *Lanes
+	current []int
+}
+
+func (l *Lanes) NewPicker() *LanePicker {
+	return &LanePicker{lanes: l, current: make([]int, l.Count())}
+}
+
+// Pick returns the lane that gets the next free slot among the lanes for which waiting
+// returns true, or -1 if no lane has waiting tasks
+func (p *LanePicker) Pick(waiting func(k int) bool) int {
+	best, total := -1, 0
+	for k, weight := range p.lanes.Weights {
+		if !waiting(k) {
+			continue
+		}
+		if p.lanes.Strict {
+			return k
+		}
+		p.current[k] += weight
+		total += weight
+		if best < 0 || p.current[k] > p.current[best] {
+			best = k
+		}
+	}
+	if best >= 0 {
+		p.current[best] -= total
+	}
+	return best
+}
+
+// SJFLanes returns Shortest Job First backed by two lanes, short tasks in the first and
+// long tasks in the second, instead of a priority queue. Weights set the share of freed
+// worker slots each lane gets unless strict is set.
+func SJFLanes(cutoff time.Duration, strict bool, shortWeight, longWeight int) Policy {
+	lanes := &Lanes{
+		Lane: func(task workload.Task) int {
+			if task.Duration <= cutoff {
+				return 0
+			}
+			return 1
+		},
+		Weights: []int{shortWeight, longWeight},
+		Strict:  strict,
+	}
+	return Policy{
+		Name:        "sjf-lanes",
+		Title:       "SJF (lanes): Shortest Job First with a Queue per Priority Demo",
+		QueueName:   "sjf_lanes_queue",
+		Description: fmt.Sprintf("Queue per priority (up to %v=lane 1, longer=lane 2), %s", cutoff, lanes.Mode()),
+		Priority: func(task workload.Task) uint {
+			return uint(lanes.Lane(task)) + 1
+		},
+		Lanes: lanes,
+	}
+}
+
+// laneQueue holds the tasks ready to run in one FIFO per lane, and hands them out in the
+// order the lane picker chooses
+type laneQueue struct {
+	tasks  []workload.Task
+	lanes  *Lanes
+	picker *LanePicker
+	fifos  [][]int
+	size   int
+}
+
+func newLaneQueue(tasks []workload.Task, lanes *Lanes) *laneQueue {
+	return &laneQueue{tasks: tasks, lanes: lanes, picker: lanes.NewPicker(), fifos: make([][]int, lanes.Count())}
+}
+
+func (q *laneQueue) Len() int { return q.size }
+
+func (q *laneQueue) push(idx int) {
+	k := q.lanes.Lane(q.tasks[idx])
+	q.fifos[k] = append(q.fifos[k], idx)
+	q.size++
+}
+
+func (q *laneQueue) pop(time.Time) int {
+	k := q.picker.Pick(func(k int) bool { return len(q.fifos[k]) > 0 })
+	idx := q.fifos[k][0]
+	q.fifos[k] = q.fifos[k][1:]
+	q.size--
+	return idx
+}
@@ -0,0 +1,129 @@
+// Package sched defines the scheduling algorithms of the experiments as DBOS queue
+// policies, and simulates how an idealized queue would schedule a workload under them.
+package sched
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+
+	"fifo-queue-demo/workload"
+)
+
+// Policy describes how a scheduling algorithm maps onto a DBOS queue
+type Policy struct {
+	Name         string                        // Short name used for result files (e.g. "fcfs")
+	Title        string                        // Banner title printed at the start of a run
+	QueueName    string                        // Name of the DBOS queue backing the policy
+	Description  string                        // One-line description of the queue setup
+	QueueOptions []dbos.QueueOption            // Policy-specific queue options (e.g. priorities)
+	Priority     func(task workload.Task) uint // Per-task priority, nil if the queue has no priorities
+	Lanes        *Lanes                        // One queue per priority lane instead of QueueName, nil for a single queue
+
+	// Size estimator of policies that run on predicted durations, and the features it
+	// sees of each task; nil for policies that don't predict
+	Estimator SizeEstimator
+	Features  func(task workload.Task) Features
+
+	// Switching between policies with the load, nil for policies that don't switch
+	Adaptive *Adaptive
+
+	// Picks every task to dispatch among the waiting ones, nil for policies that order
+	// tasks by priority
+	Dispatcher Dispatcher
+
+	// Recent usage of each tenant, charged as tasks complete, for fair-share policies
+	FairShare *FairShare
+}
+
+// FCFS returns the First-Come-First-Served policy: a plain queue dequeued in arrival order
+func FCFS() Policy {
+	return Policy{
+		Name:        "fcfs",
+		Title:       "FCFS: First-Come-First-Served Queue Scheduling Demo",
+		QueueName:   "fcfs_queue",
+		Description: "Single fcfs queue",
+	}
+}
+
+// SJF returns the Shortest Job First policy: a priority queue where short tasks get a
+// higher priority (lower number) than long ones. Tasks up to cutoff count as short.
+func SJF(cutoff time.Duration) Policy {
+	return Policy{
+		Name:        "sjf",
+		Title:       "SJF: Shortest Job First Queue Scheduling Demo",
+		QueueName:   "sjf_queue",
+		Description: fmt.Sprintf("Priority queue (up to %v=priority 1, longer=priority 2)", cutoff),
+		QueueOptions: []dbos.QueueOption{
+			dbos.WithPriorityEnabled(),
+		},
+		Priority: func(task workload.Task) uint {
+			if task.Duration <= cutoff {
+				return 1 // Higher priority (lower number) for short tasks
+			}
+			return 2 // Lower priority (higher number) for long tasks
+		},
+	}
+}
+
+// SJFBuckets returns Shortest Job First with quantized size information: the queue only
+// knows which of len(boundaries)+1 size buckets a task falls into, not its duration.
+// Tasks up to boundaries[0] get priority 1, up to boundaries[1] priority 2, and so on,
+// and tasks longer than the last boundary get the lowest priority. Boundaries must be
+// increasing.
+func SJFBuckets(boundaries []time.Duration) Policy {
+	description := "Priority queue by size bucket ("
+	for i, boundary := range boundaries {
+		description += fmt.Sprintf("up to %v=priority %d, ", boundary, i+1)
+	}
+	description += fmt.Sprintf("longer=priority %d)", len(boundaries)+1)
+	return Policy{
+		Name:        "sjf-buckets",
+		Title:       "SJF (buckets): Shortest Job First on Size Buckets Demo",
+		QueueName:   "sjf_buckets_queue",
+		Description: description,
+		QueueOptions: []dbos.QueueOption{
+			dbos.WithPriorityEnabled(),
+		},
+		Priority: func(task workload.Task) uint {
+			return uint(SizeBucket(boundaries, task.Duration)) + 1
+		},
+	}
+}
+
+// SizeBucket returns the index of the size bucket a task of the given duration falls
+// into: the number of boundaries below its duration
+func SizeBucket(boundaries []time.Duration, duration time.Duration) int {
+	bucket := 0
+	for bucket < len(boundaries) && duration > boundaries[bucket] {
+		bucket++
+	}
+	return bucket
+}
+
+// edfEpoch is the reference point of EDF priorities. Priorities are stored as 32-bit
+// integers, so they count milliseconds since the process started rather than since 1970.
+var edfEpoch = time.Now()
+
+// EDF returns the Earliest Deadline First policy: a priority queue where the task with
+// the earliest deadline runs first. Tasks without a deadline are ordered by arrival.
+func EDF() Policy {
+	return Policy{
+		Name:        "edf",
+		Title:       "EDF: Earliest Deadline First Queue Scheduling Demo",
+		QueueName:   "edf_queue",
+		Description: "Priority queue (priority = deadline)",
+		QueueOptions: []dbos.QueueOption{
+			dbos.WithPriorityEnabled(),
+		},
+		Priority: func(task workload.Task) uint {
+			deadline := task.Deadline
+			if deadline.IsZero() {
+				deadline = task.ArrivalTime
+			}
+			// Priority 0 means no priority, so the earliest possible deadline gets 1
+			return uint(max(0, deadline.Sub(edfEpoch).Milliseconds())) + 1
+		},
+	}
+}
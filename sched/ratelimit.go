@@ -0,0 +1,114 @@
+package sched
+
+import (
+	"sync"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// TokenBucket is the rate limit of one tenant: it may submit Rate tasks per second on
+// average, and up to Burst at once after a quiet spell. A zero Rate leaves the tenant
+// unlimited.
+type TokenBucket struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter admits tasks in front of the queue through a token bucket per tenant, like
+// an API gateway. Each task takes a token of its tenant's bucket when it arrives. Without
+// one, the task is rejected, or, with Delay, held until its token is due: tokens are then
+// taken ahead, so the tenant's later tasks wait behind it. Tasks must be admitted in
+// arrival order. Tasks without a tenant share a bucket.
+type RateLimiter struct {
+	Default TokenBucket            // Bucket of the tenants without their own
+	Tenants map[string]TokenBucket // Buckets of specific tenants
+	Delay   bool                   // Hold throttled tasks instead of rejecting them
+
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+	stats   map[string]*ThrottleStats
+}
+
+// bucketState is the fill of a tenant's bucket when it was last updated. It goes
+// negative when Delay takes tokens ahead.
+type bucketState struct {
+	tokens float64
+	at     time.Time
+}
+
+// ThrottleStats counts what the rate limiter did to the tasks of one tenant
+type ThrottleStats struct {
+	TenantID   string
+	Bucket     TokenBucket
+	Offered    int           // Tasks the tenant submitted
+	Rejected   int           // Tasks rejected for lack of a token
+	Delayed    int           // Tasks held until their token was due
+	TotalDelay time.Duration // Time the delayed tasks were held
+	MaxDelay   time.Duration
+}
+
+// Bucket returns the token bucket of a tenant
+func (l *RateLimiter) Bucket(tenantID string) TokenBucket {
+	if bucket, ok := l.Tenants[tenantID]; ok {
+		return bucket
+	}
+	return l.Default
+}
+
+// Admit takes a token for the task at its arrival. It returns whether the task is
+// admitted, and how long it is held before entering the queue.
+func (l *RateLimiter) Admit(task workload.Task) (delay time.Duration, admitted bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucketState)
+		l.stats = make(map[string]*ThrottleStats)
+	}
+	bucket := l.Bucket(task.TenantID)
+	stats, ok := l.stats[task.TenantID]
+	if !ok {
+		stats = &ThrottleStats{TenantID: task.TenantID, Bucket: bucket}
+		l.stats[task.TenantID] = stats
+	}
+	stats.Offered++
+	if bucket.Rate <= 0 {
+		return 0, true
+	}
+
+	// The bucket refills at the rate since it was last updated, up to the burst
+	state, ok := l.buckets[task.TenantID]
+	if !ok {
+		state = &bucketState{tokens: float64(bucket.Burst), at: task.ArrivalTime}
+		l.buckets[task.TenantID] = state
+	}
+	if elapsed := task.ArrivalTime.Sub(state.at); elapsed > 0 {
+		state.tokens = min(state.tokens+elapsed.Seconds()*bucket.Rate, float64(bucket.Burst))
+		state.at = task.ArrivalTime
+	}
+	if state.tokens >= 1 {
+		state.tokens--
+		return 0, true
+	}
+	if !l.Delay {
+		stats.Rejected++
+		return 0, false
+	}
+	state.tokens--
+	delay = time.Duration(-state.tokens / bucket.Rate * float64(time.Second))
+	stats.Delayed++
+	stats.TotalDelay += delay
+	stats.MaxDelay = max(stats.MaxDelay, delay)
+	return delay, true
+}
+
+// Stats returns what the rate limiter did to each tenant that submitted tasks, by tenant
+func (l *RateLimiter) Stats() map[string]ThrottleStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]ThrottleStats, len(l.stats))
+	for tenant, s := range l.stats {
+		stats[tenant] = *s
+	}
+	return stats
+}
@@ -0,0 +1,132 @@
+package sched
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// Reservations split the worker slots into two tiers: slots reserved for a group of tasks
+// (a tenant or a class), which only its tasks may use, and a shared best-effort pool any
+// task may use. A task takes a reserved slot of its group when one is free, and spills
+// over to the shared pool otherwise. A reserved slot its group doesn't use stays idle.
+type Reservations struct {
+	Group  func(task workload.Task) string // Group of a task
+	Slots  map[string]int                  // Reserved slots of each group with a reservation
+	Shared int                             // Slots of the shared pool
+}
+
+// Reserved returns the number of reserved slots of every group together
+func (r *Reservations) Reserved() int {
+	total := 0
+	for _, slots := range r.Slots {
+		total += slots
+	}
+	return total
+}
+
+// String describes the reservations, e.g. "tenant-0: 2, short: 1, shared: 3"
+func (r *Reservations) String() string {
+	groups := make([]string, 0, len(r.Slots))
+	for group := range r.Slots {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	var parts []string
+	for _, group := range groups {
+		parts = append(parts, fmt.Sprintf("%s: %d", group, r.Slots[group]))
+	}
+	parts = append(parts, fmt.Sprintf("shared: %d", r.Shared))
+	return strings.Join(parts, ", ")
+}
+
+// SlotUsage tracks which slots the running tasks hold
+type SlotUsage struct {
+	reservations *Reservations
+	reserved     map[string]int // Reserved slots in use, by group
+	shared       int            // Shared slots in use
+	inReserved   map[int]bool   // Running tasks holding a reserved slot, by task ID
+}
+
+func (r *Reservations) NewUsage() *SlotUsage {
+	return &SlotUsage{reservations: r, reserved: make(map[string]int), inReserved: make(map[int]bool)}
+}
+
+// CanStart reports whether a slot is free for the task: a reserved slot of its group or a
+// shared one
+func (u *SlotUsage) CanStart(task workload.Task) bool {
+	group := u.reservations.Group(task)
+	return u.reserved[group] < u.reservations.Slots[group] || u.shared < u.reservations.Shared
+}
+
+// Start takes a slot for the task, a reserved one if its group has one free. It returns
+// whether the slot is reserved.
+func (u *SlotUsage) Start(task workload.Task) bool {
+	group := u.reservations.Group(task)
+	if u.reserved[group] < u.reservations.Slots[group] {
+		u.reserved[group]++
+		u.inReserved[task.TaskID] = true
+		return true
+	}
+	u.shared++
+	return false
+}
+
+// Finish frees the slot of the task
+func (u *SlotUsage) Finish(task workload.Task) {
+	if u.inReserved[task.TaskID] {
+		delete(u.inReserved, task.TaskID)
+		u.reserved[u.reservations.Group(task)]--
+		return
+	}
+	u.shared--
+}
+
+// reservationQueue hands out the most urgent task of the queue it wraps that a slot is
+// free for, and nothing when no waiting task may start. It learns when the tasks it
+// handed out complete through started.
+type reservationQueue struct {
+	readyQueue
+	tasks   []workload.Task
+	usage   *SlotUsage
+	running *completionQueue
+}
+
+func newReservationQueue(ready readyQueue, tasks []workload.Task, reservations *Reservations) *reservationQueue {
+	return &reservationQueue{
+		readyQueue: ready,
+		tasks:      tasks,
+		usage:      reservations.NewUsage(),
+		running:    &completionQueue{completions: make([]time.Time, len(tasks))},
+	}
+}
+
+func (q *reservationQueue) pop(now time.Time) int {
+	for q.running.Len() > 0 && !q.running.completions[q.running.items[0]].After(now) {
+		q.usage.Finish(q.tasks[heap.Pop(q.running).(int)])
+	}
+	var skipped []int
+	idx := -1
+	for q.readyQueue.Len() > 0 {
+		candidate := q.readyQueue.pop(now)
+		if q.usage.CanStart(q.tasks[candidate]) {
+			idx = candidate
+			break
+		}
+		skipped = append(skipped, candidate)
+	}
+	for _, skip := range skipped {
+		q.readyQueue.push(skip)
+	}
+	return idx
+}
+
+func (q *reservationQueue) started(idx int, completion time.Time) {
+	q.usage.Start(q.tasks[idx])
+	q.running.completions[idx] = completion
+	heap.Push(q.running, idx)
+}
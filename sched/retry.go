@@ -0,0 +1,38 @@
+package sched
+
+import (
+	"math"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// RetryPolicy describes how failed task attempts are retried. It follows DBOS step
+// retries: the n-th retry waits BaseInterval * BackoffFactor^(n-1), at most MaxInterval.
+type RetryPolicy struct {
+	MaxRetries    int // Retries after the first attempt, 0 for none
+	BaseInterval  time.Duration
+	MaxInterval   time.Duration
+	BackoffFactor float64
+}
+
+// Delay returns how long the given retry (counting from 1) waits after the failed attempt
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := float64(p.BaseInterval) * math.Pow(p.BackoffFactor, float64(retry-1))
+	return time.Duration(math.Min(delay, float64(p.MaxInterval)))
+}
+
+// Outcome returns how a task's attempts play out under the policy, when every attempt
+// takes the task's full duration: the number of attempts, whether the task ends up
+// failing, and the time from the start of its first attempt to the start of its last.
+func (p RetryPolicy) Outcome(task workload.Task) (attempts int, failed bool, retryDelay time.Duration) {
+	failures := task.TransientFailures
+	if task.FailsPermanently {
+		failures = math.MaxInt
+	}
+	attempts = min(failures, p.MaxRetries) + 1
+	for retry := 1; retry < attempts; retry++ {
+		retryDelay += task.Duration + p.Delay(retry)
+	}
+	return attempts, failures >= attempts, retryDelay
+}
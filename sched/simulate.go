@@ -0,0 +1,363 @@
+package sched
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// Replay is the outcome of replaying tasks through an idealized queue, indexed like the
+// tasks
+type Replay struct {
+	Waits     []time.Duration // Time from arrival to dispatch, or to cancellation
+	LockWaits []time.Duration // Time waiting for the shared lock after dispatch
+	Boosted   []bool          // Tasks the watchdog boosted
+	Cancelled []bool          // Tasks cancelled while they were waiting, which never ran
+	Abandoned []bool          // Tasks abandoned when their client's patience ran out
+	Unsent    []bool          // Retries the client didn't send, as the attempt before didn't time out
+	Setups    []time.Duration // Cold-start setup of the server before running the task
+}
+
+// Simulate replays the tasks' arrivals through an idealized queue with the given
+// number of servers, where a free server picks the next task the instant it arrives (no
+// polling, no dispatch overhead). Tasks are picked by priority (lower first) then arrival,
+// and each occupies a server for service(task). It returns the wait time each task would
+// have had, in the order of the input slice.
+func Simulate(tasks []workload.Task, servers int, priority func(workload.Task) uint, service func(workload.Task) time.Duration) []time.Duration {
+	return SimulateWithLock(tasks, servers, priority, service, nil).Waits
+}
+
+// SimulateWithLock is Simulate with a lock shared by the tasks for which locked returns
+// true, like a Postgres advisory lock: they hold it for their whole service, and wait for
+// it on their server, in the order they asked for it. A nil locked means no task takes
+// the lock.
+//
+// Tasks with a CancelAfter delay that are still waiting when it runs out are cancelled:
+// they leave the queue without running. Tasks already running go on to completion. Tasks
+// with a Patience that are still waiting when it runs out are abandoned the same way.
+func SimulateWithLock(tasks []workload.Task, servers int, priority func(workload.Task) uint, service func(workload.Task) time.Duration,
+	locked func(workload.Task) bool) Replay {
+	return simulate(tasks, servers, newReplayQueue(tasks, priority), service, locked, nil, nil, nil, nil)
+}
+
+// SimulatePolicy is SimulateWithLock under the policy: tasks are picked by its priority,
+// or from its lanes, as the lane picker chooses, if it has lanes. A watchdog, if not nil,
+// boosts the tasks that wait too long.
+//
+// With client retries, tasks include every attempt the clients may send, at the time
+// they would send it. An attempt is only sent if the attempt before it timed out;
+// Unsent reports the others, which didn't run.
+//
+// A policy with an estimator learns the duration of each task as it completes, so tasks
+// arriving later are prioritized on what it learned by their arrival. An adaptive policy
+// observes the backlog of the servers as each task arrives. A policy with a dispatcher
+// picks the task each free server takes, and is told when each task completes. A
+// fair-share policy charges each task's tenant as it completes. With reservations, not
+// nil, a task only starts when a reserved slot of its group or a shared slot is free, and
+// the most urgent task that may start goes first. With a cold start, not nil, each task
+// takes the free server it has the least setup on, and pays the setup before its service.
+func SimulatePolicy(tasks []workload.Task, servers int, policy Policy, service func(workload.Task) time.Duration,
+	locked func(workload.Task) bool, watchdog *Watchdog, retry *ClientRetry, reservations *Reservations, coldStart *ColdStart) Replay {
+	var ready readyQueue = newReplayQueue(tasks, policy.Priority)
+	if policy.Lanes != nil {
+		ready = newLaneQueue(tasks, policy.Lanes)
+	}
+	if policy.Dispatcher != nil {
+		ready = newDispatchQueue(tasks, policy.Dispatcher)
+	}
+	if reservations != nil {
+		ready = newReservationQueue(ready, tasks, reservations)
+	}
+	var observe func(workload.Task)
+	if policy.Observes() {
+		observe = policy.Observe
+	}
+	var arrive func(time.Time, int)
+	if policy.Adaptive != nil {
+		policy.Adaptive.Slots = servers
+		arrive = policy.Adaptive.Observe
+	}
+	if watchdog == nil {
+		return simulate(tasks, servers, ready, service, locked, retry, observe, arrive, coldStart)
+	}
+	boosts := newBoostQueue(ready, tasks, watchdog)
+	replay := simulate(tasks, servers, boosts, service, locked, retry, observe, arrive, coldStart)
+	replay.Boosted = boosts.boosted
+	return replay
+}
+
+// readyQueue holds the indices of the tasks that arrived and wait for a server. pop is
+// given the time of the dispatch.
+type readyQueue interface {
+	Len() int
+	push(idx int)
+	pop(now time.Time) int
+}
+
+// admissionQueue is a ready queue that may hold back every waiting task: pop returns -1
+// when none may start yet. It is told when the tasks it handed out start and complete.
+type admissionQueue interface {
+	readyQueue
+	started(idx int, completion time.Time)
+}
+
+// simulate replays the tasks through the ready queue. observe, if not nil, is called with
+// each task that ran once it completes, with its dequeue and completion times, in
+// completion order, before the tasks arriving after it are queued. arrive, if not nil, is
+// called with the arrival time and the number of queued tasks before each task is queued.
+// coldStart, if not nil, gives the setup of each task on each server.
+func simulate(tasks []workload.Task, servers int, ready readyQueue, service func(workload.Task) time.Duration,
+	locked func(workload.Task) bool, retry *ClientRetry, observe func(workload.Task), arrive func(time.Time, int),
+	coldStart *ColdStart) Replay {
+	replay := Replay{
+		Waits:     make([]time.Duration, len(tasks)),
+		LockWaits: make([]time.Duration, len(tasks)),
+		Boosted:   make([]bool, len(tasks)),
+		Cancelled: make([]bool, len(tasks)),
+		Abandoned: make([]bool, len(tasks)),
+		Unsent:    make([]bool, len(tasks)),
+		Setups:    make([]time.Duration, len(tasks)),
+	}
+	if len(tasks) == 0 || servers < 1 {
+		return replay
+	}
+
+	// The attempt before each retry, and when dispatched tasks complete, to tell whether
+	// the client sends the retry
+	previous := make([]int, len(tasks))
+	completions := make([]time.Time, len(tasks))
+	if retry != nil {
+		type attempt struct{ request, number int }
+		index := make(map[attempt]int)
+		for i, task := range tasks {
+			index[attempt{task.Request(), task.ClientAttempt}] = i
+		}
+		for i, task := range tasks {
+			previous[i] = -1
+			if task.ClientAttempt > 0 {
+				previous[i] = index[attempt{task.Request(), task.ClientAttempt - 1}]
+			}
+		}
+	}
+
+	// Process arrivals in time order
+	order := make([]int, len(tasks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return tasks[order[a]].ArrivalTime.Before(tasks[order[b]].ArrivalTime)
+	})
+
+	freeAt := make([]time.Time, servers)
+	var idleUntil time.Time // When the queue last ran empty, no server can start before the next arrival
+	var lockFreeAt time.Time
+	workers := make([]Worker, servers)                    // What each server last ran, with a cold start
+	running := &completionQueue{completions: completions} // Tasks not yet observed, with observe
+	next := 0
+	for next < len(order) || ready.Len() > 0 {
+		// The server that frees up first takes the next dispatch
+		server := 0
+		for s := range freeAt {
+			if freeAt[s].Before(freeAt[server]) {
+				server = s
+			}
+		}
+		if ready.Len() == 0 && tasks[order[next]].ArrivalTime.After(idleUntil) {
+			idleUntil = tasks[order[next]].ArrivalTime
+		}
+		now := freeAt[server]
+		if idleUntil.After(now) {
+			now = idleUntil
+		}
+
+		// Tasks completed by now are observed before the arrivals compete for the server
+		for observe != nil && running.Len() > 0 && !completions[running.items[0]].After(now) {
+			observeCompleted(tasks, replay, completions, heap.Pop(running).(int), observe)
+		}
+
+		// Everything that has arrived by now competes for the server. Attempts dispatched
+		// from now on complete after now, so whether a retry's previous attempt timed out
+		// is known when the retry is due.
+		for next < len(order) && !tasks[order[next]].ArrivalTime.After(now) {
+			idx := order[next]
+			next++
+			if retry != nil {
+				if prev := previous[idx]; prev >= 0 && (replay.Unsent[prev] || !retry.TimedOut(tasks[prev], completions[prev])) {
+					replay.Unsent[idx] = true
+					continue
+				}
+			}
+			if arrive != nil {
+				arrive(tasks[idx].ArrivalTime, ready.Len())
+			}
+			ready.push(idx)
+		}
+		if ready.Len() == 0 {
+			continue
+		}
+
+		// A task cancelled or abandoned while it waited left the queue then, whichever
+		// came first, and leaves the server free. When no waiting task may start, the
+		// server idles until the next arrival or completion.
+		idx := ready.pop(now)
+		if idx < 0 {
+			next := nextEvent(tasks, order, next, freeAt, now)
+			if next.IsZero() {
+				break
+			}
+			freeAt[server] = next
+			continue
+		}
+		task, wait := tasks[idx], now.Sub(tasks[idx].ArrivalTime)
+		switch {
+		case task.CancelAfter > 0 && task.CancelAfter <= wait && (task.Patience == 0 || task.CancelAfter <= task.Patience):
+			replay.Waits[idx] = task.CancelAfter
+			replay.Cancelled[idx] = true
+			continue
+		case task.Patience > 0 && wait > task.Patience:
+			replay.Waits[idx] = task.Patience
+			replay.Abandoned[idx] = true
+			continue
+		}
+		replay.Waits[idx] = wait
+		start := now
+		if coldStart != nil {
+			// Of the servers free by now, the one the task has the least setup on takes it
+			var free []Worker
+			var freeServers []int
+			for s := range freeAt {
+				if !freeAt[s].After(now) {
+					free = append(free, workers[s])
+					freeServers = append(freeServers, s)
+				}
+			}
+			if best := coldStart.Pick(free, task, now); best >= 0 {
+				server = freeServers[best]
+			}
+			replay.Setups[idx] = coldStart.Setup(workers[server], task, now)
+			start = now.Add(replay.Setups[idx])
+		}
+		if locked != nil && locked(tasks[idx]) {
+			if lockFreeAt.After(start) {
+				start = lockFreeAt
+			}
+			replay.LockWaits[idx] = start.Sub(now.Add(replay.Setups[idx]))
+			lockFreeAt = start.Add(service(tasks[idx]))
+		}
+		freeAt[server] = start.Add(service(tasks[idx]))
+		completions[idx] = freeAt[server]
+		if coldStart != nil {
+			workers[server] = Worker{Class: coldStart.Class(task), IdleAt: freeAt[server]}
+		}
+		if admission, ok := ready.(admissionQueue); ok {
+			admission.started(idx, completions[idx])
+		}
+		if observe != nil {
+			heap.Push(running, idx)
+		}
+	}
+	for observe != nil && running.Len() > 0 {
+		observeCompleted(tasks, replay, completions, heap.Pop(running).(int), observe)
+	}
+	return replay
+}
+
+// nextEvent returns the first time after now a task arrives or a server frees up, or the
+// zero time if nothing happens after now
+func nextEvent(tasks []workload.Task, order []int, next int, freeAt []time.Time, now time.Time) time.Time {
+	var at time.Time
+	if next < len(order) {
+		at = tasks[order[next]].ArrivalTime
+	}
+	for _, free := range freeAt {
+		if free.After(now) && (at.IsZero() || free.Before(at)) {
+			at = free
+		}
+	}
+	return at
+}
+
+// observeCompleted calls observe with the task that completed, with its dequeue and
+// completion times
+func observeCompleted(tasks []workload.Task, replay Replay, completions []time.Time, idx int, observe func(workload.Task)) {
+	task := tasks[idx]
+	task.DequeueTime = task.ArrivalTime.Add(replay.Waits[idx])
+	task.CompletionTime = completions[idx]
+	observe(task)
+}
+
+// completionQueue is a heap of task indices ordered by completion time
+type completionQueue struct {
+	completions []time.Time
+	items       []int
+}
+
+func (q *completionQueue) Len() int { return len(q.items) }
+
+func (q *completionQueue) Less(i, j int) bool {
+	return q.completions[q.items[i]].Before(q.completions[q.items[j]])
+}
+
+func (q *completionQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *completionQueue) Push(x any) { q.items = append(q.items, x.(int)) }
+
+func (q *completionQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
+
+// replayQueue is a heap of task indices ordered by priority, then arrival time. Each
+// task is prioritized once, when it is queued, like at enqueue in a real run.
+type replayQueue struct {
+	tasks      []workload.Task
+	priority   func(workload.Task) uint
+	priorities []uint
+	items      []int
+}
+
+func newReplayQueue(tasks []workload.Task, priority func(workload.Task) uint) *replayQueue {
+	q := &replayQueue{tasks: tasks, priority: priority}
+	if priority != nil {
+		q.priorities = make([]uint, len(tasks))
+	}
+	return q
+}
+
+func (q *replayQueue) Len() int { return len(q.items) }
+
+func (q *replayQueue) Less(i, j int) bool {
+	a, b := q.tasks[q.items[i]], q.tasks[q.items[j]]
+	if q.priority != nil {
+		if pa, pb := q.priorities[q.items[i]], q.priorities[q.items[j]]; pa != pb {
+			return pa < pb
+		}
+	}
+	if !a.ArrivalTime.Equal(b.ArrivalTime) {
+		return a.ArrivalTime.Before(b.ArrivalTime)
+	}
+	return a.TaskID < b.TaskID
+}
+
+func (q *replayQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *replayQueue) Push(x any) { q.items = append(q.items, x.(int)) }
+
+func (q *replayQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
+
+func (q *replayQueue) push(idx int) {
+	if q.priority != nil {
+		q.priorities[idx] = q.priority(q.tasks[idx])
+	}
+	heap.Push(q, idx)
+}
+
+func (q *replayQueue) pop(time.Time) int { return heap.Pop(q).(int) }
@@ -0,0 +1,90 @@
+package sched
+
+import (
+	"time"
+
+	"fifo-queue-demo/workload"
+)
+
+// Watchdog guards against starvation: every ScanInterval, it boosts the tasks that have
+// waited longer than MaxWait, which then run before every task it hasn't boosted, in
+// arrival order.
+type Watchdog struct {
+	MaxWait      time.Duration
+	ScanInterval time.Duration // 0 boosts tasks the moment they exceed MaxWait
+}
+
+// BoostAt returns when the watchdog boosts a task if it is still waiting by then: at the
+// first scan after the task exceeds MaxWait, scans starting at start
+func (w *Watchdog) BoostAt(task workload.Task, start time.Time) time.Time {
+	due := task.ArrivalTime.Add(w.MaxWait)
+	if w.ScanInterval <= 0 || !due.After(start) {
+		return due
+	}
+	scans := (due.Sub(start) + w.ScanInterval - 1) / w.ScanInterval
+	return start.Add(scans * w.ScanInterval)
+}
+
+// boostQueue hands out the tasks the watchdog boosted first, in arrival order, and the
+// other tasks in the order of the queue it wraps. Boosted tasks stay in the wrapped
+// queue until it hands them out, and are skipped then.
+type boostQueue struct {
+	readyQueue
+	boostAt []time.Time
+	arrived []int        // Waiting tasks in arrival order, with some already handed out
+	taken   map[int]bool // Boosted tasks handed out but still in the wrapped queue
+	out     []bool
+	boosted []bool
+}
+
+func newBoostQueue(ready readyQueue, tasks []workload.Task, watchdog *Watchdog) *boostQueue {
+	q := &boostQueue{
+		readyQueue: ready,
+		boostAt:    make([]time.Time, len(tasks)),
+		taken:      make(map[int]bool),
+		out:        make([]bool, len(tasks)),
+		boosted:    make([]bool, len(tasks)),
+	}
+	var start time.Time
+	for i, task := range tasks {
+		if i == 0 || task.ArrivalTime.Before(start) {
+			start = task.ArrivalTime
+		}
+	}
+	for i, task := range tasks {
+		q.boostAt[i] = watchdog.BoostAt(task, start)
+	}
+	return q
+}
+
+func (q *boostQueue) Len() int { return q.readyQueue.Len() - len(q.taken) }
+
+// push must be called in arrival order, as simulate does
+func (q *boostQueue) push(idx int) {
+	q.readyQueue.push(idx)
+	q.arrived = append(q.arrived, idx)
+}
+
+func (q *boostQueue) pop(now time.Time) int {
+	for len(q.arrived) > 0 && q.out[q.arrived[0]] {
+		q.arrived = q.arrived[1:]
+	}
+	// Boost times follow arrivals, so the earliest waiting task is the first boosted
+	if len(q.arrived) > 0 && !q.boostAt[q.arrived[0]].After(now) {
+		idx := q.arrived[0]
+		q.arrived = q.arrived[1:]
+		q.taken[idx] = true
+		q.out[idx] = true
+		q.boosted[idx] = true
+		return idx
+	}
+	for {
+		idx := q.readyQueue.pop(now)
+		if q.taken[idx] {
+			delete(q.taken, idx)
+			continue
+		}
+		q.out[idx] = true
+		return idx
+	}
+}
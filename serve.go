@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+	"google.golang.org/grpc"
+
+	"fifo-queue-demo/api"
+)
+
+// taskRequest is the body of POST /tasks. The duration defaults to that of the class;
+// the priority and deadline are optional.
+type taskRequest struct {
+	DurationMs int       `json:"duration_ms"`
+	Class      string    `json:"class"`    // "short" or "long"
+	Priority   uint      `json:"priority"` // Only used by policies with priorities, 0 to let the policy decide
+	Deadline   time.Time `json:"deadline"` // RFC 3339
+}
+
+// taskResponse describes a submitted task: enqueued, or completed with its latency as
+// the server saw it
+type taskResponse struct {
+	TaskID     int     `json:"task_id"`
+	Status     string  `json:"status"` // "enqueued" or "completed"
+	WaitMs     float64 `json:"wait_ms,omitempty"`
+	ResponseMs float64 `json:"response_ms,omitempty"`
+}
+
+// taskServer enqueues the tasks external clients submit over HTTP into the policy's queue,
+// and polls for their results, which it hands to the requests waiting for them
+type taskServer struct {
+	cluster *cluster
+	runID   string
+	webhook *webhookNotifier // Where completions are delivered too, nil without a webhook
+
+	mu          sync.Mutex
+	nextID      int
+	pending     map[int][]chan Task // Requests waiting for each unfinished task
+	completed   map[int]Task
+	subscribers map[chan Task]bool // Streams of every completion
+	stopped     bool
+
+	// Descriptors from the arrival source that were enqueued, and those that weren't
+	consumed      int
+	rejected      int
+	lastRejection error
+}
+
+func newTaskServer(cluster *cluster, runID string) *taskServer {
+	return &taskServer{
+		cluster:     cluster,
+		runID:       runID,
+		pending:     make(map[int][]chan Task),
+		completed:   make(map[int]Task),
+		subscribers: make(map[chan Task]bool),
+	}
+}
+
+// subscriberBuffer is the number of completions a stream can lag behind before the server
+// drops it
+const subscriberBuffer = 4096
+
+// taskDuration returns the duration of a submitted task: the one asked for, or else the
+// configured duration of its class
+func taskDuration(durationMs int64, class string) (time.Duration, error) {
+	switch {
+	case durationMs < 0:
+		return 0, fmt.Errorf("duration_ms can't be negative")
+	case durationMs > 0:
+		return time.Duration(durationMs) * time.Millisecond, nil
+	case class == "short":
+		return AppConfig.Workload.ShortTaskDuration(), nil
+	case class == "long":
+		return AppConfig.Workload.LongTaskDuration(), nil
+	}
+	return 0, fmt.Errorf(`set duration_ms, or class to "short" or "long"`)
+}
+
+// submit enqueues a task that arrives now. Unless wait is false, the channel it returns
+// receives the task once it completes.
+func (s *taskServer) submit(duration time.Duration, priority uint, deadline time.Time, wait bool) (Task, chan Task, error) {
+	s.mu.Lock()
+	task := Task{TaskID: s.nextID, Duration: duration, Priority: priority, Deadline: deadline, ArrivalTime: time.Now()}
+	s.nextID++
+	var done chan Task
+	if wait {
+		done = make(chan Task, 1)
+	}
+	s.pending[task.TaskID] = append(s.pending[task.TaskID], done)
+	s.mu.Unlock()
+
+	if err := s.cluster.queue.Enqueue(task, taskWorkflowID(s.runID, task.TaskID)); err != nil {
+		s.mu.Lock()
+		delete(s.pending, task.TaskID)
+		s.mu.Unlock()
+		return task, nil, fmt.Errorf("failed to enqueue task: %w", err)
+	}
+	return task, done, nil
+}
+
+// submitDescriptor enqueues the task a descriptor from the arrival source asks for.
+// Descriptors that aren't valid tasks, or fail to be enqueued, are counted and skipped.
+func (s *taskServer) submitDescriptor(descriptor []byte) {
+	var req taskRequest
+	err := json.Unmarshal(descriptor, &req)
+	var duration time.Duration
+	if err == nil {
+		duration, err = taskDuration(int64(req.DurationMs), req.Class)
+	}
+	if err == nil {
+		_, _, err = s.submit(duration, req.Priority, req.Deadline, false)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.rejected++
+		s.lastRejection = err
+		return
+	}
+	s.consumed++
+}
+
+// subscribe returns a channel that receives every task that completes from now on,
+// preceded by those of taskIDs that already completed. The channel is closed when the
+// server stops, or when the subscriber falls subscriberBuffer completions behind.
+func (s *taskServer) subscribe(taskIDs []int) chan Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan Task, subscriberBuffer+len(taskIDs))
+	for _, taskID := range taskIDs {
+		if task, ok := s.completed[taskID]; ok {
+			ch <- task
+		}
+	}
+	s.subscribers[ch] = true
+	return ch
+}
+
+func (s *taskServer) unsubscribe(ch chan Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers[ch] {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// closeSubscribers ends every stream of completions, once the server stopped
+func (s *taskServer) closeSubscribers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// handleSubmit serves POST /tasks. It waits for the task to complete, so clients measure
+// its response time end to end, unless the query sets wait=false.
+func (s *taskServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req taskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid task: %v", err), http.StatusBadRequest)
+		return
+	}
+	duration, err := taskDuration(int64(req.DurationMs), req.Class)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wait := r.URL.Query().Get("wait") != "false"
+	task, done, err := s.submit(duration, req.Priority, req.Deadline, wait)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !wait {
+		writeJSON(w, http.StatusAccepted, taskResponse{TaskID: task.TaskID, Status: "enqueued"})
+		return
+	}
+	select {
+	case task = <-done:
+		writeJSON(w, http.StatusOK, completedResponse(task))
+	case <-r.Context().Done():
+	}
+}
+
+// handleGet serves GET /tasks/{id}
+func (s *taskServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid task ID", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	task, completed := s.completed[taskID]
+	_, pending := s.pending[taskID]
+	s.mu.Unlock()
+	switch {
+	case completed:
+		writeJSON(w, http.StatusOK, completedResponse(task))
+	case pending:
+		writeJSON(w, http.StatusOK, taskResponse{TaskID: taskID, Status: "enqueued"})
+	default:
+		http.Error(w, "unknown task", http.StatusNotFound)
+	}
+}
+
+func completedResponse(task Task) taskResponse {
+	return taskResponse{
+		TaskID:     task.TaskID,
+		Status:     "completed",
+		WaitMs:     task.WaitTime().Seconds() * 1000,
+		ResponseMs: task.ResponseTime().Seconds() * 1000,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// poll looks up the unfinished tasks every resultPollInterval until ctx is done, and
+// hands the results of those that finished to the requests waiting for them
+func (s *taskServer) poll(ctx context.Context) error {
+	ticker := time.NewTicker(resultPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		ids := make([]string, 0, len(s.pending))
+		for taskID := range s.pending {
+			ids = append(ids, taskWorkflowID(s.runID, taskID))
+		}
+		s.mu.Unlock()
+
+		for start := 0; start < len(ids); start += resultBatchSize {
+			workflows, err := dbos.ListWorkflows(s.cluster.executors[0],
+				dbos.WithWorkflowIDs(ids[start:min(start+resultBatchSize, len(ids))]),
+				dbos.WithStatus(finishedStatuses),
+				dbos.WithLoadInput(false))
+			if err != nil {
+				return fmt.Errorf("failed to poll task results: %w", err)
+			}
+			for _, wf := range workflows {
+				task, err := decodeTask(wf)
+				if err != nil {
+					return err
+				}
+				if s.webhook != nil {
+					s.webhook.Notify(task)
+				}
+				s.mu.Lock()
+				for _, done := range s.pending[task.TaskID] {
+					if done != nil {
+						done <- task
+					}
+				}
+				delete(s.pending, task.TaskID)
+				s.completed[task.TaskID] = task
+				for ch := range s.subscribers {
+					select {
+					case ch <- task:
+					default:
+						delete(s.subscribers, ch)
+						close(ch)
+					}
+				}
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Stopped reports whether the server stopped streaming completions
+func (s *taskServer) Stopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// Completed returns the tasks that completed so far, in task ID order
+func (s *taskServer) Completed() []Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]Task, 0, len(s.completed))
+	for taskID := range s.nextID {
+		if task, ok := s.completed[taskID]; ok {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// serveCommand runs executors serving an algorithm's queue behind an HTTP API, so external
+// load generators can submit tasks. When interrupted, it stops accepting tasks and exports
+// the results of those that completed, like a run.
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	algo := fs.String("algo", "fcfs", "Scheduling algorithm whose queue to serve ("+algorithmNames()+")")
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	grpcAddr := fs.String("grpc-addr", "", "Address to serve the gRPC API on (api/tasks.proto), none when empty")
+	registerConfigFlags(fs)
+	fs.Parse(args)
+	if err := validateForRun(); err != nil {
+		return err
+	}
+
+	policy, err := lookupPolicy(*algo)
+	if err != nil {
+		return err
+	}
+	// Like a run, the server only runs the tasks submitted to it
+	runID := AppConfig.Queue.RunTagPrefix() + fmt.Sprintf("serve-%s-%s", policy.Name, time.Now().Format("20060102T150405.000"))
+	queueCfg := AppConfig.Queue
+	if queueCfg.RunTag == "" {
+		queueCfg.RunTag = runID
+	}
+	cluster, err := launchExecutors(policy, queueCfg)
+	if err != nil {
+		return err
+	}
+	defer cluster.Shutdown()
+
+	server := newTaskServer(cluster, runID)
+	server.webhook = newWebhookNotifier(AppConfig.Webhook, runID, policy.Name)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", server.handleSubmit)
+	mux.HandleFunc("GET /tasks/{id}", server.handleGet)
+	httpServer := &http.Server{Addr: *addr, Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	source := newArrivalSource(AppConfig.Arrivals)
+	sourceCtx, stopSource := context.WithCancel(context.Background())
+	defer stopSource()
+	sourced := make(chan error, 1)
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	defer stopPolling()
+	polled := make(chan error, 1)
+	go func() { polled <- server.poll(pollCtx) }()
+	served := make(chan error, 1)
+	go func() { served <- httpServer.ListenAndServe() }()
+	fmt.Printf("Serving %s at %s (run ID %s). POST tasks to /tasks; press Ctrl+C to stop.\n", policy.QueueName, *addr, runID)
+
+	grpcServer := grpc.NewServer()
+	api.RegisterTaskQueueServer(grpcServer, &grpcTaskServer{server: server})
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			httpServer.Close()
+			return fmt.Errorf("failed to listen on %s: %w", *grpcAddr, err)
+		}
+		go func() { served <- grpcServer.Serve(listener) }()
+		fmt.Printf("Serving the gRPC API at %s\n", *grpcAddr)
+	}
+	if source != nil {
+		go func() { sourced <- source.Run(sourceCtx, server.submitDescriptor) }()
+		fmt.Printf("Enqueueing the tasks published to the %s\n", source)
+	}
+
+	select {
+	case <-ctx.Done():
+	case err := <-served:
+		httpServer.Close()
+		grpcServer.Stop()
+		return fmt.Errorf("server failed: %w", err)
+	case err := <-polled:
+		httpServer.Close()
+		grpcServer.Stop()
+		return err
+	case err := <-sourced:
+		httpServer.Close()
+		grpcServer.Stop()
+		return err
+	}
+	// Requests still waiting for their task get until they complete, or a second signal.
+	// Completion streams go on until then too.
+	fmt.Printf("\nStopping: waiting for the requests in progress...\n")
+	stop()
+	if source != nil {
+		stopSource()
+		if err := <-sourced; err != nil {
+			return err
+		}
+	}
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	shutdownCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	stopPolling()
+	if err := <-polled; err != nil {
+		return err
+	}
+	server.closeSubscribers()
+	<-grpcStopped
+	if server.webhook != nil {
+		server.webhook.Close()
+	}
+
+	tasks := server.Completed()
+	fmt.Printf("%d tasks submitted, %d completed\n", server.nextID, len(tasks))
+	if source != nil {
+		fmt.Printf("%d tasks from the %s, %d descriptors rejected\n", server.consumed, source, server.rejected)
+		if server.lastRejection != nil {
+			fmt.Printf("  Last rejection: %v\n", server.lastRejection)
+		}
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	_, err = exportResults(tasks, policy, "serve")
+	return err
+}
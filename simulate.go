@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/experiment"
+)
+
+// printSimulationBanner explains that results come from the in-process simulation and how
+// to switch to a real database
+func printSimulationBanner() {
+	fmt.Println("============================================================")
+	fmt.Println("SIMULATION MODE: no database configured")
+	fmt.Println("============================================================")
+	fmt.Println("DBOS_SYSTEM_DATABASE_URL is not set, so tasks run through an in-process")
+	fmt.Println("simulation of the queue instead of Postgres. Results are idealized: no")
+	fmt.Println("polling delay, no dispatch overhead, no database contention.")
+	fmt.Println("For real measurements, set DBOS_SYSTEM_DATABASE_URL or use -db embedded.")
+	fmt.Println("============================================================")
+	fmt.Println()
+}
+
+// simulateExperiment runs the configured workload through the in-process engine of the
+// experiment package instead of DBOS: tasks are picked by the policy's priority, then
+// arrival, the instant a worker slot is free. Nothing sleeps, so results come out
+// immediately. Results are exported like a real run's, with "simulated" in the label.
+func simulateExperiment(policy SchedulingPolicy, queueCfg QueueConfig, label string) ([]Task, error) {
+	if AppConfig.Autoscaler.Enabled {
+		fmt.Println("Note: the simulation doesn't autoscale; it uses the autoscaler's maximum capacity")
+		queueCfg = AppConfig.Autoscaler.QueueConfig(queueCfg)
+	}
+	capacity := queueCfg.Capacity()
+	avgTaskDuration, interArrivalTime := shapeWorkload(queueCfg)
+	cfg := sizeWorkload(interArrivalTime)
+	printRunBanner(policy, queueCfg, avgTaskDuration, interArrivalTime)
+
+	fmt.Printf("\nSimulating %d tasks on %d worker slots...\n", cfg.NumTasks, capacity)
+	rateLimit := AppConfig.RateLimit.Policy()
+	reservations := AppConfig.Reservations.Policy(capacity)
+	if reservations != nil && (policy.Lanes != nil || policy.Dispatcher != nil) {
+		return nil, fmt.Errorf("%s can't be combined with reservations", policy.Name)
+	}
+	report, err := experiment.RunExperiment(experiment.Experiment{
+		Workload:     cfg,
+		Policy:       policy,
+		Capacity:     capacity,
+		Stages:       AppConfig.Pipeline.WorkloadStages(queueCfg),
+		Retry:        AppConfig.Retry.Policy(),
+		Watchdog:     AppConfig.Watchdog.Policy(),
+		DeadLetter:   AppConfig.Retry.DeadLetter,
+		ClientRetry:  AppConfig.ClientRetry.Policy(),
+		RateLimit:    rateLimit,
+		Reservations: reservations,
+		ColdStart:    AppConfig.ColdStart.Policy(),
+		Seed:         AppConfig.Workload.RunSeed(time.Now()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if report.Duplicates > 0 {
+		fmt.Printf("  %d duplicate requests dropped\n", report.Duplicates)
+	}
+	// Reports cover the tasks that completed; the cancellation and abandonment reports
+	// cover them all
+	tasks, _ := splitCompleted(report.Tasks)
+	fmt.Printf("\nAll %d tasks completed!\n", len(report.Tasks))
+
+	// Report like a real run, minus the reports about the database and the executors.
+	// Exporting prints the summary and the starvation report.
+	if label != "" {
+		label += "-"
+	}
+	label += "simulated"
+	filename, err := exportResults(tasks, policy, label)
+	if err != nil {
+		return nil, err
+	}
+	if err := exportTaskLogs(report.Tasks, policy, filename); err != nil {
+		return nil, err
+	}
+	printWaitBreakdown(tasks)
+	printPipelineReport(tasks)
+	printLockReport(tasks, policy)
+	printWatchdogReport(tasks, AppConfig.Watchdog)
+	sloResults := evaluateSLOs(tasks, AppConfig.SLOs)
+	printSLOReport(sloResults)
+	printFairnessReport(tasks)
+	printJobReport(tasks)
+	printDeadlineReport(tasks)
+	printRetryReport(tasks)
+	printDeadLetterReport(tasks)
+	printCancellationReport(report.Tasks, cfg.TargetUtilization)
+	printAbandonmentReport(report.Tasks)
+	printClientRetryReport(tasks, AppConfig.ClientRetry, cfg.TargetUtilization)
+	printStepReport(tasks)
+	printPhaseReport(tasks)
+	printOverloadReport(tasks)
+	if cfg.RunDuration() > 0 {
+		printSoakReport(soakWindowsFromTasks(tasks, AppConfig.Soak.Window()))
+	}
+	printConvoyReport(report.Tasks, policy)
+	printHOLReport(report.Tasks, policy)
+	printPredictionReport(tasks, policy)
+	printAdaptiveReport(tasks, policy, nil)
+	printAgentReport(policy)
+	printBatchReport(policy)
+	printFairShareReport(tasks, policy)
+	printRateLimitReport(rateLimit, tasks)
+	printReservationReport(reservations, tasks)
+	printColdStartReport(AppConfig.ColdStart.Policy(), tasks)
+	if AppConfig.Cost.Enabled() {
+		computeCost(AppConfig.Cost, tasks, fixedWorkerSeconds(tasks, capacity), sloResults).Print()
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Println("Simulation completed! Set DBOS_SYSTEM_DATABASE_URL to run on Postgres.")
+	fmt.Println("============================================================")
+	return report.Tasks, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sizeBinCount is the number of task size bins of the slowdown curve. Bins are the
+// quantiles of task size, so each holds about as many tasks whatever the distribution;
+// workloads with few distinct sizes, like the short/long mix, get one bin per size.
+const sizeBinCount = 10
+
+// sizeBin holds the slowdown of the tasks whose service time falls in a bin, from the
+// smallest to the largest of them
+type sizeBin struct {
+	Smallest     time.Duration
+	Largest      time.Duration
+	Tasks        int
+	MeanSize     time.Duration
+	MeanResponse time.Duration
+	MeanSlowdown float64
+	P99Slowdown  float64
+}
+
+// sizeBinEdges returns the edges of the size bins of the tasks, from the smallest service
+// time to just past the largest: the sizeBinCount quantiles of service time, merged where
+// they coincide. It returns nil when no task has a size.
+func sizeBinEdges(tasks []Task) []time.Duration {
+	var sizes []time.Duration
+	for _, task := range tasks {
+		if task.Duration > 0 {
+			sizes = append(sizes, task.Duration)
+		}
+	}
+	if len(sizes) == 0 {
+		return nil
+	}
+	slices.Sort(sizes)
+	var edges []time.Duration
+	for i := range sizeBinCount {
+		edge := sizes[i*len(sizes)/sizeBinCount]
+		if len(edges) == 0 || edge > edges[len(edges)-1] {
+			edges = append(edges, edge)
+		}
+	}
+	return append(edges, sizes[len(sizes)-1]+1)
+}
+
+// slowdownBySize returns the slowdown of the tasks in each bin between the edges, empty
+// bins included. Tasks outside the edges are left out.
+func slowdownBySize(tasks []Task, edges []time.Duration) []sizeBin {
+	if len(edges) < 2 {
+		return nil
+	}
+	bins := make([]sizeBin, len(edges)-1)
+	slowdowns := make([][]float64, len(bins))
+	sizes := make([]time.Duration, len(bins))
+	responses := make([]time.Duration, len(bins))
+	for _, task := range tasks {
+		i := sort.Search(len(edges), func(i int) bool { return edges[i] > task.Duration }) - 1
+		if task.Duration <= 0 || i < 0 || i >= len(bins) {
+			continue
+		}
+		if len(slowdowns[i]) == 0 || task.Duration < bins[i].Smallest {
+			bins[i].Smallest = task.Duration
+		}
+		bins[i].Largest = max(bins[i].Largest, task.Duration)
+		slowdowns[i] = append(slowdowns[i], slowdown(task))
+		sizes[i] += task.Duration
+		responses[i] += task.ResponseTime()
+	}
+	for i := range bins {
+		n := len(slowdowns[i])
+		bins[i].Tasks = n
+		if n == 0 {
+			continue
+		}
+		total := 0.0
+		for _, s := range slowdowns[i] {
+			total += s
+		}
+		sort.Float64s(slowdowns[i])
+		bins[i].MeanSize = sizes[i] / time.Duration(n)
+		bins[i].MeanResponse = responses[i] / time.Duration(n)
+		bins[i].MeanSlowdown = total / float64(n)
+		bins[i].P99Slowdown = slowdowns[i][min(n-1, int(math.Ceil(0.99*float64(n)))-1)]
+	}
+	return bins
+}
+
+// printSlowdownBySizeReport prints the slowdown curve of a run: the mean and p99 slowdown
+// of the tasks by size, and how the largest tasks fare relative to the smallest. Size-based
+// policies like SJF lower the mean slowdown by making large tasks wait behind small ones,
+// which shows as a curve rising with size.
+func printSlowdownBySizeReport(tasks []Task) {
+	var bins []sizeBin
+	for _, bin := range slowdownBySize(tasks, sizeBinEdges(tasks)) {
+		if bin.Tasks > 0 {
+			bins = append(bins, bin)
+		}
+	}
+	if len(bins) < 2 {
+		return
+	}
+	fmt.Printf("\nSlowdown by task size (response time / service time, by size quantile):\n")
+	fmt.Printf("  %-21s %7s %12s %12s %14s %14s\n", "Size (ms)", "Tasks", "Mean size", "Mean resp", "Mean slowdown", "p99 slowdown")
+	for _, bin := range bins {
+		fmt.Printf("  %-21s %7d %12s %12s %13.2fx %13.2fx\n", formatMs(bin.Smallest)+" - "+formatMs(bin.Largest), bin.Tasks,
+			formatMs(bin.MeanSize), formatMs(bin.MeanResponse), bin.MeanSlowdown, bin.P99Slowdown)
+	}
+	smallest, largest := bins[0], bins[len(bins)-1]
+	fmt.Printf("  Mean slowdown of the smallest tasks %.2fx, of the largest %.2fx\n", smallest.MeanSlowdown, largest.MeanSlowdown)
+}
+
+// sizesFilename returns the size scatter file that goes with a results file
+func sizesFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_sizes.csv"
+}
+
+// slowdownFilename returns the slowdown curve file that goes with a results file
+func slowdownFilename(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_slowdown.csv"
+}
+
+// exportSizes writes the service and response time of every task, for a scatter plot of
+// response time against size, and the binned slowdown curve next to the results file
+func exportSizes(tasks []Task, resultsFile string) error {
+	write := func(filename string, header []string, rows [][]string) error {
+		file, err := os.Create(filename)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", filename, err)
+		}
+		defer file.Close()
+		writer := csv.NewWriter(file)
+		writer.Write(header)
+		writer.WriteAll(rows)
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+		return nil
+	}
+	msValue := func(d time.Duration) string {
+		return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+	}
+
+	var scatter [][]string
+	for _, task := range tasks {
+		scatter = append(scatter, []string{fmt.Sprintf("%d", task.TaskID), taskClass(task),
+			msValue(task.Duration), msValue(task.ResponseTime()), fmt.Sprintf("%.4f", slowdown(task))})
+	}
+	if err := write(sizesFilename(resultsFile), []string{"task_id", "class", "service_time_ms", "response_time_ms", "slowdown"}, scatter); err != nil {
+		return err
+	}
+
+	var curve [][]string
+	for _, bin := range slowdownBySize(tasks, sizeBinEdges(tasks)) {
+		if bin.Tasks == 0 {
+			continue
+		}
+		curve = append(curve, []string{msValue(bin.Smallest), msValue(bin.Largest), fmt.Sprintf("%d", bin.Tasks), msValue(bin.MeanSize),
+			msValue(bin.MeanResponse), fmt.Sprintf("%.4f", bin.MeanSlowdown), fmt.Sprintf("%.4f", bin.P99Slowdown)})
+	}
+	header := []string{"size_min_ms", "size_max_ms", "tasks", "mean_service_time_ms", "mean_response_time_ms", "mean_slowdown", "p99_slowdown"}
+	if err := write(slowdownFilename(resultsFile), header, curve); err != nil {
+		return err
+	}
+	fmt.Printf("Sizes exported to %s, slowdown curve to %s\n", sizesFilename(resultsFile), slowdownFilename(resultsFile))
+	return nil
+}
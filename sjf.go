@@ -1,145 +1,21 @@
 package main
 
-import (
-	"context"
-	"fmt"
-	"math/rand"
-	"os"
-	"path/filepath"
-	"time"
+import "fifo-queue-demo/sched"
 
-	"github.com/dbos-inc/dbos-transact-golang/dbos"
-)
-
-// SJF implements the Shortest Job First scheduling algorithm
-func SJF() {
-	cfg := AppConfig.Workload
-	shortDuration := cfg.ShortTaskDuration()
-	longDuration := cfg.LongTaskDuration()
-
-	avgTaskDuration := time.Duration(float64(shortDuration)*cfg.ShortTaskProbability +
-		float64(longDuration)*(1-cfg.ShortTaskProbability))
-	interArrivalTime := time.Duration(float64(avgTaskDuration) / cfg.TargetUtilization)
-
-	fmt.Println("============================================================")
-	fmt.Println("SJF: Shortest Job First Queue Scheduling Demo")
-	fmt.Println("============================================================")
-	fmt.Printf("Configuration:\n")
-	fmt.Printf("  Number of tasks: %d\n", cfg.NumTasks)
-	fmt.Printf("  Short task duration: %v\n", shortDuration)
-	fmt.Printf("  Long task duration: %v\n", longDuration)
-	fmt.Printf("  Short task probability: %.0f%%\n", cfg.ShortTaskProbability*100)
-	fmt.Printf("  Average task duration: %v\n", avgTaskDuration)
-	fmt.Printf("  Target utilization: %.0f%%\n", cfg.TargetUtilization*100)
-	fmt.Printf("  Average inter-arrival time: %v\n", interArrivalTime)
-	fmt.Printf("  Queue: Priority queue (short=priority 1, long=priority 2) with single worker\n")
-	fmt.Println("============================================================")
-
-	// Initialize DBOS context with PostgreSQL
-	dbosContext, err := dbos.NewDBOSContext(context.Background(), dbos.Config{
-		AppName:     "sjf-queue-demo",
-		DatabaseURL: os.Getenv("DBOS_SYSTEM_DATABASE_URL"),
-	})
-	if err != nil {
-		panic(fmt.Sprintf("Initializing DBOS failed: %v", err))
-	}
-
-	// Create a priority queue with worker concurrency of 1 (single worker)
-	sjfQueue := dbos.NewWorkflowQueue(dbosContext, "sjf_queue", dbos.WithWorkerConcurrency(1), dbos.WithPriorityEnabled(), dbos.WithQueueBasePollingInterval(100*time.Millisecond), dbos.WithQueueMaxPollingInterval(10*time.Millisecond))
-
-	// Register the workflow
-	dbos.RegisterWorkflow(dbosContext, processTask)
-
-	// Launch DBOS
-	err = dbos.Launch(dbosContext)
-	if err != nil {
-		panic(fmt.Sprintf("Launching DBOS failed: %v", err))
-	}
-	defer dbos.Shutdown(dbosContext, 5*time.Second)
-
-	// Enqueue tasks one at a time, respecting arrival times
-	fmt.Printf("\nEnqueueing tasks to priority queue with respect to arrival times...\n")
-	startTime := time.Now()
-	handles := make([]dbos.WorkflowHandle[Task], cfg.NumTasks)
-	completedTasks := make([]Task, cfg.NumTasks)
-	shortCount := 0
-	longCount := 0
-
-	for i := range cfg.NumTasks {
-		// Pick task duration based on probability
-		var duration time.Duration
-		var priority int
-		if rand.Float64() < cfg.ShortTaskProbability {
-			duration = shortDuration
-			priority = 1 // Higher priority (lower number) for short tasks
-			shortCount++
-		} else {
-			duration = longDuration
-			priority = 2 // Lower priority (higher number) for long tasks
-			longCount++
-		}
-
-		// Calculate arrival time for this task
-		expectedArrivalTime := startTime.Add(time.Duration(i) * interArrivalTime)
-
-		// Sleep until the task is due
-		now := time.Now()
-		if expectedArrivalTime.After(now) {
-			time.Sleep(expectedArrivalTime.Sub(now))
-		}
-
-		// Create task with current time as arrival time
-		task := Task{
-			TaskID:      i,
-			Duration:    duration,
-			ArrivalTime: time.Now(),
-		}
-
-		// Enqueue the task with priority
-		handle, err := dbos.RunWorkflow(dbosContext, processTask, task, dbos.WithQueue(sjfQueue.Name), dbos.WithPriority(uint(priority)))
-		if err != nil {
-			panic(fmt.Sprintf("Failed to enqueue task %d: %v", i, err))
-		}
-		handles[i] = handle
-
-		if (i+1)%10 == 0 {
-			fmt.Printf("  Enqueued %d/%d tasks...\n", i+1, cfg.NumTasks)
-		}
-	}
-
-	fmt.Printf("\nAll %d tasks enqueued (%d short, %d long). Processing...\n", cfg.NumTasks, shortCount, longCount)
-
-	// Wait for all tasks to complete and collect results
-	for i, handle := range handles {
-		result, err := handle.GetResult()
-		if err != nil {
-			panic(fmt.Sprintf("Task %d failed: %v", i, err))
-		}
-		completedTasks[i] = result
-		if (i+1)%10 == 0 {
-			fmt.Printf("  Completed %d/%d tasks...\n", i+1, cfg.NumTasks)
-		}
-	}
-
-	fmt.Printf("\nAll %d tasks completed!\n", len(completedTasks))
-
-	// Create results directory if it doesn't exist
-	resultsDir := "results"
-	if err := os.MkdirAll(resultsDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create results directory: %v", err))
-	}
-
-	// Generate unique filename with timestamp
-	timestamp := time.Now().Format("20060102_150405")
-	filename := filepath.Join(resultsDir, fmt.Sprintf("sjf_results_%s.csv", timestamp))
+// sjfPolicy returns the Shortest Job First policy with the configured cutoff between
+// short and long tasks
+func sjfPolicy(cfg SJFConfig) SchedulingPolicy {
+	return sched.SJF(cfg.Cutoff(AppConfig.Workload))
+}
 
-	// Export results to CSV
-	fmt.Printf("\nExporting results...\n")
-	if err := exportToCSV(completedTasks, filename); err != nil {
-		panic(fmt.Sprintf("Failed to export CSV: %v", err))
-	}
+// sjfLanesPolicy returns Shortest Job First backed by a queue per priority, split at the
+// cutoff of Shortest Job First
+func sjfLanesPolicy(cfg AlgorithmsConfig) SchedulingPolicy {
+	lanes := cfg.SJFLanes
+	return sched.SJFLanes(cfg.SJF.Cutoff(AppConfig.Workload), lanes.Mode == "strict", lanes.ShortWeight, lanes.LongWeight)
+}
 
-	fmt.Println("\n============================================================")
-	fmt.Println("Demo completed successfully!")
-	fmt.Println("============================================================")
+// sjfBucketsPolicy returns Shortest Job First on the configured size buckets
+func sjfBucketsPolicy(cfg AlgorithmsConfig) SchedulingPolicy {
+	return sched.SJFBuckets(cfg.SJFBuckets.Boundaries())
 }
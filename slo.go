@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// SLOResult is the outcome of one latency SLO over a run
+type SLOResult struct {
+	SLO        SLOConfig
+	Tasks      int           // Tasks of the SLO's class
+	Observed   time.Duration // Observed latency at the SLO's percentile
+	Met        bool          // Whether the observed percentile is within the threshold
+	Violations int           // Tasks whose latency exceeded the threshold
+	Attainment float64       // Fraction of tasks within the threshold
+	MaxStreak  int           // Longest run of consecutive tasks (in arrival order) over the threshold
+}
+
+// taskClass returns the workload class of a task ("short" or "long")
+func taskClass(task Task) string {
+	if AppConfig.Workload.IsShort(task.Duration) {
+		return "short"
+	}
+	return "long"
+}
+
+// evaluateSLOs checks every configured SLO against the completed tasks
+func evaluateSLOs(tasks []Task, slos []SLOConfig) []SLOResult {
+	// Streaks follow arrival order
+	ordered := append([]Task(nil), tasks...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].ArrivalTime.Before(ordered[j].ArrivalTime) })
+
+	results := make([]SLOResult, 0, len(slos))
+	for _, slo := range slos {
+		result := SLOResult{SLO: slo}
+		threshold := slo.Threshold()
+		var latencies []time.Duration
+		streak := 0
+		for _, task := range ordered {
+			if slo.Class != "all" && taskClass(task) != slo.Class {
+				continue
+			}
+			latency := slo.Latency(task)
+			latencies = append(latencies, latency)
+			if latency > threshold {
+				result.Violations++
+				streak++
+				result.MaxStreak = max(result.MaxStreak, streak)
+			} else {
+				streak = 0
+			}
+		}
+
+		result.Tasks = len(latencies)
+		if result.Tasks > 0 {
+			result.Observed = metrics.Percentile(latencies, slo.Percentile)
+			result.Met = result.Observed <= threshold
+			result.Attainment = float64(result.Tasks-result.Violations) / float64(result.Tasks)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// printSLOReport prints the attainment of each SLO
+func printSLOReport(results []SLOResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Printf("\nSLOs:\n")
+	for _, result := range results {
+		slo := result.SLO
+		status := "MET"
+		if !result.Met {
+			status = "VIOLATED"
+		}
+		fmt.Printf("  %s tasks p%g %s < %d ms: %s (observed %s ms, n=%d)\n",
+			slo.Class, slo.Percentile, slo.Metric, slo.ThresholdMs, status, formatMs(result.Observed), result.Tasks)
+		fmt.Printf("    Attainment: %.2f%%, violations: %d, longest violation streak: %d tasks\n",
+			result.Attainment*100, result.Violations, result.MaxStreak)
+	}
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// soakWarmupFraction is the share of a soak run's first windows left out of its trends,
+// while the queue fills up to its steady state
+const soakWarmupFraction = 0.1
+
+// soakDriftTolerance is how much a metric can move over the steady state of a soak run,
+// relative to its mean, before the report flags it as drifting, and soakDriftSignificance
+// how many standard errors from flat its slope must be, so noisy windows aren't mistaken
+// for a drift
+const (
+	soakDriftTolerance    = 0.25
+	soakDriftSignificance = 2
+)
+
+// soakMetric is a statistic soak runs follow from window to window
+type soakMetric struct {
+	Name   string
+	Column string // Column of the windows CSV file
+	Unit   string
+}
+
+// soakMetrics lists the statistics of a window, in the order of soakWindow.Values
+var soakMetrics = []soakMetric{
+	{"Throughput", "throughput", "tasks/s"},
+	{"Response p50", "response_p50_ms", "ms"},
+	{"Response p99", "response_p99_ms", "ms"},
+	{"Mean wait", "wait_mean_ms", "ms"},
+	{"Backlog", "backlog", "tasks"},
+	{"Connections in use", "pool_in_use", "conns"},
+	{"Pool waits", "pool_wait_pct", "%"},
+	{"Queue tables", "queue_tables_mb", "MB"},
+	{"Dead tuples", "dead_tuples", "tuples"},
+	{"Heap", "heap_mb", "MB"},
+}
+
+// Indices of the soak metrics
+const (
+	soakThroughput = iota
+	soakResponseP50
+	soakResponseP99
+	soakMeanWait
+	soakBacklog
+	soakPoolInUse
+	soakPoolWaits
+	soakQueueTables
+	soakDeadTuples
+	soakHeap
+)
+
+// soakWindow holds the statistics of one window of a soak run
+type soakWindow struct {
+	End       time.Duration // Since the start of the run
+	Length    time.Duration
+	Completed int
+	Failed    int
+	Draining  bool      // Whether tasks stopped arriving before the window ended
+	Values    []float64 // By soakMetrics index, NaN when unknown
+}
+
+func newSoakWindow(end, length time.Duration) soakWindow {
+	w := soakWindow{End: end, Length: length, Values: make([]float64, len(soakMetrics))}
+	for i := range w.Values {
+		w.Values[i] = math.NaN()
+	}
+	return w
+}
+
+// soakMonitor follows a soak run as it goes: executors hand it the tasks they complete,
+// and at the end of every window it adds up their throughput and latencies with the
+// backlog, connection pool usage, queue table size and heap of the moment. Each window is
+// appended to a CSV file and printed, and every checkpoint interval the run so far is
+// checkpointed to a JSON file, so a run that dies hours in still leaves its trends behind.
+type soakMonitor struct {
+	cfg            SoakConfig
+	start          time.Time
+	queue          taskQueue
+	pools          *poolMonitor
+	resources      *resourceMonitor
+	file           *os.File
+	writer         *csv.Writer
+	checkpointFile string
+
+	mu             sync.Mutex
+	response       *metrics.Histogram // Of the tasks completed in the current window
+	wait           *metrics.Histogram
+	completed      int
+	failed         int
+	windows        []soakWindow
+	total          int // Tasks completed in the previous windows
+	totalFailed    int
+	lastCheckpoint time.Time
+	checkpointed   int   // Windows in the last checkpoint
+	waited         int64 // Pool acquires that waited, at the end of the previous window
+	acquires       int64
+	err            error // First failure to write the windows or a checkpoint
+
+	draining bool // Whether tasks stopped arriving
+
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// startSoakMonitor starts following the soak run with the given ID, which started at
+// start. Its windows go to results/<run ID>_windows.csv.
+func startSoakMonitor(cfg SoakConfig, runID string, start time.Time, queue taskQueue, pools *poolMonitor, resources *resourceMonitor) (*soakMonitor, error) {
+	if err := os.MkdirAll("results", 0755); err != nil {
+		return nil, fmt.Errorf("failed to create results directory: %w", err)
+	}
+	base := filepath.Join("results", runID)
+	file, err := os.Create(base + "_windows.csv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create soak windows file: %w", err)
+	}
+	m := &soakMonitor{
+		cfg:            cfg,
+		start:          start,
+		queue:          queue,
+		pools:          pools,
+		resources:      resources,
+		file:           file,
+		writer:         csv.NewWriter(file),
+		checkpointFile: base + "_checkpoint.json",
+		lastCheckpoint: start,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	m.resetWindow()
+	header := []string{"window_end_s", "window_s", "completed", "failed", "draining"}
+	for _, metric := range soakMetrics {
+		header = append(header, metric.Column)
+	}
+	m.writer.Write(header)
+	m.writer.Flush()
+	if err := m.writer.Error(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write soak windows file: %w", err)
+	}
+	if pools != nil {
+		_, m.waited, m.acquires = pools.Usage()
+	}
+	fmt.Printf("Soak run: statistics every %v to %s, checkpoints every %v to %s\n",
+		cfg.Window(), file.Name(), cfg.CheckpointInterval(), m.checkpointFile)
+	go m.run()
+	return m, nil
+}
+
+// register makes the soak monitor follow the run: it adds up the tasks completed in each
+// window, marks the windows after the last arrival as draining, and reports the trends
+func (m *soakMonitor) register(r *experimentRun) {
+	r.OnCompleted(m.Record)
+	r.OnArrived(m.EndArrivals)
+	r.OnCollected(func([]Task) error {
+		m.Stop()
+		return nil
+	})
+	r.OnReport(func(*runOutcome) error {
+		m.Print()
+		return nil
+	})
+	r.OnClose(m.Stop)
+}
+
+// resetWindow starts a new window. Callers hold the lock.
+func (m *soakMonitor) resetWindow() {
+	cfg := AppConfig.Metrics
+	m.response = metrics.NewHistogram(cfg.HistogramMax(), cfg.HistogramSignificantFigures)
+	m.wait = metrics.NewHistogram(cfg.HistogramMax(), cfg.HistogramSignificantFigures)
+	m.completed, m.failed = 0, 0
+}
+
+// Record adds a task that completed to the current window
+func (m *soakMonitor) Record(task Task) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.response.Record(max(0, task.ResponseTime()))
+	m.wait.Record(max(0, task.WaitTime()))
+	m.completed++
+	if task.Failed {
+		m.failed++
+	}
+}
+
+// EndArrivals records that the last task arrived: the windows from here on drain the queue
+func (m *soakMonitor) EndArrivals() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.draining = true
+}
+
+func (m *soakMonitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.cfg.Window())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.closeWindow()
+		}
+	}
+}
+
+// closeWindow ends the current window: it samples the queue, the pools, the tables and
+// the heap, then records, prints and checkpoints the window
+func (m *soakMonitor) closeWindow() {
+	now := time.Now()
+	backlog, backlogErr := m.queue.Depth()
+	tableBytes, dead, tablesOK := m.resources.QueueTables()
+	heap := heapBytes()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	end := now.Sub(m.start)
+	length := m.cfg.Window()
+	if len(m.windows) > 0 {
+		length = end - m.windows[len(m.windows)-1].End
+	}
+	w := newSoakWindow(end, min(length, end))
+	w.Completed, w.Failed, w.Draining = m.completed, m.failed, m.draining
+	w.Values[soakThroughput] = float64(m.completed) / w.Length.Seconds()
+	if m.completed > 0 {
+		w.Values[soakResponseP50] = durationMs(m.response.ValueAtPercentile(50))
+		w.Values[soakResponseP99] = durationMs(m.response.ValueAtPercentile(99))
+		w.Values[soakMeanWait] = durationMs(m.wait.Mean())
+	}
+	if backlogErr == nil {
+		w.Values[soakBacklog] = float64(backlog)
+	}
+	if m.pools != nil {
+		inUse, waited, acquires := m.pools.Usage()
+		w.Values[soakPoolInUse] = float64(inUse)
+		if acquires > m.acquires {
+			w.Values[soakPoolWaits] = 100 * float64(waited-m.waited) / float64(acquires-m.acquires)
+		} else {
+			w.Values[soakPoolWaits] = 0
+		}
+		m.waited, m.acquires = waited, acquires
+	}
+	if tablesOK {
+		w.Values[soakQueueTables] = float64(tableBytes) / 1e6
+		w.Values[soakDeadTuples] = float64(dead)
+	}
+	if heap > 0 {
+		w.Values[soakHeap] = float64(heap) / 1e6
+	}
+	m.windows = append(m.windows, w)
+	m.total += m.completed
+	m.totalFailed += m.failed
+	m.resetWindow()
+
+	printSoakWindow(w)
+	if err := m.writeWindow(w); err != nil && m.err == nil {
+		m.err = err
+		fmt.Printf("  Warning: %v\n", err)
+	}
+	if now.Sub(m.lastCheckpoint) >= m.cfg.CheckpointInterval() {
+		m.checkpoint(now)
+	}
+}
+
+// writeWindow appends a window to the windows file and flushes it. Callers hold the lock.
+func (m *soakMonitor) writeWindow(w soakWindow) error {
+	row := []string{
+		strconv.FormatFloat(w.End.Seconds(), 'f', 3, 64),
+		strconv.FormatFloat(w.Length.Seconds(), 'f', 3, 64),
+		strconv.Itoa(w.Completed),
+		strconv.Itoa(w.Failed),
+		strconv.FormatBool(w.Draining),
+	}
+	for _, value := range w.Values {
+		if math.IsNaN(value) {
+			row = append(row, "")
+		} else {
+			row = append(row, strconv.FormatFloat(value, 'f', 3, 64))
+		}
+	}
+	m.writer.Write(row)
+	m.writer.Flush()
+	if err := m.writer.Error(); err != nil {
+		return fmt.Errorf("failed to write soak windows file: %w", err)
+	}
+	return nil
+}
+
+// soakCheckpoint is the state of a soak run as checkpointed
+type soakCheckpoint struct {
+	At        time.Time   `json:"at"`
+	ElapsedS  float64     `json:"elapsed_s"`
+	Windows   int         `json:"windows"`
+	Completed int         `json:"completed"`
+	Failed    int         `json:"failed"`
+	Trends    []soakTrend `json:"trends"`
+}
+
+// checkpoint replaces the checkpoint file with the run so far. The file is written to a
+// temporary file first, so a crash never leaves a partial checkpoint. Callers hold the
+// lock.
+func (m *soakMonitor) checkpoint(now time.Time) {
+	m.lastCheckpoint, m.checkpointed = now, len(m.windows)
+	trends := soakTrends(m.windows)
+	if trends == nil {
+		trends = []soakTrend{}
+	}
+	data, err := json.MarshalIndent(soakCheckpoint{
+		At:        now,
+		ElapsedS:  now.Sub(m.start).Seconds(),
+		Windows:   len(m.windows),
+		Completed: m.total,
+		Failed:    m.totalFailed,
+		Trends:    trends,
+	}, "", "  ")
+	if err == nil {
+		tmp := m.checkpointFile + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0644); err == nil {
+			err = os.Rename(tmp, m.checkpointFile)
+		}
+	}
+	if err != nil {
+		if m.err == nil {
+			m.err = err
+		}
+		fmt.Printf("  Warning: failed to checkpoint the soak run: %v\n", err)
+		return
+	}
+	fmt.Printf("  Checkpoint: %d tasks completed in %v, written to %s\n",
+		m.total, now.Sub(m.start).Round(time.Second), m.checkpointFile)
+}
+
+// Stop ends the last window, writes the final checkpoint and closes the windows file
+func (m *soakMonitor) Stop() {
+	if m == nil || m.stopped {
+		return
+	}
+	m.stopped = true
+	close(m.stop)
+	<-m.done
+	m.mu.Lock()
+	partial := m.completed > 0
+	m.mu.Unlock()
+	if partial {
+		m.closeWindow()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.checkpointed < len(m.windows) {
+		m.checkpoint(time.Now())
+	}
+	m.file.Close()
+}
+
+// Print reports the trends of the soak run
+func (m *soakMonitor) Print() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	printSoakReport(m.windows)
+}
+
+// printSoakWindow prints a one-line summary of a window as it ends
+func printSoakWindow(w soakWindow) {
+	line := fmt.Sprintf("  [%v] %d tasks completed (%.1f/s)", w.End.Round(time.Second), w.Completed, w.Values[soakThroughput])
+	if w.Failed > 0 {
+		line += fmt.Sprintf(", %d failed", w.Failed)
+	}
+	if w.Completed > 0 {
+		line += fmt.Sprintf(", response p50 %.1f / p99 %.1f ms, wait %.1f ms",
+			w.Values[soakResponseP50], w.Values[soakResponseP99], w.Values[soakMeanWait])
+	}
+	if v := w.Values[soakBacklog]; !math.IsNaN(v) {
+		line += fmt.Sprintf(", backlog %.0f", v)
+	}
+	if v := w.Values[soakPoolInUse]; !math.IsNaN(v) {
+		line += fmt.Sprintf(", %.0f connections in use (%.1f%% of acquires waited)", v, w.Values[soakPoolWaits])
+	}
+	if v := w.Values[soakQueueTables]; !math.IsNaN(v) {
+		line += fmt.Sprintf(", queue tables %.1f MB (%.0f dead tuples)", v, w.Values[soakDeadTuples])
+	}
+	if v := w.Values[soakHeap]; !math.IsNaN(v) {
+		line += fmt.Sprintf(", heap %.1f MB", v)
+	}
+	fmt.Println(line)
+}
+
+// soakTrend is how a metric evolved over the steady state of a soak run: its first, last
+// and mean values, and the slope of a least-squares line through its windows
+type soakTrend struct {
+	Metric   string  `json:"metric"`
+	Unit     string  `json:"unit"`
+	First    float64 `json:"first"`
+	Last     float64 `json:"last"`
+	Mean     float64 `json:"mean"`
+	PerHour  float64 `json:"per_hour"`
+	Drifting bool    `json:"drifting"` // The fitted change over the steady state is significant and exceeds soakDriftTolerance of the mean
+}
+
+// soakSteadyState returns the windows of a soak run past its warm-up and before it
+// started draining, and how many windows of warm-up were skipped
+func soakSteadyState(windows []soakWindow) ([]soakWindow, int) {
+	arriving := len(windows)
+	for arriving > 0 && windows[arriving-1].Draining {
+		arriving--
+	}
+	warmup := int(math.Ceil(soakWarmupFraction * float64(arriving)))
+	return windows[min(warmup, arriving):arriving], warmup
+}
+
+// soakTrends fits the trend of each metric over the steady state of a soak run. Metrics
+// unknown in some windows are fitted over the others; metrics known in fewer than three
+// windows are left out.
+func soakTrends(windows []soakWindow) []soakTrend {
+	steady, _ := soakSteadyState(windows)
+	var trends []soakTrend
+	for i, metric := range soakMetrics {
+		var xs, ys []float64
+		for _, w := range steady {
+			if !math.IsNaN(w.Values[i]) {
+				xs = append(xs, w.End.Hours())
+				ys = append(ys, w.Values[i])
+			}
+		}
+		if len(xs) < 3 {
+			continue
+		}
+		slope, stderr, mean := linearFit(xs, ys)
+		trend := soakTrend{Metric: metric.Name, Unit: metric.Unit, First: ys[0], Last: ys[len(ys)-1], Mean: mean, PerHour: slope}
+		change := math.Abs(slope * (xs[len(xs)-1] - xs[0]))
+		trend.Drifting = mean != 0 && change > soakDriftTolerance*math.Abs(mean) &&
+			math.Abs(slope) > soakDriftSignificance*stderr
+		trends = append(trends, trend)
+	}
+	return trends
+}
+
+// linearFit returns the slope of the least-squares line through the points, its standard
+// error, and the mean of their y values
+func linearFit(xs, ys []float64) (slope, stderr, meanY float64) {
+	var meanX float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+	var covariance, variance float64
+	for i := range xs {
+		covariance += (xs[i] - meanX) * (ys[i] - meanY)
+		variance += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if variance == 0 {
+		return 0, 0, meanY
+	}
+	slope = covariance / variance
+	var residuals float64
+	for i := range xs {
+		residual := ys[i] - meanY - slope*(xs[i]-meanX)
+		residuals += residual * residual
+	}
+	if len(xs) > 2 {
+		stderr = math.Sqrt(residuals / float64(len(xs)-2) / variance)
+	}
+	return slope, stderr, meanY
+}
+
+// printSoakReport prints the trend of every metric over the steady state of a soak run,
+// flagging those that drifted
+func printSoakReport(windows []soakWindow) {
+	if len(windows) == 0 {
+		return
+	}
+	fmt.Printf("\nSoak (%d windows over %v):\n", len(windows), windows[len(windows)-1].End.Round(time.Second))
+	trends := soakTrends(windows)
+	if len(trends) == 0 {
+		fmt.Printf("  Too few windows for trends; run longer or shorten soak.window_s\n")
+		return
+	}
+	steady, warmup := soakSteadyState(windows)
+	fmt.Printf("  Trends over %d windows, after a warm-up of %d and before the queue drained (first, last and mean window; fitted change per hour):\n",
+		len(steady), warmup)
+	fmt.Printf("    %-20s %12s %12s %12s %14s\n", "Metric", "First", "Last", "Mean", "Per hour")
+	var drifting []string
+	for _, t := range trends {
+		flag := ""
+		if t.Drifting {
+			flag = "  drifting"
+			drifting = append(drifting, t.Metric)
+		}
+		fmt.Printf("    %-20s %12.1f %12.1f %12.1f %+14.2f %s%s\n", t.Metric, t.First, t.Last, t.Mean, t.PerHour, t.Unit, flag)
+	}
+	if len(drifting) > 0 {
+		fmt.Printf("  Warning: %s drifted by more than %.0f%% of their mean over the run, which may not have reached a steady state\n",
+			strings.Join(drifting, ", "), soakDriftTolerance*100)
+	}
+}
+
+// soakWindowsFromTasks cuts the tasks of a run into windows by completion time, for runs
+// that weren't followed as they went, like simulated ones. The backlog of a window is
+// the tasks arrived but not started at its end, and windows that end after the last
+// arrival are draining; the database and process metrics are unknown.
+func soakWindowsFromTasks(tasks []Task, length time.Duration) []soakWindow {
+	if len(tasks) == 0 || length <= 0 {
+		return nil
+	}
+	start, lastArrival, last := tasks[0].ArrivalTime, tasks[0].ArrivalTime, tasks[0].CompletionTime
+	for _, task := range tasks {
+		if task.ArrivalTime.Before(start) {
+			start = task.ArrivalTime
+		}
+		if task.ArrivalTime.After(lastArrival) {
+			lastArrival = task.ArrivalTime
+		}
+		if task.CompletionTime.After(last) {
+			last = task.CompletionTime
+		}
+	}
+	count := int(last.Sub(start)/length) + 1
+	groups := make([][]Task, count)
+	for _, task := range tasks {
+		i := int(task.CompletionTime.Sub(start) / length)
+		groups[i] = append(groups[i], task)
+	}
+	windows := make([]soakWindow, count)
+	for i, group := range groups {
+		end := time.Duration(i+1) * length
+		w := newSoakWindow(end, length)
+		w.Completed = len(group)
+		w.Draining = start.Add(end).After(lastArrival)
+		w.Values[soakThroughput] = float64(len(group)) / length.Seconds()
+		if len(group) > 0 {
+			response := metrics.SummarizeTasks(group, nil, Task.ResponseTime)
+			w.Values[soakResponseP50] = durationMs(response.Median)
+			w.Values[soakResponseP99] = durationMs(response.P99)
+			w.Values[soakMeanWait] = durationMs(summarizeWaitTimes(group, nil).Mean)
+		}
+		backlog := 0
+		for _, task := range tasks {
+			at := start.Add(end)
+			if task.ArrivalTime.Before(at) && !task.DequeueTime.Before(at) {
+				backlog++
+			}
+		}
+		w.Values[soakBacklog] = float64(backlog)
+		for _, task := range group {
+			if task.Failed {
+				w.Failed++
+			}
+		}
+		windows[i] = w
+	}
+	return windows
+}
+
+// durationMs returns a duration in fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// standardScenario is a benchmark of the standard suite: a fully specified workload and
+// queue, with a fixed seed, run on every algorithm with its default settings. Nothing in
+// config.yaml changes what it runs, only where it runs (the database) and what gets
+// reported, so its results compare across machines and papers. A scenario's version
+// goes up whenever its specification changes, and its results are labelled with it.
+type standardScenario struct {
+	Name              string
+	Version           int
+	Description       string
+	Workload          WorkloadConfig
+	WorkerConcurrency int // On a single executor
+
+	// Compare runs the algorithms and prints their comparison, nil to run every
+	// algorithm and compare their latency
+	Compare func(label string) error
+}
+
+// standardScenarios is the standard suite, every version of every scenario. Versions
+// are never changed once released: a new specification is a new version.
+var standardScenarios = []standardScenario{
+	{
+		Name:        "light",
+		Version:     1,
+		Description: "Short/long mix at 30% utilization, where dispatch overhead dominates the wait",
+		Workload: WorkloadConfig{
+			NumTasks:             500,
+			ShortTaskDurationMs:  100,
+			LongTaskDurationMs:   2000,
+			ShortTaskProbability: 0.8,
+			TargetUtilization:    0.3,
+			Seed:                 1,
+		},
+		WorkerConcurrency: 4,
+	},
+	{
+		Name:        "heavytail",
+		Version:     1,
+		Description: "Highly variable task durations (mean 200 ms, C² 20) at 70% utilization",
+		Workload: WorkloadConfig{
+			NumTasks:             1000,
+			ShortTaskDurationMs:  100,
+			LongTaskDurationMs:   2000,
+			ShortTaskProbability: 0.8,
+			ServiceTimeMeanMs:    200,
+			ServiceTimeSCV:       20,
+			TargetUtilization:    0.7,
+			Seed:                 1,
+		},
+		WorkerConcurrency: 4,
+	},
+	{
+		Name:        "overload",
+		Version:     1,
+		Description: "Short/long mix at 120% utilization for one minute, then drain; ranks policies by graceful degradation",
+		Workload: WorkloadConfig{
+			ShortTaskDurationMs:  100,
+			LongTaskDurationMs:   2000,
+			ShortTaskProbability: 0.8,
+			TargetUtilization:    overloadUtilization,
+			OverloadDurationMs:   60000,
+			Seed:                 1,
+		},
+		WorkerConcurrency: 4,
+		Compare:           func(string) error { return overloadScenario() },
+	},
+	{
+		Name:        "multitenant",
+		Version:     1,
+		Description: "Short/long mix from 8 tenants at 70% utilization, with per-tenant fairness",
+		Workload: WorkloadConfig{
+			NumTasks:             1000,
+			ShortTaskDurationMs:  100,
+			LongTaskDurationMs:   2000,
+			ShortTaskProbability: 0.8,
+			TargetUtilization:    0.7,
+			NumTenants:           8,
+			Seed:                 1,
+		},
+		WorkerConcurrency: 4,
+	},
+}
+
+// lookupStandardScenario returns the standard scenario named name, in its latest version,
+// or in version N for name-vN
+func lookupStandardScenario(name string) (standardScenario, bool) {
+	version := 0
+	if base, v, ok := strings.Cut(name, "-v"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return standardScenario{}, false
+		}
+		name, version = base, n
+	}
+	var found standardScenario
+	for _, s := range standardScenarios {
+		if s.Name == name && (s.Version == version || version == 0 && s.Version > found.Version) {
+			found = s
+		}
+	}
+	return found, found.Name != ""
+}
+
+// Label returns the versioned name of the scenario, e.g. heavytail-v1
+func (s standardScenario) Label() string {
+	return fmt.Sprintf("%s-v%d", s.Name, s.Version)
+}
+
+// standardScenarioRunner returns the Run function of the latest version of a standard
+// scenario, for the scenarios registry
+func standardScenarioRunner(name string) func() error {
+	return func() error { return runStandardScenario(name) }
+}
+
+// runStandardScenario runs a standard scenario on its pinned configuration, and restores
+// the configuration afterwards
+func runStandardScenario(name string) error {
+	s, ok := lookupStandardScenario(name)
+	if !ok {
+		return fmt.Errorf("unknown standard scenario: %s", name)
+	}
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig = s.Config(saved)
+
+	w := AppConfig.Workload
+	fmt.Printf("Standard scenario %s: %s\n", s.Label(), s.Description)
+	fmt.Printf("  Workload: ")
+	if w.OverloadDurationMs > 0 {
+		fmt.Printf("arrivals for %v", w.OverloadDuration())
+	} else {
+		fmt.Printf("%d tasks", w.NumTasks)
+	}
+	if w.ServiceTimeSCV > 0 {
+		fmt.Printf(", durations with mean %v and C² %g", w.MeanTaskDuration(), w.ServiceTimeSCV)
+	} else {
+		fmt.Printf(", %.0f%% of %v, else %v", w.ShortTaskProbability*100, w.ShortTaskDuration(), w.LongTaskDuration())
+	}
+	if w.NumTenants > 0 {
+		fmt.Printf(", %d tenants", w.NumTenants)
+	}
+	fmt.Printf(", utilization %.0f%%, seed %d\n", w.TargetUtilization*100, w.Seed)
+	fmt.Printf("  Queue: %d worker slots on one executor, %s dispatch\n", AppConfig.Queue.Capacity(), AppConfig.Queue.Dispatch)
+
+	if s.Compare != nil {
+		return s.Compare(s.Label())
+	}
+	return compareAlgorithms(s.Label())
+}
+
+// Config returns the configuration the scenario runs on: the defaults with the
+// scenario's workload and queue. Only the database, the report settings and the run tag
+// come from cfg.
+func (s standardScenario) Config(cfg Config) Config {
+	pinned := defaultConfig()
+	mergeConfig(&pinned, Config{
+		Workload: s.Workload,
+		Queue:    QueueConfig{WorkerConcurrency: s.WorkerConcurrency},
+	})
+	pinned.Queue.RunTag = cfg.Queue.RunTag
+	pinned.Database = cfg.Database
+	pinned.Metrics = cfg.Metrics
+	pinned.SLOs = cfg.SLOs
+	pinned.Cost = cfg.Cost
+	pinned.Starvation = cfg.Starvation
+	pinned.Convoys = cfg.Convoys
+	pinned.Webhook = cfg.Webhook
+	return pinned
+}
+
+// compareAlgorithms runs every algorithm on the current configuration and prints their
+// latency side by side, with tenant fairness when tasks have tenants
+func compareAlgorithms(label string) error {
+	type result struct {
+		algorithm      string
+		response, wait ResponseSummary
+		short, long    ResponseSummary
+		fairness       FairnessSummary
+		hasFairness    bool
+	}
+	var results []result
+	isShort := func(task Task) bool { return AppConfig.Workload.IsShort(task.Duration) }
+	isLong := func(task Task) bool { return !AppConfig.Workload.IsShort(task.Duration) }
+	for _, name := range sortedKeys(algorithms) {
+		policy, err := lookupPolicy(name)
+		if err != nil {
+			return err
+		}
+		tasks, err := runExperiment(policy, AppConfig.Queue, label)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		r := result{
+			algorithm: name,
+			response:  summarizeResponseTimes(tasks, nil),
+			wait:      summarizeWaitTimes(tasks, nil),
+			short:     summarizeResponseTimes(tasks, isShort),
+			long:      summarizeResponseTimes(tasks, isLong),
+		}
+		r.fairness, r.hasFairness = summarizeFairness(tasks)
+		results = append(results, r)
+	}
+
+	fmt.Println("\n============================================================")
+	fmt.Printf("Standard scenario %s\n", label)
+	fmt.Println("============================================================")
+	fmt.Printf("%-10s %12s %12s %12s %12s %12s %12s", "Algorithm", "Resp mean", "Resp p50", "Resp p99", "Short p99", "Long p99", "Wait mean")
+	if AppConfig.Workload.NumTenants > 0 {
+		fmt.Printf(" %8s", "Jain")
+	}
+	fmt.Println()
+	for _, r := range results {
+		fmt.Printf("%-10s %12s %12s %12s %12s %12s %12s", r.algorithm, formatMs(r.response.Mean), formatMs(r.response.Median),
+			formatMs(r.response.P99), formatMs(r.short.P99), formatMs(r.long.P99), formatMs(r.wait.Mean))
+		if AppConfig.Workload.NumTenants > 0 {
+			if r.hasFairness {
+				fmt.Printf(" %8.4f", r.fairness.JainIndex)
+			} else {
+				fmt.Printf(" %8s", "-")
+			}
+		}
+		fmt.Println()
+	}
+	if AppConfig.Workload.NumTenants > 0 {
+		fmt.Println("(times in ms; Jain's index of the per-tenant mean slowdowns, 1 = perfectly fair)")
+	} else {
+		fmt.Println("(times in ms)")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// StarvationGroup counts starved tasks of one class and priority
+type StarvationGroup struct {
+	Class    string
+	Priority string // Empty when the policy has no priorities
+	Tasks    int
+	Starved  int
+	MaxWait  time.Duration
+}
+
+// StarvationReport is the outcome of the post-run starvation analysis
+type StarvationReport struct {
+	MeanWait  time.Duration
+	Threshold time.Duration // Wait above which a task counts as starved
+	Starved   int
+	Groups    []StarvationGroup
+}
+
+// starvationThreshold returns the wait above which a task is starved: the configured
+// multiple of the mean wait, or the absolute bound if it is set and lower
+func starvationThreshold(cfg StarvationConfig, meanWait time.Duration) time.Duration {
+	threshold := time.Duration(cfg.WaitMultiple * float64(meanWait))
+	if bound := cfg.MaxWait(); bound > 0 && (cfg.WaitMultiple <= 0 || bound < threshold) {
+		threshold = bound
+	}
+	return threshold
+}
+
+// detectStarvation flags the tasks whose wait exceeded the starvation threshold, setting
+// their Starved field, and groups them by class and priority
+func detectStarvation(tasks []Task, cfg StarvationConfig, priority func(Task) uint) StarvationReport {
+	var report StarvationReport
+	if len(tasks) == 0 {
+		return report
+	}
+	var total time.Duration
+	for _, task := range tasks {
+		total += task.DequeueTime.Sub(task.ArrivalTime)
+	}
+	report.MeanWait = total / time.Duration(len(tasks))
+	report.Threshold = starvationThreshold(cfg, report.MeanWait)
+
+	groups := make(map[string]*StarvationGroup)
+	for i := range tasks {
+		task := &tasks[i]
+		group := StarvationGroup{Class: taskClass(*task)}
+		if priority != nil {
+			group.Priority = fmt.Sprintf("%d", priority(*task))
+		}
+		key := group.Class + "/" + group.Priority
+		if groups[key] == nil {
+			groups[key] = &group
+		}
+
+		wait := task.DequeueTime.Sub(task.ArrivalTime)
+		task.Starved = report.Threshold > 0 && wait > report.Threshold
+		groups[key].Tasks++
+		groups[key].MaxWait = max(groups[key].MaxWait, wait)
+		if task.Starved {
+			groups[key].Starved++
+			report.Starved++
+		}
+	}
+	for _, key := range sortedKeys(groups) {
+		report.Groups = append(report.Groups, *groups[key])
+	}
+	return report
+}
+
+// Print reports how many tasks starved in each class and priority
+func (r StarvationReport) Print() {
+	fmt.Printf("\nStarvation (wait > %s ms, mean wait %s ms):\n", formatMs(r.Threshold), formatMs(r.MeanWait))
+	if r.Starved == 0 {
+		fmt.Printf("  No starved tasks\n")
+		return
+	}
+	for _, group := range r.Groups {
+		name := group.Class
+		if group.Priority != "" {
+			name += " (priority " + group.Priority + ")"
+		}
+		fmt.Printf("  %s: %d/%d starved (%.1f%%), max wait %s ms\n", name, group.Starved, group.Tasks,
+			100*float64(group.Starved)/float64(group.Tasks), formatMs(group.MaxWait))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StepSummary describes the tasks of one step of a load staircase
+type StepSummary struct {
+	Step        int
+	Utilization float64
+	Response    ResponseSummary
+	Wait        ResponseSummary
+}
+
+// summarizeSteps computes response and wait time statistics for each step of the load
+// staircase, in order. Steps are assigned by task ID, so tasks count in the step they
+// arrived in even if they completed during a later one.
+func summarizeSteps(tasks []Task) []StepSummary {
+	cfg := AppConfig.Workload
+	var steps []StepSummary
+	for step, utilization := range cfg.UtilizationSteps {
+		inStep := func(task Task) bool { return cfg.Step(task.TaskID) == step }
+		steps = append(steps, StepSummary{
+			Step:        step + 1,
+			Utilization: utilization,
+			Response:    summarizeResponseTimes(tasks, inStep),
+			Wait:        summarizeWaitTimes(tasks, inStep),
+		})
+	}
+	return steps
+}
+
+// printStepReport prints the latency of each step of the load staircase, so the growth of
+// latency with load shows in one run. It prints nothing without a staircase.
+func printStepReport(tasks []Task) {
+	steps := summarizeSteps(tasks)
+	if len(steps) == 0 {
+		return
+	}
+	fmt.Printf("\nLoad staircase:\n")
+	fmt.Printf("  %4s %6s %6s %12s %12s %12s %12s\n", "Step", "Util", "Tasks", "Resp mean", "Resp p50", "Resp p99", "Wait p99")
+	for _, s := range steps {
+		fmt.Printf("  %4d %5.0f%% %6d %12s %12s %12s %12s\n", s.Step, s.Utilization*100, s.Response.Count,
+			formatMs(s.Response.Mean), formatMs(s.Response.Median), formatMs(s.Response.P99), formatMs(s.Wait.P99))
+	}
+	fmt.Println("  (times in ms)")
+}
+
+// formatUtilizations formats utilizations as a list of percentages, e.g. "50% 60% 70%"
+func formatUtilizations(utilizations []float64) string {
+	parts := make([]string, len(utilizations))
+	for i, utilization := range utilizations {
+		parts[i] = fmt.Sprintf("%.0f%%", utilization*100)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"fifo-queue-demo/metrics"
+)
+
+// Names of the steps of task workflows, which their timings are read back by
+const (
+	dequeueStepName    = "dequeue_time"
+	workStepName       = "work"
+	completionStepName = "completion_time"
+)
+
+// readStepTimings fills in the step timings of tasks, keyed by workflow ID, from the
+// steps DBOS recorded for their workflows, in a single query. DBOS stamps steps to the
+// millisecond, so timings are too.
+func readStepTimings(ctx context.Context, pool *pgxpool.Pool, tasks map[string]*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(tasks))
+	for id := range tasks {
+		ids = append(ids, id)
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT workflow_uuid, function_name, started_at_epoch_ms, completed_at_epoch_ms
+		FROM dbos.operation_outputs
+		WHERE workflow_uuid = ANY($1) AND started_at_epoch_ms IS NOT NULL AND completed_at_epoch_ms IS NOT NULL`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to read workflow steps: %w", err)
+	}
+	defer rows.Close()
+
+	// The span of each workflow's steps, from the start of the first to the end of the
+	// last, and the time spent in them
+	type span struct {
+		first, last int64
+		inSteps     time.Duration
+	}
+	spans := make(map[string]*span)
+	for rows.Next() {
+		var id, name string
+		var startedMs, completedMs int64
+		if err := rows.Scan(&id, &name, &startedMs, &completedMs); err != nil {
+			return fmt.Errorf("failed to read workflow steps: %w", err)
+		}
+		task := tasks[id]
+		duration := time.Duration(completedMs-startedMs) * time.Millisecond
+		switch name {
+		case dequeueStepName:
+			task.DequeueStep = duration
+		case workStepName:
+			task.WorkStep = duration
+		case completionStepName:
+			task.CompletionStep = duration
+		default:
+			// Other steps, like dead-lettering, come after the task completed
+			continue
+		}
+		s := spans[id]
+		if s == nil {
+			s = &span{first: startedMs, last: completedMs}
+			spans[id] = s
+		}
+		s.first, s.last = min(s.first, startedMs), max(s.last, completedMs)
+		s.inSteps += duration
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read workflow steps: %w", err)
+	}
+	for id, s := range spans {
+		tasks[id].StepGaps = max(0, time.Duration(s.last-s.first)*time.Millisecond-s.inSteps)
+	}
+	return nil
+}
+
+// workOverrun returns how much longer the work step of a task took than its work: the
+// task's duration, plus the earlier attempts and their backoff if it was retried
+func workOverrun(task Task) time.Duration {
+	return task.WorkStep - task.Duration - task.RetryDelay
+}
+
+// printStepTimingReport splits the time tasks spent in their workflows into the work they
+// did and the overhead DBOS added around it: the steps stamping the dequeue and
+// completion times, the work step beyond the work, and the time between steps, where
+// DBOS checkpoints each step, less any cold-start setup. It prints nothing when DBOS recorded no steps, as in the
+// simulation.
+func printStepTimingReport(tasks []Task) {
+	recorded := func(task Task) bool { return task.WorkStep > 0 }
+	dequeue := metrics.SummarizeTasks(tasks, recorded, func(t Task) time.Duration { return t.DequeueStep })
+	if dequeue.Count == 0 {
+		return
+	}
+	work := metrics.SummarizeTasks(tasks, recorded, func(t Task) time.Duration { return t.WorkStep })
+	overrun := metrics.SummarizeTasks(tasks, recorded, workOverrun)
+	completion := metrics.SummarizeTasks(tasks, recorded, func(t Task) time.Duration { return t.CompletionStep })
+	gaps := metrics.SummarizeTasks(tasks, recorded, func(t Task) time.Duration { return t.StepGaps })
+	overhead := metrics.SummarizeTasks(tasks, recorded, func(t Task) time.Duration {
+		return t.DequeueStep + max(0, workOverrun(t)) + t.CompletionStep + max(0, t.StepGaps-t.Setup)
+	})
+
+	fmt.Printf("\nWorkflow steps (%d tasks, as DBOS recorded them, to the millisecond):\n", dequeue.Count)
+	for _, step := range []struct {
+		name    string
+		summary metrics.Summary
+	}{
+		{"Dequeue time step", dequeue},
+		{"Work step", work},
+		{"  beyond the task's work", overrun},
+		{"Completion time step", completion},
+		{"Between steps", gaps},
+	} {
+		fmt.Printf("  %-26s mean %10s ms, p99 %10s ms\n", step.name+":", formatMs(step.summary.Mean), formatMs(step.summary.P99))
+	}
+	inWorkflow := work.Mean + dequeue.Mean + completion.Mean + gaps.Mean
+	if inWorkflow > 0 {
+		fmt.Printf("  Framework overhead inside the workflow: mean %s ms, p99 %s ms, %.1f%% of the time in the workflow\n",
+			formatMs(overhead.Mean), formatMs(overhead.P99), 100*float64(overhead.Mean)/float64(inWorkflow))
+	}
+}
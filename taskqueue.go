@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dbos-inc/dbos-transact-golang/dbos"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// errTaskDeduplicated is returned by Enqueue when a task with the same deduplication ID
+// is still queued or running
+var errTaskDeduplicated = errors.New("task deduplicated")
+
+// taskQueue is where the producer submits tasks. Implementations decide how tasks reach
+// the workers: through DBOS queues, which workers poll, or through the notify dispatcher.
+type taskQueue interface {
+	// Enqueue submits a task whose workflow will run under workflowID
+	Enqueue(task Task, workflowID string) error
+	// Depth returns the number of tasks enqueued but not yet started
+	Depth() (int, error)
+}
+
+// taskPriority returns the queue priority of a task under a policy with priorities: the
+// one its client asked for if any, else the policy's
+func taskPriority(policy SchedulingPolicy, task Task) uint {
+	if task.Priority > 0 {
+		return task.Priority
+	}
+	return policy.Priority(task)
+}
+
+// dbosTaskQueue submits tasks to a DBOS workflow queue
+type dbosTaskQueue struct {
+	ctx    dbos.DBOSContext
+	pool   *pgxpool.Pool // System database pool of ctx, which Depth counts the backlog over
+	policy SchedulingPolicy
+}
+
+func (q *dbosTaskQueue) Enqueue(task Task, workflowID string) error {
+	// Enqueue the task, with its priority if the policy uses them, or in its lane's queue
+	opts := []dbos.WorkflowOption{dbos.WithQueue(q.policy.QueueName), dbos.WithWorkflowID(workflowID)}
+	if lanes := q.policy.Lanes; lanes != nil {
+		opts[0] = dbos.WithQueue(lanes.QueueName(q.policy.QueueName, lanes.Lane(task)))
+	} else if q.policy.Priority != nil {
+		opts = append(opts, dbos.WithPriority(taskPriority(q.policy, task)))
+	}
+	if task.DedupID != "" {
+		opts = append(opts, dbos.WithDeduplicationID(task.DedupID))
+	}
+	_, err := dbos.RunWorkflow(q.ctx, processTask, task, opts...)
+	if errors.Is(err, &dbos.DBOSError{Code: dbos.QueueDeduplicated}) {
+		return errTaskDeduplicated
+	}
+	return err
+}
+
+func (q *dbosTaskQueue) Depth() (int, error) {
+	// Only the tasks of this run's tag, which share the executors' application version.
+	// The backlog of a policy with lanes is spread over their queues.
+	depth, err := countEnqueued(context.Background(), q.pool, policyQueueNames(q.policy, q.policy.QueueName),
+		q.ctx.GetApplicationVersion())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read queue depth: %w", err)
+	}
+	return depth, nil
+}
+
+// policyQueueNames returns the DBOS queues backing a policy whose queue is named
+// queueName: the queue itself, or the queue of each of its lanes
+func policyQueueNames(policy SchedulingPolicy, queueName string) []string {
+	if policy.Lanes != nil {
+		return policy.Lanes.QueueNames(queueName)
+	}
+	return []string{queueName}
+}
+
+// processTaskName is the name DBOS registers processTask under
+const processTaskName = "main.processTask"
+
+// clientTaskQueue submits tasks through a DBOS client, the way an external producer that
+// doesn't run workflows itself would. The queue doesn't need to be registered anywhere.
+type clientTaskQueue struct {
+	client    dbos.Client
+	pool      *pgxpool.Pool // System database pool of the client, which Depth counts the backlog over
+	queueName string
+	policy    SchedulingPolicy
+	version   string // Application version of the executors, if they only dequeue their own
+}
+
+func (q *clientTaskQueue) Enqueue(task Task, workflowID string) error {
+	opts := []dbos.EnqueueOption{dbos.WithEnqueueWorkflowID(workflowID)}
+	queueName := q.queueName
+	if lanes := q.policy.Lanes; lanes != nil {
+		queueName = lanes.QueueName(q.queueName, lanes.Lane(task))
+	} else if q.policy.Priority != nil {
+		opts = append(opts, dbos.WithEnqueuePriority(taskPriority(q.policy, task)))
+	}
+	if task.DedupID != "" {
+		opts = append(opts, dbos.WithEnqueueDeduplicationID(task.DedupID))
+	}
+	if q.version != "" {
+		opts = append(opts, dbos.WithEnqueueApplicationVersion(q.version))
+	}
+	_, err := q.client.Enqueue(queueName, processTaskName, task, opts...)
+	if errors.Is(err, &dbos.DBOSError{Code: dbos.QueueDeduplicated}) {
+		return errTaskDeduplicated
+	}
+	return err
+}
+
+func (q *clientTaskQueue) Depth() (int, error) {
+	depth, err := countEnqueued(context.Background(), q.pool, policyQueueNames(q.policy, q.queueName), q.version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read queue depth: %w", err)
+	}
+	return depth, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// busyPeriod is the time a task occupied a worker slot, from its claim to its completion
+type busyPeriod struct {
+	Slot  int
+	Task  Task
+	Start time.Time
+	End   time.Time
+}
+
+// timeline assigns the tasks that ran to worker slots. Tasks don't record the slot that
+// ran them, so each task takes the first slot free when it was claimed; that uses as few
+// slots as the run needed at its busiest, at most the queue's capacity. It returns the
+// busy periods in start order and the number of slots.
+func timeline(tasks []Task) ([]busyPeriod, int) {
+	var periods []busyPeriod
+	for _, task := range tasks {
+		start, ok := taskDispatch(task)
+		if !ok {
+			continue
+		}
+		periods = append(periods, busyPeriod{Task: task, Start: start, End: task.CompletionTime})
+	}
+	sort.SliceStable(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+
+	var freeAt []time.Time // When each slot is free again
+	for i := range periods {
+		slot := 0
+		for slot < len(freeAt) && freeAt[slot].After(periods[i].Start) {
+			slot++
+		}
+		if slot == len(freeAt) {
+			freeAt = append(freeAt, time.Time{})
+		}
+		freeAt[slot] = periods[i].End
+		periods[i].Slot = slot
+	}
+	return periods, len(freeAt)
+}
+
+// timelineFilename returns the timeline file that goes with a results file, with the
+// given extension
+func timelineFilename(resultsFile, ext string) string {
+	return strings.TrimSuffix(resultsFile, ".csv") + "_timeline." + ext
+}
+
+// exportTimeline writes the busy periods of the worker slots of a run, one row per task,
+// and with format "svg" draws them as a Gantt chart too
+func exportTimeline(tasks []Task, format, resultsFile string) error {
+	periods, slots := timeline(tasks)
+	filename := timelineFilename(resultsFile, "csv")
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create timeline CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"slot", "task_id", "class", "start_time", "end_time", "busy_ms"})
+	for _, p := range periods {
+		writer.Write([]string{
+			fmt.Sprintf("%d", p.Slot),
+			fmt.Sprintf("%d", p.Task.TaskID),
+			taskClass(p.Task),
+			p.Start.Format(time.RFC3339Nano),
+			p.End.Format(time.RFC3339Nano),
+			fmt.Sprintf("%.3f", p.End.Sub(p.Start).Seconds()*1000),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write timeline CSV file: %w", err)
+	}
+	fmt.Printf("Timeline exported to %s (%d worker slots in use)\n", filename, slots)
+
+	if format != "svg" {
+		return nil
+	}
+	filename = timelineFilename(resultsFile, "svg")
+	if err := os.WriteFile(filename, []byte(timelineSVG(periods, slots)), 0644); err != nil {
+		return fmt.Errorf("failed to write timeline SVG file: %w", err)
+	}
+	fmt.Printf("Timeline chart exported to %s\n", filename)
+	return nil
+}
+
+// Layout of the timeline chart, in pixels
+const (
+	timelineWidth     = 1200 // Width of the plot area
+	timelineRowHeight = 14
+	timelineMargin    = 60 // Room for the slot labels on the left and the time axis below
+)
+
+// timelineSVG draws one row per worker slot and one bar per task it ran, colored by class,
+// with the task's timing as a tooltip. Idle gaps show as white space in a row, and convoys
+// as a long bar followed by a run of short ones.
+func timelineSVG(periods []busyPeriod, slots int) string {
+	var b strings.Builder
+	height := slots*timelineRowHeight + timelineMargin
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="10">`+"\n",
+		timelineWidth+timelineMargin+10, height)
+	if len(periods) == 0 {
+		b.WriteString("</svg>\n")
+		return b.String()
+	}
+	start, end := periods[0].Start, periods[0].End
+	for _, p := range periods {
+		if p.End.After(end) {
+			end = p.End
+		}
+	}
+	span := end.Sub(start)
+	if span <= 0 {
+		span = time.Millisecond
+	}
+	x := func(t time.Time) float64 {
+		return timelineMargin + float64(t.Sub(start))/float64(span)*timelineWidth
+	}
+
+	for slot := range slots {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">slot %d</text>`+"\n",
+			timelineMargin-4, slot*timelineRowHeight+timelineRowHeight-3, slot)
+	}
+	// Ten ticks along the time axis, in seconds since the first claim
+	axis := slots*timelineRowHeight + 4
+	for i := range 11 {
+		t := start.Add(span * time.Duration(i) / 10)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="0" x2="%.1f" y2="%d" stroke="#ddd"/>`+"\n", x(t), x(t), axis)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%.1fs</text>`+"\n", x(t), axis+12, t.Sub(start).Seconds())
+	}
+	colors := map[string]string{"short": "#4c78a8", "long": "#f58518"}
+	for _, p := range periods {
+		width := max(x(p.End)-x(p.Start), 0.5)
+		title := fmt.Sprintf("task %d (%s): waited %s ms, ran %s ms", p.Task.TaskID, taskClass(p.Task),
+			formatMs(p.Start.Sub(p.Task.ArrivalTime)), formatMs(p.End.Sub(p.Start)))
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s" stroke="white" stroke-width="0.5"><title>%s</title></rect>`+"\n",
+			x(p.Start), p.Slot*timelineRowHeight+1, width, timelineRowHeight-2, colors[taskClass(p.Task)], html.EscapeString(title))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d">blue: short tasks, orange: long tasks</text>`+"\n", timelineMargin, axis+28)
+	b.WriteString("</svg>\n")
+	return b.String()
+}
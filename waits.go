@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fifo-queue-demo/metrics"
+)
+
+// WaitBreakdown splits the wait of a group of tasks into its components
+type WaitBreakdown struct {
+	Tasks     int
+	Wait      metrics.Summary
+	Enqueue   metrics.Summary // Arrival until eligible: the enqueue round trip and backpressure
+	Queueing  metrics.Summary // Eligible until claimed: waiting for a worker slot
+	Startup   metrics.Summary // Claimed until running: dispatch and workflow instantiation
+	HasTiming bool            // Whether the queue timestamps of the tasks are known
+}
+
+// summarizeWaits breaks down the wait of the tasks matching filter
+func summarizeWaits(tasks []Task, filter func(Task) bool) WaitBreakdown {
+	breakdown := WaitBreakdown{
+		Wait:     metrics.SummarizeTasks(tasks, filter, Task.WaitTime),
+		Enqueue:  metrics.SummarizeTasks(tasks, filter, Task.EnqueueDelay),
+		Queueing: metrics.SummarizeTasks(tasks, filter, Task.QueueingDelay),
+		Startup:  metrics.SummarizeTasks(tasks, filter, Task.StartupDelay),
+	}
+	breakdown.Tasks = breakdown.Wait.Count
+	for _, task := range tasks {
+		if (filter == nil || filter(task)) && !task.EnqueuedAt.IsZero() && !task.StartedAt.IsZero() {
+			breakdown.HasTiming = true
+			break
+		}
+	}
+	return breakdown
+}
+
+// printWaitBreakdown prints where tasks spent their wait, overall and per class, so a long
+// wait can be pinned on the producer, the scheduling policy or the dispatch machinery.
+// It prints nothing for tasks without queue timestamps.
+func printWaitBreakdown(tasks []Task) {
+	all := summarizeWaits(tasks, nil)
+	if !all.HasTiming {
+		return
+	}
+	fmt.Printf("\nWait breakdown (mean / p99 in ms, share of mean wait):\n")
+	for _, group := range []struct {
+		name      string
+		breakdown WaitBreakdown
+	}{
+		{"All", all},
+		{"Short", summarizeWaits(tasks, func(task Task) bool { return taskClass(task) == "short" })},
+		{"Long", summarizeWaits(tasks, func(task Task) bool { return taskClass(task) == "long" })},
+	} {
+		b := group.breakdown
+		if b.Tasks == 0 {
+			continue
+		}
+		share := func(d time.Duration) float64 {
+			if b.Wait.Mean == 0 {
+				return 0
+			}
+			return float64(d) / float64(b.Wait.Mean) * 100
+		}
+		fmt.Printf("  %s tasks: enqueue %s / %s (%.0f%%), queueing %s / %s (%.0f%%), startup %s / %s (%.0f%%)\n", group.name,
+			formatMs(b.Enqueue.Mean), formatMs(b.Enqueue.P99), share(b.Enqueue.Mean),
+			formatMs(b.Queueing.Mean), formatMs(b.Queueing.P99), share(b.Queueing.Mean),
+			formatMs(b.Startup.Mean), formatMs(b.Startup.P99), share(b.Startup.Mean))
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Watchdog boosts the tasks of a run that wait longer than the configured bound, so no
+// policy can starve them for good. Every scan, it moves the tasks waiting in Postgres to
+// the front of the queue: priority 0 on the policy's queue, or the first lane for
+// policies with lanes, or priority 0 in the notify task table. Tasks already dequeued but
+// waiting at the capacity gate of this process go ahead of the other gate waiters.
+type Watchdog struct {
+	cfg       WatchdogConfig
+	pool      *pgxpool.Pool
+	runID     string
+	notify    bool
+	queues    []string // Queues holding the run's waiting tasks
+	fastQueue string   // Queue boosted tasks move to
+
+	mu      sync.Mutex
+	boosted map[int]bool // IDs of the tasks boosted so far
+	scans   int
+	fired   int // Scans that boosted at least one task
+	err     error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startWatchdog connects to Postgres and starts scanning the run's waiting tasks
+func startWatchdog(cfg WatchdogConfig, policy SchedulingPolicy, queueCfg QueueConfig, runID string, monitor *poolMonitor) (*Watchdog, error) {
+	pool, err := newPool(context.Background(), AppConfig.Database)
+	if err != nil {
+		return nil, err
+	}
+	monitor.Add("watchdog", pool)
+	queues := policyQueueNames(policy, policy.QueueName)
+	w := &Watchdog{
+		cfg:       cfg,
+		pool:      pool,
+		runID:     runID,
+		notify:    queueCfg.Dispatch == "notify",
+		queues:    queues,
+		fastQueue: queues[0],
+		boosted:   make(map[int]bool),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// register makes the watchdog follow the run: once every task was collected, it stops
+// and marks the tasks it boosted, and it reports how often it fired
+func (w *Watchdog) register(r *experimentRun) {
+	r.OnCollected(func(tasks []Task) error {
+		w.Stop()
+		if err := w.Err(); err != nil {
+			return err
+		}
+		w.Mark(tasks)
+		return nil
+	})
+	r.OnReport(func(*runOutcome) error {
+		w.Print()
+		return nil
+	})
+	r.OnClose(w.Stop)
+}
+
+// Stop stops scanning and closes the watchdog's connections
+func (w *Watchdog) Stop() {
+	select {
+	case <-w.stop:
+		return
+	default:
+		close(w.stop)
+	}
+	<-w.done
+	w.pool.Close()
+}
+
+// Err returns the first error a scan ran into
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *Watchdog) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.ScanInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.scan()
+		}
+	}
+}
+
+// scan boosts every task that waited longer than the bound and wasn't boosted yet
+func (w *Watchdog) scan() {
+	cutoff := time.Now().Add(-w.cfg.MaxWait())
+	workflowIDs, err := w.boostQueued(cutoff)
+
+	var taskIDs []int
+	for _, workflowID := range workflowIDs {
+		if taskID, ok := w.taskID(workflowID); ok {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	if gate := activeGate.Load(); gate != nil {
+		for _, task := range gate.Boost(cutoff) {
+			taskIDs = append(taskIDs, task.TaskID)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.scans++
+	newlyBoosted := 0
+	for _, taskID := range taskIDs {
+		if !w.boosted[taskID] {
+			w.boosted[taskID] = true
+			newlyBoosted++
+		}
+	}
+	if newlyBoosted > 0 {
+		w.fired++
+	}
+}
+
+// boostQueued moves the run's tasks enqueued before cutoff and still waiting in Postgres
+// to the front of the queue, and returns their workflow IDs
+func (w *Watchdog) boostQueued(cutoff time.Time) ([]string, error) {
+	w.mu.Lock()
+	var skip []string
+	for taskID := range w.boosted {
+		skip = append(skip, taskWorkflowID(w.runID, taskID))
+	}
+	w.mu.Unlock()
+
+	ctx := context.Background()
+	var query string
+	var args []any
+	if w.notify {
+		query = `
+			UPDATE schedq_tasks SET priority = 0
+			WHERE queue_name = $1 AND claimed_at IS NULL AND starts_with(workflow_id, $2)
+			  AND enqueued_at < $3 AND workflow_id <> ALL($4)
+			RETURNING workflow_id`
+		args = []any{w.fastQueue, tagPrefix(w.runID), cutoff, skip}
+	} else {
+		query = `
+			UPDATE dbos.workflow_status SET priority = 0, queue_name = $1
+			WHERE queue_name = ANY($2) AND status = 'ENQUEUED' AND starts_with(workflow_uuid, $3)
+			  AND created_at < $4 AND workflow_uuid <> ALL($5)
+			RETURNING workflow_uuid`
+		args = []any{w.fastQueue, w.queues, tagPrefix(w.runID), cutoff.UnixMilli(), skip}
+	}
+	rows, err := w.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("watchdog failed to boost waiting tasks: %w", err)
+	}
+	defer rows.Close()
+	var workflowIDs []string
+	for rows.Next() {
+		var workflowID string
+		if err := rows.Scan(&workflowID); err != nil {
+			return nil, fmt.Errorf("watchdog failed to read a boosted task: %w", err)
+		}
+		workflowIDs = append(workflowIDs, workflowID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("watchdog failed to boost waiting tasks: %w", err)
+	}
+	return workflowIDs, nil
+}
+
+// taskID returns the ID of the task of one of the run's workflows
+func (w *Watchdog) taskID(workflowID string) (int, bool) {
+	taskID, err := strconv.Atoi(strings.TrimPrefix(workflowID, tagPrefix(w.runID)))
+	return taskID, err == nil
+}
+
+// Mark sets the Boosted field of the tasks the watchdog boosted, and returns how many
+// there are
+func (w *Watchdog) Mark(tasks []Task) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	marked := 0
+	for i := range tasks {
+		tasks[i].Boosted = w.boosted[tasks[i].TaskID]
+		if tasks[i].Boosted {
+			marked++
+		}
+	}
+	return marked
+}
+
+// Print reports how often the watchdog fired during the run
+func (w *Watchdog) Print() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Printf("\nWatchdog scans: %d, fired in %d (boosted %d tasks)\n", w.scans, w.fired, len(w.boosted))
+}
+
+// WatchdogSummary describes the tasks the watchdog boosted in one class
+type WatchdogSummary struct {
+	Class   string
+	Tasks   int
+	Boosted int
+	Wait    ResponseSummary // Wait of the boosted tasks
+}
+
+// summarizeWatchdog counts the boosted tasks of each class
+func summarizeWatchdog(tasks []Task) []WatchdogSummary {
+	var summaries []WatchdogSummary
+	for _, class := range []string{"short", "long"} {
+		inClass := func(task Task) bool { return taskClass(task) == class }
+		summary := WatchdogSummary{Class: class}
+		for _, task := range tasks {
+			if inClass(task) {
+				summary.Tasks++
+				if task.Boosted {
+					summary.Boosted++
+				}
+			}
+		}
+		summary.Wait = summarizeWaitTimes(tasks, func(task Task) bool { return inClass(task) && task.Boosted })
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// printWatchdogReport prints how many tasks of each class the watchdog boosted and how
+// long they waited. It prints nothing when the watchdog is disabled.
+func printWatchdogReport(tasks []Task, cfg WatchdogConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	fmt.Printf("\nWatchdog (boost after %v, scan every %v):\n", cfg.MaxWait(), cfg.ScanInterval())
+	for _, s := range summarizeWatchdog(tasks) {
+		if s.Tasks == 0 {
+			continue
+		}
+		fmt.Printf("  %s: %d/%d boosted (%.1f%%)", s.Class, s.Boosted, s.Tasks, 100*float64(s.Boosted)/float64(s.Tasks))
+		if s.Boosted > 0 {
+			fmt.Printf(", wait of boosted tasks: mean %s ms, max %s ms", formatMs(s.Wait.Mean), formatMs(s.Wait.Max))
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhookBuffer is the number of events that can wait for delivery; events beyond it are
+// dropped rather than holding back result collection
+const webhookBuffer = 10000
+
+// completionEventJSON is the event delivered for each finished task. Times are RFC 3339,
+// omitted when unknown, and durations are in milliseconds.
+type completionEventJSON struct {
+	RunID          string     `json:"run_id"`
+	Algorithm      string     `json:"algorithm"`
+	TaskID         int        `json:"task_id"`
+	Status         string     `json:"status"` // "completed", "failed", "cancelled" or "abandoned"
+	Class          string     `json:"class"`
+	DurationMs     float64    `json:"duration_ms"`
+	ArrivalTime    time.Time  `json:"arrival_time"`
+	EnqueuedAt     *time.Time `json:"enqueued_at,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	CompletionTime *time.Time `json:"completion_time,omitempty"`
+	WaitMs         float64    `json:"wait_ms"`
+	ResponseMs     float64    `json:"response_ms"`
+	ExecutorID     string     `json:"executor_id,omitempty"`
+}
+
+// webhookNotifier POSTs the completion events of a run to an HTTP endpoint, as JSON arrays
+// of at most batch_size events. Events are delivered in the background in the order tasks
+// were collected; a batch that fails is counted and not retried, so a slow or broken
+// endpoint never slows the run down.
+type webhookNotifier struct {
+	cfg       WebhookConfig
+	runID     string
+	algorithm string
+	client    *http.Client
+	events    chan completionEventJSON
+	done      sync.WaitGroup
+
+	// Counted by the sender, and by Notify for dropped events
+	mu        sync.Mutex
+	delivered int
+	failed    int
+	dropped   int
+	lastErr   error
+}
+
+// newWebhookNotifier starts delivering the events of a run, or returns nil when no
+// webhook URL is configured
+func newWebhookNotifier(cfg WebhookConfig, runID, algorithm string) *webhookNotifier {
+	if cfg.URL == "" {
+		return nil
+	}
+	n := &webhookNotifier{
+		cfg:       cfg,
+		runID:     runID,
+		algorithm: algorithm,
+		client:    &http.Client{Timeout: cfg.Timeout()},
+		events:    make(chan completionEventJSON, webhookBuffer),
+	}
+	n.done.Add(1)
+	go n.send()
+	return n
+}
+
+// Notify queues the completion event of a finished task
+func (n *webhookNotifier) Notify(task Task) {
+	select {
+	case n.events <- n.event(task):
+	default:
+		n.mu.Lock()
+		n.dropped++
+		n.mu.Unlock()
+	}
+}
+
+func (n *webhookNotifier) event(task Task) completionEventJSON {
+	event := completionEventJSON{
+		RunID:       n.runID,
+		Algorithm:   n.algorithm,
+		TaskID:      task.TaskID,
+		Status:      "completed",
+		Class:       "long",
+		DurationMs:  task.Duration.Seconds() * 1000,
+		ArrivalTime: task.ArrivalTime,
+		ExecutorID:  task.ExecutorID,
+	}
+	switch {
+	case task.Abandoned:
+		event.Status = "abandoned"
+	case task.Cancelled:
+		event.Status = "cancelled"
+	case task.Failed:
+		event.Status = "failed"
+	}
+	if AppConfig.Workload.IsShort(task.Duration) {
+		event.Class = "short"
+	}
+	event.EnqueuedAt = optionalTime(task.EnqueuedAt)
+	event.StartedAt = optionalTime(task.StartedAt)
+	event.CompletionTime = optionalTime(task.CompletionTime)
+	if !task.DequeueTime.IsZero() {
+		event.WaitMs = task.WaitTime().Seconds() * 1000
+	}
+	if !task.CompletionTime.IsZero() {
+		event.ResponseMs = task.ResponseTime().Seconds() * 1000
+	}
+	return event
+}
+
+// optionalTime returns nil for a zero time, so it is left out of the event
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// send delivers the queued events until Close, batching those that are already waiting
+func (n *webhookNotifier) send() {
+	defer n.done.Done()
+	for event := range n.events {
+		batch := []completionEventJSON{event}
+	fill:
+		for len(batch) < n.cfg.BatchSize {
+			select {
+			case event, ok := <-n.events:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, event)
+			default:
+				break fill
+			}
+		}
+		err := n.post(batch)
+		n.mu.Lock()
+		if err != nil {
+			n.failed += len(batch)
+			n.lastErr = err
+		} else {
+			n.delivered += len(batch)
+		}
+		n.mu.Unlock()
+	}
+}
+
+func (n *webhookNotifier) post(batch []completionEventJSON) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", n.cfg.URL, resp.Status)
+	}
+	return nil
+}
+
+// Close delivers the events still queued and prints how many events were delivered
+func (n *webhookNotifier) Close() {
+	close(n.events)
+	n.done.Wait()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	fmt.Printf("Webhook: %d completion events delivered to %s", n.delivered, n.cfg.URL)
+	if n.failed > 0 || n.dropped > 0 {
+		fmt.Printf(", %d failed, %d dropped", n.failed, n.dropped)
+	}
+	fmt.Println()
+	if n.lastErr != nil {
+		fmt.Printf("  Last delivery error: %v\n", n.lastErr)
+	}
+}
@@ -2,19 +2,19 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/dbos-inc/dbos-transact-golang/dbos"
+
+	"fifo-queue-demo/workload"
 )
 
-// Task represents a single task with timing information
-type Task struct {
-	TaskID         int
-	Duration       time.Duration
-	ArrivalTime    time.Time
-	DequeueTime    time.Time
-	CompletionTime time.Time
-}
+// Task is a request of the workload, as defined by the workload package
+type Task = workload.Task
+
+// WorkProfile describes the work of a task class, as defined by the workload package
+type WorkProfile = workload.WorkProfile
 
 // TaskResult includes calculated metrics
 type TaskResult struct {
@@ -25,38 +25,216 @@ type TaskResult struct {
 
 // Step to get current time (non-deterministic operation)
 func getCurrentTime(ctx context.Context) (time.Time, error) {
-	return time.Now(), nil
+	return monotonicNow(), nil
 }
 
-// Step to simulate work by sleeping
-func simulateWork(_ context.Context, duration time.Duration) (string, error) {
-	time.Sleep(duration)
+// Step to simulate the work of a task: the work profile of its class if it has one,
+// otherwise sleeping, spinning on a CPU core or querying Postgres depending on the
+// configured work mode
+func simulateWork(ctx context.Context, workflowID string, duration time.Duration) (string, error) {
+	if profile, ok := AppConfig.Workload.WorkProfile(duration); ok {
+		if err := profileWork(ctx, workflowID, profile); err != nil {
+			return "", err
+		}
+		return "completed", nil
+	}
+
+	switch AppConfig.Workload.WorkMode {
+	case "cpu":
+		if err := cpuWork(ctx, duration); err != nil {
+			return "", err
+		}
+	case "io":
+		worker := activeIOWork.Load()
+		if worker == nil {
+			return "", fmt.Errorf("io work is not set up in this executor")
+		}
+		if err := worker.Work(ctx, workflowID, worker.Queries(duration)); err != nil {
+			return "", err
+		}
+	default:
+		if AppConfig.Workload.HighResolution() {
+			sleepPrecisely(duration)
+		} else {
+			time.Sleep(duration)
+		}
+	}
 	return "completed", nil
 }
 
+// lockedWork runs the work of a task, holding the shared lock if the task needs it, and
+// adds the time the task waited for the lock to its lock wait
+func lockedWork(ctx context.Context, task *Task, work func() (string, error)) (string, error) {
+	if !task.NeedsLock {
+		return work()
+	}
+	lock := activeSharedLock.Load()
+	if lock == nil {
+		return "", fmt.Errorf("the shared lock is not set up in this executor")
+	}
+	var result string
+	wait, err := lock.Hold(ctx, func() error {
+		var err error
+		result, err = work()
+		return err
+	})
+	task.LockWait += wait
+	return result, err
+}
+
+// profileWork does the work of a work profile: its CPU work, then its queries, then its
+// sleep
+func profileWork(ctx context.Context, workflowID string, profile WorkProfile) error {
+	if profile.CPUMs > 0 {
+		if err := cpuWork(ctx, profile.CPU()); err != nil {
+			return err
+		}
+	}
+	if profile.Queries > 0 {
+		worker := activeIOWork.Load()
+		if worker == nil {
+			return fmt.Errorf("io work is not set up in this executor")
+		}
+		if err := worker.Work(ctx, workflowID, profile.Queries); err != nil {
+			return err
+		}
+	}
+	time.Sleep(profile.Sleep())
+	return nil
+}
+
 // Workflow to process a task
 func processTask(ctx dbos.DBOSContext, task Task) (Task, error) {
-	// Record dequeue time when workflow starts
-	dequeueTime, err := dbos.RunAsStep(ctx, getCurrentTime)
+	// In autoscaled runs, for policies with lanes or an agent, and with reservations, wait
+	// for a worker slot of the gate before starting
+	if gate := activeGate.Load(); gate != nil {
+		gate.Acquire(task)
+		defer gate.Release(task)
+	}
+
+	// Record dequeue time when workflow starts. In pipeline runs, the task's dequeue
+	// time is that of its first stage.
+	pipeline := activePipeline.Load()
+	dequeueTime, err := dbos.RunAsStep(ctx, getCurrentTime, dbos.WithStepName(dequeueStepName))
 	if err != nil {
 		return task, err
 	}
-	task.DequeueTime = dequeueTime
+	if task.Stage == 0 {
+		task.DequeueTime = dequeueTime
+	}
 
-	// Simulate work by sleeping for the task duration
-	_, err = dbos.RunAsStep(ctx, func(stepCtx context.Context) (string, error) {
-		return simulateWork(stepCtx, task.Duration)
-	})
+	// The worker sets up for the task first if it last ran another class of tasks or
+	// went cold
+	if pool := activeColdStart.Load(); pool != nil {
+		setup, release := pool.Acquire(task)
+		defer release()
+		time.Sleep(setup)
+		task.Setup = setup
+	}
+
+	// Simulate work by sleeping for the task duration. Attempts fail as drawn by the
+	// workload generator, after doing their work, and DBOS retries them in place. Tasks
+	// that failed for good in an earlier stage pass through the later ones.
+	workflowID, err := dbos.GetWorkflowID(ctx)
 	if err != nil {
 		return task, err
 	}
+	if !task.Failed {
+		duration := pipeline.stage(task).Duration(task.Duration)
+		attempts := 0
+		var firstStart, lastStart time.Time
+		_, err = dbos.RunAsStep(ctx, func(stepCtx context.Context) (string, error) {
+			attempts++
+			lastStart = monotonicNow()
+			if attempts == 1 {
+				firstStart = lastStart
+			}
+			result, err := lockedWork(stepCtx, &task, func() (string, error) {
+				return simulateWork(stepCtx, workflowID, duration)
+			})
+			if err != nil {
+				return result, err
+			}
+			if task.FailsPermanently || attempts <= task.TransientFailures {
+				return "", fmt.Errorf("task %d failed on attempt %d", task.TaskID, attempts)
+			}
+			return result, nil
+		}, append(retryOptions(AppConfig.Retry), dbos.WithStepName(workStepName))...)
+		// A task's attempts are its first attempt plus its retries in every stage
+		if task.Stage == 0 {
+			task.Attempts = attempts
+		} else {
+			task.Attempts += attempts - 1
+		}
+		task.RetryDelay += lastStart.Sub(firstStart)
+		if err != nil {
+			// Running out of retries on failures drawn by the generator is a result;
+			// anything else aborts the task
+			if !task.FailsPermanently && attempts > task.TransientFailures {
+				return task, err
+			}
+			task.Failed = true
+		}
+	}
 
 	// Record completion time
-	completionTime, err := dbos.RunAsStep(ctx, getCurrentTime)
+	completionTime, err := dbos.RunAsStep(ctx, getCurrentTime, dbos.WithStepName(completionStepName))
 	if err != nil {
 		return task, err
 	}
 	task.CompletionTime = completionTime
 
+	// Pipeline tasks record their time in the stage, then move on to the queue of the
+	// next stage until the last one completes them
+	if pipeline != nil {
+		enqueuedAt := task.ArrivalTime
+		if task.Stage > 0 {
+			enqueuedAt = task.Stages[task.Stage-1].CompletionTime
+		}
+		task.Stages = append(task.Stages, workload.StageTiming{EnqueuedAt: enqueuedAt, DequeueTime: dequeueTime, CompletionTime: completionTime})
+	}
+	if !pipeline.last(task) {
+		task.Stage++
+		opts := []dbos.WorkflowOption{
+			dbos.WithQueue(stageQueueName(pipeline.policy, task.Stage)),
+			dbos.WithWorkflowID(stageWorkflowID(taskWorkflowID(runIDOf(workflowID), task.TaskID), task.Stage)),
+		}
+		if pipeline.policy.Priority != nil {
+			opts = append(opts, dbos.WithPriority(pipeline.policy.Priority(task)))
+		}
+		_, err := dbos.RunWorkflow(ctx, processTask, task, opts...)
+		return task, err
+	}
+
+	// Like most real tasks, return a small result: the payload only travels on the way in
+	task.Payload = nil
+	if policy := activeObserver.Load(); policy != nil {
+		policy.Observe(task)
+	}
+	if run := activeRun.Load(); run != nil {
+		run.completed(task)
+	}
+
+	// Tasks that failed for good are parked in the dead-letter queue
+	if task.Failed && AppConfig.Retry.DeadLetter {
+		task.DeadLettered = true
+		if err := routeToDeadLetter(ctx, task, workflowID); err != nil {
+			return task, err
+		}
+	}
 	return task, nil
 }
+
+// retryOptions returns the DBOS step options of the retry policy
+func retryOptions(cfg RetryConfig) []dbos.StepOption {
+	if cfg.MaxRetries == 0 {
+		return nil
+	}
+	policy := cfg.Policy()
+	return []dbos.StepOption{
+		dbos.WithStepMaxRetries(policy.MaxRetries),
+		dbos.WithBaseInterval(policy.BaseInterval),
+		dbos.WithMaxInterval(policy.MaxInterval),
+		dbos.WithBackoffFactor(policy.BackoffFactor),
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Some queries of dbos.workflow_status go straight to the table rather than through DBOS:
+// counts and pages, which DBOS only offers by listing every matching workflow, and
+// cancellations that must only catch the workflows still waiting in a queue. They rely
+// on the columns and the (queue_name, status, started_at_epoch_ms) index of the system
+// database schema of DBOS Transact Go v0.8, migrations 1 to 5.
+
+// countEnqueued returns how many workflows wait in the DBOS queues named queueNames, only
+// those of the given application version unless it is empty. It costs the same however
+// many tasks are waiting.
+func countEnqueued(ctx context.Context, pool *pgxpool.Pool, queueNames []string, version string) (int, error) {
+	var count int
+	err := pool.QueryRow(ctx, `
+		SELECT count(*) FROM dbos.workflow_status
+		WHERE queue_name = ANY($1) AND status = 'ENQUEUED' AND ($2::text = '' OR application_version = $2)`,
+		queueNames, version).Scan(&count)
+	return count, err
+}
+
+// countUnfinished returns how many workflows are still queued or running among those
+// enqueued on queueName, or any queue if empty, between since and until, whose ID starts
+// with prefix. Zero times don't restrict anything.
+func countUnfinished(ctx context.Context, pool *pgxpool.Pool, queueName, prefix string, since, until time.Time) (int, error) {
+	var count int
+	err := pool.QueryRow(ctx, `
+		SELECT count(*) FROM dbos.workflow_status
+		WHERE status IN ('ENQUEUED', 'PENDING') AND starts_with(workflow_uuid, $1)
+			AND ($2::text = '' OR queue_name = $2)
+			AND ($3::bigint = 0 OR created_at >= $3) AND ($4::bigint = 0 OR created_at <= $4)`,
+		prefix, queueName, epochMs(since), epochMs(until)).Scan(&count)
+	return count, err
+}
+
+// workflowKey is where a page of workflows ends: they are ordered by creation, then ID,
+// which no later change of status can reorder
+type workflowKey struct {
+	createdAt int64 // Milliseconds since the epoch
+	id        string
+}
+
+// pageFinished returns the IDs of up to limit finished workflows past after, selected
+// like countUnfinished does, and the key of the last one. Paging by key rather than
+// offset neither skips nor repeats workflows when others finish between pages.
+func pageFinished(ctx context.Context, pool *pgxpool.Pool, queueName, prefix string, since, until time.Time, after workflowKey, limit int) ([]string, workflowKey, error) {
+	statuses := make([]string, len(finishedStatuses))
+	for i, status := range finishedStatuses {
+		statuses[i] = string(status)
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT workflow_uuid, created_at FROM dbos.workflow_status
+		WHERE status = ANY($1) AND starts_with(workflow_uuid, $2)
+			AND ($3::text = '' OR queue_name = $3)
+			AND ($4::bigint = 0 OR created_at >= $4) AND ($5::bigint = 0 OR created_at <= $5)
+			AND (created_at, workflow_uuid) > ($6::bigint, $7::text)
+		ORDER BY created_at, workflow_uuid
+		LIMIT $8`,
+		statuses, prefix, queueName, epochMs(since), epochMs(until), after.createdAt, after.id, limit)
+	if err != nil {
+		return nil, after, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		if err := rows.Scan(&after.id, &after.createdAt); err != nil {
+			return nil, after, err
+		}
+		ids = append(ids, after.id)
+	}
+	return ids, after, rows.Err()
+}
+
+// epochMs returns a time in milliseconds since the epoch, as DBOS stores them, or 0 for
+// the zero time
+func epochMs(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// cancelEnqueued cancels the workflows matching condition that are still waiting in a
+// queue, in a single statement that leaves the running ones alone, and returns how many
+// it cancelled. It writes what DBOS's CancelWorkflow does: the CANCELLED status, the
+// update time, and no start time, so the workflow no longer counts against the queue's
+// concurrency. Placeholders of condition start at $2.
+func cancelEnqueued(ctx context.Context, pool *pgxpool.Pool, condition string, args ...any) (int64, error) {
+	tag, err := pool.Exec(ctx, `
+		UPDATE dbos.workflow_status SET status = 'CANCELLED', updated_at = $1, started_at_epoch_ms = NULL
+		WHERE status = 'ENQUEUED' AND `+condition,
+		append([]any{time.Now().UnixMilli()}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// cancelEnqueuedWorkflow cancels a workflow if it is still waiting in its queue, and
+// reports whether it did
+func cancelEnqueuedWorkflow(ctx context.Context, pool *pgxpool.Pool, workflowID string) (bool, error) {
+	cancelled, err := cancelEnqueued(ctx, pool, `workflow_uuid = $2`, workflowID)
+	return cancelled > 0, err
+}
+
+// cancelEnqueuedRun cancels the workflows still waiting in the DBOS queues named
+// queueNames whose IDs start with prefix, and returns how many it cancelled
+func cancelEnqueuedRun(ctx context.Context, pool *pgxpool.Pool, queueNames []string, prefix string) (int64, error) {
+	return cancelEnqueued(ctx, pool, `queue_name = ANY($2) AND starts_with(workflow_uuid, $3)`, queueNames, prefix)
+}
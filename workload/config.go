@@ -0,0 +1,247 @@
+package workload
+
+import "time"
+
+// Config holds the workload configuration parameters
+type Config struct {
+	NumTasks             int     `yaml:"num_tasks"`
+	ShortTaskDurationMs  int     `yaml:"short_task_duration_ms"`
+	LongTaskDurationMs   int     `yaml:"long_task_duration_ms"`
+	ShortTaskProbability float64 `yaml:"short_task_probability"`
+	TargetUtilization    float64 `yaml:"target_utilization"`
+	DuplicateProbability float64 `yaml:"duplicate_probability"`
+	NumTenants           int     `yaml:"num_tenants"`     // 0 means tasks carry no tenant
+	TenantSkew           float64 `yaml:"tenant_skew"`     // Zipf exponent of the tenants' shares of the tasks, 0 for equal shares
+	TasksPerJob          int     `yaml:"tasks_per_job"`   // 0 or 1 means independent tasks
+	DeadlineFactor       float64 `yaml:"deadline_factor"` // Relative deadline in task durations, 0 for none
+
+	// Fork-join requests: with FanOut above 1, every request fans out into FanOut parallel
+	// tasks of a job, each doing an equal share of the request's work, and completes when
+	// they all join. NumTasks counts the tasks, not the requests.
+	FanOut int `yaml:"fan_out"`
+
+	// Run overloaded for a fixed time: arrivals stop after OverloadDurationMs, which sets
+	// the number of tasks from the arrival rate, then the backlog drains. This bounds runs
+	// at a target utilization of 1 or more.
+	OverloadDurationMs int `yaml:"overload_duration_ms"`
+
+	// Soak runs are bounded by time rather than tasks: tasks arrive at the target
+	// utilization for Duration, a Go duration such as "6h", which sets the number of tasks
+	Duration string `yaml:"duration"`
+
+	// Phases run back to back within one run, each with its own load level and mix.
+	// When set, they define the number of tasks.
+	Phases []Phase `yaml:"phases"`
+
+	// A load staircase: when set, the tasks are split into as many equal consecutive
+	// steps, each arriving at its own utilization instead of TargetUtilization
+	UtilizationSteps []float64 `yaml:"utilization_steps"`
+
+	// With ServiceTimeSCV above 0, task durations are drawn from a distribution with mean
+	// ServiceTimeMeanMs or ServiceTimeMeanUs (the mean of the short/long mix if 0) and that
+	// squared coefficient of variation, instead of being either short or long. Tasks up to
+	// the short task duration still count as short.
+	ServiceTimeMeanMs int     `yaml:"service_time_mean_ms"`
+	ServiceTimeSCV    float64 `yaml:"service_time_scv"`
+
+	// How tasks do their work: "sleep" waits for the task duration, "cpu" spins through
+	// CPU work calibrated to take the task duration on an idle core, "io" runs Postgres
+	// queries calibrated to take the task duration on an idle database
+	WorkMode string `yaml:"work_mode"`
+
+	// Per-class work profiles. Tasks of a class with a profile do the profile's work
+	// instead of following WorkMode, so classes can have different bottlenecks.
+	WorkProfiles WorkProfiles `yaml:"work_profiles"`
+
+	// Share of tasks whose work holds a lock shared by all tasks, a Postgres advisory lock
+	// in real runs. Tasks wait for the lock in their worker slot, so a short task can be
+	// stuck behind a long one whatever its priority.
+	LockProbability float64 `yaml:"lock_probability"`
+
+	// Every task carries a payload of this many bytes (0 for none), serialized with the
+	// task on enqueue and dequeue. "bytes" payloads are random, "json" ones a JSON document.
+	PayloadBytes  int    `yaml:"payload_bytes"`
+	PayloadFormat string `yaml:"payload_format"`
+
+	// Each attempt of a task's work fails with FailureProbability, and succeeds on retry
+	// with the same chance. A PermanentFailureProbability share of tasks fail every attempt.
+	FailureProbability          float64 `yaml:"failure_probability"`
+	PermanentFailureProbability float64 `yaml:"permanent_failure_probability"`
+
+	// A CancelProbability share of tasks are cancelled by their client, which gives up
+	// after an exponentially distributed delay from arrival with mean CancelDelayMs.
+	// Cancellation only takes effect if the task hasn't completed by then.
+	CancelProbability float64 `yaml:"cancel_probability"`
+	CancelDelayMs     int     `yaml:"cancel_delay_ms"`
+
+	// Clients abandon tasks that haven't started within PatienceMs of their arrival, 0
+	// for clients that wait as long as it takes. Abandoned tasks never run.
+	PatienceMs int `yaml:"patience_ms"`
+
+	// Seed of the workload generator, so runs can replay the same workload; 0 draws a new
+	// seed for every run
+	Seed int `yaml:"seed"`
+
+	// With BurstSize above 1, tasks arrive in bursts of that many at once, spaced so the
+	// mean arrival rate still loads the queue to the target utilization
+	BurstSize int `yaml:"burst_size"`
+
+	// Replay the tasks of a trace, a CSV file like the schedule of a dry run, instead of
+	// generating them: its arrival offsets, durations, duplicates, tenants, jobs and
+	// deadlines make the workload, and set the number of tasks
+	TraceFile string      `yaml:"trace_file"`
+	trace     []TraceTask // Loaded by ResolveTrace
+
+	// Task durations in microseconds, for high-resolution runs of very short tasks. Each
+	// one set takes the place of its milliseconds counterpart.
+	ShortTaskDurationUs int `yaml:"short_task_duration_us"`
+	LongTaskDurationUs  int `yaml:"long_task_duration_us"`
+	ServiceTimeMeanUs   int `yaml:"service_time_mean_us"`
+}
+
+// HighResolution reports whether task durations are set in microseconds
+func (c *Config) HighResolution() bool {
+	return c.ShortTaskDurationUs > 0 || c.LongTaskDurationUs > 0 || c.ServiceTimeMeanUs > 0
+}
+
+// configuredDuration returns a duration set in microseconds if us isn't 0, and in
+// milliseconds otherwise
+func configuredDuration(ms, us int) time.Duration {
+	if us > 0 {
+		return time.Duration(us) * time.Microsecond
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (c *Config) ShortTaskDuration() time.Duration {
+	return configuredDuration(c.ShortTaskDurationMs, c.ShortTaskDurationUs)
+}
+
+// RunSeed returns the seed of the workload of a run starting at now: the configured seed,
+// or one drawn from the start time
+func (c *Config) RunSeed(now time.Time) int64 {
+	if c.Seed != 0 {
+		return int64(c.Seed)
+	}
+	return now.UnixNano()
+}
+
+func (c *Config) CancelDelay() time.Duration {
+	return time.Duration(c.CancelDelayMs) * time.Millisecond
+}
+
+func (c *Config) Patience() time.Duration {
+	return time.Duration(c.PatienceMs) * time.Millisecond
+}
+
+func (c *Config) LongTaskDuration() time.Duration {
+	return configuredDuration(c.LongTaskDurationMs, c.LongTaskDurationUs)
+}
+
+// ServiceTimeMean returns the configured mean of the service time distribution, 0 for the
+// mean of the short/long mix
+func (c *Config) ServiceTimeMean() time.Duration {
+	return configuredDuration(c.ServiceTimeMeanMs, c.ServiceTimeMeanUs)
+}
+
+// MeanTaskDuration returns the mean duration of the tasks of the workload
+func (c *Config) MeanTaskDuration() time.Duration {
+	if c.ServiceTimeSCV > 0 && c.ServiceTimeMean() > 0 {
+		return c.ServiceTimeMean()
+	}
+	return time.Duration(float64(c.ShortTaskDuration())*c.ShortTaskProbability +
+		float64(c.LongTaskDuration())*(1-c.ShortTaskProbability))
+}
+
+// JobSize returns the number of tasks of a job: the fan-out of fork-join requests, or
+// TasksPerJob. It is 1 for independent tasks.
+func (c *Config) JobSize() int {
+	if c.FanOut > 1 {
+		return c.FanOut
+	}
+	return max(1, c.TasksPerJob)
+}
+
+// OverloadDuration returns how long tasks arrive for in an overload run, 0 otherwise
+func (c *Config) OverloadDuration() time.Duration {
+	return time.Duration(c.OverloadDurationMs) * time.Millisecond
+}
+
+// RunDuration returns how long tasks arrive for in a soak run, 0 otherwise
+func (c *Config) RunDuration() time.Duration {
+	d, _ := time.ParseDuration(c.Duration)
+	return d
+}
+
+// OverloadTasks returns the number of tasks arriving during the overload duration at the
+// given inter-arrival time
+func (c *Config) OverloadTasks(interArrival time.Duration) int {
+	return max(1, int(c.OverloadDuration()/interArrival))
+}
+
+// TasksPerStep returns how many tasks each step of the load staircase holds. The last
+// step may hold fewer.
+func (c *Config) TasksPerStep() int {
+	if len(c.UtilizationSteps) == 0 {
+		return c.NumTasks
+	}
+	return (c.NumTasks + len(c.UtilizationSteps) - 1) / len(c.UtilizationSteps)
+}
+
+// Step returns the step of the load staircase the task with the given ID belongs to
+func (c *Config) Step(taskID int) int {
+	return taskID / c.TasksPerStep()
+}
+
+// IsShort reports whether tasks of the given duration belong to the short class
+func (c *Config) IsShort(duration time.Duration) bool {
+	return duration <= c.ShortTaskDuration()
+}
+
+// WorkProfiles holds the work profile of each task class
+type WorkProfiles struct {
+	Short WorkProfile `yaml:"short"`
+	Long  WorkProfile `yaml:"long"`
+}
+
+// WorkProfile describes the work of a task as a mix of resources, done in order: CPU
+// work calibrated to take CPUMs on an idle core, Queries Postgres queries and a SleepMs
+// wait, e.g. for an external call
+type WorkProfile struct {
+	CPUMs   int `yaml:"cpu_ms"`
+	Queries int `yaml:"queries"`
+	SleepMs int `yaml:"sleep_ms"`
+}
+
+// IsSet reports whether the profile describes any work
+func (p WorkProfile) IsSet() bool {
+	return p.CPUMs > 0 || p.Queries > 0 || p.SleepMs > 0
+}
+
+func (p WorkProfile) CPU() time.Duration {
+	return time.Duration(p.CPUMs) * time.Millisecond
+}
+
+func (p WorkProfile) Sleep() time.Duration {
+	return time.Duration(p.SleepMs) * time.Millisecond
+}
+
+// WorkProfile returns the work profile of tasks of the given duration, and whether their
+// class has one
+func (c *Config) WorkProfile(duration time.Duration) (WorkProfile, bool) {
+	profile := c.WorkProfiles.Long
+	if c.IsShort(duration) {
+		profile = c.WorkProfiles.Short
+	}
+	return profile, profile.IsSet()
+}
+
+// UsesCPU reports whether tasks may do CPU work, through the work mode or a profile
+func (c *Config) UsesCPU() bool {
+	return c.WorkMode == "cpu" || c.WorkProfiles.Short.CPUMs > 0 || c.WorkProfiles.Long.CPUMs > 0
+}
+
+// UsesQueries reports whether tasks may query Postgres, through the work mode or a profile
+func (c *Config) UsesQueries() bool {
+	return c.WorkMode == "io" || c.WorkProfiles.Short.Queries > 0 || c.WorkProfiles.Long.Queries > 0
+}
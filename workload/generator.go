@@ -0,0 +1,307 @@
+// Package workload generates the synthetic workloads of scheduling experiments: a stream
+// of short and long tasks, or of tasks with a tunable duration variability, arriving at a
+// rate that loads the queue to a target utilization, optionally with duplicates, tenants,
+// jobs, fork-join requests and deadlines.
+package workload
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Shape returns the average task duration of the workload and the inter-arrival
+// time that spreads its target utilization over the given number of task slots
+func Shape(cfg Config, capacity int) (avgTaskDuration, interArrivalTime time.Duration) {
+	avgTaskDuration = cfg.MeanTaskDuration()
+	if cfg.FanOut > 1 {
+		// Fork-join tasks each do their share of a request's work
+		avgTaskDuration /= time.Duration(cfg.FanOut)
+	}
+	interArrivalTime = time.Duration(float64(avgTaskDuration) / (cfg.TargetUtilization * float64(capacity)))
+	return avgTaskDuration, interArrivalTime
+}
+
+// maxTransientFailures bounds the failures drawn for one task, so a failure probability
+// close to 1 still terminates
+const maxTransientFailures = 100
+
+// Generator draws the tasks of a workload one at a time, in arrival order. Real runs,
+// dry runs and simulations share the generators, so a dry run shows exactly what a run
+// would enqueue, and policies only ever see the tasks, never how they arrive.
+type Generator interface {
+	// Next returns the next task and when it is due, as an offset from the start of the
+	// run. The task's arrival time and deadline are set by Arrive once it actually arrives.
+	Next() (Task, time.Duration)
+	// Arrive stamps the task with its arrival time, and the deadline that follows from it
+	Arrive(task *Task, at time.Time)
+}
+
+// NewGenerator returns the generator of the configured workload: the tasks of the
+// trace if there is one, else synthetic tasks with a short/long mix or a distribution of
+// durations, grouped into bursts if the workload is bursty. The same seed always
+// generates the same workload. A trace must be resolved first.
+func NewGenerator(cfg Config, interArrival time.Duration, seed int64) Generator {
+	if cfg.TraceFile != "" {
+		return NewTraceGenerator(cfg, seed)
+	}
+	var generator Generator
+	if cfg.ServiceTimeSCV > 0 {
+		generator = NewDistributional(cfg, interArrival, seed)
+	} else {
+		generator = NewBimodal(cfg, interArrival, seed)
+	}
+	if cfg.BurstSize > 1 {
+		generator = NewBursty(generator, cfg.BurstSize)
+	}
+	return generator
+}
+
+// Synthetic draws the tasks of a synthetic workload, spread evenly at the target
+// utilization, over its phases or the steps of its load staircase
+type Synthetic struct {
+	cfg          Config
+	interArrival time.Duration
+	rng          *rand.Rand
+	serviceTime  *ServiceTime // Set when durations follow a C² distribution
+	next         int
+	previous     Task
+	share        time.Duration // Duration of the tasks of the current fork-join request
+	tenantWeight []float64     // Cumulative share of the tasks of each tenant, with a tenant skew
+
+	// With phases, the first task of each phase, when it is due, and the inter-arrival
+	// time within the phase
+	phaseStart        []int
+	phaseOffset       []time.Duration
+	phaseInterArrival []time.Duration
+}
+
+// NewBimodal creates a generator of short and long tasks, spacing arrivals by
+// interArrival
+func NewBimodal(cfg Config, interArrival time.Duration, seed int64) *Synthetic {
+	return newSynthetic(cfg, interArrival, seed, nil)
+}
+
+// NewDistributional creates a generator of tasks whose durations follow a distribution
+// with the workload's mean and squared coefficient of variation, spacing arrivals by
+// interArrival
+func NewDistributional(cfg Config, interArrival time.Duration, seed int64) *Synthetic {
+	serviceTime := NewServiceTime(cfg.MeanTaskDuration(), cfg.ServiceTimeSCV)
+	return newSynthetic(cfg, interArrival, seed, &serviceTime)
+}
+
+func newSynthetic(cfg Config, interArrival time.Duration, seed int64, serviceTime *ServiceTime) *Synthetic {
+	g := &Synthetic{cfg: cfg, interArrival: interArrival, rng: rand.New(rand.NewSource(seed)), serviceTime: serviceTime}
+	if cfg.NumTenants > 0 && cfg.TenantSkew > 0 {
+		// Tenant i submits a share of the tasks proportional to 1/(i+1)^skew
+		var total float64
+		for i := range cfg.NumTenants {
+			total += math.Pow(float64(i+1), -cfg.TenantSkew)
+			g.tenantWeight = append(g.tenantWeight, total)
+		}
+		for i := range g.tenantWeight {
+			g.tenantWeight[i] /= total
+		}
+	}
+	g.cfg.ResolvePhases(interArrival)
+	start, offset := 0, time.Duration(0)
+	for _, phase := range g.cfg.Phases {
+		phaseInterArrival := g.cfg.PhaseInterArrival(phase, interArrival)
+		g.phaseStart = append(g.phaseStart, start)
+		g.phaseOffset = append(g.phaseOffset, offset)
+		g.phaseInterArrival = append(g.phaseInterArrival, phaseInterArrival)
+		start += phase.Tasks
+		offset += time.Duration(phase.Tasks) * phaseInterArrival
+	}
+	return g
+}
+
+// Next returns the next task and when it is due, as an offset from the start of the run
+func (g *Synthetic) Next() (Task, time.Duration) {
+	cfg := g.cfg
+	i := g.next
+	g.next++
+
+	// Duplicates repeat the previous request, with the same deduplication ID
+	isDuplicate := i > 0 && cfg.DuplicateProbability > 0 && g.rng.Float64() < cfg.DuplicateProbability
+
+	// Pick task duration based on probability. The tasks of a fork-join request share
+	// its work equally.
+	var duration time.Duration
+	if isDuplicate {
+		duration = g.previous.Duration
+	} else if cfg.FanOut > 1 && i%cfg.FanOut > 0 {
+		duration = g.share
+	} else {
+		if g.serviceTime != nil {
+			duration = g.serviceTime.Sample(g.rng)
+		} else if g.rng.Float64() < g.shortProbability(i) {
+			duration = cfg.ShortTaskDuration()
+		} else {
+			duration = cfg.LongTaskDuration()
+		}
+		if cfg.FanOut > 1 {
+			duration /= time.Duration(cfg.FanOut)
+			g.share = duration
+		}
+	}
+
+	task := Task{
+		TaskID:    i,
+		Duration:  duration,
+		Duplicate: isDuplicate,
+	}
+	if size := cfg.JobSize(); size > 1 {
+		task.JobID = JobID(i / size)
+	}
+	if cfg.NumTenants > 0 {
+		task.TenantID = TenantID(g.tenant())
+		if isDuplicate {
+			task.TenantID = g.previous.TenantID
+		}
+	}
+	if cfg.DuplicateProbability > 0 {
+		task.DedupID = fmt.Sprintf("task-%d", i)
+		if isDuplicate {
+			task.DedupID = g.previous.DedupID
+		}
+	}
+	decorate(cfg, g.rng, &task, g.previous)
+	g.previous = task
+	return task, g.Offset(i)
+}
+
+// decorate draws the attributes of a task that don't shape the workload: its failures,
+// whether it needs the lock, when its client cancels it, its patience and its payload. A
+// duplicate keeps the payload of the previous request, which it repeats.
+func decorate(cfg Config, rng *rand.Rand, task *Task, previous Task) {
+	if cfg.FailureProbability > 0 {
+		for task.TransientFailures < maxTransientFailures && rng.Float64() < cfg.FailureProbability {
+			task.TransientFailures++
+		}
+	}
+	if cfg.PermanentFailureProbability > 0 {
+		task.FailsPermanently = rng.Float64() < cfg.PermanentFailureProbability
+	}
+	if cfg.LockProbability > 0 {
+		task.NeedsLock = rng.Float64() < cfg.LockProbability
+	}
+	if cfg.CancelProbability > 0 && rng.Float64() < cfg.CancelProbability {
+		task.CancelAfter = max(time.Duration(rng.ExpFloat64()*float64(cfg.CancelDelay())), time.Millisecond)
+	}
+	task.Patience = cfg.Patience()
+	if cfg.PayloadBytes > 0 {
+		task.Payload = payload(cfg, rng)
+		if task.Duplicate {
+			task.Payload = previous.Payload
+		}
+	}
+}
+
+// tenant draws the tenant of a task
+func (g *Synthetic) tenant() int {
+	if g.tenantWeight == nil {
+		return g.rng.Intn(g.cfg.NumTenants)
+	}
+	u := g.rng.Float64()
+	return min(sort.SearchFloat64s(g.tenantWeight, u), g.cfg.NumTenants-1)
+}
+
+// shortProbability returns the share of short tasks at the task with the given ID
+func (g *Synthetic) shortProbability(taskID int) float64 {
+	if len(g.cfg.Phases) == 0 {
+		return g.cfg.ShortTaskProbability
+	}
+	return g.cfg.ShortProbability(g.cfg.Phases[g.cfg.Phase(taskID)])
+}
+
+// Offset returns when the task with the given ID is due, from the start of the run. The
+// tasks of a job all arrive with the job's first task. interArrival holds for the target
+// utilization, and is scaled for the steps of a load staircase and for each phase.
+func (g *Synthetic) Offset(taskID int) time.Duration {
+	arrivalSlot := taskID - taskID%g.cfg.JobSize()
+	if len(g.cfg.Phases) > 0 {
+		phase := g.cfg.Phase(arrivalSlot)
+		return g.phaseOffset[phase] + time.Duration(arrivalSlot-g.phaseStart[phase])*g.phaseInterArrival[phase]
+	}
+	if len(g.cfg.UtilizationSteps) == 0 {
+		return time.Duration(arrivalSlot) * g.interArrival
+	}
+
+	// Each step of the staircase spaces its arrivals for its own utilization
+	perStep := g.cfg.TasksPerStep()
+	var offset float64
+	for step, utilization := range g.cfg.UtilizationSteps {
+		slots := min(arrivalSlot-step*perStep, perStep)
+		if slots <= 0 {
+			break
+		}
+		offset += float64(slots) * float64(g.interArrival) * g.cfg.TargetUtilization / utilization
+	}
+	return time.Duration(offset)
+}
+
+// Arrive stamps the task with its arrival time, and the deadline that follows from it
+func (g *Synthetic) Arrive(task *Task, at time.Time) {
+	task.ArrivalTime = at
+	if g.cfg.DeadlineFactor > 0 {
+		task.Deadline = at.Add(time.Duration(g.cfg.DeadlineFactor * float64(task.Duration)))
+	}
+}
+
+// Bursty groups the arrivals of another generator into bursts: every task of a burst
+// arrives with its first task, and the bursts keep the other generator's mean arrival
+// rate, so the load comes in waves of Size tasks with idle gaps between them
+type Bursty struct {
+	Generator
+	Size int // Tasks per burst
+
+	next   int
+	offset time.Duration // When the current burst arrives
+}
+
+// NewBursty groups the arrivals of the generator into bursts of size tasks
+func NewBursty(generator Generator, size int) *Bursty {
+	return &Bursty{Generator: generator, Size: size}
+}
+
+// Next returns the next task of the other generator, due with the first task of its
+// burst
+func (b *Bursty) Next() (Task, time.Duration) {
+	task, offset := b.Generator.Next()
+	if b.next%b.Size == 0 {
+		b.offset = offset
+	}
+	b.next++
+	return task, b.offset
+}
+
+// JobID returns the ID of the i-th job
+func JobID(i int) string {
+	return fmt.Sprintf("job-%d", i)
+}
+
+// TenantID returns the ID of the i-th tenant
+func TenantID(i int) string {
+	return fmt.Sprintf("tenant-%d", i)
+}
+
+// payload returns a payload of the configured size and format. JSON payloads are an
+// object with a random hex string, padded to exactly the configured size when possible.
+func payload(cfg Config, rng *rand.Rand) []byte {
+	size := cfg.PayloadBytes
+	payload := make([]byte, size)
+	rng.Read(payload)
+	if cfg.PayloadFormat != "json" {
+		return payload
+	}
+	const prefix, suffix = `{"data":"`, `"}`
+	data := max(size-len(prefix)-len(suffix), 0)
+	hex := "0123456789abcdef"
+	for i := range data {
+		payload[i] = hex[payload[i]%16]
+	}
+	return []byte(prefix + string(payload[:data]) + suffix)
+}
@@ -0,0 +1,98 @@
+package workload
+
+import (
+	"fmt"
+	"time"
+)
+
+// Phase is one phase of a multi-phase workload. Phases run back to back in one run, each
+// with its own load level and mix of short and long tasks, and last either a number of
+// tasks or a time.
+type Phase struct {
+	Name        string  `yaml:"name"`
+	Utilization float64 `yaml:"utilization"` // 0 for the workload's target utilization
+	NumTasks    int     `yaml:"num_tasks"`
+	DurationMs  int     `yaml:"duration_ms"` // Arrivals for this long, instead of NumTasks
+
+	// Share of short tasks, nil for the workload's. Ignored when durations follow a C²
+	// distribution.
+	ShortTaskProbability *float64 `yaml:"short_task_probability"`
+
+	// Tasks of the phase, resolved from NumTasks or DurationMs by ResolvePhases
+	Tasks int `yaml:"-"`
+}
+
+// Duration returns how long tasks arrive for in a phase sized by time, 0 otherwise
+func (p Phase) Duration() time.Duration {
+	return time.Duration(p.DurationMs) * time.Millisecond
+}
+
+// ShortProbability returns the share of short tasks of the phase
+func (c *Config) ShortProbability(phase Phase) float64 {
+	if phase.ShortTaskProbability != nil {
+		return *phase.ShortTaskProbability
+	}
+	return c.ShortTaskProbability
+}
+
+// PhaseUtilization returns the target utilization of the phase
+func (c *Config) PhaseUtilization(phase Phase) float64 {
+	if phase.Utilization > 0 {
+		return phase.Utilization
+	}
+	return c.TargetUtilization
+}
+
+// PhaseInterArrival returns the inter-arrival time of the phase, given the one of the
+// workload at its target utilization: arrivals are spaced for the phase's own
+// utilization and mean task duration
+func (c *Config) PhaseInterArrival(phase Phase, interArrival time.Duration) time.Duration {
+	factor := c.TargetUtilization / c.PhaseUtilization(phase)
+	if c.ServiceTimeSCV == 0 {
+		p := c.ShortProbability(phase)
+		mean := float64(c.ShortTaskDuration())*p + float64(c.LongTaskDuration())*(1-p)
+		if base := float64(c.MeanTaskDuration()); base > 0 {
+			factor *= mean / base
+		}
+	}
+	return time.Duration(float64(interArrival) * factor)
+}
+
+// ResolvePhases sizes every phase at the given inter-arrival time of the workload, and
+// sets the number of tasks to their total. Phases sized by time hold the arrivals that
+// fit in it. The phases are copied, so other copies of the config keep theirs.
+func (c *Config) ResolvePhases(interArrival time.Duration) {
+	if len(c.Phases) == 0 {
+		return
+	}
+	c.Phases = append([]Phase(nil), c.Phases...)
+	c.NumTasks = 0
+	for i := range c.Phases {
+		phase := &c.Phases[i]
+		phase.Tasks = phase.NumTasks
+		if phase.DurationMs > 0 {
+			phase.Tasks = max(1, int(phase.Duration()/c.PhaseInterArrival(*phase, interArrival)))
+		}
+		c.NumTasks += phase.Tasks
+	}
+}
+
+// Phase returns the index of the phase the task with the given ID belongs to, once the
+// phases are resolved
+func (c *Config) Phase(taskID int) int {
+	for i, phase := range c.Phases {
+		if taskID < phase.Tasks {
+			return i
+		}
+		taskID -= phase.Tasks
+	}
+	return len(c.Phases) - 1
+}
+
+// PhaseName returns the name of a phase, or its number if it has none
+func (c *Config) PhaseName(i int) string {
+	if name := c.Phases[i].Name; name != "" {
+		return name
+	}
+	return fmt.Sprintf("phase %d", i+1)
+}
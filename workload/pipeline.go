@@ -0,0 +1,30 @@
+package workload
+
+import "time"
+
+// Stage is one stage of a pipeline (tandem queue) run: tasks flow through every stage in
+// order, each with its own queue and worker slots, and spend DurationFactor times their
+// duration in each
+type Stage struct {
+	DurationFactor float64
+	Capacity       int // Worker slots of the stage
+}
+
+// Duration returns how long a task of the given duration runs in the stage
+func (s Stage) Duration(duration time.Duration) time.Duration {
+	return time.Duration(float64(duration) * s.DurationFactor)
+}
+
+// BottleneckFactor returns how much slower than a single queue of the given capacity the
+// slowest stage of a pipeline serves tasks. Stretching inter-arrival times by it holds
+// the bottleneck stage at the target utilization. It is 1 without stages.
+func BottleneckFactor(stages []Stage, capacity int) float64 {
+	factor := 0.0
+	for _, stage := range stages {
+		factor = max(factor, stage.DurationFactor*float64(capacity)/float64(stage.Capacity))
+	}
+	if factor == 0 {
+		return 1
+	}
+	return factor
+}
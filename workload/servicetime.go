@@ -0,0 +1,85 @@
+package workload
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// minServiceTimeSCV is the lowest supported squared coefficient of variation. Lower
+// values need Erlang distributions with more phases than is practical to sample.
+const minServiceTimeSCV = 0.01
+
+// ServiceTime draws task durations with a given mean and squared coefficient of
+// variation (C² = variance / mean²), by fitting the classic two-moment distributions:
+//   - C² = 1: exponential
+//   - C² < 1: a mixture of Erlang(k-1) and Erlang(k) with a common rate, where
+//     1/k <= C² <= 1/(k-1)
+//   - C² > 1: a two-phase hyperexponential with balanced means
+//
+// Changing C² changes the variability of task durations while their mean stays put.
+type ServiceTime struct {
+	mean float64 // Mean duration, in nanoseconds
+	scv  float64
+
+	// Erlang mixture (C² < 1): k-1 phases with probability p, else k phases, at rate
+	k    int
+	p    float64
+	rate float64
+
+	// Hyperexponential (C² > 1): rate1 with probability p, else rate2
+	rate1, rate2 float64
+}
+
+// NewServiceTime returns the distribution with the given mean and C²
+func NewServiceTime(mean time.Duration, scv float64) ServiceTime {
+	s := ServiceTime{mean: float64(mean), scv: math.Max(scv, minServiceTimeSCV)}
+	switch {
+	case s.scv < 1:
+		s.k = int(math.Ceil(1 / s.scv))
+		k := float64(s.k)
+		s.p = (k*s.scv - math.Sqrt(k*(1+s.scv)-k*k*s.scv)) / (1 + s.scv)
+		s.rate = (k - s.p) / s.mean
+	case s.scv > 1:
+		s.p = 0.5 * (1 + math.Sqrt((s.scv-1)/(s.scv+1)))
+		s.rate1 = 2 * s.p / s.mean
+		s.rate2 = 2 * (1 - s.p) / s.mean
+	}
+	return s
+}
+
+// Sample draws one task duration
+func (s ServiceTime) Sample(rng *rand.Rand) time.Duration {
+	var ns float64
+	switch {
+	case s.scv < 1:
+		phases := s.k
+		if rng.Float64() < s.p {
+			phases--
+		}
+		for range phases {
+			ns += rng.ExpFloat64() / s.rate
+		}
+	case s.scv > 1:
+		rate := s.rate2
+		if rng.Float64() < s.p {
+			rate = s.rate1
+		}
+		ns = rng.ExpFloat64() / rate
+	default:
+		ns = rng.ExpFloat64() * s.mean
+	}
+	return time.Duration(ns)
+}
+
+// Describe names the fitted distribution, e.g. "hyperexponential" for C² > 1
+func (s ServiceTime) Describe() string {
+	switch {
+	case s.scv < 1:
+		return "Erlang mixture"
+	case s.scv > 1:
+		return "hyperexponential"
+	default:
+		return "exponential"
+	}
+}
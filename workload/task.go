@@ -0,0 +1,199 @@
+package workload
+
+import "time"
+
+// Task is one request of the workload. The producer fills in its arrival time; the queue
+// fills in when it was dequeued and completed.
+type Task struct {
+	TaskID         int
+	Duration       time.Duration
+	ArrivalTime    time.Time
+	DequeueTime    time.Time
+	CompletionTime time.Time
+	DedupID        string    // Deduplication ID, empty when deduplication is disabled
+	Duplicate      bool      // Whether the task repeats an earlier request
+	TenantID       string    // Tenant that submitted the task, empty without tenants
+	JobID          string    // Job the task belongs to, empty for independent tasks
+	Deadline       time.Time // Completion deadline, zero when the workload has no deadlines
+	Priority       uint      // Queue priority an external client asked for, 0 to let the policy decide
+	Starved        bool      // Set by the post-run starvation analysis
+	Boosted        bool      // Whether the anti-starvation watchdog boosted the task
+	Payload        []byte    // Opaque request data, empty without payloads
+
+	// Failures the task's work runs into, drawn by the generator: attempts that fail
+	// before one succeeds, or failure on every attempt
+	TransientFailures int
+	FailsPermanently  bool
+
+	// Outcome of the task's attempts: how many ran, whether the last one failed too, and
+	// the time from the start of the first attempt to the start of the last one
+	Attempts   int
+	Failed     bool
+	RetryDelay time.Duration
+
+	// Whether the failed task was routed to the dead-letter queue
+	DeadLettered bool
+
+	// How long after arrival the client cancels the task, 0 if it never does, and
+	// whether the cancellation took effect: the task hadn't completed by then. Tasks
+	// cancelled while waiting never ran; tasks cancelled while running still finished
+	// the work they had started.
+	CancelAfter time.Duration
+	Cancelled   bool
+
+	// How long the client waits for the task to start before abandoning it, 0 if it
+	// waits as long as it takes, and whether it abandoned the task, which never ran
+	Patience  time.Duration
+	Abandoned bool
+
+	// Attempt of the client's request the task is, 0 for the first one, the task ID of
+	// the first attempt for retries, and whether the client timed out on the task and
+	// sent another attempt
+	ClientAttempt int
+	RequestID     int
+	TimedOut      bool
+
+	// Whether the task's work holds the shared lock, and how long it waited for it after
+	// being dequeued
+	NeedsLock bool
+	LockWait  time.Duration
+
+	// Time the producer held the request because of backpressure before enqueueing it
+	BackpressureDelay time.Duration
+
+	// Time the tenant's rate limit held the request before enqueueing it
+	ThrottleDelay time.Duration
+
+	// When the workload scheduled the task to arrive, and how late it actually arrived.
+	// Zero when unknown, as in the simulation.
+	ScheduledArrival time.Time
+	ArrivalDrift     time.Duration
+
+	// Cold-start setup the worker paid before running the task, because it last ran
+	// another class of tasks or had gone cold idling. It is part of the task's service.
+	Setup time.Duration
+
+	// When the queue recorded the task, and when an executor claimed it for a worker
+	// slot. Zero when unknown; they split the wait into its components.
+	EnqueuedAt time.Time
+	StartedAt  time.Time
+
+	// Executor that ran the task, empty when unknown, as in the simulation
+	ExecutorID string
+
+	// How long the steps of the task's workflow took as DBOS recorded them, to the
+	// millisecond: the step stamping its dequeue time, its work step with every retry,
+	// and the step stamping its completion, then the time of the workflow between its
+	// first and last step spent outside any step (checkpoints, cold-start setup). Zero
+	// when unknown, as in the simulation. In pipelines, they are those of the last stage.
+	DequeueStep    time.Duration
+	WorkStep       time.Duration
+	CompletionStep time.Duration
+	StepGaps       time.Duration
+
+	// In pipeline runs, the stage the task is in and the timing of the stages it went
+	// through. The task's dequeue time is that of its first stage, its completion time
+	// that of its last.
+	Stage  int
+	Stages []StageTiming
+}
+
+// StageTiming is when a task reached, started and left one stage of a pipeline
+type StageTiming struct {
+	EnqueuedAt     time.Time
+	DequeueTime    time.Time
+	CompletionTime time.Time
+}
+
+// WaitTime returns how long the task waited for a worker slot of the stage
+func (s StageTiming) WaitTime() time.Duration {
+	return s.DequeueTime.Sub(s.EnqueuedAt)
+}
+
+// SojournTime returns how long the task spent in the stage, waiting and running
+func (s StageTiming) SojournTime() time.Duration {
+	return s.CompletionTime.Sub(s.EnqueuedAt)
+}
+
+// WaitTime returns how long the task waited in the queue before it was dequeued
+func (t Task) WaitTime() time.Duration {
+	return t.DequeueTime.Sub(t.ArrivalTime)
+}
+
+// ResponseTime returns how long the task took from arrival to completion
+func (t Task) ResponseTime() time.Duration {
+	return t.CompletionTime.Sub(t.ArrivalTime)
+}
+
+// Lateness returns how late the task finished relative to its deadline
+func (t Task) Lateness() time.Duration {
+	return t.CompletionTime.Sub(t.Deadline)
+}
+
+// Ran reports whether the task did its work: every task except those abandoned or
+// cancelled before they were dequeued
+func (t Task) Ran() bool {
+	return !t.Abandoned && (!t.Cancelled || !t.DequeueTime.IsZero())
+}
+
+// Request returns the ID of the client request the task is an attempt of: the task ID of
+// its first attempt
+func (t Task) Request() int {
+	if t.ClientAttempt == 0 {
+		return t.TaskID
+	}
+	return t.RequestID
+}
+
+// Completed reports whether the task returned its result to its client: every task
+// except those cancelled or abandoned
+func (t Task) Completed() bool {
+	return !t.Cancelled && !t.Abandoned
+}
+
+// FailureReason returns why a failed task failed: a permanent failure, or more transient
+// failures than its retries could absorb. It is empty for tasks that didn't fail.
+func (t Task) FailureReason() string {
+	switch {
+	case !t.Failed:
+		return ""
+	case t.FailsPermanently:
+		return "permanent failure"
+	default:
+		return "retries exhausted"
+	}
+}
+
+// EnqueueDelay returns how long the task took to become eligible for dequeueing after it
+// arrived: the enqueue round trip, plus any backpressure hold
+func (t Task) EnqueueDelay() time.Duration {
+	return t.enqueued().Sub(t.ArrivalTime)
+}
+
+// QueueingDelay returns how long the eligible task waited for a worker slot, until an
+// executor claimed it
+func (t Task) QueueingDelay() time.Duration {
+	return t.started().Sub(t.enqueued())
+}
+
+// StartupDelay returns how long the claimed task took to start running: dispatching
+// its workflow and instantiating it on the executor
+func (t Task) StartupDelay() time.Duration {
+	return t.DequeueTime.Sub(t.started())
+}
+
+// enqueued returns when the task was enqueued, its arrival if unknown
+func (t Task) enqueued() time.Time {
+	if t.EnqueuedAt.IsZero() {
+		return t.ArrivalTime
+	}
+	return t.EnqueuedAt
+}
+
+// started returns when the task was claimed, its dequeue if unknown
+func (t Task) started() time.Time {
+	if t.StartedAt.IsZero() {
+		return t.DequeueTime
+	}
+	return t.StartedAt
+}
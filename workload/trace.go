@@ -0,0 +1,175 @@
+package workload
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// TraceHeader lists the columns of a workload trace, as dry runs write their schedule.
+// Offsets are relative to the start of the run.
+var TraceHeader = []string{"task_id", "arrival_offset_ms", "duration_ms", "duplicate", "tenant_id", "job_id", "deadline_offset_ms"}
+
+// TraceTask is a task of a trace
+type TraceTask struct {
+	Offset         time.Duration // When the task arrives, from the start of the run
+	Duration       time.Duration
+	Duplicate      bool // Repeats the previous request
+	TenantID       string
+	JobID          string
+	DeadlineOffset time.Duration // How long after its arrival the task is due, 0 for no deadline
+}
+
+// ReadTrace loads a trace: a CSV file with the columns of TraceHeader, matched by name,
+// in arrival order. Only the arrival offset and duration columns are required.
+func ReadTrace(filename string) ([]TraceTask, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header of trace %s: %w", filename, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"arrival_offset_ms", "duration_ms"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s is not a trace: missing %s column", filename, name)
+		}
+	}
+
+	var tasks []TraceTask
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trace %s: %w", filename, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		ms := func(name string) (time.Duration, error) {
+			value := field(name)
+			if value == "" {
+				return 0, nil
+			}
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("%s:%d: invalid %s %q", filename, line, name, value)
+			}
+			return time.Duration(f * float64(time.Millisecond)), nil
+		}
+
+		var task TraceTask
+		if task.Offset, err = ms("arrival_offset_ms"); err != nil {
+			return nil, err
+		}
+		if task.Duration, err = ms("duration_ms"); err != nil {
+			return nil, err
+		}
+		if task.DeadlineOffset, err = ms("deadline_offset_ms"); err != nil {
+			return nil, err
+		}
+		if value := field("duplicate"); value != "" {
+			if task.Duplicate, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid duplicate %q", filename, line, value)
+			}
+		}
+		task.TenantID, task.JobID = field("tenant_id"), field("job_id")
+		if task.Duration <= 0 {
+			return nil, fmt.Errorf("%s:%d: duration_ms must be positive", filename, line)
+		}
+		if n := len(tasks); n > 0 && task.Offset < tasks[n-1].Offset {
+			return nil, fmt.Errorf("%s:%d: tasks must be in arrival order", filename, line)
+		}
+		if task.Duplicate && len(tasks) == 0 {
+			return nil, fmt.Errorf("%s:%d: the first task can't be a duplicate", filename, line)
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("trace %s has no tasks", filename)
+	}
+	return tasks, nil
+}
+
+// ResolveTrace loads the trace of the workload, if it has one, and sets the number of
+// tasks to its length. The trace is loaded once; later calls keep it.
+func (c *Config) ResolveTrace() error {
+	if c.TraceFile == "" || c.trace != nil {
+		return nil
+	}
+	trace, err := ReadTrace(c.TraceFile)
+	if err != nil {
+		return err
+	}
+	c.trace = trace
+	c.NumTasks = len(trace)
+	return nil
+}
+
+// TraceGenerator replays the tasks of a trace, at their offsets. The trace sets what
+// shapes the workload: arrivals, durations, duplicates, tenants, jobs and deadlines. The
+// other attributes of the tasks, such as failures and payloads, are drawn as configured.
+type TraceGenerator struct {
+	cfg      Config
+	rng      *rand.Rand
+	dedup    bool // Whether the trace has duplicates, which tasks need deduplication IDs for
+	next     int
+	previous Task
+}
+
+// NewTraceGenerator creates a generator replaying the resolved trace of the workload
+func NewTraceGenerator(cfg Config, seed int64) *TraceGenerator {
+	g := &TraceGenerator{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+	for _, entry := range cfg.trace {
+		g.dedup = g.dedup || entry.Duplicate
+	}
+	return g
+}
+
+// Next returns the next task of the trace and its offset
+func (g *TraceGenerator) Next() (Task, time.Duration) {
+	i := g.next
+	g.next++
+	entry := g.cfg.trace[i]
+	task := Task{
+		TaskID:    i,
+		Duration:  entry.Duration,
+		Duplicate: entry.Duplicate,
+		TenantID:  entry.TenantID,
+		JobID:     entry.JobID,
+	}
+	if g.dedup {
+		task.DedupID = fmt.Sprintf("task-%d", i)
+		if task.Duplicate {
+			task.DedupID = g.previous.DedupID
+		}
+	}
+	decorate(g.cfg, g.rng, &task, g.previous)
+	g.previous = task
+	return task, entry.Offset
+}
+
+// Arrive stamps the task with its arrival time, and its deadline in the trace
+func (g *TraceGenerator) Arrive(task *Task, at time.Time) {
+	task.ArrivalTime = at
+	if deadline := g.cfg.trace[task.TaskID].DeadlineOffset; deadline > 0 {
+		task.Deadline = at.Add(deadline)
+	}
+}